@@ -0,0 +1,362 @@
+package dict
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+)
+
+// dict 是拉链法实现的哈希表，与 redis 的 dict 相同，扩容、缩容时使用两张表渐进式 rehash：
+// 每次写操作只迁移一个桶，避免一次性迁移大量元素造成长时间的停顿。
+// 读操作不会进行 rehash，因此没有任何副作用。
+
+const (
+	initSize = 4
+	// 每次 rehash 最多访问的空桶数量
+	emptyVisits = 10
+)
+
+type entry struct {
+	key  string
+	val  interface{}
+	next *entry
+}
+
+type table struct {
+	buckets []*entry
+	mask    uint64
+	used    int
+}
+
+func newTable(size uint64) *table {
+	return &table{
+		buckets: make([]*entry, size),
+		mask:    size - 1,
+	}
+}
+
+type Dict struct {
+	tables [2]*table
+	// rehashIdx 是下一个要迁移的桶，-1 表示没有在 rehash
+	rehashIdx int64
+	seed      maphash.Seed
+}
+
+func CreateDict() *Dict {
+	return &Dict{
+		tables:    [2]*table{newTable(initSize)},
+		rehashIdx: -1,
+		seed:      maphash.MakeSeed(),
+	}
+}
+
+func (d *Dict) hash(key string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(d.seed)
+	_, _ = h.WriteString(key)
+	return h.Sum64()
+}
+
+func (d *Dict) isRehashing() bool {
+	return d.rehashIdx != -1
+}
+
+// Len returns the number of elements in d.
+func (d *Dict) Len() int {
+	length := d.tables[0].used
+	if d.tables[1] != nil {
+		length += d.tables[1].used
+	}
+	return length
+}
+
+// BucketLen returns the number of buckets of both tables.
+func (d *Dict) BucketLen() int {
+	n := len(d.tables[0].buckets)
+	if d.tables[1] != nil {
+		n += len(d.tables[1].buckets)
+	}
+	return n
+}
+
+// Get gets the value of key.
+func (d *Dict) Get(key string) (interface{}, bool) {
+	if e := d.find(key); e != nil {
+		return e.val, true
+	}
+	return nil, false
+}
+
+func (d *Dict) find(key string) *entry {
+	h := d.hash(key)
+	for i := 0; i < 2; i++ {
+		t := d.tables[i]
+		if t == nil {
+			break
+		}
+		for e := t.buckets[h&t.mask]; e != nil; e = e.next {
+			if e.key == key {
+				return e
+			}
+		}
+		if !d.isRehashing() {
+			break
+		}
+	}
+	return nil
+}
+
+// Set sets the value of key, true is returned when key is newly added.
+func (d *Dict) Set(key string, val interface{}) bool {
+	d.rehashStep()
+
+	if e := d.find(key); e != nil {
+		e.val = val
+		return false
+	}
+
+	d.add(key, val)
+	return true
+}
+
+// Add adds key only when it doesn't exist, true is returned when key is added.
+func (d *Dict) Add(key string, val interface{}) bool {
+	d.rehashStep()
+
+	if d.find(key) != nil {
+		return false
+	}
+
+	d.add(key, val)
+	return true
+}
+
+func (d *Dict) add(key string, val interface{}) {
+	d.expandIfNeeded()
+
+	// rehash 期间新元素只插入到新表中
+	t := d.tables[0]
+	if d.isRehashing() {
+		t = d.tables[1]
+	}
+
+	index := d.hash(key) & t.mask
+	t.buckets[index] = &entry{
+		key:  key,
+		val:  val,
+		next: t.buckets[index],
+	}
+	t.used++
+}
+
+// Delete deletes key, the deleted value is returned.
+func (d *Dict) Delete(key string) (interface{}, bool) {
+	d.rehashStep()
+
+	h := d.hash(key)
+	for i := 0; i < 2; i++ {
+		t := d.tables[i]
+		if t == nil {
+			break
+		}
+		index := h & t.mask
+		var prev *entry
+		for e := t.buckets[index]; e != nil; prev, e = e, e.next {
+			if e.key != key {
+				continue
+			}
+
+			if prev == nil {
+				t.buckets[index] = e.next
+			} else {
+				prev.next = e.next
+			}
+			t.used--
+			d.shrinkIfNeeded()
+			return e.val, true
+		}
+		if !d.isRehashing() {
+			break
+		}
+	}
+
+	return nil, false
+}
+
+// ForEach calls fn for every element in d until fn returns false, d must not be modified in fn.
+func (d *Dict) ForEach(fn func(key string, val interface{}) bool) {
+	for i := 0; i < 2; i++ {
+		t := d.tables[i]
+		if t == nil {
+			break
+		}
+		for _, e := range t.buckets {
+			for ; e != nil; e = e.next {
+				if !fn(e.key, e.val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Scan visits elements of the bucket pointed by cursor and returns the cursor of the next call, 0 is
+// returned when all elements have been visited. Like redis dictScan, the cursor is increased from the
+// highest bit, so elements that exist during the whole scan are visited at least once even if d is
+// resized between calls, but some elements may be visited more than once.
+func (d *Dict) Scan(cursor uint64, fn func(key string, val interface{})) uint64 {
+	if d.Len() == 0 {
+		return 0
+	}
+
+	visit := func(t *table, index uint64) {
+		for e := t.buckets[index]; e != nil; e = e.next {
+			fn(e.key, e.val)
+		}
+	}
+
+	if !d.isRehashing() {
+		t := d.tables[0]
+		visit(t, cursor&t.mask)
+		return nextCursor(cursor, t.mask)
+	}
+
+	// rehash 期间先访问小表的桶，再访问大表中所有由该桶扩展出的桶
+	small, large := d.tables[0], d.tables[1]
+	if len(small.buckets) > len(large.buckets) {
+		small, large = large, small
+	}
+
+	visit(small, cursor&small.mask)
+	for {
+		visit(large, cursor&large.mask)
+		cursor = nextCursor(cursor, large.mask)
+		if cursor&(small.mask^large.mask) == 0 {
+			break
+		}
+	}
+
+	return cursor
+}
+
+// nextCursor 将 cursor 中 mask 之外的位置为 1 后反转加一，即从高位开始递增
+func nextCursor(cursor, mask uint64) uint64 {
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// RandomEntry returns a random element of d, false is returned when d is empty.
+func (d *Dict) RandomEntry() (string, interface{}, bool) {
+	if d.Len() == 0 {
+		return "", nil, false
+	}
+
+	var head *entry
+	if d.isRehashing() {
+		// 旧表中 rehashIdx 之前的桶已经为空
+		t0, t1 := d.tables[0], d.tables[1]
+		for head == nil {
+			index := uint64(d.rehashIdx) + uint64(rand.Int63n(int64(len(t0.buckets))+int64(len(t1.buckets))-d.rehashIdx))
+			if index >= uint64(len(t0.buckets)) {
+				head = t1.buckets[index-uint64(len(t0.buckets))]
+			} else {
+				head = t0.buckets[index]
+			}
+		}
+	} else {
+		t := d.tables[0]
+		for head == nil {
+			head = t.buckets[uint64(rand.Int63())&t.mask]
+		}
+	}
+
+	// 在链表中随机选择一个元素
+	length := 0
+	for e := head; e != nil; e = e.next {
+		length++
+	}
+	e := head
+	for i := rand.Intn(length); i > 0; i-- {
+		e = e.next
+	}
+
+	return e.key, e.val, true
+}
+
+// Clear deletes all elements in d.
+func (d *Dict) Clear() {
+	d.tables = [2]*table{newTable(initSize)}
+	d.rehashIdx = -1
+}
+
+// expandIfNeeded 负载因子达到 1 时扩容为元素个数的两倍
+func (d *Dict) expandIfNeeded() {
+	if d.isRehashing() {
+		return
+	}
+
+	if t := d.tables[0]; t.used >= len(t.buckets) {
+		d.resize(uint64(t.used) * 2)
+	}
+}
+
+// shrinkIfNeeded 负载因子小于 0.1 时缩容
+func (d *Dict) shrinkIfNeeded() {
+	if d.isRehashing() {
+		return
+	}
+
+	if t := d.tables[0]; len(t.buckets) > initSize && t.used*10 < len(t.buckets) {
+		d.resize(uint64(t.used))
+	}
+}
+
+// resize 创建大小为不小于 size 的 2 的幂次的新表，并开始 rehash
+func (d *Dict) resize(size uint64) {
+	realSize := uint64(initSize)
+	for realSize < size {
+		realSize <<= 1
+	}
+	if realSize == uint64(len(d.tables[0].buckets)) {
+		return
+	}
+
+	d.tables[1] = newTable(realSize)
+	d.rehashIdx = 0
+}
+
+// rehashStep 迁移一个桶，最多访问 emptyVisits 个空桶
+func (d *Dict) rehashStep() {
+	if !d.isRehashing() {
+		return
+	}
+
+	src, dst := d.tables[0], d.tables[1]
+	for visits := 0; src.used > 0 && src.buckets[d.rehashIdx] == nil; visits++ {
+		d.rehashIdx++
+		if visits >= emptyVisits {
+			return
+		}
+	}
+
+	if src.used > 0 {
+		for e := src.buckets[d.rehashIdx]; e != nil; {
+			next := e.next
+			index := d.hash(e.key) & dst.mask
+			e.next = dst.buckets[index]
+			dst.buckets[index] = e
+			src.used--
+			dst.used++
+			e = next
+		}
+		src.buckets[d.rehashIdx] = nil
+		d.rehashIdx++
+	}
+
+	if src.used == 0 {
+		d.tables[0], d.tables[1] = dst, nil
+		d.rehashIdx = -1
+	}
+}
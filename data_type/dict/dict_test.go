@@ -0,0 +1,219 @@
+package dict
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// checkDict 检查 d 中的元素是否与 model 一致
+func checkDict(t *testing.T, d *Dict, model map[string]interface{}) {
+	t.Helper()
+
+	if d.Len() != len(model) {
+		t.Fatalf("Len() = %d, want %d", d.Len(), len(model))
+	}
+	for key, want := range model {
+		if got, ok := d.Get(key); !ok || got != want {
+			t.Fatalf("Get(%s) = %v, %v, want %v", key, got, ok, want)
+		}
+	}
+
+	visited := 0
+	d.ForEach(func(key string, val interface{}) bool {
+		if model[key] != val {
+			t.Fatalf("ForEach() visited %s: %v, want %v", key, val, model[key])
+		}
+		visited++
+		return true
+	})
+	if visited != len(model) {
+		t.Fatalf("ForEach() visited %d elements, want %d", visited, len(model))
+	}
+}
+
+func TestDict_Basic(t *testing.T) {
+	d := CreateDict()
+	if !d.Set("a", 1) || d.Set("a", 2) {
+		t.Fatalf("Set() should return true only when the key is added")
+	}
+	if d.Add("a", 3) || !d.Add("b", 4) {
+		t.Fatalf("Add() should only add absent keys")
+	}
+	checkDict(t, d, map[string]interface{}{"a": 2, "b": 4})
+
+	if val, ok := d.Delete("a"); !ok || val != 2 {
+		t.Fatalf("Delete(a) = %v, %v, want 2, true", val, ok)
+	}
+	if _, ok := d.Delete("a"); ok {
+		t.Fatalf("Delete(a) twice should fail")
+	}
+	if _, ok := d.Get("a"); ok {
+		t.Fatalf("Get(a) after deleting should fail")
+	}
+	checkDict(t, d, map[string]interface{}{"b": 4})
+
+	d.Clear()
+	checkDict(t, d, map[string]interface{}{})
+}
+
+func TestDict_Rehash(t *testing.T) {
+	d := CreateDict()
+	model := make(map[string]interface{})
+	rehashed := false
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		d.Set(key, i)
+		model[key] = i
+		rehashed = rehashed || d.isRehashing()
+	}
+	if !rehashed {
+		t.Fatalf("dict should rehash while growing")
+	}
+	checkDict(t, d, model)
+
+	for i := 0; i < 990; i++ {
+		key := strconv.Itoa(i)
+		d.Delete(key)
+		delete(model, key)
+	}
+	checkDict(t, d, model)
+
+	// 继续写入直到 rehash 完成，表应当已经缩小
+	for i := 0; d.isRehashing(); i++ {
+		d.Set("x", i)
+		model["x"] = i
+	}
+	if size := len(d.tables[0].buckets); size >= 1024 {
+		t.Fatalf("size of table is still %d after deleting", size)
+	}
+	checkDict(t, d, model)
+}
+
+func TestDict_RandomOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	d := CreateDict()
+	model := make(map[string]interface{})
+	for i := 0; i < 20000; i++ {
+		key := strconv.Itoa(r.Intn(2000))
+		switch r.Intn(3) {
+		case 0:
+			_, exist := model[key]
+			if added := d.Set(key, i); added == exist {
+				t.Fatalf("Set(%s) = %v, key exists: %v", key, added, exist)
+			}
+			model[key] = i
+		case 1:
+			_, exist := model[key]
+			if added := d.Add(key, i); added == exist {
+				t.Fatalf("Add(%s) = %v, key exists: %v", key, added, exist)
+			}
+			if !exist {
+				model[key] = i
+			}
+		default:
+			_, exist := model[key]
+			if _, deleted := d.Delete(key); deleted != exist {
+				t.Fatalf("Delete(%s) = %v, key exists: %v", key, deleted, exist)
+			}
+			delete(model, key)
+		}
+
+		if i%1000 == 0 {
+			checkDict(t, d, model)
+		}
+	}
+	checkDict(t, d, model)
+}
+
+func TestDict_Scan(t *testing.T) {
+	tests := []struct {
+		name string
+		// modify 在每次 Scan 之间修改 d
+		modify func(d *Dict, i int)
+	}{
+		{name: "stable", modify: func(d *Dict, i int) {}},
+		{name: "expand", modify: func(d *Dict, i int) {
+			// 持续扩容时遍历无法结束，只在前 100 次调用之间扩容
+			for j := 0; j < 20 && i < 100; j++ {
+				d.Set("new"+strconv.Itoa(i*20+j), 0)
+			}
+		}},
+		{name: "shrink", modify: func(d *Dict, i int) {
+			for j := 0; j < 20; j++ {
+				d.Delete("tmp" + strconv.Itoa(i*20+j))
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := CreateDict()
+			for i := 0; i < 500; i++ {
+				d.Set("key"+strconv.Itoa(i), i)
+			}
+			for i := 0; i < 2000; i++ {
+				d.Set("tmp"+strconv.Itoa(i), i)
+			}
+
+			// 整个遍历过程中一直存在的元素至少被访问一次
+			visited := make(map[string]bool)
+			cursor, calls := uint64(0), 0
+			for {
+				cursor = d.Scan(cursor, func(key string, val interface{}) {
+					visited[key] = true
+				})
+				if cursor == 0 {
+					break
+				}
+				tt.modify(d, calls)
+				calls++
+			}
+			for i := 0; i < 500; i++ {
+				if !visited["key"+strconv.Itoa(i)] {
+					t.Fatalf("key%d is not visited after %d calls", i, calls)
+				}
+			}
+		})
+	}
+
+	if cursor := CreateDict().Scan(0, func(key string, val interface{}) {}); cursor != 0 {
+		t.Fatalf("Scan() of empty dict = %d, want 0", cursor)
+	}
+}
+
+func TestDict_RandomEntry(t *testing.T) {
+	d := CreateDict()
+	if _, _, ok := d.RandomEntry(); ok {
+		t.Fatalf("RandomEntry() of empty dict should fail")
+	}
+
+	for i := 0; i < 100; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+	// 触发 rehash，rehash 期间也能返回两张表中的元素
+	for i := 100; i < 200; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 20000; i++ {
+		key, val, ok := d.RandomEntry()
+		if !ok || val != mustAtoi(t, key) {
+			t.Fatalf("RandomEntry() = %s, %v, %v", key, val, ok)
+		}
+		seen[key] = true
+	}
+	if len(seen) != d.Len() {
+		t.Fatalf("RandomEntry() returned %d different elements, want %d", len(seen), d.Len())
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	t.Helper()
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatalf("Atoi(%s) error = %v", s, err)
+	}
+	return n
+}
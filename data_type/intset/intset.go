@@ -0,0 +1,259 @@
+package intset
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// intset 是有序的整数数组，所有元素使用相同的宽度(int16/int32/int64)存储，宽度由最大的元素决定。
+// 插入超出当前宽度的元素时，原地将所有元素升级到更大的宽度，宽度不会再降低。
+// note: 与 redis 相同，所有数据以小端形式存储
+
+const (
+	EncodingInt16 = 2
+	EncodingInt32 = 4
+	EncodingInt64 = 8
+)
+
+type IntSet struct {
+	encoding uint8
+	length   int
+	content  []byte
+}
+
+// New creates an empty intset encoded as int16.
+func New() *IntSet {
+	return &IntSet{encoding: EncodingInt16}
+}
+
+// Dup returns a copy of is.
+func (is *IntSet) Dup() *IntSet {
+	return &IntSet{
+		encoding: is.encoding,
+		length:   is.length,
+		content:  append([]byte(nil), is.content...),
+	}
+}
+
+// valueEncoding returns the smallest encoding which can store v.
+func valueEncoding(v int64) uint8 {
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return EncodingInt64
+	}
+	if v < math.MinInt16 || v > math.MaxInt16 {
+		return EncodingInt32
+	}
+	return EncodingInt16
+}
+
+func (is *IntSet) getEncoded(pos int, encoding uint8) int64 {
+	switch encoding {
+	case EncodingInt64:
+		return int64(binary.LittleEndian.Uint64(is.content[pos*8:]))
+	case EncodingInt32:
+		return int64(int32(binary.LittleEndian.Uint32(is.content[pos*4:])))
+	default:
+		return int64(int16(binary.LittleEndian.Uint16(is.content[pos*2:])))
+	}
+}
+
+func (is *IntSet) get(pos int) int64 {
+	return is.getEncoded(pos, is.encoding)
+}
+
+func (is *IntSet) set(pos int, v int64) {
+	switch is.encoding {
+	case EncodingInt64:
+		binary.LittleEndian.PutUint64(is.content[pos*8:], uint64(v))
+	case EncodingInt32:
+		binary.LittleEndian.PutUint32(is.content[pos*4:], uint32(v))
+	default:
+		binary.LittleEndian.PutUint16(is.content[pos*2:], uint16(v))
+	}
+}
+
+func (is *IntSet) resize(length int) {
+	size := length * int(is.encoding)
+	if size <= cap(is.content) {
+		is.content = is.content[:size]
+		return
+	}
+
+	content := make([]byte, size, size+size/2)
+	copy(content, is.content)
+	is.content = content
+}
+
+// search 二分查找 v，返回 v 的位置；v 不存在时返回 v 应该插入的位置
+func (is *IntSet) search(v int64) (int, bool) {
+	if is.length == 0 {
+		return 0, false
+	}
+	// 大多数情况下 v 比所有元素都大或者都小，直接判断
+	if v > is.get(is.length-1) {
+		return is.length, false
+	}
+	if v < is.get(0) {
+		return 0, false
+	}
+
+	low, high := 0, is.length-1
+	for low <= high {
+		mid := (low + high) / 2
+		cur := is.get(mid)
+		if cur == v {
+			return mid, true
+		}
+		if cur < v {
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+	return low, false
+}
+
+// upgradeAndAdd 将 intset 升级为 v 的宽度后插入 v，v 超出原来的范围，所以一定在最前面或者最后面
+func (is *IntSet) upgradeAndAdd(v int64) {
+	oldEncoding := is.encoding
+	is.encoding = valueEncoding(v)
+	prepend := 0
+	if v < 0 {
+		prepend = 1
+	}
+
+	length := is.length
+	is.resize(length + 1)
+	// 从后向前移动，避免覆盖未移动的元素
+	for i := length - 1; i >= 0; i-- {
+		is.set(i+prepend, is.getEncoded(i, oldEncoding))
+	}
+
+	if prepend == 1 {
+		is.set(0, v)
+	} else {
+		is.set(length, v)
+	}
+	is.length++
+}
+
+// Add adds v to is, false is returned when v already exists.
+func (is *IntSet) Add(v int64) bool {
+	if valueEncoding(v) > is.encoding {
+		is.upgradeAndAdd(v)
+		return true
+	}
+
+	pos, ok := is.search(v)
+	if ok {
+		return false
+	}
+
+	is.resize(is.length + 1)
+	width := int(is.encoding)
+	copy(is.content[(pos+1)*width:], is.content[pos*width:is.length*width])
+	is.set(pos, v)
+	is.length++
+	return true
+}
+
+// Remove removes v from is, false is returned when v doesn't exist.
+func (is *IntSet) Remove(v int64) bool {
+	if valueEncoding(v) > is.encoding {
+		return false
+	}
+
+	pos, ok := is.search(v)
+	if !ok {
+		return false
+	}
+
+	width := int(is.encoding)
+	copy(is.content[pos*width:], is.content[(pos+1)*width:])
+	is.length--
+	is.resize(is.length)
+	return true
+}
+
+// Find reports whether v is in is.
+func (is *IntSet) Find(v int64) bool {
+	if valueEncoding(v) > is.encoding {
+		return false
+	}
+
+	_, ok := is.search(v)
+	return ok
+}
+
+// Get returns the element at pos in ascending order, false is returned when pos is out of range.
+func (is *IntSet) Get(pos int) (int64, bool) {
+	if pos < 0 || pos >= is.length {
+		return 0, false
+	}
+	return is.get(pos), true
+}
+
+// Random returns a random element, is must not be empty.
+func (is *IntSet) Random() int64 {
+	return is.get(rand.Intn(is.length))
+}
+
+func (is *IntSet) Len() int {
+	return is.length
+}
+
+// Encoding returns width in bytes of each element.
+func (is *IntSet) Encoding() uint8 {
+	return is.encoding
+}
+
+// BlobLen returns bytes used by elements.
+func (is *IntSet) BlobLen() int {
+	return len(is.content)
+}
+
+// intset 序列化后的格式与 redis 相同，所有字段都是小端:
+// | encoding(4Byte) | length(4Byte) | contents |
+const headerSize = 8
+
+var CorruptedDataErr = errors.New("corrupted intset data")
+
+// Bytes returns is in the same format as redis intset, which is used by RDB.
+func (is *IntSet) Bytes() []byte {
+	data := make([]byte, headerSize+len(is.content))
+	binary.LittleEndian.PutUint32(data, uint32(is.encoding))
+	binary.LittleEndian.PutUint32(data[4:], uint32(is.length))
+	copy(data[headerSize:], is.content)
+	return data
+}
+
+// FromBytes creates an intset from data of untrusted source produced by Bytes or redis, data is
+// copied. CorruptedDataErr is returned when data is invalid or elements are not strictly increasing.
+func FromBytes(data []byte) (*IntSet, error) {
+	if len(data) < headerSize {
+		return nil, CorruptedDataErr
+	}
+
+	encoding := binary.LittleEndian.Uint32(data)
+	if encoding != EncodingInt16 && encoding != EncodingInt32 && encoding != EncodingInt64 {
+		return nil, CorruptedDataErr
+	}
+	length := binary.LittleEndian.Uint32(data[4:])
+	if uint64(length)*uint64(encoding) != uint64(len(data)-headerSize) {
+		return nil, CorruptedDataErr
+	}
+
+	is := &IntSet{
+		encoding: uint8(encoding),
+		length:   int(length),
+		content:  append([]byte(nil), data[headerSize:]...),
+	}
+	for i := 1; i < is.length; i++ {
+		if is.get(i-1) >= is.get(i) {
+			return nil, CorruptedDataErr
+		}
+	}
+	return is, nil
+}
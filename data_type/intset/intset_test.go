@@ -0,0 +1,194 @@
+package intset
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkIntSet 检查 is 中的元素与 want 相同，且有序
+func checkIntSet(t *testing.T, is *IntSet, want map[int64]struct{}) {
+	t.Helper()
+
+	if is.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", is.Len(), len(want))
+	}
+	if is.BlobLen() != is.Len()*int(is.Encoding()) {
+		t.Fatalf("BlobLen() = %d, want %d", is.BlobLen(), is.Len()*int(is.Encoding()))
+	}
+
+	values := make([]int64, 0, len(want))
+	for v := range want {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i] < values[j]
+	})
+	for i, v := range values {
+		if got, ok := is.Get(i); !ok || got != v {
+			t.Fatalf("Get(%d) = %d, %v, want %d", i, got, ok, v)
+		}
+	}
+	if _, ok := is.Get(len(values)); ok {
+		t.Fatalf("Get(%d) should be out of range", len(values))
+	}
+}
+
+func TestIntSet_Add(t *testing.T) {
+	tests := []struct {
+		name         string
+		values       []int64
+		wantEncoding uint8
+	}{
+		{name: "int16", values: []int64{5, 1, 3, -2, 3, math.MaxInt16, math.MinInt16}, wantEncoding: EncodingInt16},
+		{name: "upgrade to int32 at tail", values: []int64{1, 2, math.MaxInt16 + 1}, wantEncoding: EncodingInt32},
+		{name: "upgrade to int32 at head", values: []int64{1, -2, math.MinInt16 - 1}, wantEncoding: EncodingInt32},
+		{name: "upgrade to int64 at tail", values: []int64{1, math.MaxInt32, math.MaxInt64, 0}, wantEncoding: EncodingInt64},
+		{name: "upgrade to int64 at head", values: []int64{-1, math.MinInt32, math.MinInt64, 7}, wantEncoding: EncodingInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := New()
+			want := map[int64]struct{}{}
+			for _, v := range tt.values {
+				_, exists := want[v]
+				if got := is.Add(v); got == exists {
+					t.Fatalf("Add(%d) = %v, want %v", v, got, !exists)
+				}
+				want[v] = struct{}{}
+				checkIntSet(t, is, want)
+			}
+			if is.Encoding() != tt.wantEncoding {
+				t.Fatalf("Encoding() = %d, want %d", is.Encoding(), tt.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestIntSet_Remove(t *testing.T) {
+	is := New()
+	for _, v := range []int64{1, 2, 3, math.MaxInt32 + 1} {
+		is.Add(v)
+	}
+
+	tests := []struct {
+		v    int64
+		want bool
+	}{
+		{v: 4, want: false},
+		{v: math.MaxInt64, want: false},
+		{v: 2, want: true},
+		{v: 2, want: false},
+		{v: math.MaxInt32 + 1, want: true},
+		{v: 1, want: true},
+	}
+	for _, tt := range tests {
+		if got := is.Remove(tt.v); got != tt.want {
+			t.Fatalf("Remove(%d) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+	checkIntSet(t, is, map[int64]struct{}{3: {}})
+	// 删除元素后不会降级
+	if is.Encoding() != EncodingInt64 {
+		t.Fatalf("Encoding() = %d, want %d", is.Encoding(), EncodingInt64)
+	}
+
+	s := New()
+	s.Add(1)
+	if s.Remove(math.MaxInt64) || !s.Find(1) {
+		t.Fatalf("removing value out of encoding should do nothing")
+	}
+}
+
+func TestIntSet_Random(t *testing.T) {
+	is := New()
+	for _, v := range []int64{-1, 0, 1} {
+		is.Add(v)
+	}
+
+	seen := map[int64]bool{}
+	for i := 0; i < 1000; i++ {
+		v := is.Random()
+		if !is.Find(v) {
+			t.Fatalf("Random() = %d, not in intset", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("Random() only returns %v", seen)
+	}
+}
+
+func TestIntSet_RandomOps(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ranges := []int64{100, math.MaxInt16 * 2, math.MaxInt32 * 2, math.MaxInt64}
+
+	is := New()
+	want := map[int64]struct{}{}
+	for i := 0; i < 5000; i++ {
+		bound := ranges[r.Intn(len(ranges))]
+		v := r.Int63n(bound)
+		if r.Intn(2) == 0 {
+			v = -v
+		}
+
+		switch r.Intn(3) {
+		case 0, 1:
+			_, exists := want[v]
+			if is.Add(v) == exists {
+				t.Fatalf("Add(%d) returns wrong result", v)
+			}
+			want[v] = struct{}{}
+		default:
+			_, exists := want[v]
+			if is.Remove(v) != exists {
+				t.Fatalf("Remove(%d) returns wrong result", v)
+			}
+			delete(want, v)
+		}
+		if _, exists := want[v]; is.Find(v) != exists {
+			t.Fatalf("Find(%d) returns wrong result", v)
+		}
+	}
+	checkIntSet(t, is, want)
+}
+
+func TestIntSet_Bytes(t *testing.T) {
+	is := New()
+	want := map[int64]struct{}{}
+	for _, v := range []int64{5, -3, 1 << 20} {
+		is.Add(v)
+		want[v] = struct{}{}
+	}
+
+	// 与 redis 的格式相同：encoding、length 以及小端存储的元素
+	data := is.Bytes()
+	expected := []byte{4, 0, 0, 0, 3, 0, 0, 0, 0xfd, 0xff, 0xff, 0xff, 5, 0, 0, 0, 0, 0, 0x10, 0}
+	if string(data) != string(expected) {
+		t.Fatalf("Bytes() = %v, want %v", data, expected)
+	}
+
+	got, err := FromBytes(data)
+	if err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+	checkIntSet(t, got, want)
+
+	corrupted := [][]byte{
+		nil,
+		{2, 0, 0, 0, 1, 0, 0},
+		// encoding 错误
+		{3, 0, 0, 0, 0, 0, 0, 0},
+		// length 与内容不符
+		{2, 0, 0, 0, 2, 0, 0, 0, 1, 0},
+		// 元素不是严格递增
+		{2, 0, 0, 0, 2, 0, 0, 0, 1, 0, 1, 0},
+		{2, 0, 0, 0, 2, 0, 0, 0, 2, 0, 1, 0},
+	}
+	for _, data := range corrupted {
+		if _, err := FromBytes(data); err != CorruptedDataErr {
+			t.Fatalf("FromBytes(%v) error = %v, want CorruptedDataErr", data, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package listpack
+
+import (
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+)
+
+// FromZipList creates a listpack with the same entries as zl.
+func FromZipList(zl *ziplist.ZipList) (*ListPack, error) {
+	lp := InitListPack()
+
+	c := zl.ZipListIndex(0)
+	for c != nil {
+		val, err := zl.ZipListGet(c)
+		if err != nil {
+			return nil, err
+		}
+		if err = lp.ListPackPush(val); err != nil {
+			return nil, err
+		}
+
+		if c, err = zl.ZipListNext(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return lp, nil
+}
+
+// ToZipList creates a ziplist with the same entries as lp.
+func (lp *ListPack) ToZipList() (*ziplist.ZipList, error) {
+	zl := ziplist.InitZipList()
+
+	for p := uint32(HeaderSize); lp.content[p] != End; {
+		entry, err := lp.decodeEntry(p)
+		if err != nil {
+			return nil, err
+		}
+		if err = zl.ZipListPush(entry.value()); err != nil {
+			return nil, err
+		}
+		p += entry.getLength()
+	}
+
+	return zl, nil
+}
@@ -0,0 +1,669 @@
+package listpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// listpack 是 ziplist 的替代。ziplist 的 entry 在头部记录前一个 entry 的长度，前一个 entry 长度变化时
+// 可能引起级联更新；listpack 的 entry 在尾部记录自身的长度(backlen)，修改一个 entry 不会影响其他 entry。
+// 布局与 redis 的 listpack 完全一致(多字节整数以小端形式存储)，因此 RDB 中的 listpack 可以直接使用。
+// -----------------------------------------------------------------
+// | 字节数量(4Byte) | 节点个数(2Byte) | entry1 | ... | entryN | lpend |
+// -----------------------------------------------------------------
+// entry: | encoding | data | backlen |
+// backlen 是 encoding + data 的长度，每个字节使用低 7 位，从后向前解析，最高位为 1 表示前面还有字节。
+
+const (
+	HeaderSize = 6
+	End        = 0xff
+
+	Encoding7BitUint     = 0x00 // 0xxxxxxx
+	Encoding7BitUintMask = 0x80
+	Encoding6BitStr      = 0x80 // 10xxxxxx
+	Encoding6BitStrMask  = 0xc0
+	Encoding13BitInt     = 0xc0 // 110xxxxx yyyyyyyy
+	Encoding13BitIntMask = 0xe0
+	Encoding12BitStr     = 0xe0 // 1110xxxx yyyyyyyy
+	Encoding12BitStrMask = 0xf0
+	Encoding32BitStr     = 0xf0
+	Encoding16BitInt     = 0xf1
+	Encoding24BitInt     = 0xf2
+	Encoding32BitInt     = 0xf3
+	Encoding64BitInt     = 0xf4
+
+	SixBitStrMaxLength    = 0x3f
+	TwelveBitStrMaxLength = 0xfff
+	ThirtyTwoBitStrMaxLen = 0xffffffff
+)
+
+var (
+	PointedToEndErr     = errors.New("points to the end of listpack")
+	PointerIsNilErr     = errors.New("pointer is nil")
+	OffsetOutOfRangeErr = errors.New("offset is out of range of listpack")
+	UnknownEncodingErr  = errors.New("unknown encoding of listpack entry")
+	CursorInvalidErr    = errors.New("cursor is invalidated by modification of listpack")
+	CursorNotBelongErr  = errors.New("cursor does not belong to listpack")
+)
+
+type ListPack struct {
+	content []byte
+	version uint64 // 每次修改 content 都会递增，用于判断 Cursor 是否失效
+}
+
+// Cursor 指向 listpack 中的一个 entry，与 ziplist.Cursor 相同，记录的是偏移量而不是指针，
+// listpack 修改后之前获取的 Cursor 全部失效。
+type Cursor struct {
+	lp      *ListPack
+	offset  uint32
+	version uint64
+}
+
+// Valid reports whether c can still be used.
+func (c *Cursor) Valid() bool {
+	return c != nil && c.lp != nil && c.version == c.lp.version
+}
+
+func (lp *ListPack) newCursor(offset uint32) *Cursor {
+	return &Cursor{
+		lp:      lp,
+		offset:  offset,
+		version: lp.version,
+	}
+}
+
+func (lp *ListPack) checkCursor(c *Cursor) error {
+	if c == nil {
+		return PointerIsNilErr
+	}
+	if c.lp != lp {
+		return CursorNotBelongErr
+	}
+	if c.version != lp.version {
+		return CursorInvalidErr
+	}
+
+	return nil
+}
+
+// lpEntry 是解析后的 entry
+type lpEntry struct {
+	offset         uint32
+	encodingLength uint32
+	dataLength     uint32
+	backlenLength  uint32
+	data           []byte // 字符串的内容
+	isInt          bool
+	dataInt        int64
+}
+
+func (entry *lpEntry) getLength() uint32 {
+	return entry.encodingLength + entry.dataLength + entry.backlenLength
+}
+
+func CreateListPack(elements ...interface{}) (*ListPack, error) {
+	lp := InitListPack()
+	for _, elem := range elements {
+		err := lp.ListPackPush(elem)
+		if err != nil {
+			log.Printf("When push element:%v, error happened: %v\n", elem, err)
+			return nil, err
+		}
+	}
+	return lp, nil
+}
+
+func InitListPack() *ListPack {
+	lp := &ListPack{
+		content: make([]byte, HeaderSize+1),
+	}
+
+	binary.LittleEndian.PutUint32(lp.content[:4], HeaderSize+1)
+	lp.content[HeaderSize] = End
+
+	return lp
+}
+
+func (lp *ListPack) getCount() uint16 {
+	return binary.LittleEndian.Uint16(lp.content[4:6])
+}
+
+func (lp *ListPack) setCount(count int) {
+	if count > int(utils.UINT16_MAX) {
+		count = int(utils.UINT16_MAX)
+	}
+	binary.LittleEndian.PutUint16(lp.content[4:6], uint16(count))
+}
+
+// incrCount adjusts the count in header, the count saturates at UINT16_MAX just like ziplist.
+func (lp *ListPack) incrCount(delta int) {
+	count := lp.getCount()
+	if count == utils.UINT16_MAX {
+		if delta < 0 {
+			lp.setCount(lp.countEntries())
+		}
+		return
+	}
+
+	lp.setCount(int(count) + delta)
+}
+
+func (lp *ListPack) countEntries() int {
+	count := 0
+	for p := uint32(HeaderSize); lp.content[p] != End; count++ {
+		entry, _ := lp.decodeEntry(p)
+		p += entry.getLength()
+	}
+
+	return count
+}
+
+// ListPackLen returns the number of entries in lp.
+func (lp *ListPack) ListPackLen() int {
+	if count := lp.getCount(); count < utils.UINT16_MAX {
+		return int(count)
+	}
+
+	return lp.countEntries()
+}
+
+// ListPackBytes returns the number of bytes lp takes.
+func (lp *ListPack) ListPackBytes() int {
+	return len(lp.content)
+}
+
+// ListPackPush pushes an entry at the end of lp.
+func (lp *ListPack) ListPackPush(val interface{}) error {
+	entryBytes, err := encodeVal(val)
+	if err != nil {
+		return err
+	}
+
+	end := uint32(len(lp.content) - 1)
+	lp.splice(end, end, entryBytes)
+	lp.incrCount(1)
+
+	return nil
+}
+
+// ListPackInsert inserts an entry after the entry pointed by c, the entry is inserted at the head
+// of lp when c is nil. Cursor of the new entry is returned.
+func (lp *ListPack) ListPackInsert(c *Cursor, val interface{}) (*Cursor, error) {
+	position := uint32(HeaderSize)
+	if c != nil {
+		if err := lp.checkCursor(c); err != nil {
+			return nil, err
+		}
+		entry, err := lp.decodeEntry(c.offset)
+		if err != nil {
+			return nil, err
+		}
+		position = c.offset + entry.getLength()
+	}
+
+	entryBytes, err := encodeVal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	lp.splice(position, position, entryBytes)
+	lp.incrCount(1)
+
+	return lp.newCursor(position), nil
+}
+
+// ListPackReplace replaces the entry pointed by c with val. When the new entry has the same length as
+// the old one, the entry is overwritten in place and cursors of lp are still valid, otherwise they are
+// invalidated. Cursor of the new entry is returned.
+func (lp *ListPack) ListPackReplace(c *Cursor, val interface{}) (*Cursor, error) {
+	if err := lp.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	oldEntry, err := lp.decodeEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	entryBytes, err := encodeVal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint32(len(entryBytes)) == oldEntry.getLength() {
+		copy(lp.content[c.offset:], entryBytes)
+		return c, nil
+	}
+
+	lp.splice(c.offset, c.offset+oldEntry.getLength(), entryBytes)
+
+	return lp.newCursor(c.offset), nil
+}
+
+// ListPackDelete deletes the first entry equal to val, see ListPackFind for how entries are compared.
+func (lp *ListPack) ListPackDelete(val interface{}) (bool, error) {
+	c, err := lp.ListPackFind(nil, val, 0)
+	if err != nil || c == nil {
+		return false, err
+	}
+
+	_, err = lp.ListPackDeleteAt(c)
+	return err == nil, err
+}
+
+// ListPackDeleteAt deletes the entry pointed by c, cursor of the next entry is returned,
+// nil is returned when the last entry is deleted.
+func (lp *ListPack) ListPackDeleteAt(c *Cursor) (*Cursor, error) {
+	if err := lp.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	entry, err := lp.decodeEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	lp.splice(c.offset, c.offset+entry.getLength(), nil)
+	lp.incrCount(-1)
+
+	if lp.content[c.offset] == End {
+		return nil, nil
+	}
+	return lp.newCursor(c.offset), nil
+}
+
+// ListPackDeleteRange deletes count entries from the entry with specific index, negative index counts
+// from the tail. The number of deleted entries is returned.
+func (lp *ListPack) ListPackDeleteRange(index int, count int) (int, error) {
+	c := lp.ListPackIndex(index)
+	if c == nil || count <= 0 {
+		return 0, nil
+	}
+
+	end := c.offset
+	deleted := 0
+	for ; deleted < count && lp.content[end] != End; deleted++ {
+		entry, err := lp.decodeEntry(end)
+		if err != nil {
+			return 0, err
+		}
+		end += entry.getLength()
+	}
+
+	lp.splice(c.offset, end, nil)
+	lp.incrCount(-deleted)
+
+	return deleted, nil
+}
+
+// splice 将 [start, end) 区间替换为 entryBytes，content 容量足够时复用原有的空间。
+func (lp *ListPack) splice(start, end uint32, entryBytes []byte) {
+	suffixLen := uint32(len(lp.content)) - end
+	newLen := start + uint32(len(entryBytes)) + suffixLen
+
+	var newContent []byte
+	if int(newLen) <= cap(lp.content) {
+		newContent = lp.content[:newLen]
+	} else {
+		// 预留一部分空间，连续 push 时不需要每次都重新分配
+		newContent = make([]byte, newLen, newLen+newLen/4)
+		copy(newContent, lp.content[:start])
+	}
+	copy(newContent[start+uint32(len(entryBytes)):], lp.content[end:end+suffixLen])
+	copy(newContent[start:], entryBytes)
+
+	lp.content = newContent
+	lp.version++
+	binary.LittleEndian.PutUint32(lp.content[:4], newLen)
+}
+
+// ListPackIndex gets cursor of the entry with specific index, negative index counts from the tail,
+// e.g. -1 is the last entry. Nil is returned when index is out of range.
+func (lp *ListPack) ListPackIndex(index int) *Cursor {
+	if index >= 0 {
+		p := uint32(HeaderSize)
+		for ; index > 0 && lp.content[p] != End; index-- {
+			entry, err := lp.decodeEntry(p)
+			if err != nil {
+				return nil
+			}
+			p += entry.getLength()
+		}
+
+		if lp.content[p] == End {
+			return nil
+		}
+		return lp.newCursor(p)
+	}
+
+	p := uint32(len(lp.content) - 1)
+	for ; index < 0; index++ {
+		if p <= HeaderSize {
+			return nil
+		}
+		p = lp.prevOffset(p)
+	}
+
+	return lp.newCursor(p)
+}
+
+// ListPackNext gets cursor of the entry after c, nil is returned when c points to the last entry.
+func (lp *ListPack) ListPackNext(c *Cursor) (*Cursor, error) {
+	if err := lp.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	entry, err := lp.decodeEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	next := c.offset + entry.getLength()
+	if lp.content[next] == End {
+		return nil, nil
+	}
+
+	return lp.newCursor(next), nil
+}
+
+// ListPackPrev gets cursor of the entry before c, nil is returned when c points to the first entry.
+func (lp *ListPack) ListPackPrev(c *Cursor) (*Cursor, error) {
+	if err := lp.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	if c.offset <= HeaderSize {
+		return nil, nil
+	}
+
+	return lp.newCursor(lp.prevOffset(c.offset)), nil
+}
+
+// prevOffset gets offset of the entry before p through backlen of the previous entry.
+func (lp *ListPack) prevOffset(p uint32) uint32 {
+	length, backlenLength := decodeBacklen(lp.content[:p])
+	return p - backlenLength - uint32(length)
+}
+
+// ListPackGet gets value of the entry pointed by c, the value is either int64 or *rs.RedisString.
+func (lp *ListPack) ListPackGet(c *Cursor) (interface{}, error) {
+	if err := lp.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	entry, err := lp.decodeEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.value(), nil
+}
+
+func (entry *lpEntry) value() interface{} {
+	if entry.isInt {
+		return entry.dataInt
+	}
+
+	str := &rs.RedisString{
+		Content: make([]byte, entry.dataLength),
+	}
+	copy(str.Content, entry.data)
+
+	return str
+}
+
+// ListPackFind finds the first entry equal to val from the entry pointed by c, the search begins at
+// the head of lp when c is nil. Entries are compared in the same way as ziplist.ZipListFind, and skip
+// entries are skipped between every two comparisons. Nil is returned when val is not found.
+func (lp *ListPack) ListPackFind(c *Cursor, val interface{}, skip int) (*Cursor, error) {
+	p := uint32(HeaderSize)
+	if c != nil {
+		if err := lp.checkCursor(c); err != nil {
+			return nil, err
+		}
+		p = c.offset
+	}
+
+	var (
+		isInt    bool
+		intVal   int64
+		str      []byte
+		strIsInt bool
+	)
+	switch val := val.(type) {
+	case nil:
+		return nil, errors.New("Val to find is nil")
+	case int64:
+		isInt, intVal = true, val
+	case *rs.RedisString:
+		str = val.Content
+		intVal, strIsInt = utils.String2Int64(val.Content)
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown val to find: %v", val))
+	}
+
+	toSkip := 0
+	for lp.content[p] != End {
+		entry, err := lp.decodeEntry(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if toSkip == 0 {
+			var match bool
+			if entry.isInt {
+				match = (isInt || strIsInt) && entry.dataInt == intVal
+			} else if isInt {
+				num, ok := utils.String2Int64(entry.data)
+				match = ok && num == intVal
+			} else {
+				match = bytes.Equal(entry.data, str)
+			}
+			if match {
+				return lp.newCursor(p), nil
+			}
+			toSkip = skip
+		} else {
+			toSkip--
+		}
+
+		p += entry.getLength()
+	}
+
+	return nil, nil
+}
+
+func (lp *ListPack) decodeEntry(p uint32) (*lpEntry, error) {
+	if p >= uint32(len(lp.content)) {
+		return nil, OffsetOutOfRangeErr
+	}
+	if lp.content[p] == End {
+		return nil, PointedToEndErr
+	}
+
+	entry, err := decodeEncoding(lp.content[p:])
+	if err != nil {
+		return nil, err
+	}
+	entry.offset = p
+
+	return entry, nil
+}
+
+// decodeEncoding parses the entry at the beginning of buf, backlen is not read but calculated.
+func decodeEncoding(buf []byte) (*lpEntry, error) {
+	entry := new(lpEntry)
+	encoding := buf[0]
+
+	switch {
+	case encoding&Encoding7BitUintMask == Encoding7BitUint:
+		entry.encodingLength = 1
+		entry.isInt = true
+		entry.dataInt = int64(encoding & 0x7f)
+	case encoding&Encoding6BitStrMask == Encoding6BitStr:
+		entry.encodingLength = 1
+		entry.dataLength = uint32(encoding & 0x3f)
+	case encoding&Encoding13BitIntMask == Encoding13BitInt:
+		entry.encodingLength = 2
+		entry.isInt = true
+		// 13 位补码，左移后算术右移完成符号扩展
+		uval := uint16(encoding&0x1f)<<8 | uint16(buf[1])
+		entry.dataInt = int64(int16(uval<<3) >> 3)
+	case encoding&Encoding12BitStrMask == Encoding12BitStr:
+		entry.encodingLength = 2
+		entry.dataLength = uint32(encoding&0x0f)<<8 | uint32(buf[1])
+	case encoding == Encoding32BitStr:
+		entry.encodingLength = 5
+		entry.dataLength = binary.LittleEndian.Uint32(buf[1:5])
+	case encoding == Encoding16BitInt:
+		entry.encodingLength = 3
+		entry.isInt = true
+		entry.dataInt = int64(int16(binary.LittleEndian.Uint16(buf[1:3])))
+	case encoding == Encoding24BitInt:
+		entry.encodingLength = 4
+		entry.isInt = true
+		uval := uint32(buf[1]) | uint32(buf[2])<<8 | uint32(buf[3])<<16
+		entry.dataInt = int64(int32(uval<<8) >> 8)
+	case encoding == Encoding32BitInt:
+		entry.encodingLength = 5
+		entry.isInt = true
+		entry.dataInt = int64(int32(binary.LittleEndian.Uint32(buf[1:5])))
+	case encoding == Encoding64BitInt:
+		entry.encodingLength = 9
+		entry.isInt = true
+		entry.dataInt = int64(binary.LittleEndian.Uint64(buf[1:9]))
+	default:
+		return nil, fmt.Errorf("%w: 0x%02x", UnknownEncodingErr, encoding)
+	}
+
+	if !entry.isInt {
+		entry.data = buf[entry.encodingLength : entry.encodingLength+entry.dataLength]
+	}
+	entry.backlenLength = backlenLength(uint64(entry.encodingLength + entry.dataLength))
+
+	return entry, nil
+}
+
+// encodeVal encodes val into bytes of an entry including backlen.
+func encodeVal(val interface{}) ([]byte, error) {
+	var buf []byte
+	switch val := val.(type) {
+	case nil:
+		return nil, errors.New("Val to push is nil")
+	case int64:
+		buf = encodeInt(val)
+	case *rs.RedisString:
+		if val == nil {
+			return nil, PointerIsNilErr
+		}
+		var err error
+		if buf, err = encodeStr(val.Content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown val to push: %v", val))
+	}
+
+	return append(buf, encodeBacklen(uint64(len(buf)))...), nil
+}
+
+func encodeInt(num int64) []byte {
+	switch {
+	case num >= 0 && num <= 127:
+		return []byte{byte(num)}
+	case num >= -4096 && num <= 4095:
+		uval := uint16(num) & 0x1fff
+		return []byte{byte(uval>>8) | Encoding13BitInt, byte(uval)}
+	case num >= int64(utils.INT16_MIN) && num <= int64(utils.INT16_MAX):
+		buf := []byte{Encoding16BitInt, 0, 0}
+		binary.LittleEndian.PutUint16(buf[1:], uint16(num))
+		return buf
+	case num >= int64(utils.INT24_MIN) && num <= int64(utils.INT24_MAX):
+		uval := uint32(num)
+		return []byte{Encoding24BitInt, byte(uval), byte(uval >> 8), byte(uval >> 16)}
+	case num >= int64(utils.INT32_MIN) && num <= int64(utils.INT32_MAX):
+		buf := []byte{Encoding32BitInt, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(buf[1:], uint32(num))
+		return buf
+	default:
+		buf := make([]byte, 9)
+		buf[0] = Encoding64BitInt
+		binary.LittleEndian.PutUint64(buf[1:], uint64(num))
+		return buf
+	}
+}
+
+func encodeStr(str []byte) ([]byte, error) {
+	length := uint64(len(str))
+	var buf []byte
+	switch {
+	case length <= SixBitStrMaxLength:
+		buf = make([]byte, 1, 1+length+1)
+		buf[0] = Encoding6BitStr | byte(length)
+	case length <= TwelveBitStrMaxLength:
+		buf = make([]byte, 2, 2+length+2)
+		buf[0] = Encoding12BitStr | byte(length>>8)
+		buf[1] = byte(length)
+	case length <= ThirtyTwoBitStrMaxLen:
+		buf = make([]byte, 5, 5+length+5)
+		buf[0] = Encoding32BitStr
+		binary.LittleEndian.PutUint32(buf[1:], uint32(length))
+	default:
+		return nil, errors.New(fmt.Sprintf("The length of str: is %d which is bigger than limit: %d", length, uint64(ThirtyTwoBitStrMaxLen)))
+	}
+
+	return append(buf, str...), nil
+}
+
+// encodeBacklen encodes length of encoding + data, the bytes are parsed from back to front.
+func encodeBacklen(length uint64) []byte {
+	n := backlenLength(length)
+	buf := make([]byte, n)
+	for i := int(n) - 1; i >= 0; i-- {
+		buf[i] = byte(length & 127)
+		// 除了最前面的字节，其余字节的最高位都为 1
+		if i != 0 {
+			buf[i] |= 128
+		}
+		length >>= 7
+	}
+
+	return buf
+}
+
+func backlenLength(length uint64) uint32 {
+	switch {
+	case length <= 127:
+		return 1
+	case length < 16383:
+		return 2
+	case length < 2097151:
+		return 3
+	case length < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// decodeBacklen parses backlen which ends at the end of buf, the length and the number of bytes of
+// backlen are returned. The number of bytes is 0 when backlen is invalid.
+func decodeBacklen(buf []byte) (uint64, uint32) {
+	length := uint64(0)
+	shift := uint(0)
+	for i := len(buf) - 1; i >= 0 && shift <= 28; i-- {
+		length |= uint64(buf[i]&127) << shift
+		if buf[i]&128 == 0 {
+			return length, uint32(len(buf) - i)
+		}
+		shift += 7
+	}
+
+	return 0, 0
+}
@@ -0,0 +1,656 @@
+package listpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+func TestCreateListPack(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []interface{}
+		want     []byte
+	}{
+		{
+			name: "empty",
+			want: []byte{0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff},
+		},
+		{
+			name: "7 bit uint and 6 bit str",
+			elements: []interface{}{
+				int64(1),
+				&rs.RedisString{Content: []byte("a")},
+			},
+			want: []byte{0x0c, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0x01, 0x81, 'a', 0x02, 0xff},
+		},
+		{
+			name: "13 bit int",
+			elements: []interface{}{
+				int64(-1),
+				int64(1000),
+				int64(-4096),
+			},
+			want: []byte{0x10, 0x00, 0x00, 0x00, 0x03, 0x00, 0xdf, 0xff, 0x02, 0xc3, 0xe8, 0x02, 0xd0, 0x00, 0x02, 0xff},
+		},
+		{
+			name: "multi byte int",
+			elements: []interface{}{
+				int64(5000),
+				int64(utils.INT24_MIN),
+				int64(utils.INT32_MAX),
+				int64(-1) << 40,
+			},
+			want: []byte{
+				0x20, 0x00, 0x00, 0x00, 0x04, 0x00,
+				0xf1, 0x88, 0x13, 0x03,
+				0xf2, 0x00, 0x00, 0x80, 0x04,
+				0xf3, 0xff, 0xff, 0xff, 0x7f, 0x05,
+				0xf4, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0x09,
+				0xff,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, err := CreateListPack(tt.elements...)
+			if err != nil {
+				t.Fatalf("CreateListPack() error = %v", err)
+			}
+			if !bytes.Equal(lp.content, tt.want) {
+				t.Errorf("CreateListPack() = %x, want %x", lp.content, tt.want)
+			}
+			checkListPack(t, lp, tt.elements)
+		})
+	}
+}
+
+func Test_encodeStr(t *testing.T) {
+	tests := []struct {
+		name       string
+		length     int
+		wantHeader []byte
+		wantBack   []byte
+	}{
+		{name: "6 bit max", length: 63, wantHeader: []byte{0xbf}, wantBack: []byte{0x40}},
+		{name: "12 bit min", length: 64, wantHeader: []byte{0xe0, 0x40}, wantBack: []byte{0x42}},
+		{name: "12 bit with 2 bytes backlen", length: 126, wantHeader: []byte{0xe0, 0x7e}, wantBack: []byte{0x01, 0x80}},
+		{name: "12 bit max", length: 4095, wantHeader: []byte{0xef, 0xff}, wantBack: []byte{0x20, 0x81}},
+		{name: "32 bit", length: 4096, wantHeader: []byte{0xf0, 0x00, 0x10, 0x00, 0x00}, wantBack: []byte{0x20, 0x85}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeVal(&rs.RedisString{Content: make([]byte, tt.length)})
+			if err != nil {
+				t.Fatalf("encodeVal() error = %v", err)
+			}
+			if !bytes.Equal(got[:len(tt.wantHeader)], tt.wantHeader) {
+				t.Errorf("encoding = %x, want %x", got[:len(tt.wantHeader)], tt.wantHeader)
+			}
+			if !bytes.Equal(got[len(got)-len(tt.wantBack):], tt.wantBack) {
+				t.Errorf("backlen = %x, want %x", got[len(got)-len(tt.wantBack):], tt.wantBack)
+			}
+
+			entry, err := decodeEncoding(got)
+			if err != nil {
+				t.Fatalf("decodeEncoding() error = %v", err)
+			}
+			if int(entry.getLength()) != len(got) || int(entry.dataLength) != tt.length {
+				t.Errorf("decodeEncoding() = %+v, want length %d", entry, len(got))
+			}
+		})
+	}
+}
+
+func Test_encodeBacklen(t *testing.T) {
+	for _, length := range []uint64{0, 127, 128, 16382, 16383, 2097150, 2097151, 268435454, 268435455, 1 << 32} {
+		buf := encodeBacklen(length)
+		if uint32(len(buf)) != backlenLength(length) {
+			t.Errorf("encodeBacklen(%d) takes %d bytes, want %d", length, len(buf), backlenLength(length))
+		}
+
+		// 在前面放一些干扰字节，解析时不应越过 backlen 的第一个字节
+		got, n := decodeBacklen(append([]byte{0xff, 0xff}, buf...))
+		if got != length || int(n) != len(buf) {
+			t.Errorf("decodeBacklen(encodeBacklen(%d)) = %d, %d", length, got, n)
+		}
+	}
+}
+
+func Test_decodeEncoding_Int(t *testing.T) {
+	nums := []int64{
+		0, 127, 128, -1, 4095, -4096, 4096, -4097,
+		int64(utils.INT16_MAX), int64(utils.INT16_MIN), int64(utils.INT16_MAX) + 1,
+		int64(utils.INT24_MAX), int64(utils.INT24_MIN), int64(utils.INT24_MIN) - 1,
+		int64(utils.INT32_MAX), int64(utils.INT32_MIN), int64(utils.INT32_MAX) + 1,
+		utils.INT64_MAX, utils.INT64_MIN,
+	}
+	for _, num := range nums {
+		buf, err := encodeVal(num)
+		if err != nil {
+			t.Fatalf("encodeVal(%d) error = %v", num, err)
+		}
+		entry, err := decodeEncoding(buf)
+		if err != nil {
+			t.Fatalf("decodeEncoding(%x) error = %v", buf, err)
+		}
+		if !entry.isInt || entry.dataInt != num || int(entry.getLength()) != len(buf) {
+			t.Errorf("decodeEncoding(%x) = %+v, want %d", buf, entry, num)
+		}
+	}
+}
+
+// checkListPack 检查 lp 的首部以及正向、反向遍历得到的元素是否与 want 一致
+func checkListPack(t *testing.T, lp *ListPack, want []interface{}) {
+	t.Helper()
+
+	if got := binary.LittleEndian.Uint32(lp.content[:4]); got != uint32(len(lp.content)) {
+		t.Fatalf("length in header = %d, want %d", got, len(lp.content))
+	}
+	wantCount := len(want)
+	if wantCount > int(utils.UINT16_MAX) {
+		wantCount = int(utils.UINT16_MAX)
+	}
+	if got := lp.getCount(); int(got) != wantCount {
+		t.Fatalf("count in header = %d, want %d", got, wantCount)
+	}
+	if got := lp.ListPackLen(); got != len(want) {
+		t.Fatalf("ListPackLen() = %d, want %d", got, len(want))
+	}
+
+	index := 0
+	for c := lp.ListPackIndex(0); c != nil; index++ {
+		got, err := lp.ListPackGet(c)
+		if err != nil {
+			t.Fatalf("ListPackGet() at index %d error = %v", index, err)
+		}
+		if index >= len(want) || !reflect.DeepEqual(got, want[index]) {
+			t.Fatalf("index %d = %v, want %v", index, got, want)
+		}
+		if c, err = lp.ListPackNext(c); err != nil {
+			t.Fatalf("ListPackNext() at index %d error = %v", index, err)
+		}
+	}
+	if index != len(want) {
+		t.Fatalf("forward iteration visited %d entries, want %d", index, len(want))
+	}
+
+	index = len(want) - 1
+	for c := lp.ListPackIndex(-1); c != nil; index-- {
+		got, _ := lp.ListPackGet(c)
+		if index < 0 || !reflect.DeepEqual(got, want[index]) {
+			t.Fatalf("backward index %d = %v, want %v", index, got, want)
+		}
+		var err error
+		if c, err = lp.ListPackPrev(c); err != nil {
+			t.Fatalf("ListPackPrev() at index %d error = %v", index, err)
+		}
+	}
+	if index != -1 {
+		t.Fatalf("backward iteration stopped at index %d, want -1", index)
+	}
+
+	if err := ValidateIntegrity(lp.content, true); err != nil {
+		t.Fatalf("ValidateIntegrity() error = %v", err)
+	}
+}
+
+func createTestListPack(t *testing.T) (*ListPack, []interface{}) {
+	elements := []interface{}{
+		int64(0),
+		int64(127),
+		int64(-1),
+		int64(utils.INT16_MIN),
+		int64(utils.INT24_MIN),
+		int64(utils.INT24_MAX),
+		int64(utils.INT32_MIN),
+		int64(utils.INT64_MAX),
+		&rs.RedisString{Content: []byte("abc")},
+		&rs.RedisString{Content: make([]byte, 100)},
+		&rs.RedisString{Content: make([]byte, 300)},
+		int64(7),
+	}
+	lp, err := CreateListPack(elements...)
+	if err != nil {
+		t.Fatalf("CreateListPack() error = %v", err)
+	}
+
+	return lp, elements
+}
+
+func TestListPack_ListPackIndex(t *testing.T) {
+	lp, elements := createTestListPack(t)
+
+	for index := -len(elements) - 1; index <= len(elements); index++ {
+		c := lp.ListPackIndex(index)
+		if index >= len(elements) || index < -len(elements) {
+			if c != nil {
+				t.Errorf("ListPackIndex(%d) = %v, want nil", index, c)
+			}
+			continue
+		}
+
+		wantIndex := index
+		if index < 0 {
+			wantIndex += len(elements)
+		}
+		want := elements[wantIndex]
+		got, err := lp.ListPackGet(c)
+		if err != nil {
+			t.Fatalf("ListPackGet() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ListPackIndex(%d) = %v, want %v", index, got, want)
+		}
+	}
+
+	if c := InitListPack().ListPackIndex(0); c != nil {
+		t.Errorf("ListPackIndex(0) of empty listpack = %v, want nil", c)
+	}
+}
+
+func TestListPack_ListPackInsert(t *testing.T) {
+	lp, elements := createTestListPack(t)
+
+	// 插入到头部、中间以及尾部
+	head := &rs.RedisString{Content: []byte("head")}
+	c, err := lp.ListPackInsert(nil, head)
+	if err != nil {
+		t.Fatalf("ListPackInsert() error = %v", err)
+	}
+	middle := int64(-5000)
+	if c, err = lp.ListPackInsert(lp.ListPackIndex(5), middle); err != nil {
+		t.Fatalf("ListPackInsert() error = %v", err)
+	}
+	if got, _ := lp.ListPackGet(c); !reflect.DeepEqual(got, middle) {
+		t.Errorf("cursor returned by ListPackInsert() points to %v, want %v", got, middle)
+	}
+	tail := &rs.RedisString{Content: make([]byte, 5000)}
+	if _, err = lp.ListPackInsert(lp.ListPackIndex(-1), tail); err != nil {
+		t.Fatalf("ListPackInsert() error = %v", err)
+	}
+
+	want := append([]interface{}{head}, elements[:5]...)
+	want = append(want, middle)
+	want = append(want, elements[5:]...)
+	want = append(want, tail)
+	checkListPack(t, lp, want)
+}
+
+func TestListPack_ListPackReplace(t *testing.T) {
+	lp, elements := createTestListPack(t)
+
+	// 长度相同时原地修改，其他 Cursor 仍然有效
+	other := lp.ListPackIndex(-1)
+	c, err := lp.ListPackReplace(lp.ListPackIndex(8), &rs.RedisString{Content: []byte("xyz")})
+	if err != nil {
+		t.Fatalf("ListPackReplace() error = %v", err)
+	}
+	if !other.Valid() || !c.Valid() {
+		t.Fatalf("cursors should be valid after replacing in place")
+	}
+	elements[8] = &rs.RedisString{Content: []byte("xyz")}
+	checkListPack(t, lp, elements)
+
+	// 长度不同
+	if c, err = lp.ListPackReplace(lp.ListPackIndex(1), &rs.RedisString{Content: make([]byte, 200)}); err != nil {
+		t.Fatalf("ListPackReplace() error = %v", err)
+	}
+	if other.Valid() {
+		t.Fatalf("cursors should be invalidated after replacing with different length")
+	}
+	elements[1] = &rs.RedisString{Content: make([]byte, 200)}
+	checkListPack(t, lp, elements)
+
+	if got, _ := lp.ListPackGet(c); !reflect.DeepEqual(got, elements[1]) {
+		t.Errorf("cursor returned by ListPackReplace() points to %v, want %v", got, elements[1])
+	}
+}
+
+func TestListPack_ListPackDelete(t *testing.T) {
+	lp, elements := createTestListPack(t)
+
+	tests := []struct {
+		name  string
+		val   interface{}
+		want  bool
+		index int
+	}{
+		{name: "string", val: &rs.RedisString{Content: []byte("abc")}, want: true, index: 8},
+		{name: "int", val: int64(utils.INT24_MIN), want: true, index: 4},
+		{name: "string matches int", val: &rs.RedisString{Content: []byte("127")}, want: true, index: 1},
+		{name: "not exist", val: int64(100), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lp.ListPackDelete(tt.val)
+			if err != nil {
+				t.Fatalf("ListPackDelete() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("ListPackDelete() = %v, want %v", got, tt.want)
+			}
+			if got {
+				elements = append(elements[:tt.index], elements[tt.index+1:]...)
+			}
+			checkListPack(t, lp, elements)
+		})
+	}
+}
+
+func TestListPack_ListPackDeleteAt(t *testing.T) {
+	lp, elements := createTestListPack(t)
+
+	c, err := lp.ListPackDeleteAt(lp.ListPackIndex(3))
+	if err != nil {
+		t.Fatalf("ListPackDeleteAt() error = %v", err)
+	}
+	if got, _ := lp.ListPackGet(c); !reflect.DeepEqual(got, elements[4]) {
+		t.Errorf("cursor returned by ListPackDeleteAt() points to %v, want %v", got, elements[4])
+	}
+	elements = append(elements[:3], elements[4:]...)
+	checkListPack(t, lp, elements)
+
+	if c, err = lp.ListPackDeleteAt(lp.ListPackIndex(-1)); err != nil || c != nil {
+		t.Fatalf("ListPackDeleteAt() of the last entry = %v, %v, want nil, nil", c, err)
+	}
+	checkListPack(t, lp, elements[:len(elements)-1])
+}
+
+func TestListPack_ListPackDeleteRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		count int
+		want  int
+	}{
+		{name: "middle", index: 2, count: 3, want: 3},
+		{name: "negative index", index: -3, count: 2, want: 2},
+		{name: "beyond the end", index: 10, count: 5, want: 2},
+		{name: "all", index: 0, count: 12, want: 12},
+		{name: "out of range", index: 12, count: 1, want: 0},
+		{name: "zero count", index: 0, count: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, elements := createTestListPack(t)
+			got, err := lp.ListPackDeleteRange(tt.index, tt.count)
+			if err != nil {
+				t.Fatalf("ListPackDeleteRange() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("ListPackDeleteRange() = %d, want %d", got, tt.want)
+			}
+
+			index := tt.index
+			if index < 0 {
+				index += len(elements)
+			}
+			if got > 0 {
+				elements = append(elements[:index], elements[index+got:]...)
+			}
+			checkListPack(t, lp, elements)
+		})
+	}
+}
+
+func TestListPack_ListPackFind(t *testing.T) {
+	lp, err := CreateListPack(
+		&rs.RedisString{Content: []byte("a")},
+		int64(1),
+		&rs.RedisString{Content: []byte("10")},
+		int64(1),
+		&rs.RedisString{Content: []byte("a")},
+	)
+	if err != nil {
+		t.Fatalf("CreateListPack() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		start int
+		val   interface{}
+		skip  int
+		want  int
+	}{
+		{name: "string", start: -1, val: &rs.RedisString{Content: []byte("a")}, want: 0},
+		{name: "int", start: -1, val: int64(1), want: 1},
+		{name: "string matches int", start: -1, val: &rs.RedisString{Content: []byte("1")}, want: 1},
+		{name: "int matches string", start: -1, val: int64(10), want: 2},
+		{name: "not canonical", start: -1, val: &rs.RedisString{Content: []byte("01")}, want: -1},
+		{name: "from cursor", start: 2, val: int64(1), want: 3},
+		{name: "skip", start: 1, val: &rs.RedisString{Content: []byte("a")}, skip: 2, want: 4},
+		{name: "skipped match", start: 1, val: &rs.RedisString{Content: []byte("a")}, skip: 1, want: -1},
+		{name: "start at match", start: 1, val: int64(1), skip: 0, want: 1},
+		{name: "not found", start: -1, val: int64(2), want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *Cursor
+			if tt.start >= 0 {
+				c = lp.ListPackIndex(tt.start)
+			}
+			got, err := lp.ListPackFind(c, tt.val, tt.skip)
+			if err != nil {
+				t.Fatalf("ListPackFind() error = %v", err)
+			}
+			if tt.want < 0 {
+				if got != nil {
+					t.Fatalf("ListPackFind() = %v, want nil", got)
+				}
+				return
+			}
+			if want := lp.ListPackIndex(tt.want); got == nil || got.offset != want.offset {
+				t.Fatalf("ListPackFind() = %v, want index %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursor_Invalidated(t *testing.T) {
+	lp, _ := createTestListPack(t)
+	other, _ := createTestListPack(t)
+
+	c := lp.ListPackIndex(0)
+	if _, err := other.ListPackGet(c); err != CursorNotBelongErr {
+		t.Errorf("ListPackGet() with cursor of other listpack error = %v, want %v", err, CursorNotBelongErr)
+	}
+
+	if err := lp.ListPackPush(int64(1)); err != nil {
+		t.Fatalf("ListPackPush() error = %v", err)
+	}
+	if c.Valid() {
+		t.Errorf("cursor should be invalidated after pushing")
+	}
+	if _, err := lp.ListPackNext(c); err != CursorInvalidErr {
+		t.Errorf("ListPackNext() with invalidated cursor error = %v, want %v", err, CursorInvalidErr)
+	}
+	if _, err := lp.ListPackGet(nil); err != PointerIsNilErr {
+		t.Errorf("ListPackGet(nil) error = %v, want %v", err, PointerIsNilErr)
+	}
+}
+
+func TestListPack_ListPackLen(t *testing.T) {
+	lp := InitListPack()
+	var model []interface{}
+	for i := 0; i < int(utils.UINT16_MAX)+2; i++ {
+		if err := lp.ListPackPush(int64(i % 100)); err != nil {
+			t.Fatalf("ListPackPush() error = %v", err)
+		}
+		model = append(model, int64(i%100))
+	}
+	checkListPack(t, lp, model)
+
+	// 删除后节点个数小于 UINT16_MAX，首部中的计数重新变得准确
+	if _, err := lp.ListPackDeleteRange(0, 10); err != nil {
+		t.Fatalf("ListPackDeleteRange() error = %v", err)
+	}
+	checkListPack(t, lp, model[10:])
+}
+
+func TestListPack_RandomOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomVal := func() interface{} {
+		switch r.Intn(3) {
+		case 0:
+			return int64(r.Intn(100000) - 50000)
+		case 1:
+			return &rs.RedisString{Content: make([]byte, 100+r.Intn(5000))}
+		default:
+			return &rs.RedisString{Content: make([]byte, r.Intn(70))}
+		}
+	}
+
+	lp := InitListPack()
+	var model []interface{}
+	for i := 0; i < 2000; i++ {
+		switch op := r.Intn(5); {
+		case op == 0 || len(model) == 0:
+			val := randomVal()
+			if err := lp.ListPackPush(val); err != nil {
+				t.Fatalf("ListPackPush() error = %v", err)
+			}
+			model = append(model, val)
+		case op == 1:
+			index := r.Intn(len(model) + 1)
+			var c *Cursor
+			if index > 0 {
+				c = lp.ListPackIndex(index - 1)
+			}
+			val := randomVal()
+			if _, err := lp.ListPackInsert(c, val); err != nil {
+				t.Fatalf("ListPackInsert() error = %v", err)
+			}
+			model = append(model[:index], append([]interface{}{val}, model[index:]...)...)
+		case op == 2:
+			index := r.Intn(len(model))
+			val := randomVal()
+			if _, err := lp.ListPackReplace(lp.ListPackIndex(index), val); err != nil {
+				t.Fatalf("ListPackReplace() error = %v", err)
+			}
+			model[index] = val
+		case op == 3:
+			index := r.Intn(len(model))
+			count := r.Intn(4)
+			deleted, _ := lp.ListPackDeleteRange(index, count)
+			if index+count > len(model) {
+				count = len(model) - index
+			}
+			if deleted != count {
+				t.Fatalf("ListPackDeleteRange(%d, %d) = %d, want %d", index, count, deleted, count)
+			}
+			model = append(model[:index], model[index+count:]...)
+		default:
+			index := r.Intn(len(model))
+			if _, err := lp.ListPackDeleteAt(lp.ListPackIndex(index)); err != nil {
+				t.Fatalf("ListPackDeleteAt() error = %v", err)
+			}
+			model = append(model[:index], model[index+1:]...)
+		}
+
+		checkListPack(t, lp, model)
+	}
+}
+
+func TestConvertZipList(t *testing.T) {
+	lp, elements := createTestListPack(t)
+
+	zl, err := lp.ToZipList()
+	if err != nil {
+		t.Fatalf("ToZipList() error = %v", err)
+	}
+	if got := zl.ZipListLen(); got != len(elements) {
+		t.Fatalf("ZipListLen() = %d, want %d", got, len(elements))
+	}
+	index := 0
+	for c := zl.ZipListIndex(0); c != nil; index++ {
+		got, _ := zl.ZipListGet(c)
+		if !reflect.DeepEqual(got, elements[index]) {
+			t.Fatalf("index %d of ziplist = %v, want %v", index, got, elements[index])
+		}
+		c, _ = zl.ZipListNext(c)
+	}
+
+	newLp, err := FromZipList(zl)
+	if err != nil {
+		t.Fatalf("FromZipList() error = %v", err)
+	}
+	if !bytes.Equal(newLp.content, lp.content) {
+		t.Errorf("FromZipList(ToZipList()) = %x, want %x", newLp.content, lp.content)
+	}
+
+	if newLp, err = FromZipList(ziplist.InitZipList()); err != nil {
+		t.Fatalf("FromZipList() error = %v", err)
+	}
+	checkListPack(t, newLp, nil)
+}
+
+// 每次在头部插入一个长度为 250 的字符串，ziplist 中原来的头节点的 prev 字段需要从 1 字节扩展为 5 字节，
+// 进而引起级联更新；listpack 不存在这个问题。
+const benchEntries = 1000
+
+func BenchmarkZipList_InsertHead(b *testing.B) {
+	val := &rs.RedisString{Content: make([]byte, 250)}
+	for i := 0; i < b.N; i++ {
+		zl := ziplist.InitZipList()
+		for j := 0; j < benchEntries; j++ {
+			if _, err := zl.ZipListInsert(nil, val); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkListPack_InsertHead(b *testing.B) {
+	val := &rs.RedisString{Content: make([]byte, 250)}
+	for i := 0; i < b.N; i++ {
+		lp := InitListPack()
+		for j := 0; j < benchEntries; j++ {
+			if _, err := lp.ListPackInsert(nil, val); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkZipList_Push(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		zl := ziplist.InitZipList()
+		for j := 0; j < benchEntries; j++ {
+			if err := zl.ZipListPush(int64(j)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkListPack_Push(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		lp := InitListPack()
+		for j := 0; j < benchEntries; j++ {
+			if err := lp.ListPackPush(int64(j)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkFromZipList(b *testing.B) {
+	zl := ziplist.InitZipList()
+	for j := 0; j < benchEntries; j++ {
+		_ = zl.ZipListPush(int64(j))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := FromZipList(zl); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
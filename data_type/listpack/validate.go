@@ -0,0 +1,143 @@
+package listpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// 与 ziplist 相同，从 RDB 文件加载的 listpack 是不可信的，使用前需要校验其完整性。
+
+var (
+	CorruptedListPackErr = errors.New("corrupted listpack")
+)
+
+// ValidateIntegrity validates content of a listpack from untrusted source. Header and lpend are always
+// validated. When deep is true, every entry is walked to validate encodings, backlen and entry count,
+// after that content can be safely accessed.
+func ValidateIntegrity(content []byte, deep bool) error {
+	if len(content) < HeaderSize+1 || uint64(len(content)) > uint64(utils.UINT32_MAX) {
+		return fmt.Errorf("%w: invalid length %d", CorruptedListPackErr, len(content))
+	}
+
+	if total := binary.LittleEndian.Uint32(content[:4]); int(total) != len(content) {
+		return fmt.Errorf("%w: length in header is %d, but actual length is %d", CorruptedListPackErr, total, len(content))
+	}
+
+	if content[len(content)-1] != End {
+		return fmt.Errorf("%w: the last byte is 0x%02x, not lpend", CorruptedListPackErr, content[len(content)-1])
+	}
+
+	if !deep {
+		return nil
+	}
+
+	end := len(content) - 1
+	count := 0
+	for p := HeaderSize; p < end; count++ {
+		entryLen, err := validateEntry(content[:end], p)
+		if err != nil {
+			return err
+		}
+		p += entryLen
+	}
+
+	headerCount := int(binary.LittleEndian.Uint16(content[4:6]))
+	if count >= int(utils.UINT16_MAX) {
+		count = int(utils.UINT16_MAX)
+	}
+	if headerCount != count {
+		return fmt.Errorf("%w: count in header is %d, but there are %d entries", CorruptedListPackErr, headerCount, count)
+	}
+
+	return nil
+}
+
+// validateEntry validates the entry at offset p, content doesn't contain lpend. Length of the entry
+// is returned.
+func validateEntry(content []byte, p int) (int, error) {
+	encoding := content[p]
+
+	// encoding 本身占用的字节数
+	encodingLength := 1
+	switch {
+	case encoding&Encoding13BitIntMask == Encoding13BitInt, encoding&Encoding12BitStrMask == Encoding12BitStr:
+		encodingLength = 2
+	case encoding == Encoding32BitStr:
+		encodingLength = 5
+	case encoding == End:
+		return 0, fmt.Errorf("%w: lpend in the middle at %d", CorruptedListPackErr, p)
+	case encoding > Encoding64BitInt:
+		return 0, fmt.Errorf("%w: unknown encoding 0x%02x of entry at %d", CorruptedListPackErr, encoding, p)
+	}
+	if p+encodingLength > len(content) {
+		return 0, fmt.Errorf("%w: encoding of entry at %d is out of range", CorruptedListPackErr, p)
+	}
+
+	if p+encodingLength+dataLength(content[p:p+encodingLength]) > len(content) {
+		return 0, fmt.Errorf("%w: data of entry at %d is out of range", CorruptedListPackErr, p)
+	}
+
+	entry, err := decodeEncoding(content[p:])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", CorruptedListPackErr, err)
+	}
+
+	entryLen := int(entry.getLength())
+	if p+entryLen > len(content) {
+		return 0, fmt.Errorf("%w: entry at %d with length %d is out of range", CorruptedListPackErr, p, entryLen)
+	}
+
+	// backlen 必须与 encoding + data 的长度一致，否则从后向前遍历会出错
+	length, backlenLength := decodeBacklen(content[p : p+entryLen])
+	if backlenLength != entry.backlenLength || length != uint64(entry.encodingLength+entry.dataLength) {
+		return 0, fmt.Errorf("%w: invalid backlen of entry at %d", CorruptedListPackErr, p)
+	}
+
+	return entryLen, nil
+}
+
+// dataLength returns length of data besides encoding, encoding must be complete.
+func dataLength(encoding []byte) int {
+	switch {
+	case encoding[0]&Encoding7BitUintMask == Encoding7BitUint, encoding[0]&Encoding13BitIntMask == Encoding13BitInt:
+		return 0
+	case encoding[0]&Encoding6BitStrMask == Encoding6BitStr:
+		return int(encoding[0] & 0x3f)
+	case encoding[0]&Encoding12BitStrMask == Encoding12BitStr:
+		return int(encoding[0]&0x0f)<<8 | int(encoding[1])
+	case encoding[0] == Encoding32BitStr:
+		return int(binary.LittleEndian.Uint32(encoding[1:5]))
+	case encoding[0] == Encoding16BitInt:
+		return 2
+	case encoding[0] == Encoding24BitInt:
+		return 3
+	case encoding[0] == Encoding32BitInt:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// ListPackFromBytes creates a listpack from content of untrusted source, see ValidateIntegrity for deep.
+// content is copied, so it can be reused by caller.
+func ListPackFromBytes(content []byte, deep bool) (*ListPack, error) {
+	if err := ValidateIntegrity(content, deep); err != nil {
+		return nil, err
+	}
+
+	lp := &ListPack{
+		content: make([]byte, len(content)),
+	}
+	copy(lp.content, content)
+
+	return lp, nil
+}
+
+// Bytes returns content of lp, the returned slice must not be modified and is only valid until the
+// next modification of lp.
+func (lp *ListPack) Bytes() []byte {
+	return lp.content
+}
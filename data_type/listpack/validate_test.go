@@ -0,0 +1,179 @@
+package listpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+func TestValidateIntegrity(t *testing.T) {
+	valid, _ := createTestListPack(t)
+	validContent := valid.Bytes()
+
+	// modify 返回修改后的拷贝
+	modify := func(f func(content []byte) []byte) []byte {
+		content := make([]byte, len(validContent))
+		copy(content, validContent)
+		return f(content)
+	}
+
+	tests := []struct {
+		name           string
+		content        []byte
+		wantShallowErr bool
+		wantDeepErr    bool
+	}{
+		{name: "valid", content: validContent},
+		{name: "empty listpack", content: InitListPack().Bytes()},
+		{name: "nil", content: nil, wantShallowErr: true, wantDeepErr: true},
+		{name: "too short", content: []byte{0x05, 0x00, 0x00, 0x00, 0xff}, wantShallowErr: true, wantDeepErr: true},
+		{
+			name: "wrong total length",
+			content: modify(func(content []byte) []byte {
+				binary.LittleEndian.PutUint32(content[:4], uint32(len(content)+1))
+				return content
+			}),
+			wantShallowErr: true, wantDeepErr: true,
+		},
+		{
+			name: "missing lpend",
+			content: modify(func(content []byte) []byte {
+				content[len(content)-1] = 0x00
+				return content
+			}),
+			wantShallowErr: true, wantDeepErr: true,
+		},
+		{
+			name: "wrong count",
+			content: modify(func(content []byte) []byte {
+				binary.LittleEndian.PutUint16(content[4:6], 11)
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "lpend in the middle",
+			content: modify(func(content []byte) []byte {
+				content[HeaderSize] = End
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "unknown encoding",
+			content: modify(func(content []byte) []byte {
+				content[HeaderSize] = 0xf5
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "wrong backlen",
+			content: modify(func(content []byte) []byte {
+				// 第一个 entry 是 0，backlen 为 1
+				content[HeaderSize+1] = 0x02
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "string length out of range",
+			content: func() []byte {
+				lp, _ := CreateListPack(&rs.RedisString{Content: make([]byte, 5000)})
+				content := append([]byte{}, lp.Bytes()...)
+				binary.LittleEndian.PutUint32(content[HeaderSize+1:], 0xfffffff0)
+				return content
+			}(),
+			wantDeepErr: true,
+		},
+		{
+			name: "truncated entry",
+			content: func() []byte {
+				content := []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0xf4, 0x01, 0x02, 0xff}
+				binary.LittleEndian.PutUint32(content[:4], uint32(len(content)))
+				return content
+			}(),
+			wantDeepErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIntegrity(tt.content, false)
+			if (err != nil) != tt.wantShallowErr {
+				t.Errorf("ValidateIntegrity(shallow) error = %v, wantErr %v", err, tt.wantShallowErr)
+			}
+			if err != nil && !errors.Is(err, CorruptedListPackErr) {
+				t.Errorf("ValidateIntegrity(shallow) error = %v, want %v", err, CorruptedListPackErr)
+			}
+
+			err = ValidateIntegrity(tt.content, true)
+			if (err != nil) != tt.wantDeepErr {
+				t.Errorf("ValidateIntegrity(deep) error = %v, wantErr %v", err, tt.wantDeepErr)
+			}
+			if err != nil && !errors.Is(err, CorruptedListPackErr) {
+				t.Errorf("ValidateIntegrity(deep) error = %v, want %v", err, CorruptedListPackErr)
+			}
+		})
+	}
+}
+
+func TestListPackFromBytes(t *testing.T) {
+	lp, elements := createTestListPack(t)
+	content := append([]byte{}, lp.Bytes()...)
+
+	got, err := ListPackFromBytes(content, true)
+	if err != nil {
+		t.Fatalf("ListPackFromBytes() error = %v", err)
+	}
+
+	// content 被拷贝，修改 content 不影响 listpack
+	content[HeaderSize] = 0xf5
+	checkListPack(t, got, elements)
+
+	if _, err := ListPackFromBytes(content, true); !errors.Is(err, CorruptedListPackErr) {
+		t.Fatalf("ListPackFromBytes() of corrupted content error = %v, want %v", err, CorruptedListPackErr)
+	}
+}
+
+// TestValidateIntegrity_RandomCorruption 随机修改合法 listpack 的字节，校验不能 panic，
+// 通过深度校验的数据必须可以安全地访问。
+func TestValidateIntegrity_RandomCorruption(t *testing.T) {
+	valid, _ := createTestListPack(t)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5000; i++ {
+		content := append([]byte{}, valid.Bytes()...)
+		if r.Intn(2) == 0 {
+			for j := 0; j <= r.Intn(3); j++ {
+				content[r.Intn(len(content))] = byte(r.Intn(256))
+			}
+		} else {
+			// 修改一个字节并修正计数
+			content[HeaderSize+r.Intn(len(content)-HeaderSize-1)] = byte(r.Intn(256))
+			binary.LittleEndian.PutUint16(content[4:6], uint16(r.Intn(13)))
+		}
+
+		_ = ValidateIntegrity(content, false)
+		if ValidateIntegrity(content, true) != nil {
+			continue
+		}
+
+		lp, _ := ListPackFromBytes(content, true)
+		count := 0
+		for c := lp.ListPackIndex(0); c != nil; c, _ = lp.ListPackNext(c) {
+			if _, err := lp.ListPackGet(c); err != nil {
+				t.Fatalf("ListPackGet() error = %v", err)
+			}
+			count++
+		}
+		for c := lp.ListPackIndex(-1); c != nil; c, _ = lp.ListPackPrev(c) {
+			count--
+		}
+		if count != 0 {
+			t.Fatalf("forward and backward iteration visited different number of entries")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package orderset
+
+import (
+	"sync"
+)
+
+// ConcurrentSkipList 是 SkipList 的并发安全版本，适用于多个 goroutine 读、一个 goroutine 写的场景。
+// 所有操作都在读写锁的保护下完成，每个操作要么完整可见，要么完全不可见，因此是线性一致的。
+// 注意：与 SkipList 不同，这里不会返回内部节点，避免调用方在锁外访问节点。
+
+type ConcurrentSkipList struct {
+	mu sync.RWMutex
+	sp *SkipList
+}
+
+func CreateConcurrentSkipList(scoreValPairs []*ScoreValPair) *ConcurrentSkipList {
+	return &ConcurrentSkipList{
+		sp: CreatSkipList(scoreValPairs),
+	}
+}
+
+func InitConcurrentSkipList() *ConcurrentSkipList {
+	return &ConcurrentSkipList{
+		sp: InitSkipList(),
+	}
+}
+
+// InsertNode inserts a new node in csp
+func (csp *ConcurrentSkipList) InsertNode(scoreValPair *ScoreValPair) error {
+	csp.mu.Lock()
+	defer csp.mu.Unlock()
+
+	_, err := csp.sp.InsertNode(scoreValPair)
+	return err
+}
+
+// DeleteNode deletes node with specific score and val
+func (csp *ConcurrentSkipList) DeleteNode(scoreValPair *ScoreValPair) bool {
+	csp.mu.Lock()
+	defer csp.mu.Unlock()
+
+	return csp.sp.DeleteNode(scoreValPair)
+}
+
+// UpdateNode updates node with specific score and val, the node is inserted if not exist
+func (csp *ConcurrentSkipList) UpdateNode(scoreValPair *ScoreValPair, newScore float64) error {
+	csp.mu.Lock()
+	defer csp.mu.Unlock()
+
+	_, err := csp.sp.UpdateNode(scoreValPair, newScore)
+	return err
+}
+
+// Length returns the number of elements in csp
+func (csp *ConcurrentSkipList) Length() int64 {
+	csp.mu.RLock()
+	defer csp.mu.RUnlock()
+
+	return csp.sp.Length()
+}
+
+// IsExist is used to judge whether scoreValPair exists in csp
+func (csp *ConcurrentSkipList) IsExist(scoreValPair *ScoreValPair) bool {
+	csp.mu.RLock()
+	defer csp.mu.RUnlock()
+
+	return csp.sp.IsExist(scoreValPair)
+}
+
+// GetAllScoreValPairs get all elements in csp
+func (csp *ConcurrentSkipList) GetAllScoreValPairs() []*ScoreValPair {
+	csp.mu.RLock()
+	defer csp.mu.RUnlock()
+
+	return csp.sp.GetAllScoreValPairs()
+}
+
+// GetRangeByRank gets elements whose index is in [start, end]
+func (csp *ConcurrentSkipList) GetRangeByRank(start, end int64) []*ScoreValPair {
+	csp.mu.RLock()
+	defer csp.mu.RUnlock()
+
+	return csp.sp.GetRangeByRank(start, end)
+}
+
+// GetRangeByScore gets elements whose score is in [min, max]
+func (csp *ConcurrentSkipList) GetRangeByScore(min, max float64) []*ScoreValPair {
+	csp.mu.RLock()
+	defer csp.mu.RUnlock()
+
+	return csp.sp.GetRangeByScore(min, max)
+}
+
+// MarshalBinary encodes csp into bytes
+func (csp *ConcurrentSkipList) MarshalBinary() ([]byte, error) {
+	csp.mu.RLock()
+	defer csp.mu.RUnlock()
+
+	return csp.sp.MarshalBinary()
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, the origin elements in csp are replaced.
+func (csp *ConcurrentSkipList) UnmarshalBinary(data []byte) error {
+	// 在锁外构建新的跳表，只在替换时持有写锁
+	sp := new(SkipList)
+	if err := sp.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	csp.mu.Lock()
+	defer csp.mu.Unlock()
+	csp.sp = sp
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package orderset
+
+import (
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+func newPair(score int) *ScoreValPair {
+	return &ScoreValPair{
+		Score: float64(score),
+		Val: redis_string.RedisString{
+			Content: []byte(strconv.Itoa(score)),
+		},
+	}
+}
+
+// checkScores 检查 pairs 的 score 是否恰好是 [first, first+len(pairs)) 内的连续整数
+func checkScores(pairs []*ScoreValPair, first int) bool {
+	for index, pair := range pairs {
+		if pair.Score != float64(first+index) || string(pair.Val.Content) != strconv.Itoa(first+index) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestConcurrentSkipList_Stress 一个 goroutine 按顺序插入再按顺序删除，多个 goroutine 并发读。
+// 由于每个操作都是线性一致的，读到的一定是一个连续的前缀(插入阶段)或后缀(删除阶段)，
+// 且同一个 reader 观察到的长度是单调的。使用 go test -race 运行。
+func TestConcurrentSkipList_Stress(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const (
+		total   = 1000
+		readers = 4
+	)
+
+	csp := InitConcurrentSkipList()
+
+	runPhase := func(name string, write func(i int), check func(pairs []*ScoreValPair, last int64) (int64, bool)) {
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for r := 0; r < readers; r++ {
+			wg.Add(1)
+			go func(r int) {
+				defer wg.Done()
+				last := int64(-1)
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+
+					pairs := csp.GetAllScoreValPairs()
+					cur, ok := check(pairs, last)
+					if !ok {
+						t.Errorf("%s: reader %d got inconsistent snapshot, last length: %d, pairs: %v", name, r, last, pairs)
+						return
+					}
+					last = cur
+
+					if length := csp.Length(); length < 0 || length > total {
+						t.Errorf("%s: reader %d got invalid length %d", name, r, length)
+						return
+					}
+
+					ranged := csp.GetRangeByScore(100, 199)
+					for _, pair := range ranged {
+						if pair.Score < 100 || pair.Score > 199 {
+							t.Errorf("%s: reader %d got %v out of range [100, 199]", name, r, pair)
+							return
+						}
+					}
+
+					if pairs := csp.GetRangeByRank(0, 9); len(pairs) > 10 {
+						t.Errorf("%s: reader %d got %d pairs by rank [0, 9]", name, r, len(pairs))
+						return
+					}
+				}
+			}(r)
+		}
+
+		for i := 1; i <= total; i++ {
+			write(i)
+		}
+		close(done)
+		wg.Wait()
+	}
+
+	runPhase("insert", func(i int) {
+		if err := csp.InsertNode(newPair(i)); err != nil {
+			t.Errorf("insert %d failed: %v", i, err)
+		}
+	}, func(pairs []*ScoreValPair, last int64) (int64, bool) {
+		cur := int64(len(pairs))
+		return cur, cur >= last && checkScores(pairs, 1)
+	})
+
+	if length := csp.Length(); length != total {
+		t.Fatalf("after inserting, length = %d, want %d", length, total)
+	}
+
+	runPhase("delete", func(i int) {
+		if !csp.DeleteNode(newPair(i)) {
+			t.Errorf("delete %d failed", i)
+		}
+	}, func(pairs []*ScoreValPair, last int64) (int64, bool) {
+		cur := int64(len(pairs))
+		return cur, (last < 0 || cur <= last) && checkScores(pairs, total-len(pairs)+1)
+	})
+
+	if length := csp.Length(); length != 0 {
+		t.Fatalf("after deleting, length = %d, want 0", length)
+	}
+}
+
+func TestConcurrentSkipList_UpdateNode(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	csp := CreateConcurrentSkipList([]*ScoreValPair{newPair(1), newPair(2), newPair(3)})
+
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if length := len(csp.GetAllScoreValPairs()); length != 3 {
+					t.Errorf("got %d pairs while updating, want 3", length)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		if err := csp.UpdateNode(&ScoreValPair{Score: float64(1 + i%2*10), Val: newPair(1).Val}, float64(1+(i+1)%2*10)); err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+	}
+	wg.Wait()
+
+	if !csp.IsExist(&ScoreValPair{Score: 1, Val: newPair(1).Val}) {
+		t.Fatalf("after updating, %v should exist, got %v", newPair(1), csp.GetAllScoreValPairs())
+	}
+}
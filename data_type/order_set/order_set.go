@@ -334,4 +334,108 @@ func cmpSkipListNode(src, dst *SkipListNode) int8 {
 	return cmp(&ScoreValPair{Score: src.score, Val: src.val}, &ScoreValPair{Score: dst.score, Val: dst.val})
 }
 
+// Length returns the number of elements in sp
+func (sp *SkipList) Length() int64 {
+	return sp.length
+}
+
+// IsExist is used to judge whether scoreValPair exists in sp
+func (sp *SkipList) IsExist(scoreValPair *ScoreValPair) bool {
+	return sp.isExist(scoreValPair) != nil
+}
+
+// GetRangeByRank gets elements whose index is in [start, end], index begins at 0 and negative
+// index counts from the tail, e.g. -1 is the last element.
+func (sp *SkipList) GetRangeByRank(start, end int64) []*ScoreValPair {
+	if start < 0 {
+		start += sp.length
+	}
+	if end < 0 {
+		end += sp.length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= sp.length {
+		end = sp.length - 1
+	}
+	if start > end || start >= sp.length {
+		return nil
+	}
+
+	ret := make([]*ScoreValPair, 0, end-start+1)
+	// rank 从 1 开始计数
+	curNode := sp.getNodeByRank(start + 1)
+	for i := start; i <= end; i++ {
+		ret = append(ret, copyScoreValPair(curNode))
+		curNode = curNode.indexes[0].forwardNode
+	}
+
+	return ret
+}
+
+// GetRangeByScore gets elements whose score is in [min, max]
+func (sp *SkipList) GetRangeByScore(min, max float64) []*ScoreValPair {
+	if min > max {
+		return nil
+	}
+
+	// 找到最后一个 score < min 的节点
+	curNode := sp.header
+	for curLevel := sp.level - 1; curLevel >= 0; curLevel-- {
+		for {
+			nextNode := curNode.indexes[curLevel].forwardNode
+			if nextNode == nil || nextNode.score >= min {
+				break
+			}
+			curNode = nextNode
+		}
+	}
+
+	var ret []*ScoreValPair
+	for curNode = curNode.indexes[0].forwardNode; curNode != nil && curNode.score <= max; curNode = curNode.indexes[0].forwardNode {
+		ret = append(ret, copyScoreValPair(curNode))
+	}
+
+	return ret
+}
+
+// getNodeByRank gets node with specific rank, rank begins at 1
+func (sp *SkipList) getNodeByRank(rank int64) *SkipListNode {
+	if rank <= 0 || rank > sp.length {
+		return nil
+	}
+
+	curNode := sp.header
+	traversed := int64(0)
+	for curLevel := sp.level - 1; curLevel >= 0; curLevel-- {
+		for {
+			index := curNode.indexes[curLevel]
+			if index.forwardNode == nil || traversed+index.span > rank {
+				break
+			}
+			traversed += index.span
+			curNode = index.forwardNode
+		}
+
+		if traversed == rank {
+			return curNode
+		}
+	}
+
+	return nil
+}
+
+func copyScoreValPair(node *SkipListNode) *ScoreValPair {
+	pair := &ScoreValPair{
+		Score: node.score,
+		Val: rs.RedisString{
+			Content: make([]byte, len(node.val.Content)),
+		},
+	}
+	copy(pair.Val.Content, node.val.Content)
+
+	return pair
+}
+
 // TODO: implement other useful apis about skiplist
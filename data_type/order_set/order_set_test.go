@@ -350,3 +350,64 @@ func printAllScoreValPairs(sp *SkipList, t *testing.T) {
 		t.Logf("	index:[%d], pair: {Score: %.f, Val: %s}\n", index, pair.Score, string(pair.Val.Content))
 	}
 }
+
+func TestSkipList_GetRangeByRank(t *testing.T) {
+	sp := CreatSkipList([]*ScoreValPair{newPair(3), newPair(1), newPair(5), newPair(2), newPair(4)})
+
+	tests := []struct {
+		name  string
+		start int64
+		end   int64
+		want  []float64
+	}{
+		{name: "all", start: 0, end: -1, want: []float64{1, 2, 3, 4, 5}},
+		{name: "middle", start: 1, end: 3, want: []float64{2, 3, 4}},
+		{name: "negative", start: -2, end: -1, want: []float64{4, 5}},
+		{name: "end out of range", start: 3, end: 100, want: []float64{4, 5}},
+		{name: "start out of range", start: 5, end: 6, want: nil},
+		{name: "start bigger than end", start: 3, end: 1, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sp.GetRangeByRank(tt.start, tt.end)
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetRangeByRank(%d, %d) got %d pairs, want %d", tt.start, tt.end, len(got), len(tt.want))
+			}
+			for index, pair := range got {
+				if pair.Score != tt.want[index] {
+					t.Errorf("GetRangeByRank(%d, %d)[%d] = %v, want score %v", tt.start, tt.end, index, pair, tt.want[index])
+				}
+			}
+		})
+	}
+}
+
+func TestSkipList_GetRangeByScore(t *testing.T) {
+	sp := CreatSkipList([]*ScoreValPair{newPair(3), newPair(1), newPair(5), newPair(2), newPair(4)})
+
+	tests := []struct {
+		name string
+		min  float64
+		max  float64
+		want []float64
+	}{
+		{name: "all", min: 0, max: 10, want: []float64{1, 2, 3, 4, 5}},
+		{name: "inclusive", min: 2, max: 4, want: []float64{2, 3, 4}},
+		{name: "fraction", min: 1.5, max: 3.5, want: []float64{2, 3}},
+		{name: "empty", min: 6, max: 10, want: nil},
+		{name: "min bigger than max", min: 4, max: 2, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sp.GetRangeByScore(tt.min, tt.max)
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetRangeByScore(%v, %v) got %d pairs, want %d", tt.min, tt.max, len(got), len(tt.want))
+			}
+			for index, pair := range got {
+				if pair.Score != tt.want[index] {
+					t.Errorf("GetRangeByScore(%v, %v)[%d] = %v, want score %v", tt.min, tt.max, index, pair, tt.want[index])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package orderset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+// skipList 的序列化格式如下，score 以大端形式存储:
+// ---------------------------------------------------------------------------------------
+// | 元素个数(uvarint) | score1(8Byte, IEEE754) | val1 长度(uvarint) | val1 | ... | valN |
+// ---------------------------------------------------------------------------------------
+// 元素按照 skipList 中的顺序存储，因此反序列化时可以直接按顺序构建，不需要查找插入位置。
+
+var (
+	NotSortedErr     = errors.New("score val pairs are not sorted")
+	CorruptedDataErr = errors.New("corrupted skiplist data")
+)
+
+// MarshalBinary encodes sp into bytes
+func (sp *SkipList) MarshalBinary() ([]byte, error) {
+	size := binary.MaxVarintLen64
+	for curNode := sp.header.indexes[0].forwardNode; curNode != nil; curNode = curNode.indexes[0].forwardNode {
+		size += 8 + binary.MaxVarintLen64 + len(curNode.val.Content)
+	}
+
+	data := make([]byte, size)
+	offset := binary.PutUvarint(data, uint64(sp.length))
+	for curNode := sp.header.indexes[0].forwardNode; curNode != nil; curNode = curNode.indexes[0].forwardNode {
+		binary.BigEndian.PutUint64(data[offset:], math.Float64bits(curNode.score))
+		offset += 8
+		offset += binary.PutUvarint(data[offset:], uint64(len(curNode.val.Content)))
+		offset += copy(data[offset:], curNode.val.Content)
+	}
+
+	return data[:offset], nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary, the origin elements in sp are replaced.
+func (sp *SkipList) UnmarshalBinary(data []byte) error {
+	pairs, err := decodeScoreValPairs(data)
+	if err != nil {
+		return err
+	}
+
+	newSp, err := CreateSkipListFromSorted(pairs)
+	if err != nil {
+		return err
+	}
+
+	*sp = *newSp
+	return nil
+}
+
+func decodeScoreValPairs(data []byte) ([]*ScoreValPair, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, CorruptedDataErr
+	}
+	data = data[n:]
+
+	// 每个元素至少占用 9 个字节，避免根据损坏的 count 分配过多内存
+	if count > uint64(len(data)/9) {
+		return nil, fmt.Errorf("%w: %d elements in %d bytes", CorruptedDataErr, count, len(data))
+	}
+
+	pairs := make([]*ScoreValPair, count)
+	for i := range pairs {
+		if len(data) < 8 {
+			return nil, CorruptedDataErr
+		}
+		score := math.Float64frombits(binary.BigEndian.Uint64(data))
+		data = data[8:]
+
+		valLen, n := binary.Uvarint(data)
+		if n <= 0 || valLen > uint64(len(data)-n) {
+			return nil, CorruptedDataErr
+		}
+		data = data[n:]
+
+		pairs[i] = &ScoreValPair{
+			Score: score,
+			Val: rs.RedisString{
+				Content: make([]byte, valLen),
+			},
+		}
+		copy(pairs[i].Val.Content, data[:valLen])
+		data = data[valLen:]
+	}
+
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", CorruptedDataErr, len(data))
+	}
+
+	return pairs, nil
+}
+
+// CreateSkipListFromSorted builds a skiplist from pairs which have been sorted in ascending order.
+// Every node is appended at the tail, so the whole building is O(n) instead of O(nlogn).
+func CreateSkipListFromSorted(scoreValPairs []*ScoreValPair) (*SkipList, error) {
+	sp := InitSkipList()
+
+	// 每一层的最后一个节点以及对应的 rank
+	lastNodes := make([]*SkipListNode, SkipListMaxLevel)
+	lastRanks := make([]int64, SkipListMaxLevel)
+	for i := range lastNodes {
+		lastNodes[i] = sp.header
+	}
+
+	for index, pair := range scoreValPairs {
+		if math.IsNaN(pair.Score) {
+			return nil, fmt.Errorf("%w: score of %s is NaN", NotSortedErr, string(pair.Val.Content))
+		}
+		if index > 0 && cmp(scoreValPairs[index-1], pair) >= 0 {
+			return nil, fmt.Errorf("%w: index %d", NotSortedErr, index)
+		}
+
+		newLevel := getRandomLevel()
+		if newLevel > sp.level {
+			sp.level = newLevel
+		}
+
+		newNode := createNewNode(newLevel, pair)
+		rank := int64(index + 1)
+		for curLevel := 0; curLevel < int(newLevel); curLevel++ {
+			lastNodes[curLevel].indexes[curLevel].forwardNode = newNode
+			lastNodes[curLevel].indexes[curLevel].span = rank - lastRanks[curLevel]
+			lastNodes[curLevel] = newNode
+			lastRanks[curLevel] = rank
+		}
+
+		if index > 0 {
+			newNode.backwardNode = sp.tail
+		}
+		sp.tail = newNode
+	}
+
+	sp.length = int64(len(scoreValPairs))
+
+	return sp, nil
+}
@@ -0,0 +1,154 @@
+package orderset
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+func TestSkipList_MarshalBinary(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name  string
+		pairs []*ScoreValPair
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "special scores",
+			pairs: []*ScoreValPair{
+				{Score: math.Inf(1), Val: redis_string.RedisString{Content: []byte("inf")}},
+				{Score: -0.5, Val: redis_string.RedisString{Content: []byte{}}},
+				{Score: 1e300, Val: redis_string.RedisString{Content: []byte("big")}},
+				{Score: math.Inf(-1), Val: redis_string.RedisString{Content: []byte("-inf")}},
+				{Score: 1, Val: redis_string.RedisString{Content: make([]byte, 300)}},
+			},
+		},
+		{
+			name:  "many",
+			pairs: []*ScoreValPair{newPair(5), newPair(3), newPair(4), newPair(1), newPair(2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp := CreatSkipList(tt.pairs)
+			data, err := sp.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() error = %v", err)
+			}
+
+			got := new(SkipList)
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary() error = %v", err)
+			}
+
+			want := sp.GetAllScoreValPairs()
+			gotPairs := got.GetAllScoreValPairs()
+			if len(gotPairs) != len(want) || got.Length() != sp.Length() {
+				t.Fatalf("UnmarshalBinary() got %d pairs, want %d", len(gotPairs), len(want))
+			}
+			for index := range want {
+				equal, _ := redis_string.StrCmp(&gotPairs[index].Val, &want[index].Val)
+				if equal != 0 || gotPairs[index].Score != want[index].Score {
+					t.Errorf("index: %d, got %v, want %v", index, gotPairs[index], want[index])
+				}
+			}
+		})
+	}
+}
+
+func TestSkipList_UnmarshalBinary_Corrupted(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	data, _ := CreatSkipList([]*ScoreValPair{newPair(1), newPair(2)}).MarshalBinary()
+	// 两个元素: {2, "2"}, {1, "1"}
+	unsorted := []byte{0x02,
+		0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, '2',
+		0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, '1',
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "nil", data: nil, wantErr: CorruptedDataErr},
+		{name: "truncated", data: data[:len(data)-1], wantErr: CorruptedDataErr},
+		{name: "trailing bytes", data: append(append([]byte{}, data...), 0x00), wantErr: CorruptedDataErr},
+		{name: "huge count", data: []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, wantErr: CorruptedDataErr},
+		{name: "unsorted", data: unsorted, wantErr: NotSortedErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp := InitSkipList()
+			if err := sp.UnmarshalBinary(tt.data); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnmarshalBinary() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateSkipListFromSorted(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const total = 500
+	pairs := make([]*ScoreValPair, total)
+	for i := range pairs {
+		pairs[i] = newPair(i * 2)
+	}
+
+	sp, err := CreateSkipListFromSorted(pairs)
+	if err != nil {
+		t.Fatalf("CreateSkipListFromSorted() error = %v", err)
+	}
+
+	// 通过 rank 查询校验每一层的 span
+	for i := 0; i < total; i++ {
+		got := sp.GetRangeByRank(int64(i), int64(i))
+		if len(got) != 1 || got[0].Score != float64(i*2) {
+			t.Fatalf("GetRangeByRank(%d, %d) = %v, want score %d", i, i, got, i*2)
+		}
+	}
+
+	// 构建后的跳表可以正常插入删除
+	if _, err := sp.InsertNode(newPair(1)); err != nil {
+		t.Fatalf("InsertNode() error = %v", err)
+	}
+	if got := sp.GetRangeByRank(1, 1); len(got) != 1 || got[0].Score != 1 {
+		t.Fatalf("after inserting, GetRangeByRank(1, 1) = %v, want score 1", got)
+	}
+	for i := 0; i < total; i += 3 {
+		if !sp.DeleteNode(newPair(i * 2)) {
+			t.Fatalf("DeleteNode(%d) failed", i*2)
+		}
+	}
+	all := sp.GetAllScoreValPairs()
+	if int64(len(all)) != sp.Length() {
+		t.Fatalf("got %d pairs, length is %d", len(all), sp.Length())
+	}
+	for index := 1; index < len(all); index++ {
+		if cmp(all[index-1], all[index]) >= 0 {
+			t.Fatalf("pairs are not sorted at index %d: %v, %v", index, all[index-1], all[index])
+		}
+	}
+	if sp.tail.score != all[len(all)-1].Score {
+		t.Fatalf("tail is %v, want %v", sp.tail.score, all[len(all)-1].Score)
+	}
+
+	if _, err := CreateSkipListFromSorted([]*ScoreValPair{newPair(2), newPair(1)}); !errors.Is(err, NotSortedErr) {
+		t.Fatalf("CreateSkipListFromSorted() with unsorted pairs error = %v, want %v", err, NotSortedErr)
+	}
+	if _, err := CreateSkipListFromSorted([]*ScoreValPair{newPair(1), newPair(1)}); !errors.Is(err, NotSortedErr) {
+		t.Fatalf("CreateSkipListFromSorted() with duplicate pairs error = %v, want %v", err, NotSortedErr)
+	}
+}
@@ -0,0 +1,151 @@
+package quicklist
+
+import (
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+)
+
+// Iterator 用于按顺序遍历 quicklist。遍历过程中可以通过 Delete 删除当前元素，之后 Next 会继续返回下一个元素；
+// Insert 之后迭代器结束遍历。除此之外，遍历过程中不能修改 quicklist。
+type Iterator struct {
+	ql      *QuickList
+	node    *quickListNode
+	offset  int              // 当前元素在节点中的下标
+	zl      *ziplist.ZipList // 用于读取的 node 的 ziplist，node 被压缩时是解压后的临时 ziplist
+	cursor  *ziplist.Cursor  // 当前元素
+	forward bool
+	// pending 表示 node 和 offset 指向的是下一次 Next 要返回的元素，cursor 需要重新定位
+	pending bool
+}
+
+// Iterator creates an iterator from the element with specific index, negative index counts from the
+// tail. Elements are visited from head to tail when forward is true, otherwise from tail to head.
+func (ql *QuickList) Iterator(index int, forward bool) *Iterator {
+	it := &Iterator{
+		ql:      ql,
+		forward: forward,
+		pending: true,
+	}
+
+	if index < 0 {
+		index += ql.count
+	}
+	if index >= 0 && index < ql.count {
+		it.node, it.offset = ql.locateNode(index)
+	}
+
+	return it
+}
+
+// Next moves it to the next element, false is returned when there are no more elements.
+func (it *Iterator) Next() bool {
+	if it.node == nil {
+		return false
+	}
+
+	if !it.pending {
+		var c *ziplist.Cursor
+		if it.forward {
+			c, _ = it.zl.ZipListNext(it.cursor)
+			it.offset++
+		} else {
+			c, _ = it.zl.ZipListPrev(it.cursor)
+			it.offset--
+		}
+		if c != nil {
+			it.cursor = c
+			return true
+		}
+	}
+
+	it.pending = false
+	for it.offset < 0 || it.offset >= it.node.getCount() {
+		if it.forward {
+			it.node = it.node.next
+			it.offset = 0
+		} else {
+			it.node = it.node.prev
+			if it.node != nil {
+				it.offset = it.node.getCount() - 1
+			}
+		}
+
+		if it.node == nil {
+			it.cursor = nil
+			return false
+		}
+	}
+	it.zl = it.node.readZipList()
+	it.cursor = it.zl.ZipListIndex(it.offset)
+
+	return true
+}
+
+// Value returns the current element, which is either int64 or *rs.RedisString.
+func (it *Iterator) Value() interface{} {
+	if it.node == nil || it.pending {
+		return nil
+	}
+
+	val, _ := it.zl.ZipListGet(it.cursor)
+	return val
+}
+
+// Equal reports whether the current element is equal to val, see ziplist.ZipListFind for how elements
+// are compared.
+func (it *Iterator) Equal(val interface{}) bool {
+	if it.node == nil || it.pending {
+		return false
+	}
+
+	equal, _ := it.zl.ZipListCompare(it.cursor, val)
+	return equal
+}
+
+// Delete deletes the current element, the next call of Next moves to the element after it.
+func (it *Iterator) Delete() error {
+	if it.node == nil || it.pending {
+		return IteratorInvalidErr
+	}
+
+	ql, node := it.ql, it.node
+	defer ql.finishUpdate()
+
+	zl := ql.zipList(node)
+	if _, err := zl.ZipListDeleteAt(zl.ZipListIndex(it.offset)); err != nil {
+		return err
+	}
+	ql.count--
+	it.zl, it.cursor = nil, nil
+	it.pending = true
+
+	// 从头向尾遍历时，下一个元素的下标不变
+	if !it.forward {
+		it.offset--
+	}
+
+	next, prev := node.next, node.prev
+	if ql.removeIfEmpty(node) {
+		if it.forward {
+			it.node, it.offset = next, 0
+		} else {
+			it.node = prev
+			if prev != nil {
+				it.offset = prev.getCount() - 1
+			}
+		}
+	}
+
+	return nil
+}
+
+// Insert inserts val before or after the current element, it stops iterating after that.
+func (it *Iterator) Insert(val interface{}, after bool) error {
+	if it.node == nil || it.pending {
+		return IteratorInvalidErr
+	}
+
+	err := it.ql.insertAt(it.node, it.offset, val, after)
+	it.node, it.zl, it.cursor = nil, nil, nil
+
+	return err
+}
@@ -0,0 +1,677 @@
+package quicklist
+
+import (
+	"errors"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+	"github.com/WANGgbin/tiny_redis/utils/lzf"
+)
+
+// quicklist 是由 ziplist 组成的双向链表，作为 list 类型的底层实现。
+// 单个 ziplist 过大时插入、删除的代价很高，而每个元素一个链表节点又浪费内存，quicklist 在两者之间折中：
+// 每个节点是一个大小受限的 ziplist，节点满了之后分裂，节点变小后与相邻节点合并。
+// ------------------------------------------------------
+// | head | <-> | node(ziplist) | <-> ... <-> | tail |
+// ------------------------------------------------------
+// 距离两端超过 compressDepth 个节点的 ziplist 使用 lzf 压缩。读取压缩的节点时解压到临时的 ziplist 中，
+// 修改时在原节点上解压，修改完成后由 finishUpdate 重新压缩。
+
+const (
+	// FillMax 是 fill 为正数时每个节点最多的元素个数
+	FillMax = 1<<15 - 1
+	// FillMin 是 fill 的最小值，表示每个节点最多 64KB
+	FillMin = -5
+	// DefaultFill 表示每个节点最多 8KB
+	DefaultFill = -2
+
+	// fill 为正数时，节点的字节数依然不能超过 sizeSafetyLimit，除非只有一个元素
+	sizeSafetyLimit = 8192
+
+	// CompressMax 是 compressDepth 的最大值
+	CompressMax = 1<<16 - 1
+	// 小于 minCompressBytes 的 ziplist 不压缩
+	minCompressBytes = 48
+	// 压缩至少节省 minCompressImprove 个字节时才使用压缩后的数据
+	minCompressImprove = 8
+)
+
+// optimizationLevel 是 fill 为负数时每个节点最多的字节数，fill 为 -1 时对应 4KB，以此类推
+var optimizationLevel = []int{4096, 8192, 16384, 32768, 65536}
+
+var (
+	IndexOutOfRangeErr = errors.New("index out of range")
+	IteratorInvalidErr = errors.New("iterator is invalidated")
+)
+
+type QuickList struct {
+	head   *quickListNode
+	tail   *quickListNode
+	count  int // 所有节点中元素的个数
+	length int // 节点的个数
+	// 正数表示每个节点最多的元素个数，负数表示每个节点最多的字节数，见 optimizationLevel
+	fill int
+	// 两端各 compressDepth 个节点不压缩，0 表示不压缩
+	compressDepth int
+	// uncompressed 是修改过程中修改的以及新创建的节点，修改完成后需要检查是否压缩
+	uncompressed []*quickListNode
+}
+
+type quickListNode struct {
+	prev *quickListNode
+	next *quickListNode
+	// zl 为 nil 时节点被压缩
+	zl         *ziplist.ZipList
+	compressed *compressedZipList
+}
+
+// compressedZipList 是 lzf 压缩后的 ziplist
+type compressedZipList struct {
+	data  []byte
+	size  int // 压缩前的字节数
+	count int
+}
+
+func (node *quickListNode) getCount() int {
+	if node.zl == nil {
+		return node.compressed.count
+	}
+	return node.zl.ZipListLen()
+}
+
+func (node *quickListNode) getSize() int {
+	if node.zl == nil {
+		return node.compressed.size
+	}
+	return len(node.zl.Bytes())
+}
+
+// compress compresses zl of node, node stays uncompressed when zl is too small or the compression
+// doesn't save enough memory.
+func (node *quickListNode) compress() {
+	if node.zl == nil {
+		return
+	}
+
+	content := node.zl.Bytes()
+	if len(content) < minCompressBytes {
+		return
+	}
+	out := make([]byte, len(content)-minCompressImprove)
+	n := lzf.Compress(content, out)
+	if n == 0 {
+		return
+	}
+
+	node.compressed = &compressedZipList{
+		data:  out[:n:n],
+		size:  len(content),
+		count: node.zl.ZipListLen(),
+	}
+	node.zl = nil
+}
+
+// decompress decompresses node in place.
+func (node *quickListNode) decompress() {
+	if node.zl == nil {
+		node.zl = node.readZipList()
+		node.compressed = nil
+	}
+}
+
+// readZipList returns zl of node for reading, a temporary ziplist is decompressed when node is
+// compressed and node stays unchanged.
+func (node *quickListNode) readZipList() *ziplist.ZipList {
+	if node.zl != nil {
+		return node.zl
+	}
+
+	content := make([]byte, node.compressed.size)
+	if _, err := lzf.Decompress(node.compressed.data, content); err != nil {
+		panic(err)
+	}
+	zl, err := ziplist.ZipListFromBytes(content, false)
+	if err != nil {
+		panic(err)
+	}
+	return zl
+}
+
+// CreateQuickList creates an empty quicklist, see QuickList.fill for fill.
+func CreateQuickList(fill int) *QuickList {
+	if fill > FillMax {
+		fill = FillMax
+	} else if fill < FillMin {
+		fill = FillMin
+	}
+
+	return &QuickList{
+		fill: fill,
+	}
+}
+
+func InitQuickList() *QuickList {
+	return CreateQuickList(DefaultFill)
+}
+
+// SetCompressDepth sets the number of nodes at each end that are not compressed, 0 disables compression.
+func (ql *QuickList) SetCompressDepth(depth int) {
+	if depth > CompressMax {
+		depth = CompressMax
+	} else if depth < 0 {
+		depth = 0
+	}
+	ql.compressDepth = depth
+
+	i := 0
+	for node := ql.head; node != nil; node = node.next {
+		if depth == 0 || i < depth || ql.length-1-i < depth {
+			node.decompress()
+		} else {
+			node.compress()
+		}
+		i++
+	}
+}
+
+// CompressDepth returns the compress depth of ql.
+func (ql *QuickList) CompressDepth() int {
+	return ql.compressDepth
+}
+
+// Len returns the number of elements in ql.
+func (ql *QuickList) Len() int {
+	return ql.count
+}
+
+// NodeLen returns the number of nodes in ql.
+func (ql *QuickList) NodeLen() int {
+	return ql.length
+}
+
+// Dup returns a copy of ql, compressed nodes are copied without decompressing.
+func (ql *QuickList) Dup() *QuickList {
+	dup := CreateQuickList(ql.fill)
+	dup.compressDepth = ql.compressDepth
+	for node := ql.head; node != nil; node = node.next {
+		n := &quickListNode{prev: dup.tail}
+		if node.zl != nil {
+			n.zl = node.zl.Dup()
+		} else {
+			n.compressed = &compressedZipList{
+				data:  append([]byte(nil), node.compressed.data...),
+				size:  node.compressed.size,
+				count: node.compressed.count,
+			}
+		}
+
+		if dup.tail != nil {
+			dup.tail.next = n
+		} else {
+			dup.head = n
+		}
+		dup.tail = n
+	}
+	dup.count = ql.count
+	dup.length = ql.length
+	return dup
+}
+
+// nodeOverhead 是 quickListNode 本身占用的字节数
+const nodeOverhead = 48
+
+// EstimateBytes estimates memory used by ql, only the first samples nodes are visited and the
+// others are assumed to have the same average size.
+func (ql *QuickList) EstimateBytes(samples int) int {
+	sampled, bytes := 0, 0
+	for node := ql.head; node != nil && sampled < samples; node = node.next {
+		if node.zl == nil {
+			bytes += len(node.compressed.data)
+		} else {
+			bytes += len(node.zl.Bytes())
+		}
+		sampled++
+	}
+	if sampled == 0 {
+		return 0
+	}
+
+	return bytes*ql.length/sampled + nodeOverhead*ql.length
+}
+
+// PushHead pushes val at the head of ql, val is int64, float64 or *rs.RedisString.
+func (ql *QuickList) PushHead(val interface{}) error {
+	defer ql.finishUpdate()
+
+	if !ql.nodeAllowInsert(ql.head, val) {
+		ql.insertNode(nil, ql.head, ziplist.InitZipList())
+	}
+
+	if _, err := ql.zipList(ql.head).ZipListInsert(nil, val); err != nil {
+		ql.removeIfEmpty(ql.head)
+		return err
+	}
+	ql.count++
+
+	return nil
+}
+
+// PushTail pushes val at the tail of ql, val is int64, float64 or *rs.RedisString.
+func (ql *QuickList) PushTail(val interface{}) error {
+	defer ql.finishUpdate()
+
+	if !ql.nodeAllowInsert(ql.tail, val) {
+		ql.insertNode(ql.tail, nil, ziplist.InitZipList())
+	}
+
+	if err := ql.zipList(ql.tail).ZipListPush(val); err != nil {
+		ql.removeIfEmpty(ql.tail)
+		return err
+	}
+	ql.count++
+
+	return nil
+}
+
+// AppendZipList appends zl as a new node at the tail of ql without copying, zl must not be used
+// afterwards. Like redis, the node is not split even if it exceeds fill.
+func (ql *QuickList) AppendZipList(zl *ziplist.ZipList) {
+	count := zl.ZipListLen()
+	if count == 0 {
+		return
+	}
+
+	defer ql.finishUpdate()
+	ql.insertNode(ql.tail, nil, zl)
+	ql.count += count
+}
+
+// PopHead pops the first element of ql, false is returned when ql is empty.
+func (ql *QuickList) PopHead() (interface{}, bool) {
+	if ql.head == nil {
+		return nil, false
+	}
+
+	return ql.popAt(ql.head, 0), true
+}
+
+// PopTail pops the last element of ql, false is returned when ql is empty.
+func (ql *QuickList) PopTail() (interface{}, bool) {
+	if ql.tail == nil {
+		return nil, false
+	}
+
+	return ql.popAt(ql.tail, -1), true
+}
+
+func (ql *QuickList) popAt(node *quickListNode, index int) interface{} {
+	defer ql.finishUpdate()
+
+	zl := ql.zipList(node)
+	c := zl.ZipListIndex(index)
+	val, _ := zl.ZipListGet(c)
+	_, _ = zl.ZipListDeleteAt(c)
+	ql.count--
+
+	if !ql.removeIfEmpty(node) {
+		ql.mergeAround(node)
+	}
+
+	return val
+}
+
+// Index gets the element with specific index, negative index counts from the tail, e.g. -1 is the
+// last element. False is returned when index is out of range.
+func (ql *QuickList) Index(index int) (interface{}, bool) {
+	node, offset := ql.locate(index)
+	if node == nil {
+		return nil, false
+	}
+
+	zl := node.readZipList()
+	val, _ := zl.ZipListGet(zl.ZipListIndex(offset))
+	return val, true
+}
+
+// Replace replaces the element with specific index, see Index for index.
+func (ql *QuickList) Replace(index int, val interface{}) error {
+	node, offset := ql.locate(index)
+	if node == nil {
+		return IndexOutOfRangeErr
+	}
+
+	defer ql.finishUpdate()
+	zl := ql.zipList(node)
+	_, err := zl.ZipListReplace(zl.ZipListIndex(offset), val)
+	return err
+}
+
+// DeleteRange deletes count elements from the element with specific index, negative index counts
+// from the tail. The number of deleted elements is returned.
+func (ql *QuickList) DeleteRange(index int, count int) int {
+	if index < 0 {
+		index += ql.count
+	}
+	if index < 0 || index >= ql.count || count <= 0 {
+		return 0
+	}
+	if count > ql.count-index {
+		count = ql.count - index
+	}
+
+	defer ql.finishUpdate()
+
+	node, offset := ql.locateNode(index)
+	deleted := 0
+	// 只有删除区间两端的节点可能被部分删除，删除结束后再尝试与相邻节点合并
+	var survivors []*quickListNode
+	for deleted < count {
+		next := node.next
+		n, _ := ql.zipList(node).ZipListDeleteRange(offset, count-deleted)
+		deleted += n
+		ql.count -= n
+
+		if !ql.removeIfEmpty(node) {
+			survivors = append(survivors, node)
+		}
+
+		node = next
+		offset = 0
+	}
+
+	// mergeAround 只会移除参数节点及其之后的节点，所以从后向前合并
+	for i := len(survivors) - 1; i >= 0; i-- {
+		ql.mergeAround(survivors[i])
+	}
+
+	return deleted
+}
+
+// locate gets the node which contains the element with specific index and offset of the element
+// in the node, nil is returned when index is out of range.
+func (ql *QuickList) locate(index int) (*quickListNode, int) {
+	if index < 0 {
+		index += ql.count
+	}
+	if index < 0 || index >= ql.count {
+		return nil, 0
+	}
+
+	return ql.locateNode(index)
+}
+
+// locateNode gets the node which contains the element with specific index and offset of the element
+// in the node, index must be in range. Nodes are walked from the nearer end.
+func (ql *QuickList) locateNode(index int) (*quickListNode, int) {
+	if index < ql.count/2 {
+		node := ql.head
+		for index >= node.getCount() {
+			index -= node.getCount()
+			node = node.next
+		}
+		return node, index
+	}
+
+	index = ql.count - 1 - index
+	node := ql.tail
+	for index >= node.getCount() {
+		index -= node.getCount()
+		node = node.prev
+	}
+	return node, node.getCount() - 1 - index
+}
+
+// insertAt inserts val before or after the element with specific offset in node.
+func (ql *QuickList) insertAt(node *quickListNode, offset int, val interface{}, after bool) error {
+	defer ql.finishUpdate()
+
+	atTail := after && offset == node.getCount()-1
+	atHead := !after && offset == 0
+
+	var err error
+	switch {
+	case ql.nodeAllowInsert(node, val):
+		zl := ql.zipList(node)
+		c := zl.ZipListIndex(offset)
+		if !after {
+			c, _ = zl.ZipListPrev(c)
+		}
+		_, err = zl.ZipListInsert(c, val)
+	case atTail && ql.nodeAllowInsert(node.next, val):
+		_, err = ql.zipList(node.next).ZipListInsert(nil, val)
+	case atHead && ql.nodeAllowInsert(node.prev, val):
+		err = ql.zipList(node.prev).ZipListPush(val)
+	case atTail || atHead:
+		// 相邻节点也满了，在两者之间创建一个新的节点
+		zl := ziplist.InitZipList()
+		if err = zl.ZipListPush(val); err != nil {
+			return err
+		}
+		if atTail {
+			ql.insertNode(node, node.next, zl)
+		} else {
+			ql.insertNode(node.prev, node, zl)
+		}
+	default:
+		// 在中间插入，将节点从插入位置分裂为两个，新元素放在前一个节点的尾部
+		splitAt := offset
+		if after {
+			splitAt++
+		}
+		if err = ql.splitNode(node, splitAt); err != nil {
+			return err
+		}
+		if err = ql.zipList(node).ZipListPush(val); err != nil {
+			return err
+		}
+		ql.count++
+		ql.mergeAround(node.next)
+		ql.mergeAround(node)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ql.count++
+	return nil
+}
+
+// splitNode moves elements from offset to the end of node to a new node after node.
+func (ql *QuickList) splitNode(node *quickListNode, offset int) error {
+	nodeZl := ql.zipList(node)
+	zl, err := ziplist.ZipListFromBytes(nodeZl.Bytes(), false)
+	if err != nil {
+		return err
+	}
+	if _, err = zl.ZipListDeleteRange(0, offset); err != nil {
+		return err
+	}
+	if _, err = nodeZl.ZipListDeleteRange(offset, node.getCount()-offset); err != nil {
+		return err
+	}
+
+	ql.insertNode(node, node.next, zl)
+	return nil
+}
+
+// mergeAround merges node with its previous and next node if the merged node doesn't exceed the limit.
+func (ql *QuickList) mergeAround(node *quickListNode) {
+	if node.prev != nil && ql.allowMerge(node.prev, node) {
+		node = node.prev
+		ql.mergeNext(node)
+	}
+	if node.next != nil && ql.allowMerge(node, node.next) {
+		ql.mergeNext(node)
+	}
+}
+
+// mergeNext moves all elements of node.next to node and removes node.next.
+func (ql *QuickList) mergeNext(node *quickListNode) {
+	zl, nextZl := ql.zipList(node), node.next.readZipList()
+	for c := nextZl.ZipListIndex(0); c != nil; c, _ = nextZl.ZipListNext(c) {
+		val, _ := nextZl.ZipListGet(c)
+		_ = zl.ZipListPush(val)
+	}
+
+	next := node.next
+
+	ql.removeNode(next)
+}
+
+func (ql *QuickList) allowMerge(a, b *quickListNode) bool {
+	// 合并后只有一个 ziplist 首部和尾部
+	return !ql.exceedLimit(a.getSize()+b.getSize()-zlOverhead, a.getCount()+b.getCount())
+}
+
+// zlOverhead 是空 ziplist 的字节数
+var zlOverhead = len(ziplist.InitZipList().Bytes())
+
+func (ql *QuickList) nodeAllowInsert(node *quickListNode, val interface{}) bool {
+	if node == nil {
+		return false
+	}
+
+	return !ql.exceedLimit(node.getSize()+estimateEntrySize(val), node.getCount()+1)
+}
+
+func (ql *QuickList) exceedLimit(size int, count int) bool {
+	// 节点至少包含一个元素
+	if count <= 1 {
+		return false
+	}
+
+	if ql.fill >= 0 {
+		return count > ql.fill || size > sizeSafetyLimit
+	}
+
+	return size > optimizationLevel[-ql.fill-1]
+}
+
+// estimateEntrySize estimates the number of bytes val takes in ziplist, which is no less than the
+// actual size.
+func estimateEntrySize(val interface{}) int {
+	// prev 字段的最大长度
+	size := 5
+	switch val := val.(type) {
+	case *rs.RedisString:
+		if val != nil {
+			size += 5 + len(val.Content)
+		}
+	case float64:
+		// 非整数的 float64 以字符串形式存储，最长为 24 个字节
+		size += 1 + 24
+	default:
+		size += 9
+	}
+
+	return size
+}
+
+// insertNode inserts a node with zl between prev and next, prev or next is nil when inserting at
+// the head or tail.
+func (ql *QuickList) insertNode(prev, next *quickListNode, zl *ziplist.ZipList) *quickListNode {
+	node := &quickListNode{
+		prev: prev,
+		next: next,
+		zl:   zl,
+	}
+
+	if prev != nil {
+		prev.next = node
+	} else {
+		ql.head = node
+	}
+	if next != nil {
+		next.prev = node
+	} else {
+		ql.tail = node
+	}
+	ql.length++
+	if ql.compressDepth > 0 {
+		ql.uncompressed = append(ql.uncompressed, node)
+	}
+
+	return node
+}
+
+func (ql *QuickList) removeNode(node *quickListNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		ql.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		ql.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+	ql.length--
+}
+
+func (ql *QuickList) removeIfEmpty(node *quickListNode) bool {
+	if node.getCount() != 0 {
+		return false
+	}
+
+	ql.removeNode(node)
+	return true
+}
+
+// zipList returns zl of node for modifying, node is decompressed in place if it's compressed.
+func (ql *QuickList) zipList(node *quickListNode) *ziplist.ZipList {
+	if ql.compressDepth > 0 {
+		// 没有被压缩的节点修改后也可能变得可以压缩
+		node.decompress()
+		ql.uncompressed = append(ql.uncompressed, node)
+	}
+	return node.zl
+}
+
+// finishUpdate compresses nodes decompressed or created during a modification if they are out of
+// the compress depth, and makes sure nodes within the compress depth are uncompressed.
+func (ql *QuickList) finishUpdate() {
+	if ql.compressDepth == 0 {
+		return
+	}
+
+	for _, node := range ql.uncompressed {
+		// 跳过已经被移除的节点
+		if node == ql.head || node.prev != nil {
+			ql.compress(node)
+		}
+	}
+	ql.uncompressed = ql.uncompressed[:0]
+	ql.compress(nil)
+}
+
+// compress decompresses nodes within the compress depth and compresses the first node out of the
+// depth at each end, which may be moved out of the depth by modifications. node is compressed
+// too if it's out of the depth.
+func (ql *QuickList) compress(node *quickListNode) {
+	if ql.head == nil {
+		return
+	}
+
+	forward, reverse := ql.head, ql.tail
+	inDepth := false
+	for depth := 0; depth < ql.compressDepth; depth++ {
+		forward.decompress()
+		reverse.decompress()
+		if forward == node || reverse == node {
+			inDepth = true
+		}
+		// 所有的节点都在 compressDepth 之内
+		if forward == reverse || forward.next == reverse {
+			return
+		}
+		forward, reverse = forward.next, reverse.prev
+	}
+
+	if node != nil && !inDepth {
+		node.compress()
+	}
+	forward.compress()
+	reverse.compress()
+}
@@ -0,0 +1,553 @@
+package quicklist
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+	"github.com/WANGgbin/tiny_redis/utils/lzf"
+)
+
+func str(s string) *rs.RedisString {
+	return &rs.RedisString{Content: []byte(s)}
+}
+
+// checkQuickList 检查 ql 的结构以及遍历得到的元素是否与 want 一致
+func checkQuickList(t *testing.T, ql *QuickList, want []interface{}) {
+	t.Helper()
+
+	if ql.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", ql.Len(), len(want))
+	}
+
+	count, length := 0, 0
+	var prev *quickListNode
+	for node := ql.head; node != nil; node = node.next {
+		if node.prev != prev {
+			t.Fatalf("prev of node %d is wrong", length)
+		}
+		if node.getCount() == 0 {
+			t.Fatalf("node %d is empty", length)
+		}
+		if ql.exceedLimit(node.getSize(), node.getCount()) {
+			t.Fatalf("node %d with %d elements and %d bytes exceeds the limit of fill %d", length, node.getCount(), node.getSize(), ql.fill)
+		}
+		checkCompressed(t, ql, node, length)
+		count += node.getCount()
+		length++
+		prev = node
+	}
+	if ql.tail != prev {
+		t.Fatalf("tail is wrong")
+	}
+	if count != ql.count || length != ql.length {
+		t.Fatalf("got %d elements in %d nodes, but count = %d, length = %d", count, length, ql.count, ql.length)
+	}
+
+	var got []interface{}
+	for it := ql.Iterator(0, true); it.Next(); {
+		got = append(got, it.Value())
+	}
+	if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+		t.Fatalf("forward iteration = %v, want %v", got, want)
+	}
+
+	index := len(want) - 1
+	for it := ql.Iterator(-1, false); it.Next(); index-- {
+		if index < 0 || !reflect.DeepEqual(it.Value(), want[index]) {
+			t.Fatalf("backward index %d = %v, want %v", index, it.Value(), want)
+		}
+	}
+	if index != -1 {
+		t.Fatalf("backward iteration stopped at index %d, want -1", index)
+	}
+}
+
+// checkCompressed 检查节点两端 compressDepth 之内的节点没有被压缩，其余可以压缩的节点都被压缩
+func checkCompressed(t *testing.T, ql *QuickList, node *quickListNode, index int) {
+	t.Helper()
+
+	if len(ql.uncompressed) != 0 {
+		t.Fatalf("uncompressed nodes are not handled")
+	}
+	inDepth := ql.compressDepth == 0 || index < ql.compressDepth || ql.length-1-index < ql.compressDepth
+	if inDepth {
+		if node.zl == nil {
+			t.Fatalf("node %d within compress depth %d is compressed", index, ql.compressDepth)
+		}
+		return
+	}
+
+	if node.zl != nil {
+		content := node.zl.Bytes()
+		if len(content) >= minCompressBytes && lzf.Compress(content, make([]byte, len(content)-minCompressImprove)) != 0 {
+			t.Fatalf("node %d out of compress depth %d is not compressed", index, ql.compressDepth)
+		}
+	}
+}
+
+func TestCreateQuickList(t *testing.T) {
+	tests := []struct {
+		fill int
+		want int
+	}{
+		{fill: 128, want: 128},
+		{fill: FillMax + 1, want: FillMax},
+		{fill: -2, want: -2},
+		{fill: -10, want: FillMin},
+	}
+	for _, tt := range tests {
+		if got := CreateQuickList(tt.fill).fill; got != tt.want {
+			t.Errorf("CreateQuickList(%d).fill = %d, want %d", tt.fill, got, tt.want)
+		}
+	}
+}
+
+func TestQuickList_Push(t *testing.T) {
+	tests := []struct {
+		name      string
+		fill      int
+		val       interface{}
+		total     int
+		wantNodes int
+	}{
+		{name: "count limit", fill: 4, val: int64(1), total: 10, wantNodes: 3},
+		{name: "size safety limit", fill: 100, val: &rs.RedisString{Content: make([]byte, 1000)}, total: 20, wantNodes: 3},
+		{name: "size limit", fill: -1, val: &rs.RedisString{Content: make([]byte, 1000)}, total: 8, wantNodes: 2},
+		{name: "large element", fill: -1, val: &rs.RedisString{Content: make([]byte, 10000)}, total: 3, wantNodes: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, head := range []bool{true, false} {
+				ql := CreateQuickList(tt.fill)
+				var want []interface{}
+				for i := 0; i < tt.total; i++ {
+					var err error
+					if head {
+						err = ql.PushHead(tt.val)
+					} else {
+						err = ql.PushTail(tt.val)
+					}
+					if err != nil {
+						t.Fatalf("push error = %v", err)
+					}
+					want = append(want, tt.val)
+				}
+				if ql.NodeLen() != tt.wantNodes {
+					t.Errorf("NodeLen() = %d, want %d", ql.NodeLen(), tt.wantNodes)
+				}
+				checkQuickList(t, ql, want)
+			}
+		})
+	}
+
+	ql := InitQuickList()
+	if err := ql.PushHead(nil); err == nil {
+		t.Errorf("PushHead(nil) should fail")
+	}
+	checkQuickList(t, ql, nil)
+}
+
+func TestQuickList_Pop(t *testing.T) {
+	ql := CreateQuickList(3)
+	var want []interface{}
+	for i := 0; i < 10; i++ {
+		_ = ql.PushTail(int64(i))
+		want = append(want, int64(i))
+	}
+
+	val, ok := ql.PopHead()
+	if !ok || val != int64(0) {
+		t.Fatalf("PopHead() = %v, %v, want 0, true", val, ok)
+	}
+	val, ok = ql.PopTail()
+	if !ok || val != int64(9) {
+		t.Fatalf("PopTail() = %v, %v, want 9, true", val, ok)
+	}
+	checkQuickList(t, ql, want[1:9])
+
+	for ql.Len() > 0 {
+		ql.PopTail()
+	}
+	if _, ok = ql.PopHead(); ok {
+		t.Fatalf("PopHead() of empty quicklist should fail")
+	}
+	if _, ok = ql.PopTail(); ok {
+		t.Fatalf("PopTail() of empty quicklist should fail")
+	}
+	checkQuickList(t, ql, nil)
+}
+
+func TestQuickList_IndexAndReplace(t *testing.T) {
+	ql := CreateQuickList(3)
+	var want []interface{}
+	for i := 0; i < 10; i++ {
+		_ = ql.PushTail(int64(i))
+		want = append(want, int64(i))
+	}
+
+	for index := -11; index <= 10; index++ {
+		got, ok := ql.Index(index)
+		wantIndex := index
+		if index < 0 {
+			wantIndex += len(want)
+		}
+		if wantIndex < 0 || wantIndex >= len(want) {
+			if ok {
+				t.Errorf("Index(%d) = %v, want out of range", index, got)
+			}
+			continue
+		}
+		if !ok || got != want[wantIndex] {
+			t.Errorf("Index(%d) = %v, %v, want %v", index, got, ok, want[wantIndex])
+		}
+	}
+
+	if err := ql.Replace(-2, str("eight")); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	want[8] = str("eight")
+	if err := ql.Replace(10, int64(1)); err != IndexOutOfRangeErr {
+		t.Fatalf("Replace() out of range error = %v, want %v", err, IndexOutOfRangeErr)
+	}
+	checkQuickList(t, ql, want)
+}
+
+func TestQuickList_DeleteRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		count int
+		want  int
+	}{
+		{name: "in one node", index: 1, count: 1, want: 1},
+		{name: "across nodes", index: 2, count: 6, want: 6},
+		{name: "negative index", index: -4, count: 2, want: 2},
+		{name: "beyond the end", index: 7, count: 10, want: 3},
+		{name: "all", index: 0, count: 10, want: 10},
+		{name: "out of range", index: 10, count: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ql := CreateQuickList(3)
+			var want []interface{}
+			for i := 0; i < 10; i++ {
+				_ = ql.PushTail(int64(i))
+				want = append(want, int64(i))
+			}
+
+			if got := ql.DeleteRange(tt.index, tt.count); got != tt.want {
+				t.Fatalf("DeleteRange() = %d, want %d", got, tt.want)
+			}
+			index := tt.index
+			if index < 0 {
+				index += len(want)
+			}
+			if tt.want > 0 {
+				want = append(want[:index], want[index+tt.want:]...)
+			}
+			checkQuickList(t, ql, want)
+		})
+	}
+}
+
+func TestQuickList_Merge(t *testing.T) {
+	ql := CreateQuickList(4)
+	for i := 0; i < 12; i++ {
+		_ = ql.PushTail(int64(i))
+	}
+	if ql.NodeLen() != 3 {
+		t.Fatalf("NodeLen() = %d, want 3", ql.NodeLen())
+	}
+
+	// 删除后相邻的两个节点可以合并为一个
+	ql.DeleteRange(2, 4)
+	if ql.NodeLen() != 2 {
+		t.Fatalf("after deleting, NodeLen() = %d, want 2", ql.NodeLen())
+	}
+	checkQuickList(t, ql, []interface{}{int64(0), int64(1), int64(6), int64(7), int64(8), int64(9), int64(10), int64(11)})
+}
+
+func TestQuickList_Compress(t *testing.T) {
+	ql := CreateQuickList(4)
+	ql.SetCompressDepth(1)
+	var want []interface{}
+	for i := 0; i < 400; i++ {
+		val := str(strings.Repeat("activity feed entry ", 5) + strconv.Itoa(i))
+		_ = ql.PushTail(val)
+		want = append(want, val)
+	}
+	checkQuickList(t, ql, want)
+
+	compressed, rawSize, size := 0, 0, 0
+	for node := ql.head; node != nil; node = node.next {
+		rawSize += node.getSize()
+		if node.zl == nil {
+			compressed++
+			size += len(node.compressed.data)
+		} else {
+			size += node.getSize()
+		}
+	}
+	if compressed != ql.NodeLen()-2 {
+		t.Fatalf("%d of %d nodes are compressed, want %d", compressed, ql.NodeLen(), ql.NodeLen()-2)
+	}
+	if size*2 > rawSize {
+		t.Fatalf("compressed size = %d, raw size = %d", size, rawSize)
+	}
+
+	// 读取不会解压节点
+	node, _ := ql.locate(200)
+	if val, _ := ql.Index(200); !reflect.DeepEqual(val, want[200]) {
+		t.Fatalf("Index(200) = %v, want %v", val, want[200])
+	}
+	if node.zl != nil {
+		t.Fatalf("node should stay compressed after reading")
+	}
+
+	// 修改后重新压缩
+	if err := ql.Replace(200, int64(200)); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	want[200] = int64(200)
+	if node.zl != nil {
+		t.Fatalf("node should be compressed after modifying")
+	}
+	checkQuickList(t, ql, want)
+
+	// 修改 compressDepth 后重新压缩所有节点
+	ql.SetCompressDepth(3)
+	checkQuickList(t, ql, want)
+	ql.SetCompressDepth(0)
+	for node := ql.head; node != nil; node = node.next {
+		if node.zl == nil {
+			t.Fatalf("nodes should be decompressed when compression is disabled")
+		}
+	}
+	checkQuickList(t, ql, want)
+}
+
+func TestQuickList_Dup(t *testing.T) {
+	ql := CreateQuickList(4)
+	ql.SetCompressDepth(1)
+	var want []interface{}
+	for i := 0; i < 100; i++ {
+		val := str(strings.Repeat("activity feed entry ", 5) + strconv.Itoa(i))
+		_ = ql.PushTail(val)
+		want = append(want, val)
+	}
+
+	dup := ql.Dup()
+	checkQuickList(t, dup, want)
+
+	// 修改拷贝不影响原来的 quicklist
+	if err := dup.Replace(50, int64(50)); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	dup.PopHead()
+	checkQuickList(t, ql, want)
+}
+
+func TestQuickList_AppendZipList(t *testing.T) {
+	ql := CreateQuickList(4)
+	ql.SetCompressDepth(1)
+	var want []interface{}
+	for i := 0; i < 5; i++ {
+		var elements []interface{}
+		for j := 0; j < 4; j++ {
+			elements = append(elements, str(strings.Repeat("activity feed entry ", 5)+strconv.Itoa(i*4+j)))
+		}
+		zl, err := ziplist.CreateZipList(elements...)
+		if err != nil {
+			t.Fatalf("CreateZipList() error = %v", err)
+		}
+		ql.AppendZipList(zl)
+		want = append(want, elements...)
+	}
+	// 空的 ziplist 不会添加节点
+	ql.AppendZipList(ziplist.InitZipList())
+	if ql.NodeLen() != 5 {
+		t.Fatalf("NodeLen() = %d, want 5", ql.NodeLen())
+	}
+	checkQuickList(t, ql, want)
+
+	_ = ql.PushTail(int64(100))
+	_ = ql.PushHead(int64(-1))
+	want = append([]interface{}{int64(-1)}, append(want, int64(100))...)
+	checkQuickList(t, ql, want)
+}
+
+func TestIterator_Delete(t *testing.T) {
+	for _, forward := range []bool{true, false} {
+		ql := CreateQuickList(3)
+		var want []interface{}
+		for i := 0; i < 20; i++ {
+			_ = ql.PushTail(int64(i % 4))
+			if i%4 != 1 && i%4 != 2 {
+				want = append(want, int64(i%4))
+			}
+		}
+
+		start := 0
+		if !forward {
+			start = -1
+		}
+		visited := 0
+		for it := ql.Iterator(start, forward); it.Next(); visited++ {
+			if it.Equal(int64(1)) || it.Equal(str("2")) {
+				if err := it.Delete(); err != nil {
+					t.Fatalf("Delete() error = %v", err)
+				}
+				if err := it.Delete(); err != IteratorInvalidErr {
+					t.Fatalf("Delete() twice error = %v, want %v", err, IteratorInvalidErr)
+				}
+			}
+		}
+		if visited != 20 {
+			t.Fatalf("forward: %v, visited %d elements, want 20", forward, visited)
+		}
+		checkQuickList(t, ql, want)
+	}
+}
+
+func TestIterator_Insert(t *testing.T) {
+	tests := []struct {
+		name  string
+		fill  int
+		index int
+		after bool
+	}{
+		{name: "before head", fill: 3, index: 0},
+		{name: "after tail", fill: 3, index: 8, after: true},
+		{name: "after the last of a node", fill: 3, index: 2, after: true},
+		{name: "before the first of a node", fill: 3, index: 3},
+		{name: "split before", fill: 3, index: 4},
+		{name: "split after", fill: 3, index: 4, after: true},
+		{name: "node not full", fill: 4, index: 4, after: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ql := CreateQuickList(tt.fill)
+			var want []interface{}
+			for i := 0; i < 9; i++ {
+				_ = ql.PushTail(int64(i))
+				want = append(want, int64(i))
+			}
+
+			it := ql.Iterator(tt.index, true)
+			if !it.Next() {
+				t.Fatalf("Next() = false")
+			}
+			if err := it.Insert(str("new"), tt.after); err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+			if it.Next() {
+				t.Fatalf("Next() after Insert() should return false")
+			}
+
+			index := tt.index
+			if tt.after {
+				index++
+			}
+			want = append(want[:index], append([]interface{}{str("new")}, want[index:]...)...)
+			checkQuickList(t, ql, want)
+		})
+	}
+}
+
+func TestQuickList_RandomOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomVal := func() interface{} {
+		switch r.Intn(3) {
+		case 0:
+			return int64(r.Intn(10))
+		case 1:
+			return &rs.RedisString{Content: make([]byte, r.Intn(600))}
+		default:
+			return str("abc")
+		}
+	}
+
+	for _, cfg := range []struct{ fill, depth int }{
+		{fill: 1}, {fill: 5}, {fill: -1},
+		{fill: 1, depth: 1}, {fill: 5, depth: 2}, {fill: -1, depth: 3},
+	} {
+		ql := CreateQuickList(cfg.fill)
+		ql.SetCompressDepth(cfg.depth)
+		var model []interface{}
+		for i := 0; i < 3000; i++ {
+			switch op := r.Intn(8); {
+			case op == 0 || len(model) == 0:
+				val := randomVal()
+				_ = ql.PushHead(val)
+				model = append([]interface{}{val}, model...)
+			case op == 1:
+				val := randomVal()
+				_ = ql.PushTail(val)
+				model = append(model, val)
+			case op == 2:
+				val, _ := ql.PopHead()
+				if !reflect.DeepEqual(val, model[0]) {
+					t.Fatalf("PopHead() = %v, want %v", val, model[0])
+				}
+				model = model[1:]
+			case op == 3:
+				val, _ := ql.PopTail()
+				if !reflect.DeepEqual(val, model[len(model)-1]) {
+					t.Fatalf("PopTail() = %v, want %v", val, model[len(model)-1])
+				}
+				model = model[:len(model)-1]
+			case op == 4:
+				index := r.Intn(len(model))
+				val := randomVal()
+				if err := ql.Replace(index, val); err != nil {
+					t.Fatalf("Replace() error = %v", err)
+				}
+				model[index] = val
+			case op == 5:
+				index, count := r.Intn(len(model)), r.Intn(8)
+				deleted := ql.DeleteRange(index, count)
+				if index+count > len(model) {
+					count = len(model) - index
+				}
+				if deleted != count {
+					t.Fatalf("DeleteRange(%d, %d) = %d, want %d", index, count, deleted, count)
+				}
+				model = append(model[:index], model[index+count:]...)
+			case op == 6:
+				index, after := r.Intn(len(model)), r.Intn(2) == 0
+				val := randomVal()
+				it := ql.Iterator(index, true)
+				it.Next()
+				if err := it.Insert(val, after); err != nil {
+					t.Fatalf("Insert() error = %v", err)
+				}
+				if after {
+					index++
+				}
+				model = append(model[:index], append([]interface{}{val}, model[index:]...)...)
+			default:
+				// 删除所有等于 target 的元素
+				target := randomVal()
+				var remained []interface{}
+				for it := ql.Iterator(0, true); it.Next(); {
+					if it.Equal(target) {
+						_ = it.Delete()
+					}
+				}
+				for _, val := range model {
+					if !reflect.DeepEqual(val, target) {
+						remained = append(remained, val)
+					}
+				}
+				model = remained
+			}
+
+			checkQuickList(t, ql, model)
+		}
+	}
+}
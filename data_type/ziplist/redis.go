@@ -0,0 +1,179 @@
+package ziplist
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// redis 的 ziplist(RDB 中 ziplist 编码的 list、hash、zset 以及 quicklist 的节点)与这里的布局不同:
+// -----------------------------------------------------------------------------
+// | 字节数量(4Byte) | 尾结点偏移量(4Byte) | 节点个数(2Byte) | entry1 | ... | zlend |
+// -----------------------------------------------------------------------------
+// 首部中的整数是小端，没有头节点，第一个 entry 的 prev 为 0；prev 字段为 5 字节时后 4 字节是小端；
+// 字符串的编码与这里相同；整数的编码为 0xc0 int16、0xd0 int32、0xe0 int64、0xf0 int24、0xfe int8，
+// 0xf1 ~ 0xfd 表示立即数 0 ~ 12，数据都是小端。
+// 转换时逐个 entry 改写为这里的格式直接写入 content，不需要重新插入。
+
+const (
+	redisHeaderSize = 10
+
+	redisEncodingInt16  = 0xc0
+	redisEncodingInt32  = 0xd0
+	redisEncodingInt64  = 0xe0
+	redisEncodingInt24  = 0xf0
+	redisEncodingInt8   = 0xfe
+	redisEncodingIMMMin = 0xf1
+	redisEncodingIMMMax = 0xfd
+)
+
+// ZipListFromRedis creates a ziplist from content in the format of redis ziplist, content comes from
+// untrusted source such as RDB and is validated during the conversion. content is not referenced by
+// the returned ziplist.
+func ZipListFromRedis(content []byte) (*ZipList, error) {
+	if len(content) < redisHeaderSize+1 || uint64(len(content)) > uint64(FourByteBinLength) {
+		return nil, fmt.Errorf("%w: invalid length %d", CorruptedZipListErr, len(content))
+	}
+	if total := binary.LittleEndian.Uint32(content[:4]); int(total) != len(content) {
+		return nil, fmt.Errorf("%w: length in header is %d, but actual length is %d", CorruptedZipListErr, total, len(content))
+	}
+	if content[len(content)-1] != Tail {
+		return nil, fmt.Errorf("%w: the last byte is 0x%02x, not zlend", CorruptedZipListErr, content[len(content)-1])
+	}
+
+	// 头节点使第一个 entry 的位置后移 2 字节，entry 的长度可能因为整数编码或者 prev 字段的变化而不同
+	zl := &ZipList{
+		content: make([]byte, firstEntryOffset, len(content)+firstEntryOffset-redisHeaderSize),
+	}
+	end := len(content) - 1
+	count := 0
+	prevLen, lastOffset := 2, headEntryOffset
+	redisPrevLen, redisLastOffset := 0, redisHeaderSize
+	for p := redisHeaderSize; p < end; {
+		offset := len(zl.content)
+		entryLen, err := zl.appendRedisEntry(content[:end], p, redisPrevLen, prevLen)
+		if err != nil {
+			return nil, err
+		}
+
+		count++
+		prevLen, lastOffset = len(zl.content)-offset, offset
+		redisPrevLen, redisLastOffset = entryLen, p
+		p += entryLen
+	}
+
+	if tail := binary.LittleEndian.Uint32(content[4:8]); int(tail) != redisLastOffset {
+		return nil, fmt.Errorf("%w: tail offset is %d, but the last entry is at %d", CorruptedZipListErr, tail, redisLastOffset)
+	}
+	// 节点个数达到 UINT16_MAX 后首部中的计数饱和
+	if headerCount := binary.LittleEndian.Uint16(content[8:10]); headerCount != utils.UINT16_MAX && int(headerCount) != count {
+		return nil, fmt.Errorf("%w: count in header is %d, but there are %d entries", CorruptedZipListErr, headerCount, count)
+	}
+
+	zl.content = append(zl.content, Tail)
+	binary.BigEndian.PutUint32(zl.content[:4], uint32(len(zl.content)))
+	binary.BigEndian.PutUint32(zl.content[4:8], uint32(lastOffset))
+	zl.setCount(count)
+	zl.content[headEntryOffset] = 0x00
+	zl.content[headEntryOffset+1] = EncodingIMMMin
+
+	return zl, nil
+}
+
+// appendRedisEntry validates the redis entry at offset p and appends it to zl in our format, content
+// doesn't contain zlend. prevLen is the length of the last entry of zl. Length of the redis entry is
+// returned.
+func (zl *ZipList) appendRedisEntry(content []byte, p int, redisPrevLen int, prevLen int) (int, error) {
+	// prev 字段
+	prevFieldLength := 1
+	if content[p] == Prev5BytesBegin {
+		prevFieldLength = 5
+	}
+	if p+prevFieldLength >= len(content) {
+		return 0, fmt.Errorf("%w: prev field of entry at %d is out of range", CorruptedZipListErr, p)
+	}
+
+	gotPrevLen := int(content[p])
+	if prevFieldLength == 5 {
+		gotPrevLen = int(binary.LittleEndian.Uint32(content[p+1 : p+5]))
+	}
+	if gotPrevLen != redisPrevLen {
+		return 0, fmt.Errorf("%w: prev length of entry at %d is %d, want %d", CorruptedZipListErr, p, gotPrevLen, redisPrevLen)
+	}
+
+	// encoding 字段，字符串的编码与这里相同，直接拷贝
+	encodingOffset := p + prevFieldLength
+	encoding := content[encodingOffset]
+	var encodingLength, dataLength int
+	switch encoding & EncodingMask {
+	case 0x00:
+		encodingLength = 1
+		dataLength = int(encoding & 0x3f)
+	case 0x40:
+		encodingLength = 2
+		if encodingOffset+2 > len(content) {
+			return 0, fmt.Errorf("%w: encoding of entry at %d is out of range", CorruptedZipListErr, p)
+		}
+		dataLength = int(binary.BigEndian.Uint16(content[encodingOffset:encodingOffset+2]) & TwoByteBinLength)
+	case 0x80:
+		encodingLength = 5
+		if encoding != 0x80 || encodingOffset+5 > len(content) {
+			return 0, fmt.Errorf("%w: invalid encoding of entry at %d", CorruptedZipListErr, p)
+		}
+		dataLength = int(binary.BigEndian.Uint32(content[encodingOffset+1 : encodingOffset+5]))
+	default:
+		encodingLength = 1
+		switch {
+		case encoding == redisEncodingInt8:
+			dataLength = 1
+		case encoding == redisEncodingInt16:
+			dataLength = 2
+		case encoding == redisEncodingInt24:
+			dataLength = 3
+		case encoding == redisEncodingInt32:
+			dataLength = 4
+		case encoding == redisEncodingInt64:
+			dataLength = 8
+		case encoding >= redisEncodingIMMMin && encoding <= redisEncodingIMMMax:
+			dataLength = 0
+		default:
+			return 0, fmt.Errorf("%w: unknown encoding 0x%02x of entry at %d", CorruptedZipListErr, encoding, p)
+		}
+	}
+
+	entryLen := prevFieldLength + encodingLength + dataLength
+	if dataLength > len(content) || p+entryLen > len(content) {
+		return 0, fmt.Errorf("%w: entry at %d with length %d is out of range", CorruptedZipListErr, p, entryLen)
+	}
+
+	zl.content = append(zl.content, encodePrevField(uint32(prevLen))...)
+	dataOffset := encodingOffset + encodingLength
+	data := content[dataOffset : dataOffset+dataLength]
+	if encoding&EncodingMask != 0xc0 {
+		zl.content = append(zl.content, content[encodingOffset:dataOffset+dataLength]...)
+		return entryLen, nil
+	}
+
+	// 整数按照这里的规则重新编码
+	var num int64
+	switch encoding {
+	case redisEncodingInt8:
+		num = int64(int8(data[0]))
+	case redisEncodingInt16:
+		num = int64(int16(binary.LittleEndian.Uint16(data)))
+	case redisEncodingInt24:
+		num = int64(int32(uint32(data[0])<<8|uint32(data[1])<<16|uint32(data[2])<<24) >> 8)
+	case redisEncodingInt32:
+		num = int64(int32(binary.LittleEndian.Uint32(data)))
+	case redisEncodingInt64:
+		num = int64(binary.LittleEndian.Uint64(data))
+	default:
+		num = int64(encoding&0x0f) - 1
+	}
+	entry := zl.transIntToEntry(num)
+	zl.content = append(zl.content, entry.encodingVal...)
+	zl.content = append(zl.content, entry.data...)
+
+	return entryLen, nil
+}
@@ -0,0 +1,130 @@
+package ziplist
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// redisZipList 按照 redis 的格式编码 elements，整数选择 redis 中最短的编码
+func redisZipList(elements []interface{}) []byte {
+	content := make([]byte, redisHeaderSize)
+	prevLen, lastOffset := 0, redisHeaderSize
+	for _, elem := range elements {
+		offset := len(content)
+		if prevLen < 254 {
+			content = append(content, byte(prevLen))
+		} else {
+			content = append(content, Prev5BytesBegin, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(content[len(content)-4:], uint32(prevLen))
+		}
+
+		switch v := elem.(type) {
+		case int64:
+			data := make([]byte, 8)
+			binary.LittleEndian.PutUint64(data, uint64(v))
+			switch {
+			case v >= 0 && v <= 12:
+				content = append(content, byte(redisEncodingIMMMin+v))
+			case v >= int64(utils.INT8_MIN) && v <= int64(utils.INT8_MAX):
+				content = append(content, redisEncodingInt8, data[0])
+			case v >= int64(utils.INT16_MIN) && v <= int64(utils.INT16_MAX):
+				content = append(content, redisEncodingInt16, data[0], data[1])
+			case v >= int64(utils.INT24_MIN) && v <= int64(utils.INT24_MAX):
+				content = append(content, redisEncodingInt24, data[0], data[1], data[2])
+			case v >= int64(utils.INT32_MIN) && v <= int64(utils.INT32_MAX):
+				content = append(content, redisEncodingInt32, data[0], data[1], data[2], data[3])
+			default:
+				content = append(content, redisEncodingInt64)
+				content = append(content, data...)
+			}
+		case *rs.RedisString:
+			entry, _ := (&ZipList{}).transBinToEntry(v)
+			content = append(content, entry.encodingVal...)
+			content = append(content, entry.data...)
+		}
+		prevLen, lastOffset = len(content)-offset, offset
+	}
+
+	content = append(content, Tail)
+	binary.LittleEndian.PutUint32(content[:4], uint32(len(content)))
+	binary.LittleEndian.PutUint32(content[4:8], uint32(lastOffset))
+	count := len(elements)
+	if count > int(utils.UINT16_MAX) {
+		count = int(utils.UINT16_MAX)
+	}
+	binary.LittleEndian.PutUint16(content[8:10], uint16(count))
+	return content
+}
+
+func TestZipListFromRedis(t *testing.T) {
+	// ziplist.c 注释中的例子，包含 2 和 5 两个 entry
+	content, _ := hex.DecodeString("0f0000000c000000020000f302f6ff")
+	zl, err := ZipListFromRedis(content)
+	if err != nil {
+		t.Fatalf("ZipListFromRedis() error = %v", err)
+	}
+	checkZipList(t, zl, []interface{}{int64(2), int64(5)})
+
+	_, elements := createTestZipList(t)
+	elements = append(elements,
+		int64(-100), int64(utils.INT8_MAX), int64(utils.INT16_MAX), int64(utils.INT32_MAX), int64(utils.INT64_MIN),
+		&rs.RedisString{Content: make([]byte, 20000)}, &rs.RedisString{Content: []byte{}})
+	for _, elements := range [][]interface{}{nil, elements} {
+		zl, err := ZipListFromRedis(redisZipList(elements))
+		if err != nil {
+			t.Fatalf("ZipListFromRedis() error = %v", err)
+		}
+		if err := ValidateIntegrity(zl.Bytes(), true); err != nil {
+			t.Fatalf("ValidateIntegrity() error = %v", err)
+		}
+		checkZipList(t, zl, elements)
+	}
+
+	// 节点个数超过 UINT16_MAX 时首部中的计数饱和
+	many := make([]interface{}, int(utils.UINT16_MAX)+10)
+	for i := range many {
+		many[i] = int64(i)
+	}
+	zl, err = ZipListFromRedis(redisZipList(many))
+	if err != nil {
+		t.Fatalf("ZipListFromRedis() error = %v", err)
+	}
+	checkZipList(t, zl, many)
+}
+
+func TestZipListFromRedis_Corrupted(t *testing.T) {
+	_, elements := createTestZipList(t)
+	valid := redisZipList(elements)
+
+	tests := []struct {
+		name   string
+		modify func(content []byte) []byte
+	}{
+		{"too short", func(content []byte) []byte { return content[:redisHeaderSize] }},
+		{"wrong total length", func(content []byte) []byte { return append(content, Tail) }},
+		{"no zlend", func(content []byte) []byte { content[len(content)-1] = 0; return content }},
+		{"wrong tail offset", func(content []byte) []byte { content[4]++; return content }},
+		{"wrong count", func(content []byte) []byte { content[8]++; return content }},
+		{"wrong prev length", func(content []byte) []byte { content[redisHeaderSize] = 1; return content }},
+		{"unknown encoding", func(content []byte) []byte { content[redisHeaderSize+1] = Tail; return content }},
+		{"string out of range", func(content []byte) []byte {
+			// 最后一个 entry 是 5 字节的 prev 加上立即数，之后追加一个很长的字符串
+			content = append(content[:len(content)-1], 0x06, 0x80, 0xff, 0xff, 0xff, 0xff, Tail)
+			binary.LittleEndian.PutUint32(content[:4], uint32(len(content)))
+			return content
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.modify(append([]byte{}, valid...))
+			if _, err := ZipListFromRedis(content); !errors.Is(err, CorruptedZipListErr) {
+				t.Fatalf("ZipListFromRedis() error = %v, want %v", err, CorruptedZipListErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package ziplist
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// 从 RDB 文件或者 RESTORE 命令加载的 ziplist 是不可信的，直接解析损坏的数据可能越界访问甚至死循环，
+// 所以需要先校验其完整性。
+
+var (
+	CorruptedZipListErr = errors.New("corrupted ziplist")
+)
+
+const (
+	zlMinLength = firstEntryOffset + 1 // 首部 + 头节点 + zlend
+)
+
+// ValidateIntegrity validates content of a ziplist from untrusted source. Header, head entry and zlend
+// are always validated. When deep is true, every entry is walked to validate the prev length chain,
+// encodings, entry lengths, tail offset and entry count, after that content can be safely accessed.
+func ValidateIntegrity(content []byte, deep bool) error {
+	if len(content) < zlMinLength || uint64(len(content)) > uint64(FourByteBinLength) {
+		return fmt.Errorf("%w: invalid length %d", CorruptedZipListErr, len(content))
+	}
+
+	if total := binary.BigEndian.Uint32(content[:4]); int(total) != len(content) {
+		return fmt.Errorf("%w: length in header is %d, but actual length is %d", CorruptedZipListErr, total, len(content))
+	}
+
+	if content[len(content)-1] != Tail {
+		return fmt.Errorf("%w: the last byte is 0x%02x, not zlend", CorruptedZipListErr, content[len(content)-1])
+	}
+
+	if content[headEntryOffset] != 0x00 || content[headEntryOffset+1] != EncodingIMMMin {
+		return fmt.Errorf("%w: invalid head entry", CorruptedZipListErr)
+	}
+
+	tailOffset := int(binary.BigEndian.Uint32(content[4:8]))
+	if tailOffset < headEntryOffset || tailOffset >= len(content)-1 {
+		return fmt.Errorf("%w: tail offset %d is out of range", CorruptedZipListErr, tailOffset)
+	}
+
+	if !deep {
+		return nil
+	}
+
+	end := len(content) - 1
+	count := 0
+	prevLen := 2 // 头节点的长度
+	lastOffset := headEntryOffset
+	p := firstEntryOffset
+	for p < end {
+		entryLen, err := validateEntry(content[:end], p, prevLen)
+		if err != nil {
+			return err
+		}
+
+		count++
+		prevLen = entryLen
+		lastOffset = p
+		p += entryLen
+	}
+
+	if tailOffset != lastOffset {
+		return fmt.Errorf("%w: tail offset is %d, but the last entry is at %d", CorruptedZipListErr, tailOffset, lastOffset)
+	}
+
+	// 节点个数达到 UINT16_MAX 后首部中的计数饱和，不再是真实的节点个数
+	headerCount := int(binary.BigEndian.Uint16(content[8:10]))
+	if count >= int(utils.UINT16_MAX) {
+		count = int(utils.UINT16_MAX)
+	}
+	if headerCount != count {
+		return fmt.Errorf("%w: count in header is %d, but there are %d entries", CorruptedZipListErr, headerCount, count)
+	}
+
+	return nil
+}
+
+// validateEntry validates the entry at offset p, content doesn't contain zlend. Length of the entry
+// is returned.
+func validateEntry(content []byte, p int, prevLen int) (int, error) {
+	// prev 字段
+	prevFieldLength := 1
+	if content[p] == Prev5BytesBegin {
+		prevFieldLength = 5
+	}
+	if p+prevFieldLength >= len(content) {
+		return 0, fmt.Errorf("%w: prev field of entry at %d is out of range", CorruptedZipListErr, p)
+	}
+
+	gotPrevLen := int(content[p])
+	if prevFieldLength == 5 {
+		gotPrevLen = int(binary.BigEndian.Uint32(content[p+1 : p+5]))
+	}
+	if gotPrevLen != prevLen {
+		return 0, fmt.Errorf("%w: prev length of entry at %d is %d, want %d", CorruptedZipListErr, p, gotPrevLen, prevLen)
+	}
+	if (prevLen <= 0xfd) != (prevFieldLength == 1) {
+		return 0, fmt.Errorf("%w: prev field of entry at %d is not encoded in %d bytes", CorruptedZipListErr, p, prevFieldLength)
+	}
+
+	// encoding 字段
+	encodingOffset := p + prevFieldLength
+	encoding := content[encodingOffset]
+	var encodingLength, dataLength int
+	switch encoding & EncodingMask {
+	case 0x00:
+		encodingLength = 1
+		dataLength = int(encoding & 0x3f)
+	case 0x40:
+		encodingLength = 2
+		if encodingOffset+2 > len(content) {
+			return 0, fmt.Errorf("%w: encoding of entry at %d is out of range", CorruptedZipListErr, p)
+		}
+		dataLength = int(binary.BigEndian.Uint16(content[encodingOffset:encodingOffset+2]) & TwoByteBinLength)
+	case 0x80:
+		encodingLength = 5
+		if encodingOffset+5 > len(content) {
+			return 0, fmt.Errorf("%w: encoding of entry at %d is out of range", CorruptedZipListErr, p)
+		}
+		dataLength = int(binary.BigEndian.Uint32(content[encodingOffset+1 : encodingOffset+5]))
+	default:
+		encodingLength = 1
+		switch {
+		case encoding == EncodingInt8:
+			dataLength = 1
+		case encoding == EncodingInt16:
+			dataLength = 2
+		case encoding == EncodingInt24:
+			dataLength = 3
+		case encoding == EncodingInt32:
+			dataLength = 4
+		case encoding == EncodingInt64:
+			dataLength = 8
+		case encoding >= EncodingIMMMin && encoding <= EncodingIMMMax:
+			dataLength = 0
+		default:
+			return 0, fmt.Errorf("%w: unknown encoding 0x%02x of entry at %d", CorruptedZipListErr, encoding, p)
+		}
+	}
+
+	entryLen := prevFieldLength + encodingLength + dataLength
+	if dataLength > len(content) || p+entryLen > len(content) {
+		return 0, fmt.Errorf("%w: entry at %d with length %d is out of range", CorruptedZipListErr, p, entryLen)
+	}
+
+	return entryLen, nil
+}
+
+// ZipListFromBytes creates a ziplist from content of untrusted source, see ValidateIntegrity for deep.
+// content is copied, so it can be reused by caller.
+func ZipListFromBytes(content []byte, deep bool) (*ZipList, error) {
+	if err := ValidateIntegrity(content, deep); err != nil {
+		return nil, err
+	}
+
+	zl := &ZipList{
+		content: make([]byte, len(content)),
+	}
+	copy(zl.content, content)
+
+	return zl, nil
+}
+
+// Dup returns a copy of zl.
+func (zl *ZipList) Dup() *ZipList {
+	content := make([]byte, len(zl.content))
+	copy(content, zl.content)
+	return &ZipList{content: content}
+}
+
+// Bytes returns content of zl, the returned slice must not be modified and is only valid until the
+// next modification of zl.
+func (zl *ZipList) Bytes() []byte {
+	return zl.content
+}
@@ -0,0 +1,26 @@
+//go:build go1.18
+
+package ziplist
+
+import (
+	"testing"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+// FuzzValidateIntegrity 的语料在 testdata/fuzz/FuzzValidateIntegrity 中，
+// 使用 go test -fuzz=FuzzValidateIntegrity ./data_type/ziplist 进行模糊测试。
+func FuzzValidateIntegrity(f *testing.F) {
+	f.Add(InitZipList().Bytes())
+	zl, _ := CreateZipList(int64(0), int64(-1), int64(1<<40), &rs.RedisString{Content: []byte("abc")})
+	f.Add(zl.Bytes())
+	zl, _ = CreateZipList(&rs.RedisString{Content: make([]byte, 300)}, int64(7), &rs.RedisString{Content: make([]byte, 100)})
+	f.Add(zl.Bytes())
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		_ = ValidateIntegrity(content, false)
+		if ValidateIntegrity(content, true) == nil {
+			exerciseZipList(t, content)
+		}
+	})
+}
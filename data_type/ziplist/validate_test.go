@@ -0,0 +1,215 @@
+package ziplist
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+func TestValidateIntegrity(t *testing.T) {
+	valid, _ := createTestZipList(t)
+	validContent := valid.Bytes()
+
+	// modify 返回修改后的拷贝
+	modify := func(f func(content []byte) []byte) []byte {
+		content := make([]byte, len(validContent))
+		copy(content, validContent)
+		return f(content)
+	}
+
+	tests := []struct {
+		name           string
+		content        []byte
+		wantShallowErr bool
+		wantDeepErr    bool
+	}{
+		{name: "valid", content: validContent},
+		{name: "empty ziplist", content: InitZipList().Bytes()},
+		{name: "nil", content: nil, wantShallowErr: true, wantDeepErr: true},
+		{name: "too short", content: []byte{0x00, 0x00, 0x00, 0x03, 0xff}, wantShallowErr: true, wantDeepErr: true},
+		{
+			name: "wrong total length",
+			content: modify(func(content []byte) []byte {
+				binary.BigEndian.PutUint32(content[:4], uint32(len(content)+1))
+				return content
+			}),
+			wantShallowErr: true, wantDeepErr: true,
+		},
+		{
+			name: "missing zlend",
+			content: modify(func(content []byte) []byte {
+				content[len(content)-1] = 0x00
+				return content
+			}),
+			wantShallowErr: true, wantDeepErr: true,
+		},
+		{
+			name: "invalid head entry",
+			content: modify(func(content []byte) []byte {
+				content[headEntryOffset+1] = 0x00
+				return content
+			}),
+			wantShallowErr: true, wantDeepErr: true,
+		},
+		{
+			name: "tail offset out of range",
+			content: modify(func(content []byte) []byte {
+				binary.BigEndian.PutUint32(content[4:8], uint32(len(content)))
+				return content
+			}),
+			wantShallowErr: true, wantDeepErr: true,
+		},
+		{
+			name: "tail offset not point to the last entry",
+			content: modify(func(content []byte) []byte {
+				binary.BigEndian.PutUint32(content[4:8], firstEntryOffset)
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "wrong count",
+			content: modify(func(content []byte) []byte {
+				binary.BigEndian.PutUint16(content[8:10], 1)
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "broken prev length chain",
+			content: modify(func(content []byte) []byte {
+				content[firstEntryOffset] = 3
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "unknown int encoding",
+			content: modify(func(content []byte) []byte {
+				content[firstEntryOffset+1] = 0xc1
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "string length out of range",
+			content: modify(func(content []byte) []byte {
+				// 第 9 个节点是字符串 "abc"
+				offset := valid.ZipListIndex(8).offset
+				content[offset+1] = 0x3f
+				return content
+			}),
+			wantDeepErr: true,
+		},
+		{
+			name: "zlend in the middle",
+			content: modify(func(content []byte) []byte {
+				offset := valid.ZipListIndex(3).offset
+				content[offset] = Tail
+				return content
+			}),
+			wantDeepErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateIntegrity(tt.content, false); (err != nil) != tt.wantShallowErr {
+				t.Errorf("ValidateIntegrity(shallow) error = %v, wantErr %v", err, tt.wantShallowErr)
+			}
+			err := ValidateIntegrity(tt.content, true)
+			if (err != nil) != tt.wantDeepErr {
+				t.Errorf("ValidateIntegrity(deep) error = %v, wantErr %v", err, tt.wantDeepErr)
+			}
+			if err != nil && !errors.Is(err, CorruptedZipListErr) {
+				t.Errorf("ValidateIntegrity(deep) error = %v, want %v", err, CorruptedZipListErr)
+			}
+		})
+	}
+}
+
+func TestZipListFromBytes(t *testing.T) {
+	zl, elements := createTestZipList(t)
+	content := append([]byte{}, zl.Bytes()...)
+
+	got, err := ZipListFromBytes(content, true)
+	if err != nil {
+		t.Fatalf("ZipListFromBytes() error = %v", err)
+	}
+
+	// content 被拷贝，修改 content 不影响 ziplist
+	content[firstEntryOffset+1] = 0xc1
+	checkZipList(t, got, elements)
+
+	if _, err := ZipListFromBytes(content, true); !errors.Is(err, CorruptedZipListErr) {
+		t.Fatalf("ZipListFromBytes() of corrupted content error = %v, want %v", err, CorruptedZipListErr)
+	}
+}
+
+// exerciseZipList 对校验通过的 ziplist 进行遍历和修改，这些操作都不应该 panic，并且修改后依然能通过校验
+func exerciseZipList(t *testing.T, content []byte) {
+	zl, err := ZipListFromBytes(content, true)
+	if err != nil {
+		t.Fatalf("ZipListFromBytes() error = %v", err)
+	}
+
+	count := 0
+	for c := zl.ZipListIndex(0); c != nil; c, _ = zl.ZipListNext(c) {
+		if _, err := zl.ZipListGet(c); err != nil {
+			t.Fatalf("ZipListGet() error = %v", err)
+		}
+		count++
+	}
+	for c := zl.ZipListIndex(-1); c != nil; c, _ = zl.ZipListPrev(c) {
+		count--
+	}
+	if count != 0 {
+		t.Fatalf("forward and backward iteration visited different number of entries")
+	}
+
+	_, _ = zl.ZipListFind(nil, &rs.RedisString{Content: []byte("1")}, 1)
+	_ = zl.ZipListPush(&rs.RedisString{Content: make([]byte, 300)})
+	_, _ = zl.ZipListDeleteRange(0, 1)
+	if c := zl.ZipListIndex(0); c != nil {
+		_, _ = zl.ZipListReplace(c, int64(1000))
+	}
+	if err := ValidateIntegrity(zl.Bytes(), true); err != nil {
+		t.Fatalf("ValidateIntegrity() after modification error = %v", err)
+	}
+}
+
+// TestValidateIntegrity_RandomCorruption 随机修改合法 ziplist 的字节，校验不能 panic，
+// 通过深度校验的数据必须可以安全地访问。
+func TestValidateIntegrity_RandomCorruption(t *testing.T) {
+	valid, _ := createTestZipList(t)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5000; i++ {
+		content := append([]byte{}, valid.Bytes()...)
+		switch r.Intn(3) {
+		case 0:
+			// 修改若干个字节
+			for j := 0; j <= r.Intn(3); j++ {
+				content[r.Intn(len(content))] = byte(r.Intn(256))
+			}
+		case 1:
+			// 截断并修正首部长度
+			content = content[:r.Intn(len(content))+1]
+			if len(content) >= 4 {
+				binary.BigEndian.PutUint32(content[:4], uint32(len(content)))
+				content[len(content)-1] = Tail
+			}
+		default:
+			// 修改一个字节并修正计数
+			content[firstEntryOffset+r.Intn(len(content)-firstEntryOffset-1)] = byte(r.Intn(256))
+			binary.BigEndian.PutUint16(content[8:10], uint16(r.Intn(13)))
+		}
+
+		_ = ValidateIntegrity(content, false)
+		if ValidateIntegrity(content, true) == nil {
+			exerciseZipList(t, content)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package ziplist
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
-	"reflect"
-	"unsafe"
+	"math"
 
 	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
 	"github.com/WANGgbin/tiny_redis/utils"
@@ -34,12 +34,54 @@ const (
 )
 
 var (
-	PointedToEndErr = errors.New("points to the end of ziplist")
-	PointerIsNilErr = errors.New("pointer is nil")
+	PointedToEndErr     = errors.New("points to the end of ziplist")
+	PointerIsNilErr     = errors.New("pointer is nil")
+	OffsetOutOfRangeErr = errors.New("offset is out of range of ziplist")
+	CursorInvalidErr    = errors.New("cursor is invalidated by modification of ziplist")
+	CursorNotBelongErr  = errors.New("cursor does not belong to ziplist")
+	NotFloatErr         = errors.New("value of entry is not a float")
 )
 
 type ZipList struct {
 	content []byte
+	version uint64 // 每次修改 content 都会递增，用于判断 Cursor 是否失效
+}
+
+// Cursor 指向 ziplist 中的一个 entry。Cursor 记录的是 entry 相对于 content 的偏移量而不是指针，
+// 因此 content 重新分配后也不会访问到已经释放的内存。
+// ziplist 的任何修改都可能改变 entry 的偏移量，所以修改之后，之前获取的 Cursor 全部失效，
+// 使用失效的 Cursor 会返回 CursorInvalidErr。修改类的 api 会返回一个新的有效的 Cursor。
+type Cursor struct {
+	zl      *ZipList
+	offset  uint32
+	version uint64
+}
+
+// Valid reports whether c can still be used.
+func (c *Cursor) Valid() bool {
+	return c != nil && c.zl != nil && c.version == c.zl.version
+}
+
+func (zl *ZipList) newCursor(offset uint32) *Cursor {
+	return &Cursor{
+		zl:      zl,
+		offset:  offset,
+		version: zl.version,
+	}
+}
+
+func (zl *ZipList) checkCursor(c *Cursor) error {
+	if c == nil {
+		return PointerIsNilErr
+	}
+	if c.zl != zl {
+		return CursorNotBelongErr
+	}
+	if c.version != zl.version {
+		return CursorInvalidErr
+	}
+
+	return nil
 }
 
 type dataType uint8
@@ -59,6 +101,7 @@ type zlEntry struct {
 	dataLength      uint32
 	data            []byte
 	encodingType    dataType
+	dataInt         int64 // encodingType 为 intData 时有效
 }
 
 func (entry *zlEntry) getLength() uint32 {
@@ -76,6 +119,11 @@ func (entry *zlEntry) getPrevLength() uint32 {
 // | 字节数量(4Byte) | 尾结点偏移量(4Byte) | 节点个数(2Byte) | entry1 | ... | zlend |
 // -----------------------------------------------------------------------------
 
+const (
+	headEntryOffset  = 10 // 头节点的偏移量
+	firstEntryOffset = 12 // 第一个真正节点的偏移量
+)
+
 func CreateZipList(elements ...interface{}) (*ZipList, error) {
 	zl := InitZipList()
 	for _, elem := range elements {
@@ -113,7 +161,8 @@ func (zl *ZipList) getOffset() uint32 {
 	return binary.BigEndian.Uint32(zl.content[4:8])
 }
 
-// ZipListPush pushes an entry at the end of z.
+// ZipListPush pushes an entry at the end of z. val is int64, float64 or *rs.RedisString, a float64 is
+// stored as integer when it's integral, otherwise as the shortest string which round-trips.
 func (zl *ZipList) ZipListPush(val interface{}) error {
 	entry, err := zl.convertValToEntry(val)
 	if err != nil {
@@ -121,24 +170,238 @@ func (zl *ZipList) ZipListPush(val interface{}) error {
 	}
 
 	// 填充 prevVal & prevFieldLength
-	position, _ := opPointer(&zl.content[0], zl.getOffset(), Plus)
+	position := zl.getOffset()
 	zl.fillPrev(position, entry)
 	zl.insertEntry(position, entry)
 
 	return nil
 }
 
-// InsertEntry inserts an entry after position.
-func (zl *ZipList) ZipListInsert(position *byte, val interface{}) error {
+// ZipListInsert inserts an entry after the entry pointed by c, the entry is inserted at the head
+// of zl when c is nil. Cursor of the new entry is returned.
+func (zl *ZipList) ZipListInsert(c *Cursor, val interface{}) (*Cursor, error) {
+	position := uint32(headEntryOffset)
+	if c != nil {
+		if err := zl.checkCursor(c); err != nil {
+			return nil, err
+		}
+		position = c.offset
+	}
+
 	entry, err := zl.convertValToEntry(val)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	zl.fillPrev(position, entry)
 	zl.insertEntry(position, entry)
 
-	return nil
+	return zl.newCursor(entry.offset), nil
+}
+
+// ZipListDeleteAt deletes the entry pointed by c, cursor of the next entry is returned,
+// nil is returned when the last entry is deleted.
+func (zl *ZipList) ZipListDeleteAt(c *Cursor) (*Cursor, error) {
+	if err := zl.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	entry, err := zl.decodeBytesToEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	zl.deleteEntry(entry)
+	// 被删除节点的位置现在是下一个节点
+	if zl.content[entry.offset] == Tail {
+		return nil, nil
+	}
+
+	return zl.newCursor(entry.offset), nil
+}
+
+// ZipListReplace replaces the entry pointed by c with val. When the encoding of val has the same
+// length as the old one, the entry is overwritten in place and cursors of zl are still valid,
+// otherwise they are invalidated. Cursor of the new entry is returned.
+func (zl *ZipList) ZipListReplace(c *Cursor, val interface{}) (*Cursor, error) {
+	if err := zl.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	oldEntry, err := zl.decodeBytesToEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := zl.convertValToEntry(val)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.encodingLength+entry.dataLength == oldEntry.encodingLength+oldEntry.dataLength {
+		dataOffset := c.offset + oldEntry.prevFieldLength
+		copy(zl.content[dataOffset:], entry.encodingVal)
+		copy(zl.content[dataOffset+entry.encodingLength:], entry.data)
+		return c, nil
+	}
+
+	zl.replaceEntries(c.offset, c.offset+oldEntry.getLength(), 1, entry)
+
+	return zl.newCursor(c.offset), nil
+}
+
+// ZipListDeleteRange deletes count entries from the entry with specific index, negative index counts
+// from the tail. The number of deleted entries is returned.
+func (zl *ZipList) ZipListDeleteRange(index int, count int) (int, error) {
+	c := zl.ZipListIndex(index)
+	if c == nil || count <= 0 {
+		return 0, nil
+	}
+
+	end := c.offset
+	deleted := 0
+	for ; deleted < count && zl.content[end] != Tail; deleted++ {
+		entry, err := zl.decodeBytesToEntry(end)
+		if err != nil {
+			return 0, err
+		}
+		end += entry.getLength()
+	}
+
+	zl.replaceEntries(c.offset, end, deleted, nil)
+
+	return deleted, nil
+}
+
+// ZipListIndex gets cursor of the entry with specific index, negative index counts from the tail,
+// e.g. -1 is the last entry. Nil is returned when index is out of range.
+func (zl *ZipList) ZipListIndex(index int) *Cursor {
+	if index >= 0 {
+		p := uint32(firstEntryOffset)
+		for ; index > 0 && zl.content[p] != Tail; index-- {
+			entry, err := zl.decodeBytesToEntry(p)
+			if err != nil {
+				return nil
+			}
+			p += entry.getLength()
+		}
+
+		if zl.content[p] == Tail {
+			return nil
+		}
+		return zl.newCursor(p)
+	}
+
+	p := zl.getOffset()
+	for index = -index - 1; index > 0 && p != headEntryOffset; index-- {
+		entry, err := zl.decodeBytesToEntry(p)
+		if err != nil {
+			return nil
+		}
+		p -= entry.getPrevLength()
+	}
+
+	if p == headEntryOffset {
+		return nil
+	}
+	return zl.newCursor(p)
+}
+
+// ZipListNext gets cursor of the entry after c, nil is returned when c points to the last entry.
+func (zl *ZipList) ZipListNext(c *Cursor) (*Cursor, error) {
+	if err := zl.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	entry, err := zl.decodeBytesToEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	next := c.offset + entry.getLength()
+	if zl.content[next] == Tail {
+		return nil, nil
+	}
+
+	return zl.newCursor(next), nil
+}
+
+// ZipListPrev gets cursor of the entry before c, nil is returned when c points to the first entry.
+func (zl *ZipList) ZipListPrev(c *Cursor) (*Cursor, error) {
+	if err := zl.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	entry, err := zl.decodeBytesToEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.offset <= firstEntryOffset {
+		return nil, nil
+	}
+
+	return zl.newCursor(c.offset - entry.getPrevLength()), nil
+}
+
+// ZipListGet gets value of the entry pointed by c, the value is either int64 or *rs.RedisString.
+func (zl *ZipList) ZipListGet(c *Cursor) (interface{}, error) {
+	if err := zl.checkCursor(c); err != nil {
+		return nil, err
+	}
+
+	entry, err := zl.decodeBytesToEntry(c.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.encodingType == intData {
+		return entry.dataInt, nil
+	}
+
+	str := &rs.RedisString{
+		Content: make([]byte, entry.dataLength),
+	}
+	copy(str.Content, entry.data)
+
+	return str, nil
+}
+
+// ZipListGetFloat gets value of the entry pointed by c as float64, it's the decoder of float64 values
+// pushed into zl. NotFloatErr is returned when the entry is a string which is not a float.
+func (zl *ZipList) ZipListGetFloat(c *Cursor) (float64, error) {
+	if err := zl.checkCursor(c); err != nil {
+		return 0, err
+	}
+
+	entry, err := zl.decodeBytesToEntry(c.offset)
+	if err != nil {
+		return 0, err
+	}
+
+	if entry.encodingType == intData {
+		return float64(entry.dataInt), nil
+	}
+
+	f, ok := utils.String2Float64(entry.data)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", NotFloatErr, entry.data)
+	}
+	return f, nil
+}
+
+// floatToVal 将 float64 转换为 ziplist 可以存储的值：整数(-0 除外)使用整数编码，
+// 其余使用可以精确还原的最短字符串，例如 zset 的 score。
+func floatToVal(f float64) (interface{}, error) {
+	if math.IsNaN(f) {
+		return nil, errors.New("Val is NaN")
+	}
+
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !(f == 0 && math.Signbit(f)) {
+		return int64(f), nil
+	}
+
+	return &rs.RedisString{Content: []byte(utils.Float2String(f))}, nil
 }
 
 func (zl *ZipList) convertValToEntry(val interface{}) (*zlEntry, error) {
@@ -149,6 +412,12 @@ func (zl *ZipList) convertValToEntry(val interface{}) (*zlEntry, error) {
 		return nil, errors.New("Val to push is nil")
 	case int64:
 		entry = zl.transIntToEntry(val)
+	case float64:
+		converted, err := floatToVal(val)
+		if err != nil {
+			return nil, err
+		}
+		return zl.convertValToEntry(converted)
 	case *rs.RedisString:
 		entry, err = zl.transBinToEntry(val)
 		if err != nil {
@@ -160,7 +429,7 @@ func (zl *ZipList) convertValToEntry(val interface{}) (*zlEntry, error) {
 	return entry, nil
 }
 
-func (zl *ZipList) fillPrev(position *byte, entry *zlEntry) {
+func (zl *ZipList) fillPrev(position uint32, entry *zlEntry) {
 	prevEntry, _ := zl.decodeBytesToEntry(position)
 	prevLength := prevEntry.getLength()
 
@@ -176,9 +445,14 @@ func (zl *ZipList) fillPrev(position *byte, entry *zlEntry) {
 	entry.offset = prevEntry.offset + prevLength
 }
 
-func (zl *ZipList) insertEntry(position *byte, entry *zlEntry) {
-	nextPosition, _ := opPointer(position, entry.getPrevLength(), Plus)
+func (zl *ZipList) insertEntry(position uint32, entry *zlEntry) {
+	nextPosition := position + entry.getPrevLength()
+	isTail := zl.content[nextPosition] == Tail
+	// 尾节点自身 prev 字段的扩展不会改变尾节点的偏移量
+	toExtendEntries, _ := zl.calcBytesToExtend(entry.getLength(), nextPosition)
+	tailExtended := len(toExtendEntries) > 0 && toExtendEntries[len(toExtendEntries)-1].offset == zl.getOffset()
 	extendBytes := zl.copyContentForInsert(entry, nextPosition)
+	zl.version++
 
 	// 调整总长度
 	totalBytes := uint32(len(zl.content))
@@ -186,21 +460,22 @@ func (zl *ZipList) insertEntry(position *byte, entry *zlEntry) {
 
 	// 调整尾部节点偏移量
 	offset := binary.BigEndian.Uint32(zl.content[4:8])
-	if *nextPosition == 0xff {
+	if isTail {
 		offset += entry.getPrevLength()
 	} else {
 		offset += entry.getLength() + extendBytes
+		if tailExtended {
+			offset -= 4
+		}
 	}
 
 	binary.BigEndian.PutUint32(zl.content[4:8], offset)
 
 	// 调整结点个数
-	count := binary.BigEndian.Uint16(zl.content[8:10])
-	count += 1
-	binary.BigEndian.PutUint16(zl.content[8:10], count)
+	zl.incrCount(1)
 }
 
-func (zl *ZipList) copyContentForInsert(entry *zlEntry, nextPosition *byte) uint32 {
+func (zl *ZipList) copyContentForInsert(entry *zlEntry, nextPosition uint32) uint32 {
 	entryBytes := encodeEntryToBytes(entry)
 	toExtendEntries, extendBytes := zl.calcBytesToExtend(entry.getLength(), nextPosition)
 	newContent := make([]byte, len(zl.content)+len(entryBytes)+int(extendBytes))
@@ -244,12 +519,12 @@ func (zl *ZipList) copyContentForInsert(entry *zlEntry, nextPosition *byte) uint
 	return extendBytes
 }
 
-func (zl *ZipList) calcBytesToExtend(prevLen uint32, p *byte) ([]*zlEntry, uint32) {
+func (zl *ZipList) calcBytesToExtend(prevLen uint32, p uint32) ([]*zlEntry, uint32) {
 	var toExtendEntries []*zlEntry
 	extendBytes := uint32(0)
 
 	for {
-		if *p == Tail || !zl.whetherToExtend(prevLen, p) {
+		if zl.content[p] == Tail || !zl.whetherToExtend(prevLen, p) {
 			return toExtendEntries, extendBytes
 		}
 
@@ -258,12 +533,12 @@ func (zl *ZipList) calcBytesToExtend(prevLen uint32, p *byte) ([]*zlEntry, uint3
 		entry, _ := zl.decodeBytesToEntry(p)
 		toExtendEntries = append(toExtendEntries, entry)
 		prevLen = entry.getLength() + 4
-		p, _ = opPointer(p, entry.getLength(), Plus)
+		p += entry.getLength()
 	}
 }
 
-func (zl *ZipList) whetherToExtend(prevLen uint32, p *byte) bool {
-	if *p != 0xfe && prevLen > 0xfd {
+func (zl *ZipList) whetherToExtend(prevLen uint32, p uint32) bool {
+	if zl.content[p] != 0xfe && prevLen > 0xfd {
 		return true
 	}
 
@@ -285,116 +560,259 @@ func (zl *ZipList) ZipListDelete(val interface{}) (bool, error) {
 	return true, nil
 }
 
+// ZipListFind finds the first entry equal to val from the entry pointed by c, the search begins at
+// the head of zl when c is nil. Integers are compared with integers and strings with strings, a string
+// in canonical integer form is coerced to integer when compared with an integer, just like redis.
+// skip entries are skipped between every two comparisons, e.g. skip 1 compares only fields of a hash
+// stored as field/value pairs. A float64 val is converted in the same way as ZipListPush.
+// Nil is returned when val is not found.
+func (zl *ZipList) ZipListFind(c *Cursor, val interface{}, skip int) (*Cursor, error) {
+	start := uint32(firstEntryOffset)
+	if c != nil {
+		if err := zl.checkCursor(c); err != nil {
+			return nil, err
+		}
+		start = c.offset
+	}
+
+	entry, err := zl.findEntry(start, val, skip)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	return zl.newCursor(entry.offset), nil
+}
+
+// ZipListCompare reports whether the entry pointed by c is equal to val, entries are compared in the
+// same way as ZipListFind.
+func (zl *ZipList) ZipListCompare(c *Cursor, val interface{}) (bool, error) {
+	if err := zl.checkCursor(c); err != nil {
+		return false, err
+	}
+
+	entry, err := zl.decodeBytesToEntry(c.offset)
+	if err != nil {
+		return false, err
+	}
+
+	target, err := newFindTarget(val)
+	if err != nil {
+		return false, err
+	}
+
+	return target.match(entry), nil
+}
+
 func (zl *ZipList) findElem(val interface{}) (*zlEntry, error) {
-	targetEntry, err := zl.convertValToEntry(val)
+	return zl.findEntry(firstEntryOffset, val, 0)
+}
+
+func (zl *ZipList) findEntry(start uint32, val interface{}, skip int) (*zlEntry, error) {
+	target, err := newFindTarget(val)
 	if err != nil {
 		return nil, err
 	}
 
-	startIndex := &zl.content[12]
-	for {
-		if *startIndex == Tail {
-			return nil, nil
-		}
-
-		curEntry, err := zl.decodeBytesToEntry(startIndex)
+	toSkip := 0
+	for p := start; zl.content[p] != Tail; {
+		curEntry, err := zl.decodeBytesToEntry(p)
 		if err != nil {
 			return nil, err
 		}
 
-		if reflect.DeepEqual(targetEntry.data, curEntry.data) {
-			return curEntry, nil
+		if toSkip == 0 {
+			if target.match(curEntry) {
+				return curEntry, nil
+			}
+			toSkip = skip
+		} else {
+			toSkip--
 		}
 
-		startIndex, _ = opPointer(startIndex, curEntry.getLength(), Plus)
+		p += curEntry.getLength()
 	}
+
+	return nil, nil
 }
 
-func (zl *ZipList) deleteEntry(entry *zlEntry) {
-	prevLen := entry.getPrevLength()
-	position, _ := opPointer(&zl.content[0], entry.offset + entry.getLength(), Plus)
-	toShrinkEntries, toShrinkBytes := zl.calcBytesToShrink(prevLen, position)
+// findTarget 是查找的目标值，字符串目标值会预先尝试转换为整数
+type findTarget struct {
+	isInt    bool
+	intVal   int64
+	str      []byte
+	strIsInt bool
+}
+
+func newFindTarget(val interface{}) (*findTarget, error) {
+	switch val := val.(type) {
+	case nil:
+		return nil, errors.New("Val to find is nil")
+	case int64:
+		return &findTarget{isInt: true, intVal: val}, nil
+	case float64:
+		converted, err := floatToVal(val)
+		if err != nil {
+			return nil, err
+		}
+		return newFindTarget(converted)
+	case *rs.RedisString:
+		target := &findTarget{str: val.Content}
+		target.intVal, target.strIsInt = utils.String2Int64(val.Content)
+		return target, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown val to find: %v", val))
+	}
+}
+
+func (target *findTarget) match(entry *zlEntry) bool {
+	if entry.encodingType == intData {
+		if target.isInt || target.strIsInt {
+			return entry.dataInt == target.intVal
+		}
+		return false
+	}
 
-	newContent := make([]byte, len(zl.content) - int(entry.getLength()) - int(toShrinkBytes))
+	if target.isInt {
+		num, ok := utils.String2Int64(entry.data)
+		return ok && num == target.intVal
+	}
 
-	copy(newContent, zl.content[:entry.offset])
+	return bytes.Equal(entry.data, target.str)
+}
 
-	prevLen += 4
-	curOffset := entry.offset
-	originOffset := entry.offset + entry.getLength()
+func (zl *ZipList) deleteEntry(entry *zlEntry) {
+	zl.replaceEntries(entry.offset, entry.offset+entry.getLength(), 1, nil)
+}
 
-	for _, toShrinkEntry := range toShrinkEntries {
-		copy(newContent[curOffset + 1:], toShrinkEntry.encodingVal)
-		copy(newContent[curOffset + 1 + toShrinkEntry.encodingLength:], toShrinkEntry.data)
-		newContent[curOffset] = byte(prevLen - 4)
+// replaceEntries 将 [start, end) 区间内的 removed 个节点替换为 entry，entry 为 nil 时仅删除。
+// 之后节点的 prev 字段在一次遍历中完成级联更新，content 容量足够时复用原有的空间。
+func (zl *ZipList) replaceEntries(start, end uint32, removed int, entry *zlEntry) {
+	startEntry, _ := zl.decodeBytesToEntry(start)
+	prevLen := startEntry.getPrevLength()
+	oldTail := zl.getOffset()
+	// 新 content 中最后一个写入节点的偏移量，没有写入节点时为 start 之前的节点
+	lastOffset := start - prevLen
+
+	// middle 存放新节点以及 prev 字段发生变化的节点
+	var middle []byte
+	if entry != nil {
+		entry.prevVal = encodePrevField(prevLen)
+		entry.prevFieldLength = uint32(len(entry.prevVal))
+		entry.offset = start
+		middle = append(middle, encodeEntryToBytes(entry)...)
+		prevLen = entry.getLength()
+		lastOffset = start
+	}
+
+	p := end
+	newTail := lastOffset
+	for zl.content[p] != Tail {
+		next, _ := zl.decodeBytesToEntry(p)
+		prevVal := encodePrevField(prevLen)
+		if uint32(len(prevVal)) == next.prevFieldLength {
+			// prev 字段长度不变，之后的节点都不需要调整，只需要平移
+			newTail = oldTail + start + uint32(len(middle)) - p
+			middle = append(middle, prevVal...)
+			p += next.prevFieldLength
+			break
+		}
 
-		prevLen = toShrinkEntry.getLength()
-		curOffset += prevLen - 4
-		originOffset += prevLen
+		newTail = start + uint32(len(middle))
+		middle = append(middle, prevVal...)
+		middle = append(middle, next.encodingVal...)
+		middle = append(middle, next.data...)
+		prevLen = uint32(len(prevVal)) + next.encodingLength + next.dataLength
+		p += next.getLength()
 	}
 
-	copy(newContent[curOffset:], zl.content[originOffset:])
-	if zl.content[originOffset] != Tail {
-		if zl.content[originOffset] == 0xfe {
-			binary.BigEndian.PutUint32(newContent[curOffset + 1: curOffset + 5], prevLen - 4)
-		} else {
-			newContent[curOffset] = byte(prevLen - 4)
-		}
+	suffixLen := uint32(len(zl.content)) - p
+	newLen := start + uint32(len(middle)) + suffixLen
+	var newContent []byte
+	if int(newLen) <= cap(zl.content) {
+		newContent = zl.content[:newLen]
+	} else {
+		newContent = make([]byte, newLen)
+		copy(newContent, zl.content[:start])
 	}
+	// 先平移尾部再写入 middle，copy 可以正确处理重叠的区间
+	copy(newContent[start+uint32(len(middle)):], zl.content[p:p+suffixLen])
+	copy(newContent[start:], middle)
 
 	zl.content = newContent
+	zl.version++
 
-	// 调整总长度
-	totalBytes := uint32(len(zl.content))
-	binary.BigEndian.PutUint32(zl.content[:4], totalBytes)
+	binary.BigEndian.PutUint32(zl.content[:4], newLen)
+	binary.BigEndian.PutUint32(zl.content[4:8], newTail)
+	added := 0
+	if entry != nil {
+		added = 1
+	}
+	zl.incrCount(added - removed)
+}
 
-	// 调整尾部节点偏移量
-	offset := binary.BigEndian.Uint32(zl.content[4:8])
-	if *position == 0xff {
-		offset -= entry.getPrevLength()
-	} else {
-		offset -= entry.getLength() + toShrinkBytes
+func (zl *ZipList) getCount() uint16 {
+	return binary.BigEndian.Uint16(zl.content[8:10])
+}
+
+// incrCount adjusts the count in header. Just like redis, the count saturates at UINT16_MAX and then
+// the real count can only be got by a full scan.
+func (zl *ZipList) incrCount(delta int) {
+	count := zl.getCount()
+	if count == utils.UINT16_MAX {
+		// 删除节点时本身就需要拷贝节点之后的数据，顺便重新计数，使节点个数尽快回到首部中
+		if delta < 0 {
+			zl.setCount(zl.countEntries())
+		}
+		return
 	}
 
-	binary.BigEndian.PutUint32(zl.content[4:8], offset)
+	zl.setCount(int(count) + delta)
+}
 
-	// 调整结点个数
-	count := binary.BigEndian.Uint16(zl.content[8:10])
-	count -= 1
-	binary.BigEndian.PutUint16(zl.content[8:10], count)
+func (zl *ZipList) setCount(count int) {
+	if count > int(utils.UINT16_MAX) {
+		count = int(utils.UINT16_MAX)
+	}
+	binary.BigEndian.PutUint16(zl.content[8:10], uint16(count))
 }
 
-func (zl *ZipList) calcBytesToShrink(prevLen uint32, p *byte) ([]*zlEntry, uint32) {
-	var toShrinkEntries []*zlEntry
-	shrinkBytes := uint32(0)
+func (zl *ZipList) countEntries() int {
+	count := 0
+	for p := uint32(firstEntryOffset); zl.content[p] != Tail; count++ {
+		entry, _ := zl.decodeBytesToEntry(p)
+		p += entry.getLength()
+	}
 
-	for {
-		if *p == 0xff || !zl.whetherToShrink(prevLen, p) {
-			return toShrinkEntries, shrinkBytes
-		}
+	return count
+}
 
-		shrinkBytes += 4
-		entry, _ := zl.decodeBytesToEntry(p)
-		toShrinkEntries = append(toShrinkEntries, entry)
-		prevLen = entry.getLength() - 4
-		p, _ = opPointer(p, entry.getLength(), Plus)
+// ZipListLen returns the number of entries in zl. When the count in header saturates at UINT16_MAX,
+// zl is scanned to get the real count, and the header is not updated so that reading never modifies zl.
+func (zl *ZipList) ZipListLen() int {
+	if count := zl.getCount(); count < utils.UINT16_MAX {
+		return int(count)
 	}
+
+	return zl.countEntries()
 }
 
-func (zl *ZipList) whetherToShrink(prevLen uint32, p *byte) bool {
-	if *p == 0xfe && prevLen <= 0xfd {
-		return true
+func encodePrevField(prevLen uint32) []byte {
+	if prevLen <= 0xfd {
+		return []byte{byte(prevLen)}
 	}
 
-	return false
+	prevVal := make([]byte, 5)
+	prevVal[0] = Prev5BytesBegin
+	binary.BigEndian.PutUint32(prevVal[1:], prevLen)
+	return prevVal
 }
 
-func (zl *ZipList) decodeBytesToEntry(p *byte) (*zlEntry, error) {
-	if p == nil {
-		return nil, PointerIsNilErr
+func (zl *ZipList) decodeBytesToEntry(p uint32) (*zlEntry, error) {
+	if p >= uint32(len(zl.content)) {
+		return nil, OffsetOutOfRangeErr
 	}
 
-	if *p == Tail {
+	if zl.content[p] == Tail {
 		return nil, PointedToEndErr
 	}
 	entry := new(zlEntry)
@@ -410,9 +828,9 @@ func (zl *ZipList) decodeBytesToEntry(p *byte) (*zlEntry, error) {
 	return entry, nil
 }
 
-func (zl *ZipList) decodeFieldPrev(p *byte, entry *zlEntry) {
-	entry.offset = uint32(uintptr(unsafe.Pointer(p)) - uintptr(unsafe.Pointer(&zl.content[0])))
-	if *p == Prev5BytesBegin {
+func (zl *ZipList) decodeFieldPrev(p uint32, entry *zlEntry) {
+	entry.offset = p
+	if zl.content[p] == Prev5BytesBegin {
 		entry.prevFieldLength = 5
 		entry.prevVal = zl.content[entry.offset : entry.offset+5]
 	} else {
@@ -428,7 +846,7 @@ func (zl *ZipList) decodeBinEncoding(entry *zlEntry) {
 	if afterMask == 0x00 {
 		entry.encodingLength = 1
 		entry.dataLength = uint32(zl.content[entry.offset+entry.prevFieldLength] & 0x3f)
-	} else if afterMask == 0x01 {
+	} else if afterMask == 0x40 {
 		entry.encodingLength = 2
 		tmp := make([]byte, 2)
 		copy(tmp, zl.content[entry.offset+entry.prevFieldLength:])
@@ -449,28 +867,30 @@ func (zl *ZipList) decodeIntEncoding(entry *zlEntry) {
 	entry.encodingVal = zl.content[entry.offset+entry.prevFieldLength : entry.offset+entry.prevFieldLength+entry.encodingLength]
 	entry.encodingType = intData
 
+	dataOffset := entry.offset + entry.prevFieldLength + entry.encodingLength
 	switch entry.encodingVal[0] {
 	case EncodingInt8:
 		entry.dataLength = 1
+		entry.dataInt = int64(int8(zl.content[dataOffset]))
 	case EncodingInt16:
 		entry.dataLength = 2
-		// entry.dataInt = int64(binary.BigEndian.Uint16(zl.content[entry.offset+entry.prevFieldLength+entry.encodingLength : entry.offset+entry.prevFieldLength+entry.encodingLength+2]))
+		entry.dataInt = int64(int16(binary.BigEndian.Uint16(zl.content[dataOffset : dataOffset+2])))
 	case EncodingInt24:
 		entry.dataLength = 3
-		// entry.dataInt = int64(BigEndianUint24(zl.content[entry.offset+entry.prevFieldLength+entry.encodingLength : entry.offset+entry.prevFieldLength+entry.encodingLength+3]))
+		// 左移 8 位后再算术右移，完成符号扩展
+		entry.dataInt = int64(int32(BigEndianUint24(zl.content[dataOffset:dataOffset+3])<<8) >> 8)
 	case EncodingInt32:
 		entry.dataLength = 4
-		// entry.dataInt = int64(binary.BigEndian.Uint32(zl.content[entry.offset+entry.prevFieldLength+entry.encodingLength : entry.offset+entry.prevFieldLength+entry.encodingLength+4]))
+		entry.dataInt = int64(int32(binary.BigEndian.Uint32(zl.content[dataOffset : dataOffset+4])))
 	case EncodingInt64:
 		entry.dataLength = 8
-		// entry.dataInt = int64(binary.BigEndian.Uint64(zl.content[entry.offset+entry.prevFieldLength+entry.encodingLength : entry.offset+entry.prevFieldLength+entry.encodingLength+8]))
+		entry.dataInt = int64(binary.BigEndian.Uint64(zl.content[dataOffset : dataOffset+8]))
 	default:
 		entry.dataLength = 0
-		// entry.dataInt = int64(zl.content[entry.offset+entry.prevFieldLength] & 0x0f)
+		entry.dataInt = int64(entry.encodingVal[0] - EncodingIMMMin)
 	}
 
-	entry.data = zl.content[entry.offset+entry.prevFieldLength+entry.encodingLength : entry.offset+entry.prevFieldLength+entry.encodingLength+entry.dataLength]
-
+	entry.data = zl.content[dataOffset : dataOffset+entry.dataLength]
 }
 
 func (zl *ZipList) transIntToEntry(num int64) *zlEntry {
@@ -552,27 +972,6 @@ func encodeEntryToBytes(entry *zlEntry) []byte {
 	return bytes
 }
 
-type Op uint8
-
-const (
-	Plus  Op = 0
-	Minus Op = 1
-)
-
-var (
-	UnknownPointerOpErr = errors.New("Unknown pointer operation")
-)
-
-func opPointer(src *byte, offset uint32, op Op) (*byte, error) {
-	if op == Plus {
-		return (*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(src)) + uintptr(offset))), nil
-	} else if op == Minus {
-		return (*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(src)) - uintptr(offset))), nil
-	} else {
-		return nil, UnknownPointerOpErr
-	}
-}
-
 func BigEndianUint24(bytes []byte) uint32 {
 	_ = bytes[2]
 	return uint32(bytes[0])<<16 | uint32(bytes[1])<<8 | uint32(bytes[2])
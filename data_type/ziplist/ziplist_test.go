@@ -3,6 +3,9 @@ package ziplist
 import (
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"math"
+	"math/rand"
 	"reflect"
 	"testing"
 
@@ -71,7 +74,7 @@ func TestCreateZipList(t *testing.T) {
 				t.Errorf("CreateZipList() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
+			if !reflect.DeepEqual(got.content, tt.want.content) {
 				t.Errorf("CreateZipList() = %v, want %v", got, tt.want)
 			}
 		})
@@ -221,7 +224,7 @@ func TestZipList_ZipListPush(t *testing.T) {
 			if err := zl.ZipListPush(tt.args.val); (err != nil) != tt.wantErr {
 				t.Errorf("ZipList.ZipListPush() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if !reflect.DeepEqual(zl, wantZl) {
+			if !reflect.DeepEqual(zl.content, wantZl.content) {
 				t.Errorf("ZipList.ZipListPush() zl = %v, wantZl = %v", zl, wantZl)
 			}
 		})
@@ -233,7 +236,7 @@ func TestZipList_decodeBytesToEntry(t *testing.T) {
 		content []byte
 	}
 	type args struct {
-		p *byte
+		p uint32
 	}
 	tests := []struct {
 		name    string
@@ -588,34 +591,6 @@ func Test_encodeEntryToBytes(t *testing.T) {
 	}
 }
 
-func Test_opPointer(t *testing.T) {
-	type args struct {
-		src    *byte
-		offset uint32
-		op     Op
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    *byte
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := opPointer(tt.args.src, tt.args.offset, tt.args.op)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("opPointer() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("opPointer() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestBigEndianUint24(t *testing.T) {
 	type args struct {
 		bytes []byte
@@ -795,7 +770,7 @@ func TestZipList_ZipListInsert(t *testing.T) {
 		content []byte
 	}
 	type args struct {
-		position *byte
+		position *Cursor
 		val      interface{}
 	}
 
@@ -826,7 +801,7 @@ func TestZipList_ZipListInsert(t *testing.T) {
 				content: content,
 			},
 			args: args{
-				position: &content[10],
+				position: nil,
 				val:      int64(13),
 			},
 		},
@@ -836,7 +811,7 @@ func TestZipList_ZipListInsert(t *testing.T) {
 			zl := &ZipList{
 				content: tt.fields.content,
 			}
-			if err := zl.ZipListInsert(tt.args.position, tt.args.val); (err != nil) != tt.wantErr {
+			if _, err := zl.ZipListInsert(tt.args.position, tt.args.val); (err != nil) != tt.wantErr {
 				t.Errorf("ZipList.ZipListInsert() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if !reflect.DeepEqual(zl.content, resultContent) {
@@ -884,7 +859,7 @@ func TestZipList_fillPrev(t *testing.T) {
 		content []byte
 	}
 	type args struct {
-		position *byte
+		position uint32
 		entry    *zlEntry
 	}
 	tests := []struct {
@@ -909,7 +884,7 @@ func TestZipList_insertEntry(t *testing.T) {
 		content []byte
 	}
 	type args struct {
-		position *byte
+		position uint32
 		entry    *zlEntry
 	}
 	content := []byte{0x00, 0x00, 0x00, 0x13,
@@ -941,7 +916,7 @@ func TestZipList_insertEntry(t *testing.T) {
 					dataLength:      0xff,
 					data:            make([]byte, 0xff),
 				},
-				position: &content[10],
+				position: 10,
 			},
 		},
 	}
@@ -983,7 +958,7 @@ func TestZipList_fillContent(t *testing.T) {
 	}
 	type args struct {
 		entry        *zlEntry
-		nextPosition *byte
+		nextPosition uint32
 	}
 	content := []byte{0x00, 0x00, 0x00, 0x13,
 		0x00, 0x00, 0x00, 0x0f,
@@ -1014,7 +989,7 @@ func TestZipList_fillContent(t *testing.T) {
 					dataLength:      0xff,
 					data:            make([]byte, 0xff),
 				},
-				nextPosition: &content[12],
+				nextPosition: 12,
 			},
 		},
 	}
@@ -1057,7 +1032,7 @@ func TestZipList_calcBytesToExtend(t *testing.T) {
 	}
 	type args struct {
 		prevLen uint32
-		p       *byte
+		p       uint32
 	}
 	tests := []struct {
 		name   string
@@ -1090,7 +1065,7 @@ func TestZipList_whetherToExtend(t *testing.T) {
 	}
 	type args struct {
 		prevLen uint32
-		p       *byte
+		p       uint32
 	}
 	tests := []struct {
 		name   string
@@ -1117,7 +1092,7 @@ func TestZipList_decodeFieldPrev(t *testing.T) {
 		content []byte
 	}
 	type args struct {
-		p     *byte
+		p     uint32
 		entry *zlEntry
 	}
 	tests := []struct {
@@ -1143,7 +1118,7 @@ func TestZipList_copyContentForInsert(t *testing.T) {
 	}
 	type args struct {
 		entry        *zlEntry
-		nextPosition *byte
+		nextPosition uint32
 	}
 	tests := []struct {
 		name   string
@@ -1362,62 +1337,681 @@ func TestZipList_deleteEntry(t *testing.T) {
 	}
 }
 
-func TestZipList_calcBytesToShrink(t *testing.T) {
-	type fields struct {
-		content []byte
+func createTestZipList(t *testing.T) (*ZipList, []interface{}) {
+	elements := []interface{}{
+		int64(0),
+		int64(12),
+		int64(-1),
+		int64(utils.INT16_MIN),
+		int64(utils.INT24_MIN),
+		int64(utils.INT24_MAX),
+		int64(utils.INT32_MIN),
+		int64(utils.INT64_MAX),
+		&rs.RedisString{Content: []byte("abc")},
+		&rs.RedisString{Content: make([]byte, 100)},
+		&rs.RedisString{Content: make([]byte, 300)},
+		int64(7),
+	}
+	zl, err := CreateZipList(elements...)
+	if err != nil {
+		t.Fatalf("CreateZipList() error = %v", err)
 	}
-	type args struct {
-		prevLen uint32
-		p       *byte
+
+	return zl, elements
+}
+
+func TestZipList_ZipListIndex(t *testing.T) {
+	zl, elements := createTestZipList(t)
+
+	for index := -len(elements); index < len(elements); index++ {
+		want := elements[(index+len(elements))%len(elements)]
+		p := zl.ZipListIndex(index)
+		if p == nil {
+			t.Fatalf("ZipListIndex(%d) = nil, want %v", index, want)
+		}
+		got, err := zl.ZipListGet(p)
+		if err != nil {
+			t.Fatalf("ZipListGet() at index %d error = %v", index, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ZipListGet() at index %d = %v, want %v", index, got, want)
+		}
+	}
+
+	for _, index := range []int{len(elements), len(elements) + 1, -len(elements) - 1} {
+		if p := zl.ZipListIndex(index); p != nil {
+			t.Errorf("ZipListIndex(%d) = %v, want nil", index, p)
+		}
+	}
+
+	empty := InitZipList()
+	if p := empty.ZipListIndex(0); p != nil {
+		t.Errorf("ZipListIndex(0) of empty ziplist = %v, want nil", p)
+	}
+	if p := empty.ZipListIndex(-1); p != nil {
+		t.Errorf("ZipListIndex(-1) of empty ziplist = %v, want nil", p)
+	}
+}
+
+func TestZipList_ZipListNextPrev(t *testing.T) {
+	zl, elements := createTestZipList(t)
+
+	// 正向遍历
+	index := 0
+	for c := zl.ZipListIndex(0); c != nil; index++ {
+		got, _ := zl.ZipListGet(c)
+		if !reflect.DeepEqual(got, elements[index]) {
+			t.Errorf("forward index %d = %v, want %v", index, got, elements[index])
+		}
+
+		var err error
+		if c, err = zl.ZipListNext(c); err != nil {
+			t.Fatalf("ZipListNext() at index %d error = %v", index, err)
+		}
+	}
+	if index != len(elements) {
+		t.Fatalf("forward iteration visited %d entries, want %d", index, len(elements))
+	}
+
+	// 反向遍历
+	index = len(elements) - 1
+	for c := zl.ZipListIndex(-1); c != nil; index-- {
+		got, _ := zl.ZipListGet(c)
+		if !reflect.DeepEqual(got, elements[index]) {
+			t.Errorf("backward index %d = %v, want %v", index, got, elements[index])
+		}
+
+		var err error
+		if c, err = zl.ZipListPrev(c); err != nil {
+			t.Fatalf("ZipListPrev() at index %d error = %v", index, err)
+		}
+	}
+	if index != -1 {
+		t.Fatalf("backward iteration stopped at index %d, want -1", index)
+	}
+}
+
+func TestZipList_ZipListGet(t *testing.T) {
+	zl := InitZipList()
+	if _, err := zl.ZipListGet(nil); err != PointerIsNilErr {
+		t.Errorf("ZipListGet() of nil error = %v, want %v", err, PointerIsNilErr)
+	}
+	if _, err := zl.ZipListGet(zl.newCursor(firstEntryOffset)); err != PointedToEndErr {
+		t.Errorf("ZipListGet() of zlend error = %v, want %v", err, PointedToEndErr)
+	}
+
+	// 返回的字符串是拷贝，修改不影响 ziplist
+	_ = zl.ZipListPush(&rs.RedisString{Content: []byte("abc")})
+	got, _ := zl.ZipListGet(zl.ZipListIndex(0))
+	got.(*rs.RedisString).Content[0] = 'x'
+	again, _ := zl.ZipListGet(zl.ZipListIndex(0))
+	if string(again.(*rs.RedisString).Content) != "abc" {
+		t.Errorf("ZipListGet() = %s after modifying the returned value, want abc", again.(*rs.RedisString).Content)
+	}
+}
+
+func TestCursor_Invalidated(t *testing.T) {
+	zl, _ := createTestZipList(t)
+	other, _ := createTestZipList(t)
+
+	c := zl.ZipListIndex(1)
+	if !c.Valid() {
+		t.Fatalf("cursor should be valid before modification")
+	}
+	if _, err := other.ZipListGet(c); err != CursorNotBelongErr {
+		t.Errorf("ZipListGet() with cursor of other ziplist error = %v, want %v", err, CursorNotBelongErr)
 	}
+
+	// 插入会重新分配 content，之前的 Cursor 失效，返回的 Cursor 有效
+	newCursor, err := zl.ZipListInsert(c, &rs.RedisString{Content: make([]byte, 1000)})
+	if err != nil {
+		t.Fatalf("ZipListInsert() error = %v", err)
+	}
+	if c.Valid() {
+		t.Fatalf("cursor should be invalidated after inserting")
+	}
+	if _, err := zl.ZipListGet(c); err != CursorInvalidErr {
+		t.Errorf("ZipListGet() with invalidated cursor error = %v, want %v", err, CursorInvalidErr)
+	}
+	if _, err := zl.ZipListNext(c); err != CursorInvalidErr {
+		t.Errorf("ZipListNext() with invalidated cursor error = %v, want %v", err, CursorInvalidErr)
+	}
+	if _, err := zl.ZipListPrev(c); err != CursorInvalidErr {
+		t.Errorf("ZipListPrev() with invalidated cursor error = %v, want %v", err, CursorInvalidErr)
+	}
+	if _, err := zl.ZipListInsert(c, int64(1)); err != CursorInvalidErr {
+		t.Errorf("ZipListInsert() with invalidated cursor error = %v, want %v", err, CursorInvalidErr)
+	}
+
+	got, err := zl.ZipListGet(newCursor)
+	if err != nil || len(got.(*rs.RedisString).Content) != 1000 {
+		t.Fatalf("ZipListGet() of inserted entry = %v, %v", got, err)
+	}
+	prev, _ := zl.ZipListPrev(newCursor)
+	if got, _ := zl.ZipListGet(prev); got != int64(12) {
+		t.Errorf("entry before inserted entry = %v, want 12", got)
+	}
+
+	// 删除后返回下一个节点的 Cursor
+	next, err := zl.ZipListDeleteAt(newCursor)
+	if err != nil {
+		t.Fatalf("ZipListDeleteAt() error = %v", err)
+	}
+	if newCursor.Valid() {
+		t.Fatalf("cursor should be invalidated after deleting")
+	}
+	if got, _ := zl.ZipListGet(next); got != int64(-1) {
+		t.Errorf("entry after deleted entry = %v, want -1", got)
+	}
+
+	// 删除最后一个节点返回 nil
+	last, err := zl.ZipListDeleteAt(zl.ZipListIndex(-1))
+	if err != nil || last != nil {
+		t.Errorf("ZipListDeleteAt() of last entry = %v, %v, want nil", last, err)
+	}
+	if got, _ := zl.ZipListGet(zl.ZipListIndex(-1)); len(got.(*rs.RedisString).Content) != 300 {
+		t.Errorf("last entry after deleting = %v, want 300 bytes string", got)
+	}
+}
+
+func TestZipList_ZipListInsertHead(t *testing.T) {
+	zl, _ := CreateZipList(int64(2))
+	c, err := zl.ZipListInsert(nil, int64(1))
+	if err != nil {
+		t.Fatalf("ZipListInsert() error = %v", err)
+	}
+	if c.offset != firstEntryOffset {
+		t.Errorf("inserted entry offset = %d, want %d", c.offset, firstEntryOffset)
+	}
+	for index, want := range []int64{1, 2} {
+		if got, _ := zl.ZipListGet(zl.ZipListIndex(index)); got != want {
+			t.Errorf("index %d = %v, want %v", index, got, want)
+		}
+	}
+}
+
+// checkZipList 正向、反向遍历 zl 并检查首部，确认 zl 中的元素与 want 一致
+func checkZipList(t *testing.T, zl *ZipList, want []interface{}) {
+	t.Helper()
+
+	if got := zl.getLength(); got != uint32(len(zl.content)) {
+		t.Fatalf("length in header = %d, want %d", got, len(zl.content))
+	}
+	wantCount := len(want)
+	if wantCount > int(utils.UINT16_MAX) {
+		wantCount = int(utils.UINT16_MAX)
+	}
+	if got := binary.BigEndian.Uint16(zl.content[8:10]); int(got) != wantCount {
+		t.Fatalf("count in header = %d, want %d", got, wantCount)
+	}
+	if got := zl.ZipListLen(); got != len(want) {
+		t.Fatalf("ZipListLen() = %d, want %d", got, len(want))
+	}
+
+	index := 0
+	for c := zl.ZipListIndex(0); c != nil; index++ {
+		got, err := zl.ZipListGet(c)
+		if err != nil {
+			t.Fatalf("ZipListGet() at index %d error = %v", index, err)
+		}
+		if index >= len(want) || !reflect.DeepEqual(got, want[index]) {
+			t.Fatalf("index %d = %v, want %v", index, got, want)
+		}
+		if c, err = zl.ZipListNext(c); err != nil {
+			t.Fatalf("ZipListNext() at index %d error = %v", index, err)
+		}
+	}
+	if index != len(want) {
+		t.Fatalf("forward iteration visited %d entries, want %d", index, len(want))
+	}
+
+	index = len(want) - 1
+	for c := zl.ZipListIndex(-1); c != nil; index-- {
+		got, _ := zl.ZipListGet(c)
+		if index < 0 || !reflect.DeepEqual(got, want[index]) {
+			t.Fatalf("backward index %d = %v, want %v", index, got, want)
+		}
+		var err error
+		if c, err = zl.ZipListPrev(c); err != nil {
+			t.Fatalf("ZipListPrev() at index %d error = %v", index, err)
+		}
+	}
+	if index != -1 {
+		t.Fatalf("backward iteration stopped at index %d, want -1", index)
+	}
+}
+
+func TestZipList_ZipListReplace(t *testing.T) {
+	zl, elements := createTestZipList(t)
+
+	// 长度相同，原地覆盖，Cursor 依然有效
+	c := zl.ZipListIndex(2)
+	other := zl.ZipListIndex(5)
+	got, err := zl.ZipListReplace(c, int64(-2))
+	if err != nil {
+		t.Fatalf("ZipListReplace() error = %v", err)
+	}
+	if !c.Valid() || !other.Valid() || got.offset != c.offset {
+		t.Fatalf("cursors should be valid after replacing in place")
+	}
+	elements[2] = int64(-2)
+	checkZipList(t, zl, elements)
+
+	// 长度变大，后面节点的 prev 字段需要扩展
+	c = zl.ZipListIndex(9)
+	if got, err = zl.ZipListReplace(c, &rs.RedisString{Content: make([]byte, 500)}); err != nil {
+		t.Fatalf("ZipListReplace() error = %v", err)
+	}
+	if c.Valid() {
+		t.Fatalf("cursor should be invalidated after replacing with longer entry")
+	}
+	elements[9] = &rs.RedisString{Content: make([]byte, 500)}
+	checkZipList(t, zl, elements)
+	if val, _ := zl.ZipListGet(got); !reflect.DeepEqual(val, elements[9]) {
+		t.Fatalf("ZipListGet() of returned cursor = %v, want %v", val, elements[9])
+	}
+
+	// 替换最后一个节点
+	if _, err = zl.ZipListReplace(zl.ZipListIndex(-1), &rs.RedisString{Content: []byte("tail")}); err != nil {
+		t.Fatalf("ZipListReplace() error = %v", err)
+	}
+	elements[len(elements)-1] = &rs.RedisString{Content: []byte("tail")}
+	checkZipList(t, zl, elements)
+
+	// 长度变小，后面节点的 prev 字段需要收缩
+	if _, err = zl.ZipListReplace(zl.ZipListIndex(10), int64(1)); err != nil {
+		t.Fatalf("ZipListReplace() error = %v", err)
+	}
+	elements[10] = int64(1)
+	checkZipList(t, zl, elements)
+}
+
+func TestZipList_ZipListDeleteRange(t *testing.T) {
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   []*zlEntry
-		want1  uint32
+		name        string
+		index       int
+		count       int
+		wantDeleted int
 	}{
-		// TODO: Add test cases.
+		{name: "head", index: 0, count: 3, wantDeleted: 3},
+		{name: "middle", index: 4, count: 4, wantDeleted: 4},
+		{name: "large entries", index: 8, count: 2, wantDeleted: 2},
+		{name: "before large entry", index: 7, count: 3, wantDeleted: 3},
+		{name: "to the end", index: -3, count: 10, wantDeleted: 3},
+		{name: "all", index: 0, count: 100, wantDeleted: 12},
+		{name: "out of range", index: 12, count: 1, wantDeleted: 0},
+		{name: "zero count", index: 0, count: 0, wantDeleted: 0},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			zl := &ZipList{
-				content: tt.fields.content,
+			zl, elements := createTestZipList(t)
+			deleted, err := zl.ZipListDeleteRange(tt.index, tt.count)
+			if err != nil {
+				t.Fatalf("ZipListDeleteRange() error = %v", err)
 			}
-			got, got1 := zl.calcBytesToShrink(tt.args.prevLen, tt.args.p)
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ZipList.calcBytesToShrink() got = %v, want %v", got, tt.want)
+			if deleted != tt.wantDeleted {
+				t.Fatalf("ZipListDeleteRange() = %d, want %d", deleted, tt.wantDeleted)
 			}
-			if got1 != tt.want1 {
-				t.Errorf("ZipList.calcBytesToShrink() got1 = %v, want %v", got1, tt.want1)
+
+			start := tt.index
+			if start < 0 {
+				start += len(elements)
 			}
+			if deleted > 0 {
+				elements = append(elements[:start], elements[start+deleted:]...)
+			}
+			checkZipList(t, zl, elements)
 		})
 	}
 }
 
-func TestZipList_whetherToShrink(t *testing.T) {
-	type fields struct {
-		content []byte
+// TestZipList_RandomOperations 随机执行插入、替换、删除，并与切片模型对比。
+// 字符串长度集中在 250 附近，使 prev 字段频繁地在 1 字节与 5 字节之间切换，触发级联更新。
+func TestZipList_RandomOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomVal := func() interface{} {
+		switch r.Intn(3) {
+		case 0:
+			return int64(r.Intn(1000) - 500)
+		case 1:
+			return &rs.RedisString{Content: make([]byte, 240+r.Intn(20))}
+		default:
+			return &rs.RedisString{Content: make([]byte, r.Intn(5))}
+		}
 	}
-	type args struct {
-		prevLen uint32
-		p       *byte
+
+	zl := InitZipList()
+	var model []interface{}
+	for i := 0; i < 2000; i++ {
+		switch op := r.Intn(5); {
+		case op == 0 || len(model) == 0:
+			val := randomVal()
+			if err := zl.ZipListPush(val); err != nil {
+				t.Fatalf("ZipListPush() error = %v", err)
+			}
+			model = append(model, val)
+		case op == 1:
+			index := r.Intn(len(model) + 1)
+			var c *Cursor
+			if index > 0 {
+				c = zl.ZipListIndex(index - 1)
+			}
+			val := randomVal()
+			if _, err := zl.ZipListInsert(c, val); err != nil {
+				t.Fatalf("ZipListInsert() error = %v", err)
+			}
+			model = append(model[:index], append([]interface{}{val}, model[index:]...)...)
+		case op == 2:
+			index := r.Intn(len(model))
+			val := randomVal()
+			if _, err := zl.ZipListReplace(zl.ZipListIndex(index), val); err != nil {
+				t.Fatalf("ZipListReplace() error = %v", err)
+			}
+			model[index] = val
+		case op == 3:
+			index := r.Intn(len(model))
+			count := r.Intn(4)
+			deleted, _ := zl.ZipListDeleteRange(index, count)
+			if index+count > len(model) {
+				count = len(model) - index
+			}
+			if deleted != count {
+				t.Fatalf("ZipListDeleteRange(%d, %d) = %d, want %d", index, count, deleted, count)
+			}
+			model = append(model[:index], model[index+count:]...)
+		default:
+			index := r.Intn(len(model))
+			if _, err := zl.ZipListDeleteAt(zl.ZipListIndex(index)); err != nil {
+				t.Fatalf("ZipListDeleteAt() error = %v", err)
+			}
+			model = append(model[:index], model[index+1:]...)
+		}
+
+		checkZipList(t, zl, model)
 	}
+}
+
+func TestZipList_ZipListFind(t *testing.T) {
+	str := func(s string) *rs.RedisString {
+		return &rs.RedisString{Content: []byte(s)}
+	}
+	zl, err := CreateZipList(str("1"), int64(49), str("12"), int64(12), str("012"), str("f1"), str("f2"), str("f2"), str("v2"))
+	if err != nil {
+		t.Fatalf("CreateZipList() error = %v", err)
+	}
+
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   bool
+		name      string
+		start     int
+		val       interface{}
+		skip      int
+		wantIndex int
 	}{
-		// TODO: Add test cases.
+		{name: "int not match one byte string", val: int64(49), wantIndex: 1},
+		{name: "string coerced to int", val: str("49"), wantIndex: 1},
+		{name: "string", val: str("12"), wantIndex: 2},
+		{name: "int matches string in integer form", val: int64(1), wantIndex: 0},
+		{name: "int from cursor", start: 3, val: int64(12), wantIndex: 3},
+		{name: "string not in canonical form", val: str("012"), wantIndex: 4},
+		{name: "not found", val: int64(0), wantIndex: -1},
+		{name: "empty string", val: str(""), wantIndex: -1},
+		{name: "skip values", start: 5, val: str("f2"), skip: 1, wantIndex: 7},
+		{name: "skip not found", start: 5, val: str("v2"), skip: 1, wantIndex: -1},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			zl := &ZipList{
-				content: tt.fields.content,
+			var start *Cursor
+			if tt.start > 0 {
+				start = zl.ZipListIndex(tt.start)
 			}
-			if got := zl.whetherToShrink(tt.args.prevLen, tt.args.p); got != tt.want {
-				t.Errorf("ZipList.whetherToShrink() = %v, want %v", got, tt.want)
+			got, err := zl.ZipListFind(start, tt.val, tt.skip)
+			if err != nil {
+				t.Fatalf("ZipListFind() error = %v", err)
+			}
+
+			if tt.wantIndex < 0 {
+				if got != nil {
+					t.Fatalf("ZipListFind() = %v, want nil", got)
+				}
+				return
+			}
+			if want := zl.ZipListIndex(tt.wantIndex); got == nil || got.offset != want.offset {
+				t.Fatalf("ZipListFind() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	if _, err := zl.ZipListFind(nil, 1, 0); err == nil {
+		t.Errorf("ZipListFind() with unknown type should fail")
+	}
+	c := zl.ZipListIndex(0)
+	_ = zl.ZipListPush(int64(1))
+	if _, err := zl.ZipListFind(c, int64(1), 0); err != CursorInvalidErr {
+		t.Errorf("ZipListFind() with invalidated cursor error = %v, want %v", err, CursorInvalidErr)
+	}
+}
+
+// createZipListWithImmEntries 直接构造包含 count 个立即数 1 的 ziplist，首部中的计数按照饱和规则填写
+func createZipListWithImmEntries(count int) *ZipList {
+	content := make([]byte, firstEntryOffset, firstEntryOffset+2*count+1)
+	copy(content, InitZipList().content)
+	for i := 0; i < count; i++ {
+		content = append(content, 0x02, EncodingIMMMin+1)
+	}
+	content = append(content, Tail)
+
+	binary.BigEndian.PutUint32(content[:4], uint32(len(content)))
+	binary.BigEndian.PutUint32(content[4:8], uint32(len(content)-3))
+	headerCount := count
+	if headerCount > int(utils.UINT16_MAX) {
+		headerCount = int(utils.UINT16_MAX)
+	}
+	binary.BigEndian.PutUint16(content[8:10], uint16(headerCount))
+
+	return &ZipList{content: content}
+}
+
+func TestZipList_ZipListLen(t *testing.T) {
+	maxCount := int(utils.UINT16_MAX)
+
+	zl := createZipListWithImmEntries(maxCount - 1)
+	if err := ValidateIntegrity(zl.Bytes(), true); err != nil {
+		t.Fatalf("ValidateIntegrity() error = %v", err)
+	}
+	if got := zl.ZipListLen(); got != maxCount-1 {
+		t.Fatalf("ZipListLen() = %d, want %d", got, maxCount-1)
+	}
+
+	// 达到 UINT16_MAX 后首部计数饱和
+	for i := 0; i < 2; i++ {
+		if err := zl.ZipListPush(int64(1)); err != nil {
+			t.Fatalf("ZipListPush() error = %v", err)
+		}
+	}
+	if got := zl.getCount(); got != utils.UINT16_MAX {
+		t.Fatalf("count in header = %d, want %d", got, utils.UINT16_MAX)
+	}
+	if got := zl.ZipListLen(); got != maxCount+1 {
+		t.Fatalf("ZipListLen() = %d, want %d", got, maxCount+1)
+	}
+	if err := ValidateIntegrity(zl.Bytes(), true); err != nil {
+		t.Fatalf("ValidateIntegrity() error = %v", err)
+	}
+
+	// 插入、替换不会使计数回绕
+	if _, err := zl.ZipListInsert(nil, &rs.RedisString{Content: []byte("head")}); err != nil {
+		t.Fatalf("ZipListInsert() error = %v", err)
+	}
+	if _, err := zl.ZipListReplace(zl.ZipListIndex(0), int64(1)); err != nil {
+		t.Fatalf("ZipListReplace() error = %v", err)
+	}
+	if got := zl.ZipListLen(); got != maxCount+2 {
+		t.Fatalf("ZipListLen() = %d, want %d", got, maxCount+2)
+	}
+
+	// 删除后依然不少于 UINT16_MAX 个节点，计数保持饱和
+	if deleted, _ := zl.ZipListDeleteRange(0, 2); deleted != 2 {
+		t.Fatalf("ZipListDeleteRange() = %d, want 2", deleted)
+	}
+	if got, length := zl.getCount(), zl.ZipListLen(); got != utils.UINT16_MAX || length != maxCount {
+		t.Fatalf("count in header = %d, ZipListLen() = %d, want %d, %d", got, length, utils.UINT16_MAX, maxCount)
+	}
+
+	// 节点个数小于 UINT16_MAX 后重新写入首部
+	if _, err := zl.ZipListDeleteAt(zl.ZipListIndex(-1)); err != nil {
+		t.Fatalf("ZipListDeleteAt() error = %v", err)
+	}
+	if got := zl.getCount(); int(got) != maxCount-1 {
+		t.Fatalf("count in header = %d, want %d", got, maxCount-1)
+	}
+	if deleted, _ := zl.ZipListDelete(int64(1)); !deleted {
+		t.Fatalf("ZipListDelete() = false, want true")
+	}
+	if got, length := zl.getCount(), zl.ZipListLen(); int(got) != maxCount-2 || length != maxCount-2 {
+		t.Fatalf("count in header = %d, ZipListLen() = %d, want %d", got, length, maxCount-2)
+	}
+	if err := ValidateIntegrity(zl.Bytes(), true); err != nil {
+		t.Fatalf("ValidateIntegrity() error = %v", err)
+	}
+}
+
+func TestValidateIntegrity_SaturatedCount(t *testing.T) {
+	zl := createZipListWithImmEntries(int(utils.UINT16_MAX) + 10)
+	if err := ValidateIntegrity(zl.Bytes(), true); err != nil {
+		t.Fatalf("ValidateIntegrity() error = %v", err)
+	}
+
+	// 节点个数超过 UINT16_MAX 但首部计数没有饱和
+	binary.BigEndian.PutUint16(zl.content[8:10], 10)
+	if err := ValidateIntegrity(zl.Bytes(), true); err == nil {
+		t.Fatalf("ValidateIntegrity() with wrapped count should fail")
+	}
+
+	// 首部计数饱和但节点个数不足 UINT16_MAX
+	zl = createZipListWithImmEntries(10)
+	binary.BigEndian.PutUint16(zl.content[8:10], utils.UINT16_MAX)
+	if err := ValidateIntegrity(zl.Bytes(), true); err == nil {
+		t.Fatalf("ValidateIntegrity() with saturated count of 10 entries should fail")
+	}
+}
+
+func TestZipList_Float(t *testing.T) {
+	tests := []struct {
+		name    string
+		val     float64
+		wantVal interface{}
+	}{
+		{name: "integral", val: 3, wantVal: int64(3)},
+		{name: "negative integral", val: -100000, wantVal: int64(-100000)},
+		{name: "zero", val: 0, wantVal: int64(0)},
+		{name: "negative zero", val: math.Copysign(0, -1), wantVal: &rs.RedisString{Content: []byte("-0")}},
+		{name: "fraction", val: 0.1, wantVal: &rs.RedisString{Content: []byte("0.1")}},
+		{name: "max int64", val: math.MaxInt64, wantVal: &rs.RedisString{Content: []byte("9.223372036854776e+18")}},
+		{name: "min int64", val: math.MinInt64, wantVal: int64(math.MinInt64)},
+		{name: "big", val: 1e300, wantVal: &rs.RedisString{Content: []byte("1e+300")}},
+		{name: "inf", val: math.Inf(1), wantVal: &rs.RedisString{Content: []byte("inf")}},
+		{name: "-inf", val: math.Inf(-1), wantVal: &rs.RedisString{Content: []byte("-inf")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zl := InitZipList()
+			if err := zl.ZipListPush(tt.val); err != nil {
+				t.Fatalf("ZipListPush() error = %v", err)
+			}
+			checkZipList(t, zl, []interface{}{tt.wantVal})
+
+			got, err := zl.ZipListGetFloat(zl.ZipListIndex(0))
+			if err != nil {
+				t.Fatalf("ZipListGetFloat() error = %v", err)
+			}
+			if math.Float64bits(got) != math.Float64bits(tt.val) {
+				t.Errorf("ZipListGetFloat() = %v, want %v", got, tt.val)
+			}
+
+			if c, err := zl.ZipListFind(nil, tt.val, 0); err != nil || c == nil {
+				t.Errorf("ZipListFind() = %v, %v, want the first entry", c, err)
+			}
+		})
+	}
+
+	if err := InitZipList().ZipListPush(math.NaN()); err == nil {
+		t.Errorf("ZipListPush(NaN) should fail")
+	}
+
+	zl, _ := CreateZipList(&rs.RedisString{Content: []byte("abc")})
+	if _, err := zl.ZipListGetFloat(zl.ZipListIndex(0)); !errors.Is(err, NotFloatErr) {
+		t.Errorf("ZipListGetFloat() of string error = %v, want %v", err, NotFloatErr)
+	}
+}
+
+// TestZipList_FloatRoundTrip 随机的 float64 写入 ziplist 后读出的值必须完全一致
+func TestZipList_FloatRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	zl := InitZipList()
+	var floats []float64
+	for i := 0; i < 1000; i++ {
+		var f float64
+		switch i % 3 {
+		case 0:
+			f = math.Float64frombits(r.Uint64())
+		case 1:
+			f = float64(r.Intn(1000)) / 8
+		default:
+			f = r.NormFloat64() * 1e6
+		}
+		if math.IsNaN(f) {
+			continue
+		}
+		if err := zl.ZipListPush(f); err != nil {
+			t.Fatalf("ZipListPush(%v) error = %v", f, err)
+		}
+		floats = append(floats, f)
+	}
+
+	index := 0
+	for c := zl.ZipListIndex(0); c != nil; c, _ = zl.ZipListNext(c) {
+		got, err := zl.ZipListGetFloat(c)
+		if err != nil {
+			t.Fatalf("ZipListGetFloat() error = %v", err)
+		}
+		if math.Float64bits(got) != math.Float64bits(floats[index]) {
+			t.Fatalf("index %d = %v, want %v", index, got, floats[index])
+		}
+		index++
+	}
+	if index != len(floats) {
+		t.Fatalf("got %d floats, want %d", index, len(floats))
+	}
+}
+
+func TestZipList_ZipListCompare(t *testing.T) {
+	zl, err := CreateZipList(int64(10), &rs.RedisString{Content: []byte("abc")}, 1.5)
+	if err != nil {
+		t.Fatalf("CreateZipList() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		index int
+		val   interface{}
+		want  bool
+	}{
+		{name: "int", index: 0, val: int64(10), want: true},
+		{name: "string matches int", index: 0, val: &rs.RedisString{Content: []byte("10")}, want: true},
+		{name: "float matches int", index: 0, val: float64(10), want: true},
+		{name: "different int", index: 0, val: int64(11), want: false},
+		{name: "string", index: 1, val: &rs.RedisString{Content: []byte("abc")}, want: true},
+		{name: "int not matches string", index: 1, val: int64(10), want: false},
+		{name: "float", index: 2, val: 1.5, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := zl.ZipListCompare(zl.ZipListIndex(tt.index), tt.val)
+			if err != nil {
+				t.Fatalf("ZipListCompare() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ZipListCompare() = %v, want %v", got, tt.want)
 			}
 		})
 	}
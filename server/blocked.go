@@ -0,0 +1,162 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// 阻塞操作的实现与 redis 相同：命令在所有 key 都为空时阻塞客户端，并将客户端按顺序记录在
+// redisDb.blockingKeys 中。list 类型的 key 被创建时(只有不存在的 key 上才可能有阻塞的客户端)，
+// key 被记录到 redisDb.readyKeys，当前命令执行完成后按 FIFO 的顺序重新执行阻塞的客户端的命令。
+// 在 MULTI/EXEC 中执行时不会阻塞，EXEC 完成后才处理 readyKeys，因此事务中的 push 对阻塞的客户端是原子的。
+
+type blockingState struct {
+	keys [][]byte
+	// deadline 为零值时表示永久阻塞
+	deadline time.Time
+	timer    *time.Timer
+	// id 用于区分不同的阻塞，避免过期的超时回调解除之后的阻塞
+	id uint64
+}
+
+// getTimeoutOrReply parses timeout in seconds, zero timeout means blocking forever.
+func (c *Client) getTimeoutOrReply(arg []byte) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds > math.MaxInt64/float64(time.Second) {
+		c.addReplyError("ERR timeout is not a float or out of range")
+		return 0, false
+	}
+	if seconds < 0 {
+		c.addReplyError("ERR timeout is negative")
+		return 0, false
+	}
+
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// blockForKeys blocks c until one of keys is ready or timeout.
+func (c *Client) blockForKeys(keys [][]byte, timeout time.Duration) {
+	s := c.server
+	c.blocked = true
+	c.bstate.id++
+
+	// 重新执行命令后再次阻塞时，沿用之前的超时时间
+	if !c.reprocessing {
+		c.bstate.deadline = time.Time{}
+		if timeout > 0 {
+			c.bstate.deadline = time.Now().Add(timeout)
+		}
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	c.bstate.keys = c.bstate.keys[:0]
+	for _, key := range keys {
+		if _, ok := seen[string(key)]; ok {
+			continue
+		}
+		seen[string(key)] = struct{}{}
+		c.bstate.keys = append(c.bstate.keys, key)
+		c.db.blockingKeys[string(key)] = append(c.db.blockingKeys[string(key)], c)
+	}
+
+	if !c.bstate.deadline.IsZero() {
+		id := c.bstate.id
+		c.bstate.timer = time.AfterFunc(time.Until(c.bstate.deadline), func() {
+			s.submit(func() {
+				if c.blocked && c.bstate.id == id {
+					s.unblockClient(c)
+					c.addReplyNullArray()
+					c.done <- struct{}{}
+				}
+			})
+		})
+	}
+}
+
+// unblockClient removes c from the blocking registry without replying.
+func (s *Server) unblockClient(c *Client) {
+	for _, key := range c.bstate.keys {
+		clients := c.db.blockingKeys[string(key)]
+		for i, blocked := range clients {
+			if blocked == c {
+				clients = append(clients[:i], clients[i+1:]...)
+				break
+			}
+		}
+
+		if len(clients) == 0 {
+			delete(c.db.blockingKeys, string(key))
+		} else {
+			c.db.blockingKeys[string(key)] = clients
+		}
+	}
+
+	if c.bstate.timer != nil {
+		c.bstate.timer.Stop()
+		c.bstate.timer = nil
+	}
+	c.bstate.keys = nil
+	c.blocked = false
+}
+
+// signalKeyAsReady records key when there are clients blocked on it.
+func (db *redisDb) signalKeyAsReady(key []byte) {
+	if _, ok := db.blockingKeys[string(key)]; !ok {
+		return
+	}
+	if _, ok := db.readyKeySet[string(key)]; ok {
+		return
+	}
+
+	db.readyKeySet[string(key)] = struct{}{}
+	db.readyKeys = append(db.readyKeys, string(key))
+}
+
+// handleClientsBlockedOnKeys serves clients blocked on ready keys in FIFO order. Commands of served
+// clients may make other keys ready, e.g. BLMOVE, so it loops until there are no ready keys.
+func (s *Server) handleClientsBlockedOnKeys() {
+	for {
+		handled := false
+		for _, db := range s.dbs {
+			if len(db.readyKeys) == 0 {
+				continue
+			}
+			handled = true
+
+			readyKeys := db.readyKeys
+			db.readyKeys = nil
+			for _, key := range readyKeys {
+				delete(db.readyKeySet, key)
+				s.serveClientsBlockedOnKey(db, key)
+			}
+		}
+
+		if !handled {
+			return
+		}
+	}
+}
+
+func (s *Server) serveClientsBlockedOnKey(db *redisDb, key string) {
+	clients := append([]*Client{}, db.blockingKeys[key]...)
+	for _, c := range clients {
+		// key 被前面的客户端取空或者类型不再是 list，剩下的客户端继续阻塞
+		obj := db.lookupKeyWrite([]byte(key))
+		if obj == nil || obj.Type != ObjList {
+			return
+		}
+		if !c.blocked {
+			continue
+		}
+
+		s.unblockClient(c)
+		c.reprocessing = true
+		s.call(c)
+		c.reprocessing = false
+
+		if !c.blocked {
+			c.done <- struct{}{}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// servedReply 检查阻塞的客户端已经被服务，返回它的回复
+func servedReply(t *testing.T, c *Client) string {
+	t.Helper()
+
+	select {
+	case <-c.done:
+	default:
+		t.Fatalf("client %d is not served", c.id)
+	}
+	reply := c.reply.String()
+	c.reply.Reset()
+
+	return reply
+}
+
+// execInLoop 在事件循环中执行命令，用于事件循环运行时的测试
+func (c *Client) execInLoop(args ...string) string {
+	reply := make(chan string)
+	c.server.submit(func() {
+		reply <- c.exec(args...)
+	})
+	return <-reply
+}
+
+func TestBlockingPop(t *testing.T) {
+	s := CreateServer()
+	a, b, pusher := newTestClient(s), newTestClient(s), newTestClient(s)
+	a.id, b.id = 1, 2
+
+	if got := a.exec("BLPOP", "k1", "k2", "0"); got != "" || !a.blocked {
+		t.Fatalf("BLPOP on empty keys should block, reply = %q", got)
+	}
+	if got := b.exec("BRPOP", "k2", "0"); got != "" || !b.blocked {
+		t.Fatalf("BRPOP on empty keys should block, reply = %q", got)
+	}
+
+	// 一次 push 多个元素时，阻塞的客户端按顺序被服务
+	runCmdCases(t, pusher, []cmdCase{
+		{args: []string{"RPUSH", "k2", "a", "b", "c"}, want: ":3\r\n"},
+	})
+	if got, want := servedReply(t, a), arrayReply("k2", "a"); got != want {
+		t.Fatalf("reply of a = %q, want %q", got, want)
+	}
+	if got, want := servedReply(t, b), arrayReply("k2", "c"); got != want {
+		t.Fatalf("reply of b = %q, want %q", got, want)
+	}
+	if len(s.dbs[0].blockingKeys) != 0 {
+		t.Fatalf("blockingKeys = %v, want empty", s.dbs[0].blockingKeys)
+	}
+
+	runCmdCases(t, pusher, []cmdCase{
+		{args: []string{"LRANGE", "k2", "0", "-1"}, want: arrayReply("b")},
+		// key 不为空时不阻塞
+		{args: []string{"BLPOP", "k1", "k2", "0"}, want: arrayReply("k2", "b")},
+		{args: []string{"LLEN", "k2"}, want: ":0\r\n"},
+		{args: []string{"BLPOP", "k", "-1"}, want: "-ERR timeout is negative\r\n"},
+		{args: []string{"BLPOP", "k", "a"}, want: "-ERR timeout is not a float or out of range\r\n"},
+		{args: []string{"BLPOP", "k", "inf"}, want: "-ERR timeout is not a float or out of range\r\n"},
+	})
+}
+
+func TestBlockingFIFO(t *testing.T) {
+	s := CreateServer()
+	a, b, pusher := newTestClient(s), newTestClient(s), newTestClient(s)
+
+	a.exec("BLPOP", "k", "0")
+	b.exec("BLPOP", "k", "0")
+	pusher.exec("LPUSH", "k", "1")
+	if got, want := servedReply(t, a), arrayReply("k", "1"); got != want {
+		t.Fatalf("reply of a = %q, want %q", got, want)
+	}
+	if !b.blocked {
+		t.Fatalf("b should still be blocked")
+	}
+
+	pusher.exec("LPUSH", "k", "2")
+	if got, want := servedReply(t, b), arrayReply("k", "2"); got != want {
+		t.Fatalf("reply of b = %q, want %q", got, want)
+	}
+}
+
+func TestBlockingOtherDb(t *testing.T) {
+	s := CreateServer()
+	a, pusher := newTestClient(s), newTestClient(s)
+
+	a.exec("BLPOP", "k", "0")
+	pusher.exec("SELECT", "1")
+	pusher.exec("RPUSH", "k", "a")
+	if !a.blocked {
+		t.Fatalf("push to another db should not serve the client")
+	}
+
+	pusher.exec("SELECT", "0")
+	pusher.exec("RPUSH", "k", "b")
+	if got, want := servedReply(t, a), arrayReply("k", "b"); got != want {
+		t.Fatalf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestBlmove(t *testing.T) {
+	s := CreateServer()
+	mover, popper, pusher := newTestClient(s), newTestClient(s), newTestClient(s)
+
+	// BLMOVE 写入 dst 后继续服务阻塞在 dst 上的客户端
+	mover.exec("BLMOVE", "src", "dst", "RIGHT", "LEFT", "0")
+	popper.exec("BLMPOP", "0", "2", "other", "dst", "LEFT", "COUNT", "10")
+	pusher.exec("RPUSH", "src", "a", "b")
+
+	if got, want := servedReply(t, mover), "$1\r\nb\r\n"; got != want {
+		t.Fatalf("reply of BLMOVE = %q, want %q", got, want)
+	}
+	if got, want := servedReply(t, popper), "*2\r\n$3\r\ndst\r\n"+arrayReply("b"); got != want {
+		t.Fatalf("reply of BLMPOP = %q, want %q", got, want)
+	}
+
+	runCmdCases(t, pusher, []cmdCase{
+		{args: []string{"LRANGE", "src", "0", "-1"}, want: arrayReply("a")},
+		{args: []string{"LLEN", "dst"}, want: ":0\r\n"},
+		{args: []string{"BLMOVE", "src", "dst", "LEFT", "RIGHT", "0"}, want: "$1\r\na\r\n"},
+		{args: []string{"BLMOVE", "src", "dst", "UP", "RIGHT", "0"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"BLMPOP", "0", "1", "dst", "LEFT"}, want: "*2\r\n$3\r\ndst\r\n" + arrayReply("a")},
+		{args: []string{"BLMPOP", "-1", "1", "dst", "LEFT"}, want: "-ERR timeout is negative\r\n"},
+	})
+}
+
+func TestBlockingMultiExec(t *testing.T) {
+	s := CreateServer()
+	a, pusher := newTestClient(s), newTestClient(s)
+
+	// 在同一个事务中创建并删除 key，EXEC 完成时 key 已经不存在，客户端继续阻塞
+	a.exec("BLPOP", "k", "0")
+	runCmdCases(t, pusher, []cmdCase{
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"RPUSH", "k", "a"}, want: "+QUEUED\r\n"},
+		{args: []string{"LPOP", "k"}, want: "+QUEUED\r\n"},
+		{args: []string{"EXEC"}, want: "*2\r\n:1\r\n$1\r\na\r\n"},
+	})
+	if !a.blocked {
+		t.Fatalf("client should still be blocked")
+	}
+
+	runCmdCases(t, pusher, []cmdCase{
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"RPUSH", "k", "a"}, want: "+QUEUED\r\n"},
+		{args: []string{"RPUSH", "k", "b"}, want: "+QUEUED\r\n"},
+		{args: []string{"EXEC"}, want: "*2\r\n:1\r\n:2\r\n"},
+	})
+	if got, want := servedReply(t, a), arrayReply("k", "a"); got != want {
+		t.Fatalf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	s := CreateServer()
+	go s.eventLoop()
+	c := newTestClient(s)
+
+	start := time.Now()
+	if got := c.execInLoop("BLMOVE", "src", "dst", "LEFT", "LEFT", "0.05"); got != "" {
+		t.Fatalf("BLMOVE should block, reply = %q", got)
+	}
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		t.Fatalf("client is not unblocked after timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("client is unblocked after %v, want at least 50ms", elapsed)
+	}
+	if got := c.reply.String(); got != "*-1\r\n" {
+		t.Fatalf("reply = %q, want null array", got)
+	}
+	c.reply.Reset()
+
+	// 被服务的客户端的超时不再生效
+	c.execInLoop("BLPOP", "k", "0.05")
+	newTestClient(s).execInLoop("RPUSH", "k", "a")
+	<-c.done
+	c.reply.Reset()
+	c.execInLoop("BLPOP", "k", "0")
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-c.done:
+		t.Fatalf("stale timeout should not unblock the client, reply = %q", c.reply.String())
+	default:
+	}
+}
+
+// TestBlockingDisconnect 阻塞的客户端断开连接后不再消费元素
+func TestBlockingDisconnect(t *testing.T) {
+	s := CreateServer()
+	go s.eventLoop()
+
+	serverConn, clientConn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		s.serveClient(serverConn)
+		close(served)
+	}()
+
+	go func() {
+		_, _ = clientConn.Write([]byte("BLPOP k 0\r\n"))
+	}()
+	// 等待客户端阻塞
+	deadline := time.Now().Add(time.Second)
+	for {
+		blocked := make(chan bool)
+		s.submit(func() {
+			blocked <- len(s.dbs[0].blockingKeys["k"]) == 1
+		})
+		if <-blocked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("client is not blocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = clientConn.Close()
+	<-served
+
+	c := newTestClient(s)
+	if got := c.execInLoop("RPUSH", "k", "a"); got != ":1\r\n" {
+		t.Fatalf("RPUSH = %q", got)
+	}
+	if got := c.execInLoop("LLEN", "k"); got != ":1\r\n" {
+		t.Fatalf("disconnected client should not consume the element, LLEN = %q", got)
+	}
+}
+
+// TestServeBlockedClient 通过连接测试阻塞命令的回复
+func TestServeBlockedClient(t *testing.T) {
+	s := CreateServer()
+	go s.eventLoop()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go s.serveClient(serverConn)
+
+	go func() {
+		_, _ = clientConn.Write([]byte("BRPOP k 0\r\n"))
+	}()
+	r := bufio.NewReader(clientConn)
+	replied := make(chan string)
+	go func() {
+		var reply string
+		for i := 0; i < 5; i++ {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			reply += line
+		}
+		replied <- reply
+	}()
+
+	// 客户端阻塞后才 push
+	for {
+		blocked := make(chan bool)
+		s.submit(func() {
+			blocked <- len(s.dbs[0].blockingKeys["k"]) == 1
+		})
+		if <-blocked {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	newTestClient(s).execInLoop("RPUSH", "k", "a")
+
+	if got, want := <-replied, arrayReply("k", "a"); got != want {
+		t.Fatalf("reply = %q, want %q", got, want)
+	}
+}
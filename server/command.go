@@ -0,0 +1,303 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+type commandProc func(c *Client)
+
+type redisCommand struct {
+	name string
+	proc commandProc
+	// arity 是包括命令名在内的参数个数，负数表示至少 -arity 个
+	arity int
+	flags int
+	// 第一个 key、最后一个 key 在参数中的下标以及 key 之间的间隔，没有 key 时都为 0，
+	// lastKey 为负数时表示从后向前数
+	firstKey int
+	lastKey  int
+	step     int
+	// getKeysProc 返回 key 的位置不固定的命令的 key
+	getKeysProc func(argv [][]byte) [][]byte
+}
+
+const (
+	cmdWrite = 1 << iota
+	cmdReadOnly
+	// cmdDenyOOM 表示命令可能使用更多的内存，内存超过 maxmemory 时拒绝执行
+	cmdDenyOOM
+)
+
+var commandTable = make(map[string]*redisCommand)
+
+func init() {
+	commands := []*redisCommand{
+		{name: "ping", proc: pingCommand, arity: -1},
+		{name: "echo", proc: echoCommand, arity: 2},
+		{name: "select", proc: selectCommand, arity: 2},
+		{name: "config", proc: configCommand, arity: -2},
+
+		{name: "del", proc: delCommand, arity: -2, flags: cmdWrite, firstKey: 1, lastKey: -1, step: 1},
+		{name: "unlink", proc: unlinkCommand, arity: -2, flags: cmdWrite, firstKey: 1, lastKey: -1, step: 1},
+		{name: "exists", proc: existsCommand, arity: -2, flags: cmdReadOnly, firstKey: 1, lastKey: -1, step: 1},
+		{name: "touch", proc: touchCommand, arity: -2, flags: cmdReadOnly, firstKey: 1, lastKey: -1, step: 1},
+		{name: "type", proc: typeCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "rename", proc: renameCommand, arity: 3, flags: cmdWrite, firstKey: 1, lastKey: 2, step: 1},
+		{name: "renamenx", proc: renamenxCommand, arity: 3, flags: cmdWrite, firstKey: 1, lastKey: 2, step: 1},
+		{name: "copy", proc: copyCommand, arity: -3, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 2, step: 1},
+		{name: "flushdb", proc: flushdbCommand, arity: -1, flags: cmdWrite},
+		{name: "flushall", proc: flushallCommand, arity: -1, flags: cmdWrite},
+		{name: "randomkey", proc: randomkeyCommand, arity: 1, flags: cmdReadOnly},
+		{name: "keys", proc: keysCommand, arity: 2, flags: cmdReadOnly},
+		{name: "scan", proc: scanCommand, arity: -2, flags: cmdReadOnly},
+
+		{name: "expire", proc: expireCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "pexpire", proc: pexpireCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "expireat", proc: expireatCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "pexpireat", proc: pexpireatCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "ttl", proc: ttlCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "pttl", proc: pttlCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "expiretime", proc: expiretimeCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "pexpiretime", proc: pexpiretimeCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "persist", proc: persistCommand, arity: 2, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+
+		{name: "lpush", proc: lpushCommand, arity: -3, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "rpush", proc: rpushCommand, arity: -3, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "lpop", proc: lpopCommand, arity: -2, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "rpop", proc: rpopCommand, arity: -2, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "llen", proc: llenCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "lrange", proc: lrangeCommand, arity: 4, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "lindex", proc: lindexCommand, arity: 3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "lset", proc: lsetCommand, arity: 4, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "linsert", proc: linsertCommand, arity: 5, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "lrem", proc: lremCommand, arity: 4, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "ltrim", proc: ltrimCommand, arity: 4, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "lpos", proc: lposCommand, arity: -3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "lmove", proc: lmoveCommand, arity: 5, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 2, step: 1},
+		{name: "lmpop", proc: lmpopCommand, arity: -4, flags: cmdWrite, getKeysProc: numKeysGetKeys(1)},
+		{name: "blpop", proc: blpopCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: -2, step: 1},
+		{name: "brpop", proc: brpopCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: -2, step: 1},
+		{name: "blmove", proc: blmoveCommand, arity: 6, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 2, step: 1},
+		{name: "blmpop", proc: blmpopCommand, arity: -5, flags: cmdWrite, getKeysProc: numKeysGetKeys(2)},
+
+		{name: "hset", proc: hsetCommand, arity: -4, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hsetnx", proc: hsetnxCommand, arity: 4, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hget", proc: hgetCommand, arity: 3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hmget", proc: hmgetCommand, arity: -3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hdel", proc: hdelCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hlen", proc: hlenCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hstrlen", proc: hstrlenCommand, arity: 3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hexists", proc: hexistsCommand, arity: 3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hgetall", proc: hgetallCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hkeys", proc: hkeysCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hvals", proc: hvalsCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hincrby", proc: hincrbyCommand, arity: 4, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hincrbyfloat", proc: hincrbyfloatCommand, arity: 4, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hrandfield", proc: hrandfieldCommand, arity: -2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "hscan", proc: hscanCommand, arity: -3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+
+		{name: "sadd", proc: saddCommand, arity: -3, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: 1, step: 1},
+		{name: "srem", proc: sremCommand, arity: -3, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "sismember", proc: sismemberCommand, arity: 3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "smismember", proc: smismemberCommand, arity: -3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "smembers", proc: smembersCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "scard", proc: scardCommand, arity: 2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "spop", proc: spopCommand, arity: -2, flags: cmdWrite, firstKey: 1, lastKey: 1, step: 1},
+		{name: "srandmember", proc: srandmemberCommand, arity: -2, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "smove", proc: smoveCommand, arity: 4, flags: cmdWrite, firstKey: 1, lastKey: 2, step: 1},
+		{name: "sscan", proc: sscanCommand, arity: -3, flags: cmdReadOnly, firstKey: 1, lastKey: 1, step: 1},
+		{name: "sinter", proc: sinterCommand, arity: -2, flags: cmdReadOnly, firstKey: 1, lastKey: -1, step: 1},
+		{name: "sinterstore", proc: sinterstoreCommand, arity: -3, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: -1, step: 1},
+		{name: "sintercard", proc: sintercardCommand, arity: -3, flags: cmdReadOnly, getKeysProc: numKeysGetKeys(1)},
+		{name: "sunion", proc: sunionCommand, arity: -2, flags: cmdReadOnly, firstKey: 1, lastKey: -1, step: 1},
+		{name: "sunionstore", proc: sunionstoreCommand, arity: -3, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: -1, step: 1},
+		{name: "sdiff", proc: sdiffCommand, arity: -2, flags: cmdReadOnly, firstKey: 1, lastKey: -1, step: 1},
+		{name: "sdiffstore", proc: sdiffstoreCommand, arity: -3, flags: cmdWrite | cmdDenyOOM, firstKey: 1, lastKey: -1, step: 1},
+
+		{name: "save", proc: saveCommand, arity: 1},
+		{name: "bgsave", proc: bgsaveCommand, arity: 1},
+		{name: "lastsave", proc: lastsaveCommand, arity: 1},
+
+		{name: "multi", proc: multiCommand, arity: 1},
+		{name: "exec", proc: execCommand, arity: 1},
+		{name: "discard", proc: discardCommand, arity: 1},
+	}
+
+	for _, cmd := range commands {
+		commandTable[cmd.name] = cmd
+	}
+}
+
+func lookupCommand(name []byte) *redisCommand {
+	return commandTable[strings.ToLower(string(name))]
+}
+
+// getKeys returns the keys in argv of cmd.
+func (cmd *redisCommand) getKeys(argv [][]byte) [][]byte {
+	if cmd.getKeysProc != nil {
+		return cmd.getKeysProc(argv)
+	}
+	if cmd.firstKey == 0 {
+		return nil
+	}
+
+	lastKey := cmd.lastKey
+	if lastKey < 0 {
+		lastKey += len(argv)
+	}
+	var keys [][]byte
+	for i := cmd.firstKey; i <= lastKey && i < len(argv); i += cmd.step {
+		keys = append(keys, argv[i])
+	}
+	return keys
+}
+
+// numKeysGetKeys returns getKeysProc of commands whose keys follow the numkeys argument at numKeysIdx.
+func numKeysGetKeys(numKeysIdx int) func(argv [][]byte) [][]byte {
+	return func(argv [][]byte) [][]byte {
+		numKeys, ok := utils.String2Int64(argv[numKeysIdx])
+		if !ok || numKeys <= 0 || numKeys > int64(len(argv)-numKeysIdx-1) {
+			return nil
+		}
+		return argv[numKeysIdx+1 : numKeysIdx+1+int(numKeys)]
+	}
+}
+
+// processCommand executes the command in c.argv, it must be called in the event loop.
+// c.blocked is true after processCommand when the command blocks the client.
+func (s *Server) processCommand(c *Client) {
+	defer s.lazyfree.flush()
+
+	if strings.EqualFold(string(c.argv[0]), "quit") {
+		c.addReplyOK()
+		c.closeAfterReply = true
+		return
+	}
+
+	cmd := lookupCommand(c.argv[0])
+	if cmd == nil {
+		var args strings.Builder
+		for _, arg := range c.argv[1:] {
+			args.WriteString("'")
+			args.Write(arg)
+			args.WriteString("' ")
+		}
+		c.flagTransaction()
+		c.addReplyErrorFormat("ERR unknown command '%s', with args beginning with: %s", c.argv[0], args.String())
+		return
+	}
+
+	if (cmd.arity > 0 && len(c.argv) != cmd.arity) || len(c.argv) < -cmd.arity {
+		c.flagTransaction()
+		c.addReplyErrorFormat(wrongArgCountFmt, cmd.name)
+		return
+	}
+
+	c.cmd = cmd
+	if s.config.maxmemory > 0 && !s.performEvictions() {
+		// 内存不足时拒绝可能使用更多内存的命令，事务中排队的命令也会使用内存
+		denyOOM := cmd.flags&cmdDenyOOM != 0 || (cmd.name == "exec" && c.mstateDenyOOM())
+		if c.flags&clientMulti != 0 && cmd.name != "exec" && cmd.name != "discard" {
+			denyOOM = true
+		}
+		if denyOOM {
+			c.rejectCommand(oomErr)
+			return
+		}
+	}
+
+	if c.flags&clientMulti != 0 && cmd.name != "exec" && cmd.name != "discard" && cmd.name != "multi" {
+		c.queueMultiCommand()
+		c.addReplyStatus("QUEUED")
+		return
+	}
+
+	s.call(c)
+	s.handleClientsBlockedOnKeys()
+}
+
+// call invokes c.cmd with c.argv, memory used by the keys is recomputed after write commands.
+func (s *Server) call(c *Client) {
+	c.cmd.proc(c)
+
+	if c.cmd.flags&cmdWrite != 0 {
+		for _, key := range c.cmd.getKeys(c.argv) {
+			c.db.updateKeyMemory(key)
+		}
+	}
+}
+
+// rejectCommand replies err without executing the command, the transaction is aborted when the
+// command is EXEC.
+func (c *Client) rejectCommand(err string) {
+	c.flagTransaction()
+	if c.cmd != nil && c.cmd.name == "exec" {
+		c.discardTransaction()
+		c.addReplyErrorFormat("EXECABORT Transaction discarded because of: %s", err)
+		return
+	}
+	c.addReplyError(err)
+}
+
+// getInt64OrReply parses arg as int64, an error is replied when arg is not an integer.
+func (c *Client) getInt64OrReply(arg []byte) (int64, bool) {
+	num, ok := utils.String2Int64(arg)
+	if !ok {
+		c.addReplyError(notIntegerErr)
+	}
+	return num, ok
+}
+
+// getPositiveInt64OrReply is like getInt64OrReply, but negative numbers are not accepted either.
+func (c *Client) getPositiveInt64OrReply(arg []byte) (int64, bool) {
+	num, ok := c.getInt64OrReply(arg)
+	if ok && num < 0 {
+		c.addReplyError(notPositiveErr)
+		return 0, false
+	}
+	return num, ok
+}
+
+// checkType replies an error and returns true when type of obj is not typ.
+func (c *Client) checkType(obj *Object, typ uint8) bool {
+	if obj.Type != typ {
+		c.addReplyError(wrongTypeErr)
+		return true
+	}
+	return false
+}
+
+// pingCommand PING [message]
+func pingCommand(c *Client) {
+	switch len(c.argv) {
+	case 1:
+		c.addReplyStatus("PONG")
+	case 2:
+		c.addReplyBulk(c.argv[1])
+	default:
+		c.addReplyErrorFormat(wrongArgCountFmt, "ping")
+	}
+}
+
+// echoCommand ECHO message
+func echoCommand(c *Client) {
+	c.addReplyBulk(c.argv[1])
+}
+
+// selectCommand SELECT index
+func selectCommand(c *Client) {
+	id, ok := utils.String2Int64(c.argv[1])
+	if !ok {
+		c.addReplyError("ERR invalid DB index")
+		return
+	}
+	if id < 0 || id >= int64(len(c.server.dbs)) {
+		c.addReplyError("ERR DB index is out of range")
+		return
+	}
+
+	c.db = c.server.dbs[id]
+	c.addReplyOK()
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/WANGgbin/tiny_redis/data_type/quicklist"
+)
+
+type config struct {
+	port      int
+	databases int
+	// hz 是 serverCron 每秒执行的次数
+	hz int
+	// 见 quicklist.QuickList.fill
+	listMaxZiplistSize int
+	// 见 quicklist.QuickList.compressDepth，只对新创建的 list 生效
+	listCompressDepth int
+	// hash 的元素个数或者 field、value 的长度超过限制时，从 ziplist 转换为 dict
+	hashMaxZiplistEntries int
+	hashMaxZiplistValue   int
+	// set 的元素个数超过限制时，从 intset 转换为 dict
+	setMaxIntsetEntries int
+	// 数据集使用的内存超过 maxmemory 时按照 maxmemoryPolicy 淘汰 key，0 表示不限制，见 evict.go
+	maxmemory        int64
+	maxmemoryPolicy  int
+	maxmemorySamples int
+	lfuLogFactor     int
+	lfuDecayTime     int
+	// 以下场景删除较大的 value 时是否在后台释放，见 lazyfree.go
+	lazyfreeLazyEviction  bool
+	lazyfreeLazyExpire    bool
+	lazyfreeLazyServerDel bool
+	lazyfreeLazyUserDel   bool
+	lazyfreeLazyUserFlush bool
+	// RDB 文件保存在 dir 目录下的 dbfilename 中，rdbCompression、rdbChecksum 决定保存时是否压缩字符串、计算校验和，见 rdb.go
+	dir            string
+	dbfilename     string
+	rdbCompression bool
+	rdbChecksum    bool
+}
+
+func defaultConfig() *config {
+	return &config{
+		port:                  6379,
+		databases:             16,
+		hz:                    10,
+		listMaxZiplistSize:    quicklist.DefaultFill,
+		hashMaxZiplistEntries: 128,
+		hashMaxZiplistValue:   64,
+		setMaxIntsetEntries:   512,
+		maxmemoryPolicy:       maxmemoryNoEviction,
+		maxmemorySamples:      5,
+		lfuLogFactor:          10,
+		lfuDecayTime:          1,
+		dir:                   ".",
+		dbfilename:            "dump.rdb",
+		rdbCompression:        true,
+		rdbChecksum:           true,
+	}
+}
+
+// configParam 是可以通过 CONFIG GET/SET 访问的配置项
+type configParam struct {
+	name  string
+	alias string
+	get   func(cfg *config) string
+	// set 返回错误信息，空字符串表示成功
+	set func(cfg *config, val string) string
+}
+
+func intConfigParam(name, alias string, field func(cfg *config) *int, min, max int) *configParam {
+	return &configParam{
+		name:  name,
+		alias: alias,
+		get: func(cfg *config) string {
+			return strconv.Itoa(*field(cfg))
+		},
+		set: func(cfg *config, val string) string {
+			num, err := strconv.Atoi(val)
+			if err != nil {
+				return "argument couldn't be parsed into an integer"
+			}
+			if num < min || num > max {
+				return "argument must be between " + strconv.Itoa(min) + " and " + strconv.Itoa(max) + " inclusive"
+			}
+			*field(cfg) = num
+			return ""
+		},
+	}
+}
+
+// memoryConfigParam is a param in bytes, units such as 1k(1000), 1kb(1024), 1m, 1mb, 1g and 1gb
+// are accepted.
+func memoryConfigParam(name string, field func(cfg *config) *int64) *configParam {
+	return &configParam{
+		name: name,
+		get: func(cfg *config) string {
+			return strconv.FormatInt(*field(cfg), 10)
+		},
+		set: func(cfg *config, val string) string {
+			num, ok := parseMemory(val)
+			if !ok {
+				return "argument must be a memory value"
+			}
+			*field(cfg) = num
+			return ""
+		},
+	}
+}
+
+var memoryUnits = []struct {
+	suffix string
+	unit   int64
+}{
+	// 较长的后缀在前，避免 "kb" 匹配到 "b"
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory parses a memory value such as 100mb.
+func parseMemory(val string) (int64, bool) {
+	val = strings.ToLower(val)
+	unit := int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(val, u.suffix) {
+			val, unit = strings.TrimSuffix(val, u.suffix), u.unit
+			break
+		}
+	}
+
+	num, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || num < 0 || num > math.MaxInt64/unit {
+		return 0, false
+	}
+	return num * unit, true
+}
+
+// boolConfigParam is a param whose value is yes or no.
+func boolConfigParam(name string, field func(cfg *config) *bool) *configParam {
+	return &configParam{
+		name: name,
+		get: func(cfg *config) string {
+			if *field(cfg) {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(cfg *config, val string) string {
+			switch strings.ToLower(val) {
+			case "yes":
+				*field(cfg) = true
+			case "no":
+				*field(cfg) = false
+			default:
+				return "argument must be 'yes' or 'no'"
+			}
+			return ""
+		},
+	}
+}
+
+// stringConfigParam is a param whose value is a string, check returns the error message of an invalid value.
+func stringConfigParam(name string, field func(cfg *config) *string, check func(val string) string) *configParam {
+	return &configParam{
+		name: name,
+		get: func(cfg *config) string {
+			return *field(cfg)
+		},
+		set: func(cfg *config, val string) string {
+			if errMsg := check(val); errMsg != "" {
+				return errMsg
+			}
+			*field(cfg) = val
+			return ""
+		},
+	}
+}
+
+type configEnum struct {
+	name  string
+	value int
+}
+
+// enumConfigParam is a param whose value is one of enums.
+func enumConfigParam(name string, enums []configEnum, field func(cfg *config) *int) *configParam {
+	return &configParam{
+		name: name,
+		get: func(cfg *config) string {
+			for _, e := range enums {
+				if e.value == *field(cfg) {
+					return e.name
+				}
+			}
+			return ""
+		},
+		set: func(cfg *config, val string) string {
+			names := make([]string, 0, len(enums))
+			for _, e := range enums {
+				if strings.EqualFold(e.name, val) {
+					*field(cfg) = e.value
+					return ""
+				}
+				names = append(names, e.name)
+			}
+			return "argument(s) must be one of the following: " + strings.Join(names, ", ")
+		},
+	}
+}
+
+var configParams = []*configParam{
+	intConfigParam("hz", "", func(cfg *config) *int {
+		return &cfg.hz
+	}, 1, 500),
+	intConfigParam("list-max-ziplist-size", "list-max-listpack-size", func(cfg *config) *int {
+		return &cfg.listMaxZiplistSize
+	}, quicklist.FillMin, quicklist.FillMax),
+	intConfigParam("list-compress-depth", "", func(cfg *config) *int {
+		return &cfg.listCompressDepth
+	}, 0, quicklist.CompressMax),
+	intConfigParam("hash-max-ziplist-entries", "hash-max-listpack-entries", func(cfg *config) *int {
+		return &cfg.hashMaxZiplistEntries
+	}, 0, math.MaxInt32),
+	intConfigParam("hash-max-ziplist-value", "hash-max-listpack-value", func(cfg *config) *int {
+		return &cfg.hashMaxZiplistValue
+	}, 0, math.MaxInt32),
+	intConfigParam("set-max-intset-entries", "", func(cfg *config) *int {
+		return &cfg.setMaxIntsetEntries
+	}, 0, math.MaxInt32),
+	memoryConfigParam("maxmemory", func(cfg *config) *int64 {
+		return &cfg.maxmemory
+	}),
+	enumConfigParam("maxmemory-policy", maxmemoryPolicies, func(cfg *config) *int {
+		return &cfg.maxmemoryPolicy
+	}),
+	intConfigParam("maxmemory-samples", "", func(cfg *config) *int {
+		return &cfg.maxmemorySamples
+	}, 1, 64),
+	intConfigParam("lfu-log-factor", "", func(cfg *config) *int {
+		return &cfg.lfuLogFactor
+	}, 0, math.MaxInt32),
+	intConfigParam("lfu-decay-time", "", func(cfg *config) *int {
+		return &cfg.lfuDecayTime
+	}, 0, math.MaxInt32),
+	boolConfigParam("lazyfree-lazy-eviction", func(cfg *config) *bool {
+		return &cfg.lazyfreeLazyEviction
+	}),
+	boolConfigParam("lazyfree-lazy-expire", func(cfg *config) *bool {
+		return &cfg.lazyfreeLazyExpire
+	}),
+	boolConfigParam("lazyfree-lazy-server-del", func(cfg *config) *bool {
+		return &cfg.lazyfreeLazyServerDel
+	}),
+	boolConfigParam("lazyfree-lazy-user-del", func(cfg *config) *bool {
+		return &cfg.lazyfreeLazyUserDel
+	}),
+	boolConfigParam("lazyfree-lazy-user-flush", func(cfg *config) *bool {
+		return &cfg.lazyfreeLazyUserFlush
+	}),
+	stringConfigParam("dir", func(cfg *config) *string {
+		return &cfg.dir
+	}, func(val string) string {
+		info, err := os.Stat(val)
+		if err != nil {
+			return "No such file or directory"
+		}
+		if !info.IsDir() {
+			return "Not a directory"
+		}
+		return ""
+	}),
+	stringConfigParam("dbfilename", func(cfg *config) *string {
+		return &cfg.dbfilename
+	}, func(val string) string {
+		if val == "" || filepath.Base(val) != val {
+			return "dbfilename can't be a path, just a filename"
+		}
+		return ""
+	}),
+	boolConfigParam("rdbcompression", func(cfg *config) *bool {
+		return &cfg.rdbCompression
+	}),
+	boolConfigParam("rdbchecksum", func(cfg *config) *bool {
+		return &cfg.rdbChecksum
+	}),
+}
+
+func lookupConfigParam(name string) *configParam {
+	name = strings.ToLower(name)
+	for _, param := range configParams {
+		if param.name == name || (param.alias != "" && param.alias == name) {
+			return param
+		}
+	}
+	return nil
+}
+
+// configCommand CONFIG GET parameter [parameter ...] | CONFIG SET parameter value [parameter value ...]
+func configCommand(c *Client) {
+	cfg := c.server.config
+	switch strings.ToLower(string(c.argv[1])) {
+	case "get":
+		if len(c.argv) < 3 {
+			c.addReplyErrorFormat(wrongArgCountFmt, "config|get")
+			return
+		}
+
+		var pairs []string
+		for _, name := range c.argv[2:] {
+			if param := lookupConfigParam(string(name)); param != nil {
+				pairs = append(pairs, param.name, param.get(cfg))
+			}
+		}
+		c.addReplyArrayLen(len(pairs))
+		for _, s := range pairs {
+			c.addReplyBulkString(s)
+		}
+	case "set":
+		if len(c.argv) < 4 || len(c.argv)%2 != 0 {
+			c.addReplyErrorFormat(wrongArgCountFmt, "config|set")
+			return
+		}
+
+		// 先全部校验再修改，避免只修改了部分参数
+		params := make([]*configParam, 0, len(c.argv)/2-1)
+		for i := 2; i < len(c.argv); i += 2 {
+			param := lookupConfigParam(string(c.argv[i]))
+			if param == nil {
+				c.addReplyErrorFormat("ERR Unknown option or number of arguments for CONFIG SET - '%s'", c.argv[i])
+				return
+			}
+			params = append(params, param)
+		}
+
+		backup := *cfg
+		for i, param := range params {
+			if errMsg := param.set(cfg, string(c.argv[2*i+3])); errMsg != "" {
+				*cfg = backup
+				c.addReplyErrorFormat("ERR CONFIG SET failed (possibly related to argument '%s') - %s", param.name, errMsg)
+				return
+			}
+		}
+		c.addReplyOK()
+	default:
+		c.addReplyErrorFormat("ERR unknown subcommand '%s'. Try CONFIG HELP.", c.argv[1])
+	}
+}
@@ -1,6 +1,168 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
 	"net"
 )
 
+// Client 表示一个连接。argv、db 以及 reply 只在事件循环中访问，reply 在命令执行完成后
+// 由连接所在的 goroutine 发送给客户端。
+
+type Client struct {
+	id     uint64
+	server *Server
+	conn   net.Conn
+	db     *redisDb
+
+	argv  [][]byte
+	cmd   *redisCommand
+	reply bytes.Buffer
+	// done 在当前命令执行完成后被通知，命令阻塞时直到客户端被服务或者超时才通知
+	done chan struct{}
+
+	flags  int
+	mstate []multiCmd
+
+	blocked bool
+	bstate  blockingState
+	// reprocessing 表示正在重新执行阻塞的命令
+	reprocessing bool
+
+	closeAfterReply bool
+}
+
+func (s *Server) createClient(conn net.Conn) *Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	c := &Client{
+		id:     s.nextID,
+		server: s,
+		conn:   conn,
+		db:     s.dbs[0],
+		done:   make(chan struct{}, 1),
+	}
+	s.clients[c] = struct{}{}
+
+	return c
+}
+
+func (s *Server) freeClient(c *Client) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+
+	_ = c.conn.Close()
+
+	// 客户端可能阻塞在某些 key 上，在事件循环中解除阻塞后才能释放
+	freed := make(chan struct{})
+	s.submit(func() {
+		if c.blocked {
+			s.unblockClient(c)
+		}
+		close(freed)
+	})
+	<-freed
+}
+
+// readCommands reads commands from r and sends them to cmds until an error occurs or quit is closed.
+func readCommands(r *bufio.Reader, cmds chan<- [][]byte, readErr chan<- error, quit <-chan struct{}) {
+	for {
+		argv, err := readCommand(r)
+		if err != nil {
+			readErr <- err
+			return
+		}
+		if len(argv) == 0 {
+			continue
+		}
+
+		select {
+		case cmds <- argv:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// serveClient reads commands from conn, executes them in the event loop and sends replies.
+func (s *Server) serveClient(conn net.Conn) {
+	c := s.createClient(conn)
+	defer s.freeClient(c)
+
+	// 命令由单独的 goroutine 读取，这样客户端阻塞时也能及时发现连接断开
+	cmds := make(chan [][]byte)
+	readErr := make(chan error, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+	go readCommands(bufio.NewReaderSize(conn, readBufferSize), cmds, readErr, quit)
+
+	var argv [][]byte
+	for {
+		if argv == nil {
+			select {
+			case argv = <-cmds:
+			case err := <-readErr:
+				s.handleReadError(c, err)
+				return
+			}
+		}
+
+		s.submit(func() {
+			c.argv = argv
+			s.processCommand(c)
+			if !c.blocked {
+				c.done <- struct{}{}
+			}
+		})
+		select {
+		case <-c.done:
+		case err := <-readErr:
+			// 连接在命令执行时断开，客户端阻塞时解除阻塞，已经生成的回复仍然发送
+			s.submit(func() {
+				if c.blocked {
+					s.unblockClient(c)
+					c.done <- struct{}{}
+				}
+			})
+			<-c.done
+			s.handleReadError(c, err)
+			return
+		}
+
+		// 客户端使用 pipeline 时，执行完所有已经收到的命令后再统一发送回复
+		argv = nil
+		if !c.closeAfterReply {
+			select {
+			case argv = <-cmds:
+			default:
+			}
+		}
+		if argv == nil {
+			if _, err := conn.Write(c.reply.Bytes()); err != nil {
+				return
+			}
+			c.reply.Reset()
+		}
+		if c.closeAfterReply {
+			return
+		}
+	}
+}
+
+// handleReadError sends pending replies before the connection is closed, protocol errors are replied too.
+func (s *Server) handleReadError(c *Client, err error) {
+	if errors.Is(err, ProtocolErr) {
+		c.addReplyError(err.Error())
+	} else if err != io.EOF {
+		log.Printf("Read from client %d failed: %v\n", c.id, err)
+	}
+	if c.reply.Len() > 0 {
+		_, _ = c.conn.Write(c.reply.Bytes())
+	}
+}
@@ -0,0 +1,588 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/WANGgbin/tiny_redis/data_type/dict"
+	"github.com/WANGgbin/tiny_redis/data_type/intset"
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// redisDb 是一个独立的键空间，key 为 string，value 为 *Object。
+// 设置了过期时间的 key 记录在 expires 中，value 为过期的 unix 时间戳(毫秒)。过期的 key 在被访问时删除，
+// 未被访问的 key 由 activeExpireCycle 定期抽样删除。
+// usedMemory 是所有 key 估算的内存之和：key 被添加时统计一次，写命令执行完成后重新统计它修改的 key，
+// 每个 key 统计的结果记录在 Object.size 中，删除时减去。
+
+type redisDb struct {
+	id      int
+	config  *config
+	dict    *dict.Dict
+	expires *dict.Dict
+	// expiresCursor 是 activeExpireCycle 遍历 expires 的游标
+	expiresCursor uint64
+	usedMemory    int64
+
+	// blockingKeys 记录阻塞在每个 key 上的客户端，按阻塞的先后顺序排列
+	blockingKeys map[string][]*Client
+	// readyKeys 是有客户端阻塞并且已经可以被服务的 key，readyKeySet 用于去重
+	readyKeys   []string
+	readyKeySet map[string]struct{}
+
+	lazyfree *lazyfree
+}
+
+func createRedisDb(id int, cfg *config, lf *lazyfree) *redisDb {
+	return &redisDb{
+		id:           id,
+		config:       cfg,
+		lazyfree:     lf,
+		dict:         dict.CreateDict(),
+		expires:      dict.CreateDict(),
+		blockingKeys: make(map[string][]*Client),
+		readyKeySet:  make(map[string]struct{}),
+	}
+}
+
+// lookupKeyRead gets the object of key for reading, nil is returned when key doesn't exist.
+func (db *redisDb) lookupKeyRead(key []byte) *Object {
+	return db.lookupKey(key)
+}
+
+// lookupKeyWrite gets the object of key for modifying, nil is returned when key doesn't exist.
+func (db *redisDb) lookupKeyWrite(key []byte) *Object {
+	return db.lookupKey(key)
+}
+
+func (db *redisDb) lookupKey(key []byte) *Object {
+	db.expireIfNeeded(key)
+
+	val, ok := db.dict.Get(string(key))
+	if !ok {
+		return nil
+	}
+	obj := val.(*Object)
+	if obj.shared {
+		obj = db.unshareObject(key, obj)
+	}
+	db.touch(obj)
+	return obj
+}
+
+// touch updates the access time or frequency of obj for eviction.
+func (db *redisDb) touch(obj *Object) {
+	if db.config.maxmemoryPolicy&maxmemoryFlagLFU != 0 {
+		db.updateLFU(obj)
+	} else {
+		obj.lru = lruClock()
+	}
+}
+
+// dbAdd adds key to db, key must not exist.
+func (db *redisDb) dbAdd(key []byte, obj *Object) {
+	if db.config.maxmemoryPolicy&maxmemoryFlagLFU != 0 {
+		obj.lru = lfuTimeInMinutes()<<8 | lfuInitVal
+	} else {
+		obj.lru = lruClock()
+	}
+	obj.size = keyComputeSize(key, obj)
+	db.usedMemory += obj.size
+
+	db.dict.Add(string(key), obj)
+	if obj.Type == ObjList {
+		db.signalKeyAsReady(key)
+	}
+}
+
+// setKey sets key to obj, the old value of key is overwritten.
+func (db *redisDb) setKey(key []byte, obj *Object) {
+	db.dbGenericDelete(key, db.config.lazyfreeLazyServerDel)
+	db.dbAdd(key, obj)
+}
+
+// dbDelete deletes key and its expire from db, false is returned when key doesn't exist.
+func (db *redisDb) dbDelete(key []byte) bool {
+	if db.expires.Len() > 0 {
+		db.expires.Delete(string(key))
+	}
+	val, ok := db.dict.Delete(string(key))
+	if ok {
+		db.usedMemory -= val.(*Object).size
+	}
+	return ok
+}
+
+// keyComputeSize estimates memory used by key and obj.
+func keyComputeSize(key []byte, obj *Object) int64 {
+	return dictEntryOverhead + stringOverhead + int64(len(key)) + objectComputeSize(obj, objectSizeSamples)
+}
+
+// updateKeyMemory recomputes memory used by key after it's modified.
+func (db *redisDb) updateKeyMemory(key []byte) {
+	val, ok := db.dict.Get(string(key))
+	if !ok {
+		return
+	}
+
+	obj := val.(*Object)
+	size := keyComputeSize(key, obj)
+	db.usedMemory += size - obj.size
+	obj.size = size
+}
+
+// setExpire sets the expire of key to when in unix milliseconds, key must exist.
+func (db *redisDb) setExpire(key []byte, when int64) {
+	db.expires.Set(string(key), when)
+}
+
+// getExpire returns the expire of key in unix milliseconds, -1 is returned when key has no expire.
+func (db *redisDb) getExpire(key []byte) int64 {
+	if db.expires.Len() == 0 {
+		return -1
+	}
+	when, ok := db.expires.Get(string(key))
+	if !ok {
+		return -1
+	}
+	return when.(int64)
+}
+
+// removeExpire removes the expire of key, false is returned when key has no expire.
+func (db *redisDb) removeExpire(key []byte) bool {
+	_, ok := db.expires.Delete(string(key))
+	return ok
+}
+
+// expireIfNeeded deletes key when it's expired, true is returned when key is deleted.
+func (db *redisDb) expireIfNeeded(key []byte) bool {
+	when := db.getExpire(key)
+	if when < 0 || when > mstime() {
+		return false
+	}
+
+	db.dbGenericDelete(key, db.config.lazyfreeLazyExpire)
+	return true
+}
+
+// delGenericCommand implements DEL and UNLINK, the number of deleted keys is replied. Values are
+// freed in background when lazy is true.
+func delGenericCommand(c *Client, lazy bool) {
+	deleted := int64(0)
+	for _, key := range c.argv[1:] {
+		c.db.expireIfNeeded(key)
+		if c.db.dbGenericDelete(key, lazy) {
+			deleted++
+		}
+	}
+	c.addReplyInt(deleted)
+}
+
+// delCommand DEL key [key ...]
+func delCommand(c *Client) {
+	delGenericCommand(c, c.server.config.lazyfreeLazyUserDel)
+}
+
+// unlinkCommand UNLINK key [key ...]
+func unlinkCommand(c *Client) {
+	delGenericCommand(c, true)
+}
+
+// existsCommand EXISTS key [key ...]
+// 同一个 key 出现多次时计数多次
+func existsCommand(c *Client) {
+	count := int64(0)
+	for _, key := range c.argv[1:] {
+		if c.db.lookupKeyRead(key) != nil {
+			count++
+		}
+	}
+	c.addReplyInt(count)
+}
+
+// touchCommand TOUCH key [key ...]
+func touchCommand(c *Client) {
+	existsCommand(c)
+}
+
+// objectTypeName returns the name of typ replied by TYPE.
+func objectTypeName(typ uint8) string {
+	switch typ {
+	case ObjString:
+		return "string"
+	case ObjList:
+		return "list"
+	case ObjSet:
+		return "set"
+	case ObjZSet:
+		return "zset"
+	case ObjHash:
+		return "hash"
+	default:
+		return "unknown"
+	}
+}
+
+// isObjectTypeName reports whether name is a type name replied by TYPE.
+func isObjectTypeName(name string) bool {
+	for _, typ := range []uint8{ObjString, ObjList, ObjSet, ObjZSet, ObjHash} {
+		if objectTypeName(typ) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// typeCommand TYPE key
+func typeCommand(c *Client) {
+	obj := c.db.lookupKeyRead(c.argv[1])
+	if obj == nil {
+		c.addReplyStatus("none")
+		return
+	}
+	c.addReplyStatus(objectTypeName(obj.Type))
+}
+
+// renameGenericCommand implements RENAME and RENAMENX, the expire of src is moved to dst as well.
+func renameGenericCommand(c *Client, nx bool) {
+	src, dst := c.argv[1], c.argv[2]
+	obj := c.db.lookupKeyWrite(src)
+	if obj == nil {
+		c.addReplyError(noSuchKeyErr)
+		return
+	}
+
+	// 重命名为自身时什么都不做
+	if string(src) == string(dst) {
+		if nx {
+			c.addReplyInt(0)
+		} else {
+			c.addReplyOK()
+		}
+		return
+	}
+
+	if c.db.lookupKeyWrite(dst) != nil {
+		if nx {
+			c.addReplyInt(0)
+			return
+		}
+		c.db.dbGenericDelete(dst, c.db.config.lazyfreeLazyServerDel)
+	}
+
+	expire := c.db.getExpire(src)
+	c.db.dbDelete(src)
+	c.db.dbAdd(dst, obj)
+	if expire != -1 {
+		c.db.setExpire(dst, expire)
+	}
+
+	if nx {
+		c.addReplyInt(1)
+	} else {
+		c.addReplyOK()
+	}
+}
+
+// renameCommand RENAME key newkey
+func renameCommand(c *Client) {
+	renameGenericCommand(c, false)
+}
+
+// renamenxCommand RENAMENX key newkey
+func renamenxCommand(c *Client) {
+	renameGenericCommand(c, true)
+}
+
+// copyCommand COPY source destination [DB destination-db] [REPLACE]
+func copyCommand(c *Client) {
+	src, dst := c.argv[1], c.argv[2]
+	dstDb := c.db
+	replace := false
+	for i := 3; i < len(c.argv); i++ {
+		switch {
+		case strings.EqualFold(string(c.argv[i]), "replace"):
+			replace = true
+		case strings.EqualFold(string(c.argv[i]), "db") && i+1 < len(c.argv):
+			id, ok := c.getInt64OrReply(c.argv[i+1])
+			if !ok {
+				return
+			}
+			if id < 0 || id >= int64(len(c.server.dbs)) {
+				c.addReplyError("ERR DB index is out of range")
+				return
+			}
+			dstDb = c.server.dbs[id]
+			i++
+		default:
+			c.addReplyError(syntaxErr)
+			return
+		}
+	}
+
+	if dstDb == c.db && string(src) == string(dst) {
+		c.addReplyError("ERR source and destination objects are the same")
+		return
+	}
+
+	obj := c.db.lookupKeyRead(src)
+	if obj == nil {
+		c.addReplyInt(0)
+		return
+	}
+	if dstDb.lookupKeyWrite(dst) != nil {
+		if !replace {
+			c.addReplyInt(0)
+			return
+		}
+		dstDb.dbGenericDelete(dst, dstDb.config.lazyfreeLazyServerDel)
+	}
+
+	dstDb.dbAdd(dst, dupObject(obj))
+	if expire := c.db.getExpire(src); expire != -1 {
+		dstDb.setExpire(dst, expire)
+	}
+	c.addReplyInt(1)
+}
+
+// parseFlushFlagsOrReply parses the ASYNC or SYNC option of FLUSHDB and FLUSHALL, true is returned
+// for ASYNC.
+func (c *Client) parseFlushFlagsOrReply() (async bool, ok bool) {
+	// 后台保存 RDB 时 value 还在被读取，不能在后台释放
+	switch {
+	case len(c.argv) == 1:
+		return c.server.config.lazyfreeLazyUserFlush && !c.server.rdbBgsaveInProgress, true
+	case len(c.argv) == 2 && strings.EqualFold(string(c.argv[1]), "async"):
+		return !c.server.rdbBgsaveInProgress, true
+	case len(c.argv) == 2 && strings.EqualFold(string(c.argv[1]), "sync"):
+		return false, true
+	default:
+		c.addReplyError(syntaxErr)
+		return false, false
+	}
+}
+
+// flushdbCommand FLUSHDB [ASYNC | SYNC]
+func flushdbCommand(c *Client) {
+	async, ok := c.parseFlushFlagsOrReply()
+	if !ok {
+		return
+	}
+	c.db.emptyDb(async)
+	c.addReplyOK()
+}
+
+// flushallCommand FLUSHALL [ASYNC | SYNC]
+func flushallCommand(c *Client) {
+	async, ok := c.parseFlushFlagsOrReply()
+	if !ok {
+		return
+	}
+	for _, db := range c.server.dbs {
+		db.emptyDb(async)
+	}
+	c.addReplyOK()
+}
+
+// randomKey returns a random key which is not expired, nil is returned when db is empty.
+func (db *redisDb) randomKey() []byte {
+	for {
+		key, _, ok := db.dict.RandomEntry()
+		if !ok {
+			return nil
+		}
+		if !db.expireIfNeeded([]byte(key)) {
+			return []byte(key)
+		}
+	}
+}
+
+// randomkeyCommand RANDOMKEY
+func randomkeyCommand(c *Client) {
+	key := c.db.randomKey()
+	if key == nil {
+		c.addReplyNull()
+		return
+	}
+	c.addReplyBulk(key)
+}
+
+// keysCommand KEYS pattern
+func keysCommand(c *Client) {
+	pattern := c.argv[1]
+	allKeys := len(pattern) == 1 && pattern[0] == '*'
+
+	var keys [][]byte
+	c.db.dict.ForEach(func(key string, val interface{}) bool {
+		if allKeys || utils.StringMatch(pattern, []byte(key), false) {
+			keys = append(keys, []byte(key))
+		}
+		return true
+	})
+
+	// 不能在 ForEach 的回调中修改 dict，遍历完成后再删除过期的 key
+	filtered := keys[:0]
+	for _, key := range keys {
+		if !c.db.expireIfNeeded(key) {
+			filtered = append(filtered, key)
+		}
+	}
+
+	c.addReplyArrayLen(len(filtered))
+	for _, key := range filtered {
+		c.addReplyBulk(key)
+	}
+}
+
+// scanCommand SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func scanCommand(c *Client) {
+	cursor, ok := c.parseScanCursorOrReply(c.argv[1])
+	if !ok {
+		return
+	}
+	scanGenericCommand(c, nil, cursor)
+}
+
+// parseScanCursorOrReply parses the cursor of SCAN family commands.
+func (c *Client) parseScanCursorOrReply(arg []byte) (uint64, bool) {
+	cursor, err := strconv.ParseUint(string(arg), 10, 64)
+	if err != nil {
+		c.addReplyError("ERR invalid cursor")
+		return 0, false
+	}
+	return cursor, true
+}
+
+// addReplyEmptyScan replies the result of scanning a key that doesn't exist.
+func (c *Client) addReplyEmptyScan() {
+	c.addReplyArrayLen(2)
+	c.addReplyBulkString("0")
+	c.addReplyArrayLen(0)
+}
+
+// scanGenericCommand implements SCAN family commands: options start after the cursor, obj is nil for
+// scanning the keyspace and only then TYPE is accepted. Objects encoded as hashtable are scanned with dict.Scan, others are small
+// and returned in a single call.
+func scanGenericCommand(c *Client, obj *Object, cursor uint64) {
+	optIdx := 2
+	if obj != nil {
+		optIdx = 3
+	}
+
+	count := int64(10)
+	var pattern []byte
+	var typeName string
+	for i := optIdx; i < len(c.argv); i += 2 {
+		if i+1 >= len(c.argv) {
+			c.addReplyError(syntaxErr)
+			return
+		}
+
+		switch strings.ToLower(string(c.argv[i])) {
+		case "count":
+			var ok bool
+			if count, ok = c.getInt64OrReply(c.argv[i+1]); !ok {
+				return
+			}
+			if count < 1 {
+				c.addReplyError(syntaxErr)
+				return
+			}
+		case "match":
+			pattern = c.argv[i+1]
+			// "*" 匹配所有元素，不需要过滤
+			if len(pattern) == 1 && pattern[0] == '*' {
+				pattern = nil
+			}
+		case "type":
+			// 只有遍历键空间时可以按类型过滤
+			if obj != nil {
+				c.addReplyError(syntaxErr)
+				return
+			}
+			typeName = strings.ToLower(string(c.argv[i+1]))
+			if !isObjectTypeName(typeName) {
+				c.addReplyErrorFormat("ERR unknown type name '%s'", c.argv[i+1])
+				return
+			}
+		default:
+			c.addReplyError(syntaxErr)
+			return
+		}
+	}
+
+	// hash 的元素由 field、value 组成，只根据 field 过滤
+	var items [][]byte
+	pairs := obj != nil && obj.Type == ObjHash
+
+	var d *dict.Dict
+	switch {
+	case obj == nil:
+		d = c.db.dict
+	case obj.Encoding == EncodingHashTable:
+		d = obj.Ptr.(*dict.Dict)
+	}
+
+	if d != nil {
+		// 限制遍历的次数，避免稀疏的 dict 一次遍历过多的空桶
+		maxIterations := count * 10
+		for {
+			cursor = d.Scan(cursor, func(key string, val interface{}) {
+				items = append(items, []byte(key))
+				if pairs {
+					items = append(items, []byte(val.(string)))
+				}
+			})
+			maxIterations--
+			if cursor == 0 || maxIterations <= 0 || int64(len(items)) >= count {
+				break
+			}
+		}
+	} else {
+		switch obj.Encoding {
+		case EncodingZipList:
+			zl := obj.Ptr.(*ziplist.ZipList)
+			for zc := zl.ZipListIndex(0); zc != nil; zc, _ = zl.ZipListNext(zc) {
+				val, _ := zl.ZipListGet(zc)
+				items = append(items, zipListValueBytes(val))
+			}
+		case EncodingIntSet:
+			is := obj.Ptr.(*intset.IntSet)
+			for i := 0; i < is.Len(); i++ {
+				v, _ := is.Get(i)
+				items = append(items, strconv.AppendInt(nil, v, 10))
+			}
+		}
+		cursor = 0
+	}
+
+	step := 1
+	if pairs {
+		step = 2
+	}
+	filtered := items[:0]
+	for i := 0; i < len(items); i += step {
+		if pattern != nil && !utils.StringMatch(pattern, items[i], false) {
+			continue
+		}
+		// 遍历键空间时，过期的 key 在返回前删除
+		if obj == nil && c.db.expireIfNeeded(items[i]) {
+			continue
+		}
+		if typeName != "" {
+			if val, _ := c.db.dict.Get(string(items[i])); objectTypeName(val.(*Object).Type) != typeName {
+				continue
+			}
+		}
+		filtered = append(filtered, items[i:i+step]...)
+	}
+	items = filtered
+
+	c.addReplyArrayLen(2)
+	c.addReplyBulkString(strconv.FormatUint(cursor, 10))
+	c.addReplyArrayLen(len(items))
+	for _, item := range items {
+		c.addReplyBulk(item)
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestKeyspaceCommands(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "list", "a")
+	c.exec("SADD", "set", "1")
+	c.exec("HSET", "hash", "f", "v")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"TYPE", "list"}, want: "+list\r\n"},
+		{args: []string{"TYPE", "set"}, want: "+set\r\n"},
+		{args: []string{"TYPE", "hash"}, want: "+hash\r\n"},
+		{args: []string{"TYPE", "none"}, want: "+none\r\n"},
+		{args: []string{"EXISTS", "list", "list", "none"}, want: ":2\r\n"},
+		{args: []string{"TOUCH", "set", "none"}, want: ":1\r\n"},
+		{args: []string{"DEL", "list", "none", "list"}, want: ":1\r\n"},
+		{args: []string{"UNLINK", "set", "hash"}, want: ":2\r\n"},
+		{args: []string{"EXISTS", "list", "set", "hash"}, want: ":0\r\n"},
+		{args: []string{"RANDOMKEY"}, want: "$-1\r\n"},
+	})
+	if c.db.usedMemory != 0 {
+		t.Fatalf("usedMemory = %d after deleting all keys", c.db.usedMemory)
+	}
+
+	c.exec("RPUSH", "list", "a")
+	if got := c.exec("RANDOMKEY"); got != "$4\r\nlist\r\n" {
+		t.Fatalf("RANDOMKEY = %q", got)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	c := newTestClient(nil)
+	for _, key := range []string{"one", "two", "three", "four", "a*b"} {
+		c.exec("RPUSH", key, "a")
+	}
+	c.exec("PEXPIRE", "four", "10")
+	time.Sleep(20 * time.Millisecond)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*", []string{"a*b", "one", "three", "two"}},
+		{"t*", []string{"three", "two"}},
+		{"?w*", []string{"two"}},
+		{"[ot]*e", []string{"one", "three"}},
+		{"[^o]*", []string{"a*b", "three", "two"}},
+		{"a\\*b", []string{"a*b"}},
+		{"f*", nil},
+	}
+	for _, tt := range tests {
+		if got := sortedReply(t, c.exec("KEYS", tt.pattern)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("KEYS %s = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+	// 过期的 key 被删除
+	if c.db.dict.Len() != 4 {
+		t.Fatalf("expired key should be deleted, keys = %d", c.db.dict.Len())
+	}
+}
+
+func TestRename(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "a", "1", "2")
+	c.exec("EXPIRE", "a", "100")
+	c.exec("SADD", "b", "x")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"RENAME", "none", "x"}, want: "-ERR no such key\r\n"},
+		{args: []string{"RENAMENX", "none", "x"}, want: "-ERR no such key\r\n"},
+		{args: []string{"RENAME", "a", "a"}, want: "+OK\r\n"},
+		{args: []string{"RENAMENX", "a", "a"}, want: ":0\r\n"},
+		{args: []string{"RENAMENX", "a", "b"}, want: ":0\r\n"},
+		{args: []string{"RENAMENX", "a", "c"}, want: ":1\r\n"},
+		{args: []string{"EXISTS", "a"}, want: ":0\r\n"},
+		{args: []string{"LRANGE", "c", "0", "-1"}, want: arrayReply("1", "2")},
+		{args: []string{"TTL", "c"}, want: ":100\r\n"},
+		// 覆盖已经存在的 key，key 的过期时间也被覆盖
+		{args: []string{"EXPIRE", "b", "200"}, want: ":1\r\n"},
+		{args: []string{"PERSIST", "c"}, want: ":1\r\n"},
+		{args: []string{"RENAME", "c", "b"}, want: "+OK\r\n"},
+		{args: []string{"TYPE", "b"}, want: "+list\r\n"},
+		{args: []string{"TTL", "b"}, want: ":-1\r\n"},
+	})
+	if c.db.dict.Len() != 1 || c.db.expires.Len() != 0 {
+		t.Fatalf("keys = %d, expires = %d, want 1 and 0", c.db.dict.Len(), c.db.expires.Len())
+	}
+	checkUsedMemory(t, c.server)
+}
+
+func TestCopy(t *testing.T) {
+	s := CreateServer()
+	s.config.listMaxZiplistSize = 2
+	s.config.listCompressDepth = 1
+	c := newTestClient(s)
+
+	var elems []string
+	for i := 0; i < 20; i++ {
+		elems = append(elems, strconv.Itoa(i)+"-element-of-a-compressed-list")
+	}
+	c.exec(append([]string{"RPUSH", "list"}, elems...)...)
+	c.exec("SADD", "intset", "1", "2")
+	c.exec("SADD", "set", "a", "b")
+	c.exec("HSET", "hash", "f", "v")
+	c.exec("EXPIRE", "hash", "100")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"COPY", "none", "x"}, want: ":0\r\n"},
+		{args: []string{"COPY", "list", "list"}, want: "-ERR source and destination objects are the same\r\n"},
+		{args: []string{"COPY", "list", "x", "FOO"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"COPY", "list", "x", "DB"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"COPY", "list", "x", "DB", "a"}, want: "-ERR value is not an integer or out of range\r\n"},
+		{args: []string{"COPY", "list", "x", "DB", "16"}, want: "-ERR DB index is out of range\r\n"},
+		{args: []string{"COPY", "list", "set"}, want: ":0\r\n"},
+		{args: []string{"COPY", "list", "list2"}, want: ":1\r\n"},
+		{args: []string{"COPY", "intset", "intset2"}, want: ":1\r\n"},
+		{args: []string{"COPY", "set", "set2"}, want: ":1\r\n"},
+		{args: []string{"COPY", "hash", "hash2"}, want: ":1\r\n"},
+		{args: []string{"TTL", "hash2"}, want: ":100\r\n"},
+		{args: []string{"COPY", "list", "set", "replace"}, want: ":1\r\n"},
+		{args: []string{"TYPE", "set"}, want: "+list\r\n"},
+	})
+
+	// 修改拷贝不影响原来的 key
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LSET", "list2", "10", "x"}, want: "+OK\r\n"},
+		{args: []string{"LRANGE", "list", "0", "-1"}, want: arrayReply(elems...)},
+		{args: []string{"SADD", "intset2", "3"}, want: ":1\r\n"},
+		{args: []string{"SCARD", "intset"}, want: ":2\r\n"},
+		{args: []string{"SREM", "set2", "a"}, want: ":1\r\n"},
+		{args: []string{"SISMEMBER", "set2", "a"}, want: ":0\r\n"},
+		{args: []string{"HSET", "hash2", "f", "v2"}, want: ":0\r\n"},
+		{args: []string{"HGET", "hash", "f"}, want: "$1\r\nv\r\n"},
+	})
+
+	// 拷贝到其他 db
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"COPY", "list", "list", "DB", "1"}, want: ":1\r\n"},
+		{args: []string{"COPY", "hash", "list", "db", "1"}, want: ":0\r\n"},
+		{args: []string{"SELECT", "1"}, want: "+OK\r\n"},
+		{args: []string{"LRANGE", "list", "0", "-1"}, want: arrayReply(elems...)},
+	})
+	checkUsedMemory(t, s)
+}
+
+func TestScan(t *testing.T) {
+	c := newTestClient(nil)
+	for i := 0; i < 100; i++ {
+		c.exec("RPUSH", "list"+strconv.Itoa(i), "a")
+		c.exec("SADD", "set"+strconv.Itoa(i), "a")
+	}
+	c.exec("HSET", "hash", "f", "v")
+
+	// 遍历直到 cursor 为 0，每次调用之后执行 between
+	scanAll := func(between func(), opts ...string) map[string]int {
+		got := map[string]int{}
+		cursor := "0"
+		for {
+			elems := parseReply(t, c.exec(append([]string{"SCAN", cursor}, opts...)...))
+			cursor = elems[0]
+			for _, key := range elems[1:] {
+				got[key]++
+			}
+			if cursor == "0" {
+				return got
+			}
+			if between != nil {
+				between()
+			}
+		}
+	}
+
+	if got := scanAll(nil, "COUNT", "7"); len(got) != 201 {
+		t.Fatalf("SCAN returned %d keys, want 201", len(got))
+	}
+	if got := scanAll(nil, "MATCH", "list1*"); len(got) != 11 {
+		t.Fatalf("SCAN MATCH list1* returned %v, want 11 keys", got)
+	}
+	if got := scanAll(nil, "TYPE", "SET", "COUNT", "20"); len(got) != 100 || got["set0"] != 1 {
+		t.Fatalf("SCAN TYPE set returned %d keys, want 100", len(got))
+	}
+	if got := scanAll(nil, "MATCH", "*9", "TYPE", "list"); len(got) != 10 || got["list99"] != 1 {
+		t.Fatalf("SCAN MATCH *9 TYPE list returned %v, want 10 keys", got)
+	}
+	if got := scanAll(nil, "TYPE", "zset"); len(got) != 0 {
+		t.Fatalf("SCAN TYPE zset returned %v", got)
+	}
+
+	// 遍历过程中 dict 扩容、rehash，遍历开始时就存在的 key 都被返回
+	n := 0
+	got := scanAll(func() {
+		for i := 0; i < 50 && n < 1000; i++ {
+			c.exec("RPUSH", "new"+strconv.Itoa(n), "a")
+			n++
+		}
+	}, "COUNT", "5")
+	for i := 0; i < 100; i++ {
+		if got["list"+strconv.Itoa(i)] == 0 || got["set"+strconv.Itoa(i)] == 0 {
+			t.Fatalf("SCAN during rehashing missed list%d or set%d", i, i)
+		}
+	}
+
+	// 遍历过程中删除 key 导致 dict 缩容，没有被删除的 key 都被返回
+	buckets := c.db.dict.BucketLen()
+	got = scanAll(func() {
+		for ; n > 0; n-- {
+			c.exec("DEL", "new"+strconv.Itoa(n-1))
+		}
+	}, "COUNT", "5")
+	if c.db.dict.BucketLen() >= buckets {
+		t.Fatalf("dict should be shrunk, buckets = %d", c.db.dict.BucketLen())
+	}
+	if got["hash"] == 0 {
+		t.Fatalf("SCAN during shrinking missed hash")
+	}
+	for i := 0; i < 100; i++ {
+		if got["list"+strconv.Itoa(i)] == 0 || got["set"+strconv.Itoa(i)] == 0 {
+			t.Fatalf("SCAN during shrinking missed list%d or set%d", i, i)
+		}
+	}
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"SCAN", "a"}, want: "-ERR invalid cursor\r\n"},
+		{args: []string{"SCAN", "0", "TYPE"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"SCAN", "0", "TYPE", "foo"}, want: "-ERR unknown type name 'foo'\r\n"},
+		{args: []string{"SCAN", "0", "COUNT", "0"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"HSCAN", "hash", "0", "TYPE", "hash"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"SSCAN", "set0", "0", "TYPE", "set"}, want: "-ERR syntax error\r\n"},
+	})
+}
+
+func TestScanExpired(t *testing.T) {
+	c := newTestClient(nil)
+	for i := 0; i < 10; i++ {
+		c.exec("RPUSH", "k"+strconv.Itoa(i), "a")
+		if i%2 == 0 {
+			c.exec("PEXPIRE", "k"+strconv.Itoa(i), "10")
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	want := []string{"0", "k1", "k3", "k5", "k7", "k9"}
+	if got := sortedReply(t, c.exec("SCAN", "0", "COUNT", "100")); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SCAN = %v, want %v", got, want)
+	}
+	if c.db.dict.Len() != 5 || c.db.expires.Len() != 0 {
+		t.Fatalf("expired keys should be deleted, keys = %d, expires = %d", c.db.dict.Len(), c.db.expires.Len())
+	}
+}
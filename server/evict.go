@@ -0,0 +1,289 @@
+package server
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/WANGgbin/tiny_redis/data_type/dict"
+)
+
+// 数据集的内存(见 redisDb.usedMemory)超过 maxmemory 时，执行命令前按照 maxmemory-policy 淘汰 key，
+// 淘汰后仍然超过时拒绝执行可能使用更多内存的命令。与 redis 相同，LRU、LFU 以及 volatile-ttl 都是近似的：
+// 每次从每个 db 中抽样 maxmemory-samples 个 key 放入淘汰池，淘汰池中保留最适合淘汰的 key，
+// 再从中淘汰最好的一个，淘汰池在多次淘汰之间保留，因此抽样的结果不会被浪费。
+//
+// Object.lru 在 LRU 策略下是以秒为单位的 24 位 LRU 时钟；在 LFU 策略下高 16 位是以分钟为单位的
+// 最近一次衰减的时间，低 8 位是对数计数器：访问次数越多计数器增加的概率越低，每过 lfu-decay-time 分钟
+// 计数器减 1。
+
+const (
+	maxmemoryFlagLRU = 1 << iota
+	maxmemoryFlagLFU
+	maxmemoryFlagAllKeys
+)
+
+const (
+	maxmemoryVolatileLRU    = 0<<8 | maxmemoryFlagLRU
+	maxmemoryVolatileLFU    = 1<<8 | maxmemoryFlagLFU
+	maxmemoryVolatileTTL    = 2 << 8
+	maxmemoryVolatileRandom = 3 << 8
+	maxmemoryAllKeysLRU     = 4<<8 | maxmemoryFlagLRU | maxmemoryFlagAllKeys
+	maxmemoryAllKeysLFU     = 5<<8 | maxmemoryFlagLFU | maxmemoryFlagAllKeys
+	maxmemoryAllKeysRandom  = 6<<8 | maxmemoryFlagAllKeys
+	maxmemoryNoEviction     = 7 << 8
+)
+
+var maxmemoryPolicies = []configEnum{
+	{"volatile-lru", maxmemoryVolatileLRU},
+	{"volatile-lfu", maxmemoryVolatileLFU},
+	{"volatile-random", maxmemoryVolatileRandom},
+	{"volatile-ttl", maxmemoryVolatileTTL},
+	{"allkeys-lru", maxmemoryAllKeysLRU},
+	{"allkeys-lfu", maxmemoryAllKeysLFU},
+	{"allkeys-random", maxmemoryAllKeysRandom},
+	{"noeviction", maxmemoryNoEviction},
+}
+
+const (
+	lruClockMax = 1<<24 - 1
+	// lruClockResolution 是 LRU 时钟的精度，单位为毫秒
+	lruClockResolution = 1000
+
+	lfuInitVal = 5
+
+	evictionPoolSize = 16
+)
+
+// lruClock returns the current LRU clock.
+func lruClock() uint32 {
+	return uint32(mstime() / lruClockResolution & lruClockMax)
+}
+
+// estimateObjectIdleTime returns the idle time of obj in milliseconds, the LRU clock wraps about
+// every 194 days.
+func estimateObjectIdleTime(obj *Object) uint64 {
+	clock := lruClock()
+	if clock >= obj.lru {
+		return uint64(clock-obj.lru) * lruClockResolution
+	}
+	return uint64(clock+(lruClockMax-obj.lru)) * lruClockResolution
+}
+
+// lfuTimeInMinutes returns the current time in minutes, only the least significant 16 bits are used.
+func lfuTimeInMinutes() uint32 {
+	return uint32(mstime()/1000/60) & math.MaxUint16
+}
+
+// lfuTimeElapsed returns minutes elapsed since ldt, the time wraps about every 45 days.
+func lfuTimeElapsed(ldt uint32) uint32 {
+	now := lfuTimeInMinutes()
+	if now >= ldt {
+		return now - ldt
+	}
+	return math.MaxUint16 - ldt + now
+}
+
+// lfuLogIncr increments counter logarithmically, the larger counter is the less likely it's
+// incremented.
+func lfuLogIncr(counter uint32, logFactor int) uint32 {
+	if counter == math.MaxUint8 {
+		return counter
+	}
+
+	baseval := float64(counter) - lfuInitVal
+	if baseval < 0 {
+		baseval = 0
+	}
+	if rand.Float64() < 1/(baseval*float64(logFactor)+1) {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecrAndReturn returns the counter of obj decremented by the periods elapsed since the last
+// decrement, obj is not modified.
+func lfuDecrAndReturn(obj *Object, decayTime int) uint32 {
+	ldt, counter := obj.lru>>8, obj.lru&math.MaxUint8
+	if decayTime == 0 {
+		return counter
+	}
+
+	periods := lfuTimeElapsed(ldt) / uint32(decayTime)
+	if periods >= counter {
+		return 0
+	}
+	return counter - periods
+}
+
+// updateLFU decrements the counter of obj if needed and then increments it for the access.
+func (db *redisDb) updateLFU(obj *Object) {
+	counter := lfuDecrAndReturn(obj, db.config.lfuDecayTime)
+	counter = lfuLogIncr(counter, db.config.lfuLogFactor)
+	obj.lru = lfuTimeInMinutes()<<8 | counter
+}
+
+// objectSetLRUOrLFU sets the access time or frequency of obj loaded from RDB, idle is the idle time in
+// seconds and freq is the LFU counter, -1 means it's not saved. Only the one used by maxmemory-policy
+// is applied.
+func (db *redisDb) objectSetLRUOrLFU(obj *Object, idle int64, freq int) {
+	if db.config.maxmemoryPolicy&maxmemoryFlagLFU != 0 {
+		if freq >= 0 {
+			obj.lru = lfuTimeInMinutes()<<8 | uint32(freq)
+		}
+		return
+	}
+
+	if idle >= 0 {
+		// LRU 时钟会回绕，见 estimateObjectIdleTime
+		clock := int64(lruClock()) - idle*1000/lruClockResolution
+		if clock < 0 {
+			clock += lruClockMax
+		}
+		obj.lru = uint32(clock)
+	}
+}
+
+// evictionPoolEntry 是淘汰池中的 key，idle 越大越应该被淘汰
+type evictionPoolEntry struct {
+	idle uint64
+	key  string
+	dbID int
+}
+
+// usedMemory returns memory used by keys of all dbs.
+func (s *Server) usedMemory() int64 {
+	var used int64
+	for _, db := range s.dbs {
+		used += db.usedMemory
+	}
+	return used
+}
+
+// evictionDict returns the dict to choose keys to evict from, all keys or only keys with an expire.
+func evictionDict(db *redisDb, allKeys bool) *dict.Dict {
+	if allKeys {
+		return db.dict
+	}
+	return db.expires
+}
+
+// evictionPoolPopulate samples keys of db and adds them to the eviction pool, which is kept
+// sorted by idle in ascending order.
+func (s *Server) evictionPoolPopulate(db *redisDb, allKeys bool) {
+	policy := s.config.maxmemoryPolicy
+	for i := 0; i < s.config.maxmemorySamples; i++ {
+		key, val, ok := evictionDict(db, allKeys).RandomEntry()
+		if !ok {
+			return
+		}
+
+		var idle uint64
+		switch {
+		case policy == maxmemoryVolatileTTL:
+			// 越早过期越应该被淘汰
+			idle = math.MaxUint64 - uint64(val.(int64))
+		default:
+			if !allKeys {
+				val, _ = db.dict.Get(key)
+			}
+			obj := val.(*Object)
+			if policy&maxmemoryFlagLRU != 0 {
+				idle = estimateObjectIdleTime(obj)
+			} else {
+				idle = math.MaxUint8 - uint64(lfuDecrAndReturn(obj, s.config.lfuDecayTime))
+			}
+		}
+
+		s.evictionPoolInsert(evictionPoolEntry{idle: idle, key: key, dbID: db.id})
+	}
+}
+
+func (s *Server) evictionPoolInsert(entry evictionPoolEntry) {
+	pool := s.evictionPool
+	for _, e := range pool {
+		if e.key == entry.key && e.dbID == entry.dbID {
+			return
+		}
+	}
+	// 淘汰池已满并且比所有 key 都不适合淘汰
+	if len(pool) == evictionPoolSize && entry.idle <= pool[0].idle {
+		return
+	}
+
+	k := 0
+	for k < len(pool) && pool[k].idle < entry.idle {
+		k++
+	}
+	pool = append(pool, evictionPoolEntry{})
+	copy(pool[k+1:], pool[k:])
+	pool[k] = entry
+	// 丢弃最不适合淘汰的 key
+	if len(pool) > evictionPoolSize {
+		pool = pool[1:]
+	}
+	s.evictionPool = pool
+}
+
+// evictionPoolPop pops the best key to evict from the pool, nil is returned when the pool is empty.
+// Keys that were deleted or no longer have an expire after being sampled are skipped.
+func (s *Server) evictionPoolPop(allKeys bool) (*redisDb, []byte) {
+	for len(s.evictionPool) > 0 {
+		entry := s.evictionPool[len(s.evictionPool)-1]
+		s.evictionPool = s.evictionPool[:len(s.evictionPool)-1]
+
+		db := s.dbs[entry.dbID]
+		if _, ok := evictionDict(db, allKeys).Get(entry.key); ok {
+			return db, []byte(entry.key)
+		}
+	}
+	return nil, nil
+}
+
+// performEvictions evicts keys until the used memory is not greater than maxmemory, false is
+// returned when not enough memory can be freed.
+func (s *Server) performEvictions() bool {
+	policy := s.config.maxmemoryPolicy
+	if s.usedMemory() <= s.config.maxmemory {
+		return true
+	}
+	if policy == maxmemoryNoEviction {
+		return false
+	}
+
+	allKeys := policy&maxmemoryFlagAllKeys != 0
+	for s.usedMemory() > s.config.maxmemory {
+		var db *redisDb
+		var key []byte
+
+		if policy&(maxmemoryFlagLRU|maxmemoryFlagLFU) != 0 || policy == maxmemoryVolatileTTL {
+			for key == nil {
+				total := 0
+				for _, db := range s.dbs {
+					if n := evictionDict(db, allKeys).Len(); n > 0 {
+						total += n
+						s.evictionPoolPopulate(db, allKeys)
+					}
+				}
+				if total == 0 {
+					break
+				}
+				db, key = s.evictionPoolPop(allKeys)
+			}
+		} else {
+			// 随机淘汰时依次从每个 db 中选择
+			for i := 0; i < len(s.dbs) && key == nil; i++ {
+				db = s.dbs[s.evictDb%len(s.dbs)]
+				s.evictDb++
+				if k, _, ok := evictionDict(db, allKeys).RandomEntry(); ok {
+					key = []byte(k)
+				}
+			}
+		}
+
+		if key == nil {
+			return false
+		}
+		db.dbGenericDelete(key, s.config.lazyfreeLazyEviction)
+	}
+	return true
+}
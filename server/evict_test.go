@@ -0,0 +1,288 @@
+package server
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// checkUsedMemory 检查 usedMemory 与重新统计所有 key 的结果相同
+func checkUsedMemory(t *testing.T, s *Server) {
+	t.Helper()
+
+	for _, db := range s.dbs {
+		var want int64
+		db.dict.ForEach(func(key string, val interface{}) bool {
+			want += keyComputeSize([]byte(key), val.(*Object))
+			return true
+		})
+		if db.usedMemory != want {
+			t.Fatalf("usedMemory of db %d = %d, want %d", db.id, db.usedMemory, want)
+		}
+	}
+}
+
+func TestUsedMemory(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+	if s.usedMemory() != 0 {
+		t.Fatalf("usedMemory of empty server = %d", s.usedMemory())
+	}
+
+	r := rand.New(rand.NewSource(1))
+	cmds := [][]string{
+		{"RPUSH", "list", "v"},
+		{"LPOP", "list", "3"},
+		{"HSET", "hash", "f", "v"},
+		{"HDEL", "hash", "f"},
+		{"SADD", "set", "v"},
+		{"SPOP", "set"},
+		{"SUNIONSTORE", "set", "set", "other"},
+		{"SADD", "other", "v"},
+		{"LMPOP", "2", "none", "list", "LEFT"},
+		{"LMOVE", "list", "dst", "LEFT", "LEFT"},
+		{"PEXPIRE", "dst", "1"},
+	}
+	for i := 0; i < 2000; i++ {
+		args := append([]string(nil), cmds[r.Intn(len(cmds))]...)
+		// 一部分值是整数，一部分值很长，使 hash、set 转换编码
+		for j, arg := range args {
+			switch arg {
+			case "v":
+				args[j] = strconv.Itoa(r.Intn(1000))
+				if r.Intn(10) == 0 {
+					args[j] = "long-" + strconv.Itoa(r.Intn(1000)) + string(make([]byte, 100))
+				}
+			case "f":
+				args[j] = "f" + strconv.Itoa(r.Intn(200))
+			}
+		}
+		c.exec(args...)
+		checkUsedMemory(t, s)
+	}
+
+	for _, key := range []string{"list", "hash", "set", "other", "dst"} {
+		c.db.dbDelete([]byte(key))
+	}
+	if s.usedMemory() != 0 {
+		t.Fatalf("usedMemory after deleting all keys = %d", s.usedMemory())
+	}
+}
+
+func TestMaxmemoryConfig(t *testing.T) {
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"CONFIG", "GET", "maxmemory", "maxmemory-policy"}, want: arrayReply("maxmemory", "0", "maxmemory-policy", "noeviction")},
+		{args: []string{"CONFIG", "SET", "maxmemory", "10mb", "maxmemory-policy", "ALLKEYS-LRU"}, want: "+OK\r\n"},
+		{args: []string{"CONFIG", "GET", "maxmemory", "maxmemory-policy"}, want: arrayReply("maxmemory", "10485760", "maxmemory-policy", "allkeys-lru")},
+		{args: []string{"CONFIG", "SET", "maxmemory", "2k"}, want: "+OK\r\n"},
+		{args: []string{"CONFIG", "GET", "maxmemory"}, want: arrayReply("maxmemory", "2000")},
+		{args: []string{"CONFIG", "SET", "maxmemory", "1xb"}, want: "-ERR CONFIG SET failed (possibly related to argument 'maxmemory') - argument must be a memory value\r\n"},
+		{args: []string{"CONFIG", "SET", "maxmemory", "-1"}, want: "-ERR CONFIG SET failed (possibly related to argument 'maxmemory') - argument must be a memory value\r\n"},
+		{args: []string{"CONFIG", "SET", "maxmemory-policy", "lru"}, want: "-ERR CONFIG SET failed (possibly related to argument 'maxmemory-policy') - argument(s) must be one of the following: " +
+			"volatile-lru, volatile-lfu, volatile-random, volatile-ttl, allkeys-lru, allkeys-lfu, allkeys-random, noeviction\r\n"},
+		{args: []string{"CONFIG", "SET", "maxmemory-samples", "0"}, want: "-ERR CONFIG SET failed (possibly related to argument 'maxmemory-samples') - argument must be between 1 and 64 inclusive\r\n"},
+	})
+}
+
+func TestNoEviction(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+	c.exec("RPUSH", "k", "a", "b")
+	s.config.maxmemory = s.usedMemory() - 1
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"RPUSH", "k", "c"}, want: "-" + oomErr + "\r\n"},
+		{args: []string{"HSET", "h", "f", "v"}, want: "-" + oomErr + "\r\n"},
+		// 不会使用更多内存的命令可以执行
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("a", "b")},
+		{args: []string{"EXPIRE", "k", "100"}, want: ":1\r\n"},
+		{args: []string{"LPOP", "k"}, want: "$1\r\na\r\n"},
+	})
+
+	// 事务中排队的命令也会被拒绝
+	s.config.maxmemory = 1
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"LLEN", "k"}, want: "-" + oomErr + "\r\n"},
+		{args: []string{"EXEC"}, want: "-EXECABORT Transaction discarded because of previous errors.\r\n"},
+	})
+
+	s.config.maxmemory = 0
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"RPUSH", "k", "c"}, want: "+QUEUED\r\n"},
+	})
+	s.config.maxmemory = 1
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"EXEC"}, want: "-EXECABORT Transaction discarded because of: " + oomErr + "\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("b")},
+	})
+}
+
+// setupEvictionKeys 创建 n 个大小相同的 key，返回每个 key 的内存
+func setupEvictionKeys(t *testing.T, c *Client, n int) int64 {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		c.exec("RPUSH", "k"+strconv.Itoa(i), "v")
+	}
+	return c.server.usedMemory() / int64(n)
+}
+
+// remainingKeys 返回 db 中 k0 到 k(n-1) 中仍然存在的 key 的下标
+func remainingKeys(db *redisDb, n int) map[int]bool {
+	remaining := make(map[int]bool)
+	for i := 0; i < n; i++ {
+		if _, ok := db.dict.Get("k" + strconv.Itoa(i)); ok {
+			remaining[i] = true
+		}
+	}
+	return remaining
+}
+
+func TestEvictLRU(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+	s.config.maxmemoryPolicy = maxmemoryAllKeysLRU
+	s.config.maxmemorySamples = 64
+	size := setupEvictionKeys(t, c, 10)
+
+	// 下标越小的 key 越久没有被访问
+	for i := 0; i < 10; i++ {
+		val, _ := c.db.dict.Get("k" + strconv.Itoa(i))
+		val.(*Object).lru = lruClock() - uint32(100-i)
+	}
+	s.config.maxmemory = s.usedMemory() - 3*size
+
+	c.exec("PING")
+	remaining := remainingKeys(c.db, 10)
+	if len(remaining) != 7 {
+		t.Fatalf("remaining keys = %v, want 7 keys", remaining)
+	}
+	for i := 0; i < 3; i++ {
+		if remaining[i] {
+			t.Fatalf("remaining keys = %v, k%d should be evicted", remaining, i)
+		}
+	}
+	checkUsedMemory(t, s)
+}
+
+func TestEvictLFU(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+	s.config.maxmemoryPolicy = maxmemoryAllKeysLFU
+	s.config.maxmemorySamples = 64
+	size := setupEvictionKeys(t, c, 10)
+
+	// 下标越小的 key 访问频率越低
+	for i := 0; i < 10; i++ {
+		val, _ := c.db.dict.Get("k" + strconv.Itoa(i))
+		val.(*Object).lru = lfuTimeInMinutes()<<8 | uint32(10+i)
+	}
+	s.config.maxmemory = s.usedMemory() - 3*size
+
+	c.exec("PING")
+	remaining := remainingKeys(c.db, 10)
+	if len(remaining) != 7 || remaining[0] || remaining[1] || remaining[2] {
+		t.Fatalf("remaining keys = %v, want k3 to k9", remaining)
+	}
+}
+
+func TestEvictVolatile(t *testing.T) {
+	for _, policy := range []int{maxmemoryVolatileLRU, maxmemoryVolatileLFU, maxmemoryVolatileRandom, maxmemoryVolatileTTL} {
+		t.Run(strconv.Itoa(policy), func(t *testing.T) {
+			s := CreateServer()
+			c := newTestClient(s)
+			s.config.maxmemoryPolicy = policy
+			s.config.maxmemorySamples = 64
+			size := setupEvictionKeys(t, c, 10)
+
+			// 只有偶数下标的 key 有过期时间，下标越小越早过期
+			for i := 0; i < 10; i += 2 {
+				c.exec("EXPIRE", "k"+strconv.Itoa(i), strconv.Itoa(100+i))
+			}
+			s.config.maxmemory = s.usedMemory() - 3*size
+
+			c.exec("PING")
+			remaining := remainingKeys(c.db, 10)
+			if len(remaining) != 7 {
+				t.Fatalf("remaining keys = %v, want 7 keys", remaining)
+			}
+			for i := 1; i < 10; i += 2 {
+				if !remaining[i] {
+					t.Fatalf("remaining keys = %v, k%d without expire should not be evicted", remaining, i)
+				}
+			}
+			if policy == maxmemoryVolatileTTL && (remaining[0] || remaining[2] || remaining[4]) {
+				t.Fatalf("remaining keys = %v, keys expiring first should be evicted", remaining)
+			}
+
+			// 没有可以淘汰的 key 时拒绝执行命令
+			s.config.maxmemory = size
+			if got := c.exec("RPUSH", "k1", "v"); got != "-"+oomErr+"\r\n" {
+				t.Fatalf("RPUSH = %q, want OOM error", got)
+			}
+			if remaining = remainingKeys(c.db, 10); len(remaining) != 5 {
+				t.Fatalf("remaining keys = %v, want keys without expire", remaining)
+			}
+		})
+	}
+}
+
+func TestEvictAllKeysRandom(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+	s.config.maxmemoryPolicy = maxmemoryAllKeysRandom
+	size := setupEvictionKeys(t, c, 10)
+	c.exec("SELECT", "1")
+	setupEvictionKeys(t, c, 10)
+
+	s.config.maxmemory = s.usedMemory() - 10*size
+	c.exec("PING")
+	if n := s.dbs[0].dict.Len() + s.dbs[1].dict.Len(); n != 10 {
+		t.Fatalf("remaining keys = %d, want 10", n)
+	}
+	// 依次从每个 db 中淘汰
+	if n := s.dbs[0].dict.Len(); n != 5 {
+		t.Fatalf("remaining keys of db 0 = %d, want 5", n)
+	}
+	checkUsedMemory(t, s)
+}
+
+func TestLFUCounter(t *testing.T) {
+	counter := uint32(lfuInitVal)
+	for i := 0; i < 1000; i++ {
+		counter = lfuLogIncr(counter, 10)
+	}
+	// 计数器以对数增长
+	if counter <= lfuInitVal || counter > 30 {
+		t.Fatalf("counter after 1000 increments = %d", counter)
+	}
+	for i := 0; i < 10000000 && counter < 255; i++ {
+		counter = lfuLogIncr(counter, 0)
+	}
+	if counter != 255 || lfuLogIncr(counter, 0) != 255 {
+		t.Fatalf("counter should saturate at 255, got %d", counter)
+	}
+
+	obj := &Object{lru: (lfuTimeInMinutes()-3)&0xffff<<8 | 10}
+	tests := []struct {
+		decayTime int
+		want      uint32
+	}{
+		{decayTime: 0, want: 10},
+		{decayTime: 1, want: 7},
+		{decayTime: 2, want: 9},
+		{decayTime: 4, want: 10},
+	}
+	for _, tt := range tests {
+		if got := lfuDecrAndReturn(obj, tt.decayTime); got != tt.want {
+			t.Fatalf("lfuDecrAndReturn with decay time %d = %d, want %d", tt.decayTime, got, tt.want)
+		}
+	}
+	obj.lru = (lfuTimeInMinutes()-30)&0xffff<<8 | 10
+	if got := lfuDecrAndReturn(obj, 1); got != 0 {
+		t.Fatalf("lfuDecrAndReturn = %d, want 0", got)
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// 过期的 key 有两种删除方式，与 redis 相同:
+// 1. 被动删除：访问 key 时检查是否过期，见 redisDb.expireIfNeeded
+// 2. 主动删除：serverCron 中调用 activeExpireCycle 对设置了过期时间的 key 抽样，删除其中过期的 key。
+//    抽样中过期 key 的比例较高时继续抽样，直到比例足够低或者超过时间限制，避免阻塞事件循环太久。
+
+const (
+	// activeExpireCycleKeysPerLoop 是每个 db 每轮抽样的 key 的个数
+	activeExpireCycleKeysPerLoop = 20
+	// activeExpireCycleAcceptableStale 是可以接受的过期 key 的百分比，超过时继续抽样
+	activeExpireCycleAcceptableStale = 10
+	// activeExpireCycleSlowTimePerc 是 activeExpireCycle 可以使用的 cpu 时间的百分比
+	activeExpireCycleSlowTimePerc = 25
+	// cronDbsPerCall 是每次 activeExpireCycle 最多处理的 db 的个数
+	cronDbsPerCall = 16
+)
+
+const (
+	expireNX = 1 << iota
+	expireXX
+	expireGT
+	expireLT
+)
+
+// mstime returns the current unix time in milliseconds.
+func mstime() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// activeExpireCycle samples keys with an expire in each db and deletes the expired ones. It keeps
+// sampling a db while more than activeExpireCycleAcceptableStale percent of the sampled keys are
+// expired, and returns when the time limit is reached.
+func (s *Server) activeExpireCycle() {
+	start := time.Now()
+	timelimit := time.Second * activeExpireCycleSlowTimePerc / time.Duration(s.config.hz) / 100
+
+	dbsPerCall := cronDbsPerCall
+	if dbsPerCall > len(s.dbs) {
+		dbsPerCall = len(s.dbs)
+	}
+
+	iteration := 0
+	for i := 0; i < dbsPerCall; i++ {
+		// 从上次结束的 db 继续，避免超时时后面的 db 总是得不到处理
+		db := s.dbs[s.expireDb%len(s.dbs)]
+		s.expireDb++
+
+		for {
+			num := db.expires.Len()
+			if num == 0 {
+				break
+			}
+			if num > activeExpireCycleKeysPerLoop {
+				num = activeExpireCycleKeysPerLoop
+			}
+
+			now := mstime()
+			sampled, expired := 0, 0
+			// 空桶较多时限制遍历的桶的个数
+			maxBuckets := num * 20
+			for checkedBuckets := 0; sampled < num && checkedBuckets < maxBuckets; checkedBuckets++ {
+				var keys []string
+				db.expiresCursor = db.expires.Scan(db.expiresCursor, func(key string, val interface{}) {
+					sampled++
+					if val.(int64) <= now {
+						keys = append(keys, key)
+					}
+				})
+				// 不能在 Scan 的回调中修改 dict
+				for _, key := range keys {
+					db.dbGenericDelete([]byte(key), db.config.lazyfreeLazyExpire)
+				}
+				expired += len(keys)
+
+				if db.expiresCursor == 0 {
+					break
+				}
+			}
+
+			iteration++
+			if iteration%16 == 0 && time.Since(start) > timelimit {
+				return
+			}
+			if sampled == 0 || expired*100/sampled <= activeExpireCycleAcceptableStale {
+				break
+			}
+		}
+	}
+}
+
+// parseExpireOptionsOrReply parses NX, XX, GT and LT of EXPIRE family commands.
+func (c *Client) parseExpireOptionsOrReply(args [][]byte) (int, bool) {
+	flags := 0
+	for _, arg := range args {
+		switch strings.ToLower(string(arg)) {
+		case "nx":
+			flags |= expireNX
+		case "xx":
+			flags |= expireXX
+		case "gt":
+			flags |= expireGT
+		case "lt":
+			flags |= expireLT
+		default:
+			c.addReplyErrorFormat("ERR Unsupported option %s", arg)
+			return 0, false
+		}
+	}
+
+	if flags&expireNX != 0 && flags&(expireXX|expireGT|expireLT) != 0 {
+		c.addReplyError("ERR NX and XX, GT or LT options at the same time are not compatible")
+		return 0, false
+	}
+	if flags&expireGT != 0 && flags&expireLT != 0 {
+		c.addReplyError("ERR GT and LT options at the same time are not compatible")
+		return 0, false
+	}
+	return flags, true
+}
+
+// expireGenericCommand implements EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT. basetime is the time
+// in milliseconds that argv[2] is relative to, unit is the milliseconds of argv[2].
+func expireGenericCommand(c *Client, basetime int64, unit int64) {
+	key := c.argv[1]
+	flags, ok := c.parseExpireOptionsOrReply(c.argv[3:])
+	if !ok {
+		return
+	}
+
+	when, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+	if when > math.MaxInt64/unit || when < math.MinInt64/unit || when*unit > math.MaxInt64-basetime {
+		c.addReplyErrorFormat("ERR invalid expire time in '%s' command", c.cmd.name)
+		return
+	}
+	when = when*unit + basetime
+
+	if c.db.lookupKeyWrite(key) == nil {
+		c.addReplyInt(0)
+		return
+	}
+
+	if flags != 0 {
+		// 没有过期时间的 key 认为过期时间是无穷大
+		current := c.db.getExpire(key)
+		if (flags&expireNX != 0 && current != -1) ||
+			(flags&expireXX != 0 && current == -1) ||
+			(flags&expireGT != 0 && (current == -1 || when <= current)) ||
+			(flags&expireLT != 0 && current != -1 && when >= current) {
+			c.addReplyInt(0)
+			return
+		}
+	}
+
+	if when <= mstime() {
+		c.db.dbGenericDelete(key, c.db.config.lazyfreeLazyExpire)
+	} else {
+		c.db.setExpire(key, when)
+	}
+	c.addReplyInt(1)
+}
+
+// expireCommand EXPIRE key seconds [NX | XX | GT | LT]
+func expireCommand(c *Client) {
+	expireGenericCommand(c, mstime(), 1000)
+}
+
+// pexpireCommand PEXPIRE key milliseconds [NX | XX | GT | LT]
+func pexpireCommand(c *Client) {
+	expireGenericCommand(c, mstime(), 1)
+}
+
+// expireatCommand EXPIREAT key unix-time-seconds [NX | XX | GT | LT]
+func expireatCommand(c *Client) {
+	expireGenericCommand(c, 0, 1000)
+}
+
+// pexpireatCommand PEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT]
+func pexpireatCommand(c *Client) {
+	expireGenericCommand(c, 0, 1)
+}
+
+// ttlGenericCommand implements TTL, PTTL, EXPIRETIME and PEXPIRETIME. -2 is replied when key doesn't
+// exist and -1 is replied when key has no expire.
+func ttlGenericCommand(c *Client, outputMs, outputAbs bool) {
+	key := c.argv[1]
+	if c.db.lookupKeyRead(key) == nil {
+		c.addReplyInt(-2)
+		return
+	}
+
+	expire := c.db.getExpire(key)
+	if expire == -1 {
+		c.addReplyInt(-1)
+		return
+	}
+
+	ttl := expire
+	if !outputAbs {
+		ttl -= mstime()
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if !outputMs {
+		ttl = (ttl + 500) / 1000
+	}
+	c.addReplyInt(ttl)
+}
+
+// ttlCommand TTL key
+func ttlCommand(c *Client) {
+	ttlGenericCommand(c, false, false)
+}
+
+// pttlCommand PTTL key
+func pttlCommand(c *Client) {
+	ttlGenericCommand(c, true, false)
+}
+
+// expiretimeCommand EXPIRETIME key
+func expiretimeCommand(c *Client) {
+	ttlGenericCommand(c, false, true)
+}
+
+// pexpiretimeCommand PEXPIRETIME key
+func pexpiretimeCommand(c *Client) {
+	ttlGenericCommand(c, true, true)
+}
+
+// persistCommand PERSIST key
+func persistCommand(c *Client) {
+	key := c.argv[1]
+	if c.db.lookupKeyWrite(key) == nil {
+		c.addReplyInt(0)
+		return
+	}
+
+	if c.db.removeExpire(key) {
+		c.addReplyInt(1)
+	} else {
+		c.addReplyInt(0)
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"TTL", "k"}, want: ":-1\r\n"},
+		{args: []string{"PTTL", "none"}, want: ":-2\r\n"},
+		{args: []string{"EXPIRETIME", "k"}, want: ":-1\r\n"},
+		{args: []string{"EXPIRE", "none", "100"}, want: ":0\r\n"},
+		{args: []string{"EXPIRE", "k", "a"}, want: "-ERR value is not an integer or out of range\r\n"},
+		{args: []string{"EXPIRE", "k", "9223372036854775807"}, want: "-ERR invalid expire time in 'expire' command\r\n"},
+		{args: []string{"PEXPIRE", "k", "9223372036854775807"}, want: "-ERR invalid expire time in 'pexpire' command\r\n"},
+		{args: []string{"EXPIRE", "k", "100"}, want: ":1\r\n"},
+		{args: []string{"TTL", "k"}, want: ":100\r\n"},
+		{args: []string{"PERSIST", "k"}, want: ":1\r\n"},
+		{args: []string{"PERSIST", "k"}, want: ":0\r\n"},
+		{args: []string{"PERSIST", "none"}, want: ":0\r\n"},
+		{args: []string{"TTL", "k"}, want: ":-1\r\n"},
+		{args: []string{"EXPIREAT", "k", "4000000000"}, want: ":1\r\n"},
+		{args: []string{"EXPIRETIME", "k"}, want: ":4000000000\r\n"},
+		{args: []string{"PEXPIRETIME", "k"}, want: ":4000000000000\r\n"},
+		{args: []string{"PEXPIREAT", "k", "4000000000123"}, want: ":1\r\n"},
+		{args: []string{"PEXPIRETIME", "k"}, want: ":4000000000123\r\n"},
+	})
+
+	reply := c.exec("PTTL", "k")
+	if pttl, _ := strconv.ParseInt(strings.TrimSuffix(reply[1:], "\r\n"), 10, 64); pttl <= 0 {
+		t.Fatalf("PTTL = %q, want positive ttl", reply)
+	}
+
+	// 过期时间早于当前时间时直接删除 key
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"EXPIRE", "k", "-1"}, want: ":1\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+		{args: []string{"TTL", "k"}, want: ":-2\r\n"},
+	})
+	if c.db.expires.Len() != 0 {
+		t.Fatalf("expire of deleted key should be removed")
+	}
+}
+
+func TestExpireOptions(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"EXPIRE", "k", "100", "FOO"}, want: "-ERR Unsupported option FOO\r\n"},
+		{args: []string{"EXPIRE", "k", "100", "NX", "XX"}, want: "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n"},
+		{args: []string{"EXPIRE", "k", "100", "GT", "LT"}, want: "-ERR GT and LT options at the same time are not compatible\r\n"},
+		// 没有过期时间时，XX、GT 不生效，LT 生效
+		{args: []string{"EXPIRE", "k", "100", "XX"}, want: ":0\r\n"},
+		{args: []string{"EXPIRE", "k", "100", "GT"}, want: ":0\r\n"},
+		{args: []string{"EXPIRE", "k", "100", "NX"}, want: ":1\r\n"},
+		{args: []string{"EXPIRE", "k", "200", "NX"}, want: ":0\r\n"},
+		{args: []string{"EXPIRE", "k", "200", "XX"}, want: ":1\r\n"},
+		{args: []string{"EXPIRE", "k", "100", "GT"}, want: ":0\r\n"},
+		{args: []string{"EXPIRE", "k", "300", "gt"}, want: ":1\r\n"},
+		{args: []string{"EXPIRE", "k", "400", "LT"}, want: ":0\r\n"},
+		{args: []string{"EXPIRE", "k", "50", "LT", "XX"}, want: ":1\r\n"},
+		{args: []string{"TTL", "k"}, want: ":50\r\n"},
+		{args: []string{"PERSIST", "k"}, want: ":1\r\n"},
+		{args: []string{"EXPIRE", "k", "50", "LT"}, want: ":1\r\n"},
+		{args: []string{"TTL", "k"}, want: ":50\r\n"},
+	})
+}
+
+func TestExpirePassive(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a")
+	c.exec("SADD", "s", "a")
+	c.exec("HSET", "h", "a", "1")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"PEXPIRE", "k", "20"}, want: ":1\r\n"},
+		{args: []string{"PEXPIRE", "s", "20"}, want: ":1\r\n"},
+		{args: []string{"PEXPIRE", "h", "20"}, want: ":1\r\n"},
+	})
+	time.Sleep(30 * time.Millisecond)
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+		{args: []string{"SCARD", "s"}, want: ":0\r\n"},
+		// 过期的 key 被删除后重新创建，不再有过期时间
+		{args: []string{"HSET", "h", "b", "2"}, want: ":1\r\n"},
+		{args: []string{"HGETALL", "h"}, want: arrayReply("b", "2")},
+		{args: []string{"TTL", "h"}, want: ":-1\r\n"},
+	})
+	if c.db.dict.Len() != 1 || c.db.expires.Len() != 0 {
+		t.Fatalf("expired keys should be deleted, keys = %d, expires = %d", c.db.dict.Len(), c.db.expires.Len())
+	}
+}
+
+func TestActiveExpireCycle(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+
+	// db 0 中的 key 全部过期，db 1 中只有少量 key 过期
+	past := mstime() - 1000
+	for i := 0; i < 1000; i++ {
+		key := []byte("k" + strconv.Itoa(i))
+		c.exec("RPUSH", string(key), "a")
+		s.dbs[0].setExpire(key, past)
+	}
+
+	c.exec("SELECT", "1")
+	for i := 0; i < 1000; i++ {
+		key := []byte("k" + strconv.Itoa(i))
+		c.exec("RPUSH", string(key), "a")
+		if i%100 == 0 {
+			s.dbs[1].setExpire(key, past)
+		} else {
+			s.dbs[1].setExpire(key, past+time.Hour.Milliseconds())
+		}
+	}
+
+	s.activeExpireCycle()
+	if s.dbs[0].dict.Len() != 0 || s.dbs[0].expires.Len() != 0 {
+		t.Fatalf("all expired keys should be deleted, keys = %d, expires = %d",
+			s.dbs[0].dict.Len(), s.dbs[0].expires.Len())
+	}
+	// 过期 key 的比例较低时，只抽样一轮
+	if deleted := 1000 - s.dbs[1].dict.Len(); deleted > 10 || s.dbs[1].dict.Len() != s.dbs[1].expires.Len() {
+		t.Fatalf("deleted %d keys of db 1, keys = %d, expires = %d",
+			deleted, s.dbs[1].dict.Len(), s.dbs[1].expires.Len())
+	}
+
+	// 多次执行后所有过期的 key 都被删除
+	for i := 0; i < 100; i++ {
+		s.activeExpireCycle()
+	}
+	if s.dbs[1].dict.Len() != 990 {
+		t.Fatalf("keys of db 1 = %d, want 990", s.dbs[1].dict.Len())
+	}
+}
+
+func TestServerCron(t *testing.T) {
+	s := CreateServer()
+	go s.eventLoop()
+	s.submit(s.serverCron)
+
+	c := newTestClient(s)
+	if got := c.execInLoop("RPUSH", "k", "a"); got != ":1\r\n" {
+		t.Fatalf("RPUSH = %q", got)
+	}
+	c.execInLoop("PEXPIRE", "k", "10")
+
+	// 没有访问 key，由 serverCron 删除
+	deadline := time.Now().Add(time.Second)
+	for {
+		keys := make(chan int)
+		s.submit(func() {
+			keys <- s.dbs[0].dict.Len()
+		})
+		if <-keys == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expired key is not deleted by serverCron")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
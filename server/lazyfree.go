@@ -0,0 +1,155 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"github.com/WANGgbin/tiny_redis/data_type/dict"
+	orderset "github.com/WANGgbin/tiny_redis/data_type/order_set"
+	"github.com/WANGgbin/tiny_redis/data_type/quicklist"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// 与 redis 的 lazyfree 相同，删除 key 时先把 key 从键空间中移除，较大的 value 交给后台的 worker 释放，
+// 事件循环不需要等待释放完成。UNLINK、FLUSHALL ASYNC 总是在后台释放，DEL、淘汰、过期等场景由
+// lazyfree-lazy-* 配置决定。
+// Go 中对象的内存最终由 GC 回收，这里的释放是拆解对象内部的结构(例如清空 dict 的所有桶)，
+// 交给后台之后 value 不能再被事件循环访问。同一个命令中 key 可能在两次查找之间过期，而命令还在使用
+// 第一次查找得到的 value，因此释放的任务先记录下来，当前命令或者 serverCron 执行完成后再提交给后台。
+
+const (
+	// lazyfreeThreshold 是在后台释放的 value 最小的释放代价，代价较小的 value 直接释放
+	lazyfreeThreshold = 64
+
+	lazyfreeWorkers   = 1
+	lazyfreeQueueSize = 1024
+)
+
+// lazyfree 在所有 db 之间共享，pending 是等待释放的对象的个数，freed 是后台已经释放的对象的个数，
+// jobs 是还没有提交的释放任务，只在事件循环中访问
+type lazyfree struct {
+	pool    *utils.GoPool
+	pending int64
+	freed   int64
+	jobs    []func()
+}
+
+func createLazyfree() *lazyfree {
+	return &lazyfree{
+		pool: utils.NewGoPool(lazyfreeWorkers, lazyfreeQueueSize),
+	}
+}
+
+// pendingObjects returns the number of objects waiting to be freed in background.
+func (lf *lazyfree) pendingObjects() int64 {
+	return atomic.LoadInt64(&lf.pending)
+}
+
+// freedObjects returns the number of objects freed in background.
+func (lf *lazyfree) freedObjects() int64 {
+	return atomic.LoadInt64(&lf.freed)
+}
+
+// flush submits the recorded jobs to the background workers, a job is run directly when the queue is
+// full or the workers are closed.
+func (lf *lazyfree) flush() {
+	for _, job := range lf.jobs {
+		// 不阻塞事件循环等待 worker
+		if err := lf.pool.TrySubmit(job); err != nil {
+			job()
+		}
+	}
+	lf.jobs = nil
+}
+
+// close waits until all submitted objects are freed.
+func (lf *lazyfree) close() {
+	lf.pool.Close()
+}
+
+// freeObjectsAsync frees objs in background after flush.
+func (lf *lazyfree) freeObjectsAsync(objs ...*Object) {
+	atomic.AddInt64(&lf.pending, int64(len(objs)))
+	lf.jobs = append(lf.jobs, func() {
+		for _, obj := range objs {
+			freeObject(obj)
+		}
+		atomic.AddInt64(&lf.pending, -int64(len(objs)))
+		atomic.AddInt64(&lf.freed, int64(len(objs)))
+	})
+}
+
+// freeDictAsync frees d and all objects in it in background after flush, d must not be used afterwards.
+func (lf *lazyfree) freeDictAsync(d *dict.Dict) {
+	count := int64(d.Len())
+	atomic.AddInt64(&lf.pending, count)
+	lf.jobs = append(lf.jobs, func() {
+		d.ForEach(func(key string, val interface{}) bool {
+			if obj, ok := val.(*Object); ok {
+				freeObject(obj)
+			}
+			return true
+		})
+		d.Clear()
+		atomic.AddInt64(&lf.pending, -count)
+		atomic.AddInt64(&lf.freed, count)
+	})
+}
+
+// lazyfreeGetFreeEffort returns the cost of freeing obj, it's about the number of allocations of obj.
+func lazyfreeGetFreeEffort(obj *Object) int {
+	switch obj.Encoding {
+	case EncodingQuickList:
+		return obj.Ptr.(*quicklist.QuickList).NodeLen()
+	case EncodingHashTable:
+		return obj.Ptr.(*dict.Dict).Len()
+	case EncodingSkipList:
+		return int(obj.Ptr.(*orderset.SkipList).Length())
+	default:
+		// ziplist、intset 等只有一块连续的内存
+		return 1
+	}
+}
+
+// freeObject releases the internal structures of obj, obj must not be used afterwards.
+func freeObject(obj *Object) {
+	if d, ok := obj.Ptr.(*dict.Dict); ok {
+		d.Clear()
+	}
+	obj.Ptr = nil
+}
+
+// dbAsyncDelete deletes key like dbDelete, but the value is freed in background when it's large.
+func (db *redisDb) dbAsyncDelete(key []byte) bool {
+	val, ok := db.dict.Get(string(key))
+	if !ok {
+		return false
+	}
+
+	db.dbDelete(key)
+	// 后台保存 RDB 时还在读取的 value 不能释放，交给 GC 回收
+	if obj := val.(*Object); !obj.shared && lazyfreeGetFreeEffort(obj) > lazyfreeThreshold {
+		db.lazyfree.freeObjectsAsync(obj)
+	}
+	return true
+}
+
+// dbGenericDelete deletes key with dbAsyncDelete when async is true, otherwise with dbDelete.
+func (db *redisDb) dbGenericDelete(key []byte, async bool) bool {
+	if async {
+		return db.dbAsyncDelete(key)
+	}
+	return db.dbDelete(key)
+}
+
+// emptyDb deletes all keys of db, the keys are freed in background when async is true.
+func (db *redisDb) emptyDb(async bool) {
+	if async && db.dict.Len() > 0 {
+		db.lazyfree.freeDictAsync(db.dict)
+		db.dict = dict.CreateDict()
+	} else {
+		db.dict.Clear()
+	}
+	db.expires.Clear()
+	db.expiresCursor = 0
+	db.usedMemory = 0
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// waitLazyfree 等待后台释放完所有对象，返回后台释放的对象的个数
+func waitLazyfree(t *testing.T, s *Server) int64 {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for s.lazyfree.pendingObjects() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d objects are not freed", s.lazyfree.pendingObjects())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return s.lazyfree.freedObjects()
+}
+
+// pushElements 向 key 中添加 n 个元素，每个节点只有一个元素时释放的代价为 n
+func pushElements(c *Client, key string, n int) {
+	args := []string{"RPUSH", key}
+	for i := 0; i < n; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	c.exec(args...)
+}
+
+func TestLazyfreeDel(t *testing.T) {
+	s := CreateServer()
+	s.config.listMaxZiplistSize = 1
+	c := newTestClient(s)
+
+	pushElements(c, "big", 100)
+	pushElements(c, "small", 10)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"UNLINK", "big", "small", "none"}, want: ":2\r\n"},
+		{args: []string{"EXISTS", "big", "small"}, want: ":0\r\n"},
+	})
+	// 只有较大的 value 在后台释放
+	if freed := waitLazyfree(t, s); freed != 1 {
+		t.Fatalf("%d objects are freed in background, want 1", freed)
+	}
+	if c.db.usedMemory != 0 {
+		t.Fatalf("usedMemory = %d after deleting all keys", c.db.usedMemory)
+	}
+
+	pushElements(c, "big", 100)
+	c.exec("DEL", "big")
+	if freed := waitLazyfree(t, s); freed != 1 {
+		t.Fatalf("DEL should free value synchronously by default")
+	}
+
+	pushElements(c, "big", 100)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"CONFIG", "SET", "lazyfree-lazy-user-del", "maybe"}, want: "-ERR CONFIG SET failed (possibly related to argument 'lazyfree-lazy-user-del') - argument must be 'yes' or 'no'\r\n"},
+		{args: []string{"CONFIG", "SET", "lazyfree-lazy-user-del", "yes"}, want: "+OK\r\n"},
+		{args: []string{"CONFIG", "GET", "lazyfree-lazy-user-del"}, want: arrayReply("lazyfree-lazy-user-del", "yes")},
+		{args: []string{"DEL", "big"}, want: ":1\r\n"},
+	})
+	if freed := waitLazyfree(t, s); freed != 2 {
+		t.Fatalf("%d objects are freed in background, want 2", freed)
+	}
+}
+
+func TestLazyfreeServerDel(t *testing.T) {
+	s := CreateServer()
+	s.config.listMaxZiplistSize = 1
+	s.config.lazyfreeLazyServerDel = true
+	c := newTestClient(s)
+
+	// 被 RENAME、COPY 覆盖的 value 在后台释放
+	pushElements(c, "a", 100)
+	pushElements(c, "b", 100)
+	pushElements(c, "c", 100)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"RENAME", "a", "b"}, want: "+OK\r\n"},
+		{args: []string{"COPY", "b", "c", "REPLACE"}, want: ":1\r\n"},
+		{args: []string{"LLEN", "b"}, want: ":100\r\n"},
+		{args: []string{"LLEN", "c"}, want: ":100\r\n"},
+	})
+	if freed := waitLazyfree(t, s); freed != 2 {
+		t.Fatalf("%d objects are freed in background, want 2", freed)
+	}
+	checkUsedMemory(t, s)
+}
+
+func TestLazyfreeExpire(t *testing.T) {
+	s := CreateServer()
+	s.config.hashMaxZiplistEntries = 0
+	s.config.lazyfreeLazyExpire = true
+	c := newTestClient(s)
+
+	args := []string{"HSET", "h"}
+	for i := 0; i < 100; i++ {
+		args = append(args, "f"+strconv.Itoa(i), "v")
+	}
+	c.exec(args...)
+	c.exec("PEXPIRE", "h", "10")
+	time.Sleep(20 * time.Millisecond)
+
+	if got := c.exec("HLEN", "h"); got != ":0\r\n" {
+		t.Fatalf("HLEN = %q after the key is expired", got)
+	}
+	if freed := waitLazyfree(t, s); freed != 1 {
+		t.Fatalf("%d objects are freed in background, want 1", freed)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+
+	fill := func() {
+		for _, db := range []string{"1", "0"} {
+			c.exec("SELECT", db)
+			for i := 0; i < 10; i++ {
+				c.exec("RPUSH", "k"+strconv.Itoa(i), "a")
+			}
+			c.exec("EXPIRE", "k0", "100")
+		}
+	}
+
+	fill()
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"FLUSHDB", "FOO"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"FLUSHALL", "ASYNC", "SYNC"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"FLUSHDB"}, want: "+OK\r\n"},
+		{args: []string{"RANDOMKEY"}, want: "$-1\r\n"},
+		{args: []string{"SELECT", "1"}, want: "+OK\r\n"},
+		{args: []string{"EXISTS", "k0", "k1"}, want: ":2\r\n"},
+	})
+	if s.dbs[0].usedMemory != 0 || s.dbs[0].expires.Len() != 0 {
+		t.Fatalf("db 0 is not empty after FLUSHDB")
+	}
+
+	fill()
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"FLUSHALL", "async"}, want: "+OK\r\n"},
+		{args: []string{"RANDOMKEY"}, want: "$-1\r\n"},
+		{args: []string{"SELECT", "1"}, want: "+OK\r\n"},
+		{args: []string{"RANDOMKEY"}, want: "$-1\r\n"},
+	})
+	if freed := waitLazyfree(t, s); freed != 20 {
+		t.Fatalf("%d objects are freed in background, want 20", freed)
+	}
+	checkUsedMemory(t, s)
+
+	// lazyfree-lazy-user-flush 决定没有参数时是否在后台释放
+	fill()
+	c.exec("CONFIG", "SET", "lazyfree-lazy-user-flush", "yes")
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"FLUSHDB"}, want: "+OK\r\n"},
+		{args: []string{"FLUSHALL", "SYNC"}, want: "+OK\r\n"},
+	})
+	if freed := waitLazyfree(t, s); freed != 30 {
+		t.Fatalf("%d objects are freed in background, want 30", freed)
+	}
+	for _, db := range s.dbs {
+		if db.dict.Len() != 0 || db.expires.Len() != 0 || db.usedMemory != 0 {
+			t.Fatalf("db %d is not empty after FLUSHALL", db.id)
+		}
+	}
+}
+
+func TestLazyfreeAfterClose(t *testing.T) {
+	s := CreateServer()
+	s.config.listMaxZiplistSize = 1
+	c := newTestClient(s)
+
+	pushElements(c, "a", 100)
+	pushElements(c, "b", 100)
+	c.exec("UNLINK", "a")
+	_ = s.Close()
+	if s.lazyfree.pendingObjects() != 0 || s.lazyfree.freedObjects() != 1 {
+		t.Fatalf("Close should wait until values are freed")
+	}
+
+	// 关闭之后直接释放
+	if got := c.exec("UNLINK", "b"); got != ":1\r\n" {
+		t.Fatalf("UNLINK = %q", got)
+	}
+	if s.lazyfree.pendingObjects() != 0 || s.lazyfree.freedObjects() != 2 {
+		t.Fatalf("value should be freed directly after Close")
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+// MULTI 之后的命令被放入队列，EXEC 时依次执行。排队时发现的错误(未知命令、参数个数错误)
+// 会使 EXEC 放弃整个事务。EXEC 中的命令不会阻塞客户端。
+
+type multiCmd struct {
+	cmd  *redisCommand
+	argv [][]byte
+}
+
+const (
+	// clientMulti 表示客户端处于 MULTI 中
+	clientMulti = 1 << iota
+	// clientDirtyExec 表示排队时出现了错误，EXEC 会失败
+	clientDirtyExec
+	// clientDenyBlocking 表示命令不能阻塞客户端，阻塞命令按照超时处理
+	clientDenyBlocking
+)
+
+func (c *Client) queueMultiCommand() {
+	c.mstate = append(c.mstate, multiCmd{cmd: c.cmd, argv: c.argv})
+}
+
+// flagTransaction makes EXEC fail when c is in MULTI.
+func (c *Client) flagTransaction() {
+	if c.flags&clientMulti != 0 {
+		c.flags |= clientDirtyExec
+	}
+}
+
+// mstateDenyOOM reports whether any queued command may use more memory.
+func (c *Client) mstateDenyOOM() bool {
+	for _, mc := range c.mstate {
+		if mc.cmd.flags&cmdDenyOOM != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) discardTransaction() {
+	c.mstate = nil
+	c.flags &^= clientMulti | clientDirtyExec
+}
+
+// multiCommand MULTI
+func multiCommand(c *Client) {
+	if c.flags&clientMulti != 0 {
+		c.addReplyError("ERR MULTI calls can not be nested")
+		return
+	}
+
+	c.flags |= clientMulti
+	c.addReplyOK()
+}
+
+// discardCommand DISCARD
+func discardCommand(c *Client) {
+	if c.flags&clientMulti == 0 {
+		c.addReplyError("ERR DISCARD without MULTI")
+		return
+	}
+
+	c.discardTransaction()
+	c.addReplyOK()
+}
+
+// execCommand EXEC
+func execCommand(c *Client) {
+	if c.flags&clientMulti == 0 {
+		c.addReplyError("ERR EXEC without MULTI")
+		return
+	}
+	if c.flags&clientDirtyExec != 0 {
+		c.discardTransaction()
+		c.addReplyError("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+
+	mstate := c.mstate
+	c.discardTransaction()
+
+	origCmd, origArgv := c.cmd, c.argv
+	c.flags |= clientDenyBlocking
+	c.addReplyArrayLen(len(mstate))
+	for _, mc := range mstate {
+		c.cmd, c.argv = mc.cmd, mc.argv
+		c.server.call(c)
+	}
+	c.flags &^= clientDenyBlocking
+	c.cmd, c.argv = origCmd, origArgv
+}
@@ -0,0 +1,42 @@
+package server
+
+import "testing"
+
+func TestMulti(t *testing.T) {
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"EXEC"}, want: "-ERR EXEC without MULTI\r\n"},
+		{args: []string{"DISCARD"}, want: "-ERR DISCARD without MULTI\r\n"},
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"MULTI"}, want: "-ERR MULTI calls can not be nested\r\n"},
+		{args: []string{"RPUSH", "k", "a", "b"}, want: "+QUEUED\r\n"},
+		{args: []string{"LPOP", "k"}, want: "+QUEUED\r\n"},
+		// 事务中的阻塞命令不阻塞
+		{args: []string{"BLPOP", "k", "empty", "0"}, want: "+QUEUED\r\n"},
+		{args: []string{"BLPOP", "empty", "0"}, want: "+QUEUED\r\n"},
+		{args: []string{"BLMOVE", "empty", "k", "LEFT", "LEFT", "0"}, want: "+QUEUED\r\n"},
+		{args: []string{"BLMPOP", "0", "1", "empty", "LEFT"}, want: "+QUEUED\r\n"},
+		{args: []string{"EXEC"}, want: "*6\r\n:2\r\n$1\r\na\r\n" + arrayReply("k", "b") + "*-1\r\n$-1\r\n*-1\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"RPUSH", "k", "a"}, want: "+QUEUED\r\n"},
+		{args: []string{"DISCARD"}, want: "+OK\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+
+		// 排队时出错，整个事务被放弃
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"RPUSH", "k", "a"}, want: "+QUEUED\r\n"},
+		{args: []string{"RPUSH", "k"}, want: "-ERR wrong number of arguments for 'rpush' command\r\n"},
+		{args: []string{"EXEC"}, want: "-EXECABORT Transaction discarded because of previous errors.\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+
+		// 执行时出错不影响其他命令
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"LPOP", "k", "a"}, want: "+QUEUED\r\n"},
+		{args: []string{"RPUSH", "k", "a"}, want: "+QUEUED\r\n"},
+		{args: []string{"EXEC"}, want: "*2\r\n-ERR value is not an integer or out of range\r\n:1\r\n"},
+		{args: []string{"MULTI"}, want: "+OK\r\n"},
+		{args: []string{"EXEC"}, want: "*0\r\n"},
+	})
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/WANGgbin/tiny_redis/data_type/dict"
+	"github.com/WANGgbin/tiny_redis/data_type/intset"
+	orderset "github.com/WANGgbin/tiny_redis/data_type/order_set"
+	"github.com/WANGgbin/tiny_redis/data_type/quicklist"
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// Object 是数据库中 value 的统一表示，Type 是对外的类型，Encoding 是底层的实现，
+// 同一种类型可以有多种实现，例如元素较少的 hash 使用 ziplist，较多时使用 dict。
+
+const (
+	ObjString = iota
+	ObjList
+	ObjSet
+	ObjZSet
+	ObjHash
+)
+
+const (
+	EncodingRaw = iota
+	EncodingInt
+	EncodingHashTable
+	EncodingZipList
+	EncodingIntSet
+	EncodingSkipList
+	EncodingQuickList
+)
+
+type Object struct {
+	Type     uint8
+	Encoding uint8
+	// lru 是 LRU 时钟(maxmemory-policy 为 lru 时)或者 LFU 数据(lfu 时)，见 evict.go
+	lru uint32
+	// size 是 key 和 value 最近一次统计的内存，见 redisDb.usedMemory
+	size int64
+	// shared 表示 value 正在被后台的 BGSAVE 读取，修改之前需要先拷贝，见 rdb.go
+	shared bool
+	Ptr    interface{}
+}
+
+// 以下是估算内存时各个结构本身的开销，单位为字节
+const (
+	objectOverhead    = 48
+	dictEntryOverhead = 48
+	sliceOverhead     = 24
+	stringOverhead    = 16
+	pointerSize       = 8
+	// objectSizeSamples 是估算集合类型的内存时抽样的元素个数
+	objectSizeSamples = 5
+)
+
+// objectComputeSize estimates memory used by obj. For large collections only samples elements are
+// visited and the others are assumed to have the same average size.
+func objectComputeSize(obj *Object, samples int) int64 {
+	size := objectOverhead
+	switch obj.Encoding {
+	case EncodingRaw:
+		if str, ok := obj.Ptr.(*rs.RedisString); ok {
+			size += stringOverhead + len(str.Content)
+		}
+	case EncodingSkipList:
+		// 节点本身(score、平均 2 层的索引等)的开销按 objectOverhead 估算
+		sp := obj.Ptr.(*orderset.SkipList)
+		elesize := 0
+		pairs := sp.GetRangeByRank(0, int64(samples)-1)
+		for _, pair := range pairs {
+			elesize += objectOverhead + stringOverhead + len(pair.Val.Content)
+		}
+		if len(pairs) > 0 {
+			size += elesize * int(sp.Length()) / len(pairs)
+		}
+	case EncodingQuickList:
+		size += obj.Ptr.(*quicklist.QuickList).EstimateBytes(samples)
+	case EncodingZipList:
+		size += sliceOverhead + len(obj.Ptr.(*ziplist.ZipList).Bytes())
+	case EncodingIntSet:
+		size += sliceOverhead + obj.Ptr.(*intset.IntSet).BlobLen()
+	case EncodingHashTable:
+		d := obj.Ptr.(*dict.Dict)
+		sampled, elesize := 0, 0
+		d.ForEach(func(key string, val interface{}) bool {
+			elesize += dictEntryOverhead + stringOverhead + len(key)
+			if val, ok := val.(string); ok {
+				elesize += stringOverhead + len(val)
+			}
+			sampled++
+			return sampled < samples
+		})
+		if sampled > 0 {
+			size += elesize * d.Len() / sampled
+		}
+		size += d.BucketLen() * pointerSize
+	}
+	return int64(size)
+}
+
+// createStringObject creates a string object, integers are encoded as int64 to save memory.
+func createStringObject(s []byte) *Object {
+	if v, ok := utils.String2Int64(s); ok {
+		return &Object{Type: ObjString, Encoding: EncodingInt, Ptr: v}
+	}
+	return &Object{
+		Type:     ObjString,
+		Encoding: EncodingRaw,
+		Ptr:      &rs.RedisString{Content: s},
+	}
+}
+
+// stringObjectBytes returns the content of a string object.
+func stringObjectBytes(obj *Object) []byte {
+	if obj.Encoding == EncodingInt {
+		return strconv.AppendInt(nil, obj.Ptr.(int64), 10)
+	}
+	return obj.Ptr.(*rs.RedisString).Content
+}
+
+// createQuickListObject creates an empty list, see config for fill and compress.
+func createQuickListObject(fill, compress int) *Object {
+	ql := quicklist.CreateQuickList(fill)
+	ql.SetCompressDepth(compress)
+
+	return &Object{
+		Type:     ObjList,
+		Encoding: EncodingQuickList,
+		Ptr:      ql,
+	}
+}
+
+// createHashObject creates an empty hash encoded as ziplist.
+func createHashObject() *Object {
+	return &Object{
+		Type:     ObjHash,
+		Encoding: EncodingZipList,
+		Ptr:      ziplist.InitZipList(),
+	}
+}
+
+// createIntSetObject creates an empty set encoded as intset.
+func createIntSetObject() *Object {
+	return &Object{
+		Type:     ObjSet,
+		Encoding: EncodingIntSet,
+		Ptr:      intset.New(),
+	}
+}
+
+// createDictObject creates an empty object of typ encoded as hashtable.
+func createDictObject(typ uint8) *Object {
+	return &Object{
+		Type:     typ,
+		Encoding: EncodingHashTable,
+		Ptr:      dict.CreateDict(),
+	}
+}
+
+// dupObject returns a deep copy of obj, the copy doesn't share any memory with obj that can be modified.
+func dupObject(obj *Object) *Object {
+	dup := &Object{
+		Type:     obj.Type,
+		Encoding: obj.Encoding,
+	}
+
+	switch ptr := obj.Ptr.(type) {
+	case *rs.RedisString:
+		dup.Ptr = &rs.RedisString{Content: append([]byte(nil), ptr.Content...)}
+	case *quicklist.QuickList:
+		dup.Ptr = ptr.Dup()
+	case *ziplist.ZipList:
+		dup.Ptr = ptr.Dup()
+	case *intset.IntSet:
+		dup.Ptr = ptr.Dup()
+	case *orderset.SkipList:
+		// GetAllScoreValPairs 返回的元素是拷贝，并且已经排好序
+		dup.Ptr, _ = orderset.CreateSkipListFromSorted(ptr.GetAllScoreValPairs())
+	case *dict.Dict:
+		// dict 中的 value 是 string 或 nil，不需要拷贝
+		d := dict.CreateDict()
+		ptr.ForEach(func(key string, val interface{}) bool {
+			d.Add(key, val)
+			return true
+		})
+		dup.Ptr = d
+	default:
+		dup.Ptr = obj.Ptr
+	}
+	return dup
+}
+
+// zipListValue 将参数转换为 ziplist 中存储的值，整数形式的字符串以整数存储以节省内存
+func zipListValue(arg []byte) interface{} {
+	if num, ok := utils.String2Int64(arg); ok {
+		return num
+	}
+	return &rs.RedisString{Content: arg}
+}
+
+// zipListValueBytes 将 ziplist 中的值还原为字符串
+func zipListValueBytes(val interface{}) []byte {
+	switch val := val.(type) {
+	case int64:
+		return strconv.AppendInt(nil, val, 10)
+	case *rs.RedisString:
+		return val.Content
+	default:
+		return nil
+	}
+}
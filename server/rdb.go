@@ -0,0 +1,1349 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/WANGgbin/tiny_redis/data_type/dict"
+	"github.com/WANGgbin/tiny_redis/data_type/intset"
+	"github.com/WANGgbin/tiny_redis/data_type/listpack"
+	orderset "github.com/WANGgbin/tiny_redis/data_type/order_set"
+	"github.com/WANGgbin/tiny_redis/data_type/quicklist"
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+	"github.com/WANGgbin/tiny_redis/utils"
+	"github.com/WANGgbin/tiny_redis/utils/crc64"
+	"github.com/WANGgbin/tiny_redis/utils/lzf"
+)
+
+// RDB 文件的格式与 redis 相同，redis 可以直接加载 tiny_redis 保存的文件:
+// | "REDIS" | 版本号(4 个字符) | aux 字段 | 每个 db: SELECTDB、RESIZEDB 以及所有 key | EOF | CRC64(8 字节，小端) |
+// 每个 key 的格式为 [EXPIRETIME_MS 过期时间] | value 类型(1 字节) | key | value。
+// redis 通过 fork 得到内存的快照，而 Go 中无法安全地 fork。BGSAVE 在事件循环中记录所有 key 以及 value 的指针，
+// 把 value 标记为 shared，之后由后台的 goroutine 写入文件。写入期间事件循环访问 shared 的 value 时，先拷贝一份
+// 替换 db 中的 value(见 unshareObject)，后台读取的 value 不会再被修改，相当于以 key 为粒度的写时复制。
+// 加载时还支持 redis 6、7 保存的文件(版本 9 ~ 11)，其中 ziplist、listpack 编码的 value 直接转换为 ziplist.ZipList，
+// 不需要逐个元素重新插入；模块的 aux 数据以及 function 无法支持，加载时跳过。
+
+const (
+	rdbVersion = 9
+	// rdbMaxVersion 是能够加载的最高版本
+	rdbMaxVersion = 11
+
+	// value 的类型
+	rdbTypeString    = 0
+	rdbTypeList      = 1
+	rdbTypeSet       = 2
+	rdbTypeZSet      = 3
+	rdbTypeHash      = 4
+	rdbTypeZSet2     = 5
+	rdbTypeSetIntSet = 11
+	// 以下是 redis 保存的 value 类型，只支持加载，ziplist、listpack 被直接转换为 ziplist.ZipList
+	rdbTypeModulePreGA     = 6
+	rdbTypeModule2         = 7
+	rdbTypeListZipList     = 10
+	rdbTypeZSetZipList     = 12
+	rdbTypeHashZipList     = 13
+	rdbTypeListQuickList   = 14
+	rdbTypeStreamListPack  = 15
+	rdbTypeHashListPack    = 16
+	rdbTypeZSetListPack    = 17
+	rdbTypeListQuickList2  = 18
+	rdbTypeStreamListPack2 = 19
+	rdbTypeSetListPack     = 20
+	rdbTypeStreamListPack3 = 21
+
+	// quicklist 2 中节点的类型，plain 节点只有一个元素
+	rdbQuickListNodePlain  = 1
+	rdbQuickListNodePacked = 2
+
+	// 特殊的操作码，与 value 的类型在同一个位置
+	rdbOpcodeFunction2     = 245
+	rdbOpcodeFunctionPreGA = 246
+	rdbOpcodeModuleAux     = 247
+	rdbOpcodeIdle          = 248
+	rdbOpcodeFreq          = 249
+	rdbOpcodeAux           = 250
+	rdbOpcodeResizeDb      = 251
+	rdbOpcodeExpireTimeMs  = 252
+	rdbOpcodeExpireTime    = 253
+	rdbOpcodeSelectDb      = 254
+	rdbOpcodeEOF           = 255
+
+	// 长度的编码方式由第一个字节的最高两位决定，rdbEncVal 表示后面是特殊编码的字符串
+	rdb6BitLen  = 0
+	rdb14BitLen = 1
+	rdb32BitLen = 0x80
+	rdb64BitLen = 0x81
+	rdbEncVal   = 3
+
+	// 特殊编码的字符串，整数以小端存储
+	rdbEncInt8  = 0
+	rdbEncInt16 = 1
+	rdbEncInt32 = 2
+	rdbEncLZF   = 3
+
+	// 模块数据中每个值之前的操作码
+	rdbModuleOpcodeEOF    = 0
+	rdbModuleOpcodeSInt   = 1
+	rdbModuleOpcodeUInt   = 2
+	rdbModuleOpcodeFloat  = 3
+	rdbModuleOpcodeDouble = 4
+	rdbModuleOpcodeString = 5
+
+	// redisVersion 是 aux 字段中记录的版本
+	redisVersion = "7.0.0"
+
+	bgsaveInProgressErr = "ERR Background save already in progress"
+)
+
+var RdbCorruptedErr = errors.New("corrupted rdb file")
+
+// rdbEntry 是快照中的一个 key，expire 为 -1 表示没有过期时间
+type rdbEntry struct {
+	key    string
+	obj    *Object
+	expire int64
+}
+
+// rdbSnapshot 是所有 db 在某一时刻的快照以及保存时使用的配置，BGSAVE 期间只被后台的 goroutine 读取
+type rdbSnapshot struct {
+	dbs [][]rdbEntry
+	// aux 是依次排列的 aux 字段的名称和值
+	aux         []string
+	compression bool
+	checksum    bool
+}
+
+// createSnapshot records all keys of s, the values are marked as shared when they are saved in
+// background, see unshareObject.
+func (s *Server) createSnapshot(shared bool) *rdbSnapshot {
+	snap := &rdbSnapshot{
+		dbs: make([][]rdbEntry, len(s.dbs)),
+		aux: []string{
+			"redis-ver", redisVersion,
+			"redis-bits", strconv.Itoa(strconv.IntSize),
+			"ctime", strconv.FormatInt(time.Now().Unix(), 10),
+			"used-mem", strconv.FormatInt(s.usedMemory(), 10),
+			"aof-base", "0",
+		},
+		compression: s.config.rdbCompression,
+		checksum:    s.config.rdbChecksum,
+	}
+
+	for i, db := range s.dbs {
+		entries := make([]rdbEntry, 0, db.dict.Len())
+		db.dict.ForEach(func(key string, val interface{}) bool {
+			obj := val.(*Object)
+			if shared {
+				obj.shared = true
+			}
+			entries = append(entries, rdbEntry{key: key, obj: obj, expire: db.getExpire([]byte(key))})
+			return true
+		})
+		snap.dbs[i] = entries
+	}
+	return snap
+}
+
+// release clears the shared flag of the values in snap after they are saved.
+func (snap *rdbSnapshot) release() {
+	for _, entries := range snap.dbs {
+		for _, e := range entries {
+			e.obj.shared = false
+		}
+	}
+}
+
+// unshareObject replaces obj which is being saved in background with a copy, the copy is returned.
+// Commands may modify the values they look up, and even reading a dict modifies it because of
+// rehashing, so the copy is made on any lookup.
+func (db *redisDb) unshareObject(key []byte, obj *Object) *Object {
+	dup := dupObject(obj)
+	dup.lru, dup.size = obj.lru, obj.size
+	db.dict.Set(string(key), dup)
+	return dup
+}
+
+// rdbWriter 写入 RDB 文件并计算校验和，第一次写入失败之后的写入都被忽略，错误记录在 err 中
+type rdbWriter struct {
+	w           io.Writer
+	crc         uint64
+	compression bool
+	checksum    bool
+	err         error
+}
+
+func (rw *rdbWriter) write(p []byte) {
+	if rw.err != nil {
+		return
+	}
+	if rw.checksum {
+		rw.crc = crc64.Update(rw.crc, p)
+	}
+	_, rw.err = rw.w.Write(p)
+}
+
+func (rw *rdbWriter) saveType(typ byte) {
+	rw.write([]byte{typ})
+}
+
+func (rw *rdbWriter) saveLen(n uint64) {
+	var buf [9]byte
+	switch {
+	case n < 1<<6:
+		buf[0] = rdb6BitLen<<6 | byte(n)
+		rw.write(buf[:1])
+	case n < 1<<14:
+		buf[0] = rdb14BitLen<<6 | byte(n>>8)
+		buf[1] = byte(n)
+		rw.write(buf[:2])
+	case n <= math.MaxUint32:
+		buf[0] = rdb32BitLen
+		binary.BigEndian.PutUint32(buf[1:], uint32(n))
+		rw.write(buf[:5])
+	default:
+		buf[0] = rdb64BitLen
+		binary.BigEndian.PutUint64(buf[1:], n)
+		rw.write(buf[:9])
+	}
+}
+
+func (rw *rdbWriter) saveMillisecondTime(ms int64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(ms))
+	rw.write(buf[:])
+}
+
+func (rw *rdbWriter) saveBinaryDouble(f float64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+	rw.write(buf[:])
+}
+
+// saveString saves s, integers fit in int32 are saved in binary and long strings are compressed when
+// compression is enabled.
+func (rw *rdbWriter) saveString(s []byte) {
+	// 整数最长为 11 个字符，例如 -2147483648
+	if len(s) <= 11 {
+		if v, ok := utils.String2Int64(s); ok && rw.trySaveInt(v) {
+			return
+		}
+	}
+	if rw.compression && len(s) > 20 && rw.trySaveLZF(s) {
+		return
+	}
+
+	rw.saveLen(uint64(len(s)))
+	rw.write(s)
+}
+
+// trySaveInt saves v as an integer encoded string, false is returned when v doesn't fit in int32.
+func (rw *rdbWriter) trySaveInt(v int64) bool {
+	var buf [5]byte
+	switch {
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		buf[0] = rdbEncVal<<6 | rdbEncInt8
+		buf[1] = byte(v)
+		rw.write(buf[:2])
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		buf[0] = rdbEncVal<<6 | rdbEncInt16
+		binary.LittleEndian.PutUint16(buf[1:], uint16(v))
+		rw.write(buf[:3])
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		buf[0] = rdbEncVal<<6 | rdbEncInt32
+		binary.LittleEndian.PutUint32(buf[1:], uint32(v))
+		rw.write(buf[:5])
+	default:
+		return false
+	}
+	return true
+}
+
+// trySaveLZF saves s compressed with LZF, false is returned when s is not compressible.
+func (rw *rdbWriter) trySaveLZF(s []byte) bool {
+	// 至少节省 4 个字节才压缩
+	out := make([]byte, len(s)-4)
+	n := lzf.Compress(s, out)
+	if n == 0 {
+		return false
+	}
+
+	rw.saveType(rdbEncVal<<6 | rdbEncLZF)
+	rw.saveLen(uint64(n))
+	rw.saveLen(uint64(len(s)))
+	rw.write(out[:n])
+	return true
+}
+
+// rdbObjectType returns the type of obj saved in RDB.
+func rdbObjectType(obj *Object) byte {
+	switch obj.Type {
+	case ObjString:
+		return rdbTypeString
+	case ObjList:
+		return rdbTypeList
+	case ObjSet:
+		if obj.Encoding == EncodingIntSet {
+			return rdbTypeSetIntSet
+		}
+		return rdbTypeSet
+	case ObjZSet:
+		return rdbTypeZSet2
+	default:
+		return rdbTypeHash
+	}
+}
+
+// saveObject saves the value of obj, the type is saved by rdbObjectType.
+func (rw *rdbWriter) saveObject(obj *Object) {
+	switch obj.Type {
+	case ObjString:
+		rw.saveString(stringObjectBytes(obj))
+	case ObjList:
+		ql := obj.Ptr.(*quicklist.QuickList)
+		rw.saveLen(uint64(ql.Len()))
+		for it := ql.Iterator(0, true); it.Next(); {
+			rw.saveString(zipListValueBytes(it.Value()))
+		}
+	case ObjSet:
+		if obj.Encoding == EncodingIntSet {
+			rw.saveString(obj.Ptr.(*intset.IntSet).Bytes())
+			return
+		}
+		rw.saveLen(uint64(setTypeSize(obj)))
+		setTypeForEach(obj, func(member []byte) bool {
+			rw.saveString(member)
+			return true
+		})
+	case ObjZSet:
+		pairs := obj.Ptr.(*orderset.SkipList).GetAllScoreValPairs()
+		rw.saveLen(uint64(len(pairs)))
+		for _, pair := range pairs {
+			rw.saveString(pair.Val.Content)
+			rw.saveBinaryDouble(pair.Score)
+		}
+	case ObjHash:
+		rw.saveLen(uint64(hashTypeLength(obj)))
+		hashTypeForEach(obj, func(field, value []byte) bool {
+			rw.saveString(field)
+			rw.saveString(value)
+			return true
+		})
+	}
+}
+
+// rdbSave writes snap to w in RDB format.
+func rdbSave(w io.Writer, snap *rdbSnapshot) error {
+	rw := &rdbWriter{w: w, compression: snap.compression, checksum: snap.checksum}
+	rw.write([]byte(fmt.Sprintf("REDIS%04d", rdbVersion)))
+	for i := 0; i+1 < len(snap.aux); i += 2 {
+		rw.saveType(rdbOpcodeAux)
+		rw.saveString([]byte(snap.aux[i]))
+		rw.saveString([]byte(snap.aux[i+1]))
+	}
+
+	for id, entries := range snap.dbs {
+		if len(entries) == 0 {
+			continue
+		}
+
+		expires := 0
+		for _, e := range entries {
+			if e.expire != -1 {
+				expires++
+			}
+		}
+		rw.saveType(rdbOpcodeSelectDb)
+		rw.saveLen(uint64(id))
+		rw.saveType(rdbOpcodeResizeDb)
+		rw.saveLen(uint64(len(entries)))
+		rw.saveLen(uint64(expires))
+
+		for _, e := range entries {
+			if e.expire != -1 {
+				rw.saveType(rdbOpcodeExpireTimeMs)
+				rw.saveMillisecondTime(e.expire)
+			}
+			rw.saveType(rdbObjectType(e.obj))
+			rw.saveString([]byte(e.key))
+			rw.saveObject(e.obj)
+		}
+	}
+	rw.saveType(rdbOpcodeEOF)
+
+	// 没有开启校验时校验和为 0，加载时不检查
+	var crc [8]byte
+	binary.LittleEndian.PutUint64(crc[:], rw.crc)
+	rw.write(crc[:])
+	return rw.err
+}
+
+// rdbSaveFile saves snap to filename. snap is written to a temporary file first, which is renamed to
+// filename after it's synced to disk, so filename is either the old or the new complete file.
+func rdbSaveFile(filename string, snap *rdbSnapshot) error {
+	tmpfile := filepath.Join(filepath.Dir(filename), fmt.Sprintf("temp-%d.rdb", os.Getpid()))
+	f, err := os.Create(tmpfile)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	err = rdbSave(w, snap)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpfile, filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmpfile)
+	}
+	return err
+}
+
+// rdbReader 读取 RDB 文件并计算校验和
+type rdbReader struct {
+	r   *bufio.Reader
+	crc uint64
+	// remaining 是文件中还没有读取的字节数，分配内存之前用来检查长度是否合法，避免损坏的文件导致分配过多的内存
+	remaining int64
+}
+
+func (rr *rdbReader) read(n uint64) ([]byte, error) {
+	if n > uint64(rr.remaining) {
+		return nil, fmt.Errorf("%w: unexpected end of file", RdbCorruptedErr)
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(rr.r, buf); err != nil {
+		return nil, err
+	}
+	rr.remaining -= int64(n)
+	rr.crc = crc64.Update(rr.crc, buf)
+	return buf, nil
+}
+
+func (rr *rdbReader) loadType() (byte, error) {
+	buf, err := rr.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// loadLenWithEncoding loads a length, encoded is true when it's the encoding of a special encoded string.
+func (rr *rdbReader) loadLenWithEncoding() (n uint64, encoded bool, err error) {
+	first, err := rr.loadType()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch first >> 6 {
+	case rdbEncVal:
+		return uint64(first & 0x3f), true, nil
+	case rdb6BitLen:
+		return uint64(first & 0x3f), false, nil
+	case rdb14BitLen:
+		next, err := rr.loadType()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(first&0x3f)<<8 | uint64(next), false, nil
+	}
+
+	switch first {
+	case rdb32BitLen:
+		buf, err := rr.read(4)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(buf)), false, nil
+	case rdb64BitLen:
+		buf, err := rr.read(8)
+		if err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(buf), false, nil
+	default:
+		return 0, false, fmt.Errorf("%w: unknown length encoding %d", RdbCorruptedErr, first)
+	}
+}
+
+func (rr *rdbReader) loadLen() (uint64, error) {
+	n, encoded, err := rr.loadLenWithEncoding()
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, fmt.Errorf("%w: unexpected string encoding", RdbCorruptedErr)
+	}
+	return n, nil
+}
+
+// loadString loads a string saved by saveString.
+func (rr *rdbReader) loadString() ([]byte, error) {
+	n, encoded, err := rr.loadLenWithEncoding()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return rr.read(n)
+	}
+
+	var v int64
+	switch n {
+	case rdbEncInt8:
+		buf, err := rr.read(1)
+		if err != nil {
+			return nil, err
+		}
+		v = int64(int8(buf[0]))
+	case rdbEncInt16:
+		buf, err := rr.read(2)
+		if err != nil {
+			return nil, err
+		}
+		v = int64(int16(binary.LittleEndian.Uint16(buf)))
+	case rdbEncInt32:
+		buf, err := rr.read(4)
+		if err != nil {
+			return nil, err
+		}
+		v = int64(int32(binary.LittleEndian.Uint32(buf)))
+	case rdbEncLZF:
+		return rr.loadLZFString()
+	default:
+		return nil, fmt.Errorf("%w: unknown string encoding %d", RdbCorruptedErr, n)
+	}
+	return strconv.AppendInt(nil, v, 10), nil
+}
+
+func (rr *rdbReader) loadLZFString() ([]byte, error) {
+	clen, err := rr.loadLen()
+	if err != nil {
+		return nil, err
+	}
+	length, err := rr.loadLen()
+	if err != nil {
+		return nil, err
+	}
+	if length > maxBulkLen {
+		return nil, fmt.Errorf("%w: too long compressed string", RdbCorruptedErr)
+	}
+
+	data, err := rr.read(clen)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, length)
+	if n, err := lzf.Decompress(data, out); err != nil || n != len(out) {
+		return nil, fmt.Errorf("%w: invalid LZF compressed string", RdbCorruptedErr)
+	}
+	return out, nil
+}
+
+func (rr *rdbReader) loadMillisecondTime() (int64, error) {
+	buf, err := rr.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(buf)), nil
+}
+
+func (rr *rdbReader) loadSecondTime() (int64, error) {
+	buf, err := rr.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int64(int32(binary.LittleEndian.Uint32(buf))) * 1000, nil
+}
+
+func (rr *rdbReader) loadBinaryDouble() (float64, error) {
+	buf, err := rr.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf)), nil
+}
+
+// loadDouble loads a double saved as string by old versions, the first byte is the length of the
+// string, or 253, 254, 255 for nan, inf and -inf.
+func (rr *rdbReader) loadDouble() (float64, error) {
+	n, err := rr.loadType()
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+
+	buf, err := rr.read(uint64(n))
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(string(buf), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid double %q", RdbCorruptedErr, buf)
+	}
+	return f, nil
+}
+
+// rdbLoadObject loads a value of typ, nil is returned for an empty collection which should be skipped.
+func (s *Server) rdbLoadObject(rr *rdbReader, typ byte) (*Object, error) {
+	switch typ {
+	case rdbTypeString:
+		str, err := rr.loadString()
+		if err != nil {
+			return nil, err
+		}
+		return createStringObject(str), nil
+	case rdbTypeList:
+		return s.rdbLoadList(rr)
+	case rdbTypeSet:
+		return s.rdbLoadSet(rr)
+	case rdbTypeSetIntSet:
+		data, err := rr.loadString()
+		if err != nil {
+			return nil, err
+		}
+		is, err := intset.FromBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", RdbCorruptedErr, err)
+		}
+		if is.Len() == 0 {
+			return nil, nil
+		}
+		obj := &Object{Type: ObjSet, Encoding: EncodingIntSet, Ptr: is}
+		if is.Len() > s.config.setMaxIntsetEntries {
+			setTypeConvert(obj)
+		}
+		return obj, nil
+	case rdbTypeZSet, rdbTypeZSet2:
+		return rdbLoadZSet(rr, typ)
+	case rdbTypeHash:
+		return s.rdbLoadHash(rr)
+	case rdbTypeListZipList:
+		zl, err := rr.loadZipList()
+		if err != nil {
+			return nil, err
+		}
+		return s.rdbCreateList(zl), nil
+	case rdbTypeListQuickList, rdbTypeListQuickList2:
+		return s.rdbLoadQuickList(rr, typ)
+	case rdbTypeSetListPack:
+		zl, err := rr.loadListPack()
+		if err != nil {
+			return nil, err
+		}
+		return s.rdbCreateSetFromZipList(zl)
+	case rdbTypeZSetZipList, rdbTypeZSetListPack:
+		load := rr.loadZipList
+		if typ == rdbTypeZSetListPack {
+			load = rr.loadListPack
+		}
+		zl, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return rdbCreateZSetFromZipList(zl)
+	case rdbTypeHashZipList, rdbTypeHashListPack:
+		load := rr.loadZipList
+		if typ == rdbTypeHashListPack {
+			load = rr.loadListPack
+		}
+		zl, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return s.rdbCreateHashFromZipList(zl)
+	case rdbTypeModulePreGA, rdbTypeModule2:
+		return nil, fmt.Errorf("%w: module values are not supported", RdbCorruptedErr)
+	case rdbTypeStreamListPack, rdbTypeStreamListPack2, rdbTypeStreamListPack3:
+		return nil, fmt.Errorf("%w: stream values are not supported", RdbCorruptedErr)
+	default:
+		return nil, fmt.Errorf("%w: unknown object type %d", RdbCorruptedErr, typ)
+	}
+}
+
+func (s *Server) rdbLoadList(rr *rdbReader) (*Object, error) {
+	n, err := rr.loadLen()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+
+	obj := createQuickListObject(s.config.listMaxZiplistSize, s.config.listCompressDepth)
+	ql := obj.Ptr.(*quicklist.QuickList)
+	for i := uint64(0); i < n; i++ {
+		elem, err := rr.loadString()
+		if err != nil {
+			return nil, err
+		}
+		listTypePush(ql, zipListValue(elem), false)
+	}
+	return obj, nil
+}
+
+func (s *Server) rdbLoadSet(rr *rdbReader) (*Object, error) {
+	n, err := rr.loadLen()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+
+	obj := createIntSetObject()
+	if n > uint64(s.config.setMaxIntsetEntries) {
+		setTypeConvert(obj)
+	}
+	for i := uint64(0); i < n; i++ {
+		member, err := rr.loadString()
+		if err != nil {
+			return nil, err
+		}
+
+		if !rdbSetAdd(obj, member) {
+			return nil, fmt.Errorf("%w: duplicate set member %q", RdbCorruptedErr, member)
+		}
+	}
+	return obj, nil
+}
+
+// rdbSetAdd adds member to a set being loaded, the set is converted when member can't be added to
+// intset. false is returned when member already exists.
+func rdbSetAdd(obj *Object, member []byte) bool {
+	if obj.Encoding == EncodingIntSet {
+		if v, ok := utils.String2Int64(member); ok {
+			return obj.Ptr.(*intset.IntSet).Add(v)
+		}
+		setTypeConvert(obj)
+	}
+	return obj.Ptr.(*dict.Dict).Add(string(member), nil)
+}
+
+func rdbLoadZSet(rr *rdbReader, typ byte) (*Object, error) {
+	n, err := rr.loadLen()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+
+	// 长度来自文件，不预先分配内存
+	var pairs []*orderset.ScoreValPair
+	for i := uint64(0); i < n; i++ {
+		member, err := rr.loadString()
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if typ == rdbTypeZSet2 {
+			score, err = rr.loadBinaryDouble()
+		} else {
+			score, err = rr.loadDouble()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		pair := &orderset.ScoreValPair{Score: score}
+		pair.Val.Content = member
+		pairs = append(pairs, pair)
+	}
+	return rdbCreateZSet(pairs)
+}
+
+// rdbCreateZSet creates a zset from the loaded pairs, pairs are sorted in place.
+func rdbCreateZSet(pairs []*orderset.ScoreValPair) (*Object, error) {
+	members := make(map[string]struct{}, len(pairs))
+	for _, pair := range pairs {
+		if _, ok := members[string(pair.Val.Content)]; ok {
+			return nil, fmt.Errorf("%w: duplicate zset member %q", RdbCorruptedErr, pair.Val.Content)
+		}
+		members[string(pair.Val.Content)] = struct{}{}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score < pairs[j].Score
+		}
+		return bytes.Compare(pairs[i].Val.Content, pairs[j].Val.Content) < 0
+	})
+	sp, err := orderset.CreateSkipListFromSorted(pairs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", RdbCorruptedErr, err)
+	}
+	return &Object{Type: ObjZSet, Encoding: EncodingSkipList, Ptr: sp}, nil
+}
+
+func (s *Server) rdbLoadHash(rr *rdbReader) (*Object, error) {
+	n, err := rr.loadLen()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+
+	obj := createHashObject()
+	if n > uint64(s.config.hashMaxZiplistEntries) {
+		hashTypeConvert(obj)
+	}
+	for i := uint64(0); i < n; i++ {
+		field, err := rr.loadString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := rr.loadString()
+		if err != nil {
+			return nil, err
+		}
+
+		if obj.Encoding == EncodingZipList &&
+			(len(field) > s.config.hashMaxZiplistValue || len(value) > s.config.hashMaxZiplistValue) {
+			hashTypeConvert(obj)
+		}
+		added := false
+		if obj.Encoding == EncodingZipList {
+			zl := obj.Ptr.(*ziplist.ZipList)
+			if zipListFindField(zl, field) == nil {
+				_ = zl.ZipListPush(zipListValue(field))
+				_ = zl.ZipListPush(zipListValue(value))
+				added = true
+			}
+		} else {
+			added = obj.Ptr.(*dict.Dict).Add(string(field), string(value))
+		}
+		if !added {
+			return nil, fmt.Errorf("%w: duplicate hash field %q", RdbCorruptedErr, field)
+		}
+	}
+	return obj, nil
+}
+
+// loadZipList loads a ziplist saved by redis and converts it to ziplist.ZipList.
+func (rr *rdbReader) loadZipList() (*ziplist.ZipList, error) {
+	data, err := rr.loadString()
+	if err != nil {
+		return nil, err
+	}
+	zl, err := ziplist.ZipListFromRedis(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", RdbCorruptedErr, err)
+	}
+	return zl, nil
+}
+
+// loadListPack loads a listpack saved by redis and converts it to ziplist.ZipList.
+func (rr *rdbReader) loadListPack() (*ziplist.ZipList, error) {
+	data, err := rr.loadString()
+	if err != nil {
+		return nil, err
+	}
+	lp, err := listpack.ListPackFromBytes(data, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", RdbCorruptedErr, err)
+	}
+	zl, err := lp.ToZipList()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", RdbCorruptedErr, err)
+	}
+	return zl, nil
+}
+
+// rdbLoadQuickList loads a list saved as quicklist by redis 3.2 and later, every node is a ziplist,
+// or for quicklist 2 a listpack or a plain element.
+func (s *Server) rdbLoadQuickList(rr *rdbReader, typ byte) (*Object, error) {
+	n, err := rr.loadLen()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+
+	obj := createQuickListObject(s.config.listMaxZiplistSize, s.config.listCompressDepth)
+	ql := obj.Ptr.(*quicklist.QuickList)
+	for i := uint64(0); i < n; i++ {
+		container := uint64(rdbQuickListNodePacked)
+		if typ == rdbTypeListQuickList2 {
+			if container, err = rr.loadLen(); err != nil {
+				return nil, err
+			}
+		}
+
+		var zl *ziplist.ZipList
+		switch {
+		case container == rdbQuickListNodePlain:
+			elem, err := rr.loadString()
+			if err != nil {
+				return nil, err
+			}
+			listTypePush(ql, zipListValue(elem), false)
+			continue
+		case container != rdbQuickListNodePacked:
+			return nil, fmt.Errorf("%w: unknown quicklist node container %d", RdbCorruptedErr, container)
+		case typ == rdbTypeListQuickList2:
+			zl, err = rr.loadListPack()
+		default:
+			zl, err = rr.loadZipList()
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 节点不拆分，即使超过了 list-max-ziplist-size
+		ql.AppendZipList(zl)
+	}
+
+	if ql.Len() == 0 {
+		return nil, nil
+	}
+	return obj, nil
+}
+
+// rdbCreateList creates a list with zl as its only node, nil is returned when zl is empty.
+func (s *Server) rdbCreateList(zl *ziplist.ZipList) *Object {
+	obj := createQuickListObject(s.config.listMaxZiplistSize, s.config.listCompressDepth)
+	ql := obj.Ptr.(*quicklist.QuickList)
+	ql.AppendZipList(zl)
+	if ql.Len() == 0 {
+		return nil
+	}
+	return obj
+}
+
+// zipListForEach calls fn for every value of zl until fn returns an error.
+func zipListForEach(zl *ziplist.ZipList, fn func(val interface{}) error) error {
+	for c := zl.ZipListIndex(0); c != nil; c, _ = zl.ZipListNext(c) {
+		val, err := zl.ZipListGet(c)
+		if err != nil {
+			return err
+		}
+		if err := fn(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rdbCreateSetFromZipList creates a set from the members in zl loaded from a listpack.
+func (s *Server) rdbCreateSetFromZipList(zl *ziplist.ZipList) (*Object, error) {
+	n := zl.ZipListLen()
+	if n == 0 {
+		return nil, nil
+	}
+
+	obj := createIntSetObject()
+	if n > s.config.setMaxIntsetEntries {
+		setTypeConvert(obj)
+	}
+	err := zipListForEach(zl, func(val interface{}) error {
+		if member := zipListValueBytes(val); !rdbSetAdd(obj, member) {
+			return fmt.Errorf("%w: duplicate set member %q", RdbCorruptedErr, member)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// rdbCreateZSetFromZipList creates a zset from zl in which members and scores are arranged alternately.
+func rdbCreateZSetFromZipList(zl *ziplist.ZipList) (*Object, error) {
+	n := zl.ZipListLen()
+	if n == 0 {
+		return nil, nil
+	}
+	if n%2 != 0 {
+		return nil, fmt.Errorf("%w: zset ziplist has odd number of entries %d", RdbCorruptedErr, n)
+	}
+
+	pairs := make([]*orderset.ScoreValPair, 0, n/2)
+	var member []byte
+	err := zipListForEach(zl, func(val interface{}) error {
+		if member == nil {
+			member = zipListValueBytes(val)
+			return nil
+		}
+
+		pair := &orderset.ScoreValPair{}
+		switch val := val.(type) {
+		case int64:
+			pair.Score = float64(val)
+		case *rs.RedisString:
+			score, err := strconv.ParseFloat(string(val.Content), 64)
+			if err != nil || math.IsNaN(score) {
+				return fmt.Errorf("%w: invalid zset score %q", RdbCorruptedErr, val.Content)
+			}
+			pair.Score = score
+		}
+		pair.Val.Content = member
+		pairs = append(pairs, pair)
+		member = nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rdbCreateZSet(pairs)
+}
+
+// rdbCreateHashFromZipList creates a hash from zl in which fields and values are arranged alternately,
+// zl is used as the hash directly if it doesn't exceed the limits.
+func (s *Server) rdbCreateHashFromZipList(zl *ziplist.ZipList) (*Object, error) {
+	n := zl.ZipListLen()
+	if n == 0 {
+		return nil, nil
+	}
+	if n%2 != 0 {
+		return nil, fmt.Errorf("%w: hash ziplist has odd number of entries %d", RdbCorruptedErr, n)
+	}
+
+	obj := &Object{Type: ObjHash, Encoding: EncodingZipList, Ptr: zl}
+	fields := make(map[string]struct{}, n/2)
+	exceeded := n/2 > s.config.hashMaxZiplistEntries
+	var dupField []byte
+	hashTypeForEach(obj, func(field, value []byte) bool {
+		if _, ok := fields[string(field)]; ok {
+			dupField = field
+			return false
+		}
+		fields[string(field)] = struct{}{}
+		if len(field) > s.config.hashMaxZiplistValue || len(value) > s.config.hashMaxZiplistValue {
+			exceeded = true
+		}
+		return true
+	})
+	if dupField != nil {
+		return nil, fmt.Errorf("%w: duplicate hash field %q", RdbCorruptedErr, dupField)
+	}
+
+	if exceeded {
+		hashTypeConvert(obj)
+	}
+	return obj, nil
+}
+
+// skipModuleValue skips the values saved by a module until the EOF opcode, the module is not
+// supported so the values can't be loaded.
+func (rr *rdbReader) skipModuleValue() error {
+	for {
+		opcode, err := rr.loadLen()
+		if err != nil {
+			return err
+		}
+
+		switch opcode {
+		case rdbModuleOpcodeEOF:
+			return nil
+		case rdbModuleOpcodeSInt, rdbModuleOpcodeUInt:
+			_, err = rr.loadLen()
+		case rdbModuleOpcodeFloat:
+			_, err = rr.read(4)
+		case rdbModuleOpcodeDouble:
+			_, err = rr.read(8)
+		case rdbModuleOpcodeString:
+			_, err = rr.loadString()
+		default:
+			return fmt.Errorf("%w: unknown module opcode %d", RdbCorruptedErr, opcode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// skipModuleAux skips the data saved by a module outside of keys, the module is not supported.
+func (rr *rdbReader) skipModuleAux() error {
+	id, err := rr.loadLen()
+	if err != nil {
+		return err
+	}
+	whenOpcode, err := rr.loadLen()
+	if err != nil {
+		return err
+	}
+	if whenOpcode != rdbModuleOpcodeUInt {
+		return fmt.Errorf("%w: invalid when opcode %d of module aux data", RdbCorruptedErr, whenOpcode)
+	}
+	if _, err := rr.loadLen(); err != nil {
+		return err
+	}
+
+	log.Printf("Skipping AUX data of module type '%s' which is not supported", moduleTypeName(id))
+	return rr.skipModuleValue()
+}
+
+// skipFunction skips a function library, functions are not supported. The library saved by redis 7.0
+// GA is only the code, while release candidates saved name, engine name, optional description and code.
+func (rr *rdbReader) skipFunction(opcode byte) error {
+	if opcode == rdbOpcodeFunctionPreGA {
+		for i := 0; i < 2; i++ {
+			if _, err := rr.loadString(); err != nil {
+				return err
+			}
+		}
+		hasDesc, err := rr.loadLen()
+		if err != nil {
+			return err
+		}
+		if hasDesc != 0 {
+			if _, err := rr.loadString(); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := rr.loadString(); err != nil {
+		return err
+	}
+
+	log.Printf("Skipping function library which is not supported")
+	return nil
+}
+
+// moduleTypeName returns the name of the module type encoded in the high 54 bits of id, 6 bits for
+// each of the 9 characters.
+func moduleTypeName(id uint64) string {
+	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	name := make([]byte, 9)
+	id >>= 10
+	for i := len(name) - 1; i >= 0; i-- {
+		name[i] = charset[id&63]
+		id >>= 6
+	}
+	return string(name)
+}
+
+// rdbLoad loads the RDB file from r which has size bytes into the dbs of s, the dbs must be empty.
+func (s *Server) rdbLoad(r io.Reader, size int64) error {
+	rr := &rdbReader{r: bufio.NewReader(r), remaining: size}
+	header, err := rr.read(9)
+	if err != nil {
+		return err
+	}
+	if string(header[:5]) != "REDIS" {
+		return fmt.Errorf("%w: wrong signature trying to load DB from file", RdbCorruptedErr)
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil || version < 1 || version > rdbMaxVersion {
+		return fmt.Errorf("%w: can't handle RDB format version %s", RdbCorruptedErr, header[5:])
+	}
+
+	db := s.dbs[0]
+	// expire、idle、freq 属于下一个 key，-1 表示没有
+	expire, idle, freq := int64(-1), int64(-1), -1
+	now := mstime()
+	for {
+		typ, err := rr.loadType()
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case rdbOpcodeExpireTime:
+			if expire, err = rr.loadSecondTime(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpcodeExpireTimeMs:
+			if expire, err = rr.loadMillisecondTime(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpcodeSelectDb:
+			id, err := rr.loadLen()
+			if err != nil {
+				return err
+			}
+			if id >= uint64(len(s.dbs)) {
+				return fmt.Errorf("data file was created with a server configured to handle more than %d databases", len(s.dbs))
+			}
+			db = s.dbs[id]
+			continue
+		case rdbOpcodeResizeDb:
+			// dict 按需扩容，不需要预先分配
+			if _, err := rr.loadLen(); err != nil {
+				return err
+			}
+			if _, err := rr.loadLen(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpcodeAux:
+			if _, err := rr.loadString(); err != nil {
+				return err
+			}
+			if _, err := rr.loadString(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpcodeIdle:
+			seconds, err := rr.loadLen()
+			if err != nil {
+				return err
+			}
+			idle = int64(seconds & lruClockMax)
+			continue
+		case rdbOpcodeFreq:
+			buf, err := rr.read(1)
+			if err != nil {
+				return err
+			}
+			freq = int(buf[0])
+			continue
+		case rdbOpcodeModuleAux:
+			if err := rr.skipModuleAux(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpcodeFunction2, rdbOpcodeFunctionPreGA:
+			if err := rr.skipFunction(typ); err != nil {
+				return err
+			}
+			continue
+		}
+		if typ == rdbOpcodeEOF {
+			break
+		}
+
+		key, err := rr.loadString()
+		if err != nil {
+			return err
+		}
+		obj, err := s.rdbLoadObject(rr, typ)
+		if err != nil {
+			return err
+		}
+
+		// 加载时已经过期的 key 以及空的集合直接丢弃
+		if obj != nil && (expire == -1 || expire > now) {
+			if _, ok := db.dict.Get(string(key)); ok {
+				return fmt.Errorf("%w: duplicate key %q", RdbCorruptedErr, key)
+			}
+			db.dbAdd(key, obj)
+			db.objectSetLRUOrLFU(obj, idle, freq)
+			if expire != -1 {
+				db.setExpire(key, expire)
+			}
+		}
+		expire, idle, freq = -1, -1, -1
+	}
+
+	// 版本 5 开始文件的末尾是校验和
+	if version >= 5 {
+		expected := rr.crc
+		buf, err := rr.read(8)
+		if err != nil {
+			return err
+		}
+		if crc := binary.LittleEndian.Uint64(buf); crc != 0 && crc != expected {
+			return fmt.Errorf("%w: wrong RDB checksum", RdbCorruptedErr)
+		}
+	}
+	return nil
+}
+
+// rdbLoadFile loads filename when the server starts, it's ok that filename doesn't exist.
+func (s *Server) rdbLoadFile(filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.rdbLoad(f, info.Size())
+}
+
+// rdbFilename returns the path of the RDB file.
+func (cfg *config) rdbFilename() string {
+	return filepath.Join(cfg.dir, cfg.dbfilename)
+}
+
+// rdbSaveBackground saves a snapshot of all dbs in background, rdbBgsaveDone is called in the event
+// loop when it's done.
+func (s *Server) rdbSaveBackground() error {
+	filename := s.config.rdbFilename()
+	snap := s.createSnapshot(true)
+	err := s.rdbPool.TrySubmit(func() {
+		err := rdbSaveFile(filename, snap)
+		s.submit(func() {
+			s.rdbBgsaveDone(snap, err)
+		})
+	})
+	if err != nil {
+		snap.release()
+		return err
+	}
+
+	s.rdbBgsaveInProgress = true
+	return nil
+}
+
+// rdbBgsaveDone handles the result of the background save.
+func (s *Server) rdbBgsaveDone(snap *rdbSnapshot, err error) {
+	snap.release()
+	s.rdbBgsaveInProgress = false
+	if err != nil {
+		log.Printf("Background saving error: %v\n", err)
+		return
+	}
+	s.lastSave = time.Now().Unix()
+	log.Printf("Background saving terminated with success\n")
+}
+
+// saveCommand SAVE
+func saveCommand(c *Client) {
+	s := c.server
+	if s.rdbBgsaveInProgress {
+		c.addReplyError(bgsaveInProgressErr)
+		return
+	}
+
+	if err := rdbSaveFile(s.config.rdbFilename(), s.createSnapshot(false)); err != nil {
+		log.Printf("Error saving DB on disk: %v\n", err)
+		c.addReplyError("ERR")
+		return
+	}
+	s.lastSave = time.Now().Unix()
+	c.addReplyOK()
+}
+
+// bgsaveCommand BGSAVE
+func bgsaveCommand(c *Client) {
+	if c.server.rdbBgsaveInProgress {
+		c.addReplyError(bgsaveInProgressErr)
+		return
+	}
+
+	if err := c.server.rdbSaveBackground(); err != nil {
+		log.Printf("Can't save in background: %v\n", err)
+		c.addReplyError("ERR")
+		return
+	}
+	c.addReplyStatus("Background saving started")
+}
+
+// lastsaveCommand LASTSAVE
+func lastsaveCommand(c *Client) {
+	c.addReplyInt(c.server.lastSave)
+}
@@ -0,0 +1,530 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WANGgbin/tiny_redis/data_type/listpack"
+	orderset "github.com/WANGgbin/tiny_redis/data_type/order_set"
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+// compareKeys 检查两个客户端当前 db 中 keys 的内容相同，无序的回复排序后比较
+func compareKeys(t *testing.T, a, b *Client, keys ...string) {
+	t.Helper()
+
+	for _, key := range keys {
+		typ := a.exec("TYPE", key)
+		if got := b.exec("TYPE", key); got != typ {
+			t.Fatalf("TYPE %s = %q after loading, want %q", key, got, typ)
+		}
+
+		var cmd []string
+		switch typ {
+		case "+list\r\n":
+			cmd = []string{"LRANGE", key, "0", "-1"}
+		case "+set\r\n":
+			cmd = []string{"SMEMBERS", key}
+		case "+hash\r\n":
+			cmd = []string{"HGETALL", key}
+		default:
+			continue
+		}
+		got, want := sortedReply(t, b.exec(cmd...)), sortedReply(t, a.exec(cmd...))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s = %v after loading, want %v", strings.Join(cmd, " "), got, want)
+		}
+	}
+}
+
+// loadServer 创建一个从 dir 中的 RDB 文件加载数据的 server
+func loadServer(t *testing.T, dir string) *Server {
+	t.Helper()
+
+	s := CreateServer()
+	s.config.dir = dir
+	if err := s.rdbLoadFile(s.config.rdbFilename()); err != nil {
+		t.Fatalf("rdbLoadFile() error = %v", err)
+	}
+	return s
+}
+
+func TestRdbSaveLoad(t *testing.T) {
+	s := CreateServer()
+	s.config.hashMaxZiplistEntries = 4
+	c := newTestClient(s)
+	dir := t.TempDir()
+
+	long := strings.Repeat("abcdefgh", 10)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"CONFIG", "SET", "dir", dir + "/none"}, want: "-ERR CONFIG SET failed (possibly related to argument 'dir') - No such file or directory\r\n"},
+		{args: []string{"CONFIG", "SET", "dbfilename", "a/dump.rdb"}, want: "-ERR CONFIG SET failed (possibly related to argument 'dbfilename') - dbfilename can't be a path, just a filename\r\n"},
+		{args: []string{"CONFIG", "SET", "dir", dir, "dbfilename", "test.rdb"}, want: "+OK\r\n"},
+		{args: []string{"RPUSH", "list", "a", "1", "-200", "70000", "3000000000", long, ""}, want: ":7\r\n"},
+		{args: []string{"SADD", "intset", "1", "-100000", "5"}, want: ":3\r\n"},
+		{args: []string{"SADD", "set", "a", "b", "1"}, want: ":3\r\n"},
+		{args: []string{"HSET", "small", "f", "v", "n", "1"}, want: ":2\r\n"},
+		{args: []string{"HSET", "big", "f1", "v1", "f2", long, "f3", "3", "f4", "4", "f5", "5"}, want: ":5\r\n"},
+		{args: []string{"PEXPIRE", "set", "100000"}, want: ":1\r\n"},
+		{args: []string{"SELECT", "3"}, want: "+OK\r\n"},
+		{args: []string{"RPUSH", "list", "x"}, want: ":1\r\n"},
+		{args: []string{"SELECT", "0"}, want: "+OK\r\n"},
+	})
+	// 没有对应的命令，直接添加 string 和 zset
+	c.db.dbAdd([]byte("str"), createStringObject([]byte(long)))
+	c.db.dbAdd([]byte("int"), createStringObject([]byte("-12345")))
+	sp, _ := orderset.CreateSkipListFromSorted([]*orderset.ScoreValPair{
+		{Score: 1.5, Val: rs.RedisString{Content: []byte("a")}},
+		{Score: 2, Val: rs.RedisString{Content: []byte("b")}},
+	})
+	c.db.dbAdd([]byte("zset"), &Object{Type: ObjZSet, Encoding: EncodingSkipList, Ptr: sp})
+	// 保存时已经过期的 key 在加载时被丢弃
+	c.db.dbAdd([]byte("expired"), createStringObject([]byte("v")))
+	c.db.setExpire([]byte("expired"), mstime()-1)
+
+	for _, compression := range []string{"yes", "no"} {
+		c.exec("CONFIG", "SET", "rdbcompression", compression)
+		if got := c.exec("SAVE"); got != "+OK\r\n" {
+			t.Fatalf("SAVE = %q", got)
+		}
+
+		loaded := CreateServer()
+		loaded.config.dir, loaded.config.dbfilename = dir, "test.rdb"
+		if err := loaded.rdbLoadFile(loaded.config.rdbFilename()); err != nil {
+			t.Fatalf("rdbLoadFile() error = %v", err)
+		}
+		lc := newTestClient(loaded)
+		compareKeys(t, c, lc, "list", "intset", "set", "small", "big", "str", "int", "zset")
+		if loaded.dbs[0].dict.Len() != 8 || loaded.dbs[3].dict.Len() != 1 {
+			t.Fatalf("%d keys are loaded into db 0, %d keys into db 3", loaded.dbs[0].dict.Len(), loaded.dbs[3].dict.Len())
+		}
+
+		// 加载时按照配置选择编码
+		db := loaded.dbs[0]
+		for key, encoding := range map[string]uint8{
+			"intset": EncodingIntSet, "set": EncodingHashTable, "small": EncodingZipList, "big": EncodingHashTable,
+			"str": EncodingRaw, "int": EncodingInt,
+		} {
+			if obj := db.lookupKeyRead([]byte(key)); obj.Encoding != encoding {
+				t.Fatalf("encoding of %s = %d, want %d", key, obj.Encoding, encoding)
+			}
+		}
+		pairs := db.lookupKeyRead([]byte("zset")).Ptr.(*orderset.SkipList).GetAllScoreValPairs()
+		if len(pairs) != 2 || pairs[0].Score != 1.5 || string(pairs[1].Val.Content) != "b" {
+			t.Fatalf("zset is loaded as %v", pairs)
+		}
+		if ttl := db.getExpire([]byte("set")) - mstime(); ttl <= 0 || ttl > 100000 {
+			t.Fatalf("ttl of set = %d after loading", ttl)
+		}
+		checkUsedMemory(t, loaded)
+
+		if err := loaded.rdbLoadFile(loaded.config.rdbFilename()); !errors.Is(err, RdbCorruptedErr) {
+			t.Fatalf("loading keys into non empty dbs: error = %v", err)
+		}
+	}
+}
+
+func TestRdbLoadErrors(t *testing.T) {
+	s := CreateServer()
+	c := newTestClient(s)
+	pushElements(c, "list", 100)
+	c.exec("HSET", "hash", "f", strings.Repeat("v", 100))
+
+	var buf bytes.Buffer
+	if err := rdbSave(&buf, s.createSnapshot(false)); err != nil {
+		t.Fatalf("rdbSave() error = %v", err)
+	}
+	data := buf.Bytes()
+
+	load := func(data []byte) error {
+		return CreateServer().rdbLoad(bytes.NewReader(data), int64(len(data)))
+	}
+	if err := load(data); err != nil {
+		t.Fatalf("rdbLoad() error = %v", err)
+	}
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-20] ^= 0xff
+	if err := load(corrupted); !errors.Is(err, RdbCorruptedErr) {
+		t.Fatalf("loading corrupted file: error = %v", err)
+	}
+	if err := load(data[:len(data)-1]); !errors.Is(err, RdbCorruptedErr) {
+		t.Fatalf("loading truncated file: error = %v", err)
+	}
+	if err := load([]byte("REDIS0012")); !errors.Is(err, RdbCorruptedErr) {
+		t.Fatalf("loading unsupported version: error = %v", err)
+	}
+
+	// 一个很大的长度不会导致分配过多的内存
+	huge := []byte("REDIS0009\x00\x00\x81\x7f\xff\xff\xff\xff\xff\xff\xff")
+	if err := load(huge); !errors.Is(err, RdbCorruptedErr) {
+		t.Fatalf("loading huge string: error = %v", err)
+	}
+
+	// 没有开启校验时校验和为 0，加载时不检查
+	s.config.rdbChecksum = false
+	buf.Reset()
+	_ = rdbSave(&buf, s.createSnapshot(false))
+	if crc := buf.Bytes()[buf.Len()-8:]; !bytes.Equal(crc, make([]byte, 8)) {
+		t.Fatalf("checksum = %v when rdbchecksum is no", crc)
+	}
+	if err := load(buf.Bytes()); err != nil {
+		t.Fatalf("rdbLoad() error = %v", err)
+	}
+}
+
+// redisZipList 按照 redis 的格式编码只包含短字符串的 ziplist
+func redisZipList(values ...string) []byte {
+	content := make([]byte, 10)
+	prevLen, tail := 0, 10
+	for _, v := range values {
+		tail = len(content)
+		content = append(content, byte(prevLen), byte(len(v)))
+		content = append(content, v...)
+		prevLen = len(content) - tail
+	}
+	content = append(content, 0xff)
+	binary.LittleEndian.PutUint32(content[:4], uint32(len(content)))
+	binary.LittleEndian.PutUint32(content[4:8], uint32(tail))
+	binary.LittleEndian.PutUint16(content[8:10], uint16(len(values)))
+	return content
+}
+
+// redisListPack 编码 values，listpack 的格式与 redis 相同
+func redisListPack(values ...string) []byte {
+	elements := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		elements = append(elements, zipListValue([]byte(v)))
+	}
+	lp, _ := listpack.CreateListPack(elements...)
+	return lp.Bytes()
+}
+
+// redisRdb 生成 redis 保存的版本为 version 的 RDB 文件，keys 由 saveKeys 写入 db 0
+func redisRdb(version int, saveKeys func(rw *rdbWriter)) []byte {
+	var buf bytes.Buffer
+	rw := &rdbWriter{w: &buf, checksum: true}
+	rw.write([]byte(fmt.Sprintf("REDIS%04d", version)))
+	rw.saveType(rdbOpcodeAux)
+	rw.saveString([]byte("redis-ver"))
+	rw.saveString([]byte("7.2.4"))
+	rw.saveType(rdbOpcodeSelectDb)
+	rw.saveLen(0)
+	saveKeys(rw)
+	rw.saveType(rdbOpcodeEOF)
+	crc := make([]byte, 8)
+	binary.LittleEndian.PutUint64(crc, rw.crc)
+	rw.write(crc)
+	return buf.Bytes()
+}
+
+func TestRdbLoadRedisEncodings(t *testing.T) {
+	long := strings.Repeat("v", 100)
+	data := redisRdb(11, func(rw *rdbWriter) {
+		// 模块的 aux 数据以及 function 被跳过
+		rw.saveType(rdbOpcodeModuleAux)
+		rw.saveLen(0x1234567890abcdef)
+		rw.saveLen(rdbModuleOpcodeUInt)
+		rw.saveLen(2)
+		rw.saveLen(rdbModuleOpcodeSInt)
+		rw.saveLen(5)
+		rw.saveLen(rdbModuleOpcodeString)
+		rw.saveString([]byte("module data"))
+		rw.saveLen(rdbModuleOpcodeFloat)
+		rw.write(make([]byte, 4))
+		rw.saveLen(rdbModuleOpcodeDouble)
+		rw.write(make([]byte, 8))
+		rw.saveLen(rdbModuleOpcodeEOF)
+		rw.saveType(rdbOpcodeFunction2)
+		rw.saveString([]byte("#!lua name=lib\nredis.register_function('f', function() return 1 end)"))
+		rw.saveType(rdbOpcodeFunctionPreGA)
+		rw.saveString([]byte("lib"))
+		rw.saveString([]byte("LUA"))
+		rw.saveLen(1)
+		rw.saveString([]byte("description"))
+		rw.saveString([]byte("redis.register_function('f', function() return 1 end)"))
+
+		// ziplist.c 注释中的例子，包含整数 2 和 5
+		zl, _ := hex.DecodeString("0f0000000c000000020000f302f6ff")
+		rw.saveType(rdbOpcodeIdle)
+		rw.saveLen(100)
+		rw.saveType(rdbTypeListZipList)
+		rw.saveString([]byte("list-ziplist"))
+		rw.saveString(zl)
+
+		// 空的节点被跳过
+		rw.saveType(rdbTypeListQuickList)
+		rw.saveString([]byte("list-quicklist"))
+		rw.saveLen(3)
+		rw.saveString(redisZipList("a", "b"))
+		rw.saveString(redisZipList())
+		rw.saveString(redisZipList("c"))
+
+		rw.saveType(rdbTypeListQuickList2)
+		rw.saveString([]byte("list-quicklist2"))
+		rw.saveLen(3)
+		rw.saveLen(rdbQuickListNodePacked)
+		rw.saveString(redisListPack("x", "1"))
+		rw.saveLen(rdbQuickListNodePlain)
+		rw.saveString([]byte(long))
+		rw.saveLen(rdbQuickListNodePacked)
+		rw.saveString(redisListPack("-7"))
+
+		rw.saveType(rdbOpcodeFreq)
+		rw.saveType(7)
+		rw.saveType(rdbTypeHashZipList)
+		rw.saveString([]byte("hash-ziplist"))
+		rw.saveString(redisZipList("f1", "v1", "f2", "2"))
+
+		rw.saveType(rdbTypeHashListPack)
+		rw.saveString([]byte("hash-listpack"))
+		rw.saveString(redisListPack("f", "1", "g", long))
+
+		rw.saveType(rdbTypeZSetZipList)
+		rw.saveString([]byte("zset-ziplist"))
+		rw.saveString(redisZipList("b", "2.5", "a", "3"))
+
+		rw.saveType(rdbTypeZSetListPack)
+		rw.saveString([]byte("zset-listpack"))
+		rw.saveString(redisListPack("m", "3", "n", "-inf"))
+
+		rw.saveType(rdbTypeSetListPack)
+		rw.saveString([]byte("set-intset"))
+		rw.saveString(redisListPack("3", "-1", "2"))
+
+		rw.saveType(rdbTypeSetListPack)
+		rw.saveString([]byte("set-hashtable"))
+		rw.saveString(redisListPack("1", "a"))
+
+		// 空的集合被丢弃
+		rw.saveType(rdbTypeSetListPack)
+		rw.saveString([]byte("empty"))
+		rw.saveString(redisListPack())
+	})
+
+	for _, policy := range []int{maxmemoryNoEviction, maxmemoryAllKeysLFU} {
+		s := CreateServer()
+		s.config.maxmemoryPolicy = policy
+		if err := s.rdbLoad(bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("rdbLoad() error = %v", err)
+		}
+		c := newTestClient(s)
+		runCmdCases(t, c, []cmdCase{
+			{args: []string{"LRANGE", "list-ziplist", "0", "-1"}, want: arrayReply("2", "5")},
+			{args: []string{"LRANGE", "list-quicklist", "0", "-1"}, want: arrayReply("a", "b", "c")},
+			{args: []string{"LRANGE", "list-quicklist2", "0", "-1"}, want: arrayReply("x", "1", long, "-7")},
+			{args: []string{"HGETALL", "hash-ziplist"}, want: arrayReply("f1", "v1", "f2", "2")},
+			{args: []string{"HGET", "hash-listpack", "g"}, want: "$100\r\n" + long + "\r\n"},
+			{args: []string{"SMEMBERS", "set-intset"}, want: arrayReply("-1", "2", "3")},
+			{args: []string{"SISMEMBER", "set-hashtable", "a"}, want: ":1\r\n"},
+			{args: []string{"EXISTS", "empty"}, want: ":0\r\n"},
+		})
+
+		db := s.dbs[0]
+		for key, encoding := range map[string]uint8{
+			"list-ziplist": EncodingQuickList, "hash-ziplist": EncodingZipList, "hash-listpack": EncodingHashTable,
+			"set-intset": EncodingIntSet, "set-hashtable": EncodingHashTable,
+		} {
+			if obj := db.lookupKeyRead([]byte(key)); obj.Encoding != encoding {
+				t.Fatalf("encoding of %s = %d, want %d", key, obj.Encoding, encoding)
+			}
+		}
+		for key, want := range map[string][]orderset.ScoreValPair{
+			"zset-ziplist":  {{Score: 2.5, Val: rs.RedisString{Content: []byte("b")}}, {Score: 3, Val: rs.RedisString{Content: []byte("a")}}},
+			"zset-listpack": {{Score: math.Inf(-1), Val: rs.RedisString{Content: []byte("n")}}, {Score: 3, Val: rs.RedisString{Content: []byte("m")}}},
+		} {
+			pairs := db.lookupKeyRead([]byte(key)).Ptr.(*orderset.SkipList).GetAllScoreValPairs()
+			if len(pairs) != len(want) {
+				t.Fatalf("%s is loaded as %v", key, pairs)
+			}
+			for i := range pairs {
+				if pairs[i].Score != want[i].Score || !bytes.Equal(pairs[i].Val.Content, want[i].Val.Content) {
+					t.Fatalf("%s is loaded as %v", key, pairs)
+				}
+			}
+		}
+		checkUsedMemory(t, s)
+
+		// IDLE、FREQ 只在对应的淘汰策略下生效，读取 key 之前检查
+		s = CreateServer()
+		s.config.maxmemoryPolicy = policy
+		_ = s.rdbLoad(bytes.NewReader(data), int64(len(data)))
+		list, _ := s.dbs[0].dict.Get("list-ziplist")
+		hash, _ := s.dbs[0].dict.Get("hash-ziplist")
+		if policy == maxmemoryAllKeysLFU {
+			if counter := hash.(*Object).lru & math.MaxUint8; counter != 7 {
+				t.Fatalf("LFU counter of hash-ziplist = %d, want 7", counter)
+			}
+		} else if idle := estimateObjectIdleTime(list.(*Object)); idle < 100000 || idle > 102000 {
+			t.Fatalf("idle time of list-ziplist = %d, want 100000", idle)
+		}
+	}
+}
+
+func TestRdbLoadRedisEncodingErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		saveKeys func(rw *rdbWriter)
+	}{
+		{"corrupted ziplist", func(rw *rdbWriter) {
+			zl := redisZipList("a", "b")
+			zl[4]++
+			rw.saveType(rdbTypeListZipList)
+			rw.saveString([]byte("key"))
+			rw.saveString(zl)
+		}},
+		{"corrupted listpack", func(rw *rdbWriter) {
+			lp := redisListPack("a", "b")
+			rw.saveType(rdbTypeSetListPack)
+			rw.saveString([]byte("key"))
+			rw.saveString(lp[:len(lp)-1])
+		}},
+		{"odd hash entries", func(rw *rdbWriter) {
+			rw.saveType(rdbTypeHashListPack)
+			rw.saveString([]byte("key"))
+			rw.saveString(redisListPack("f", "v", "g"))
+		}},
+		{"duplicate hash field", func(rw *rdbWriter) {
+			rw.saveType(rdbTypeHashZipList)
+			rw.saveString([]byte("key"))
+			rw.saveString(redisZipList("f", "v", "f", "v"))
+		}},
+		{"invalid zset score", func(rw *rdbWriter) {
+			rw.saveType(rdbTypeZSetZipList)
+			rw.saveString([]byte("key"))
+			rw.saveString(redisZipList("m", "nan"))
+		}},
+		{"duplicate set member", func(rw *rdbWriter) {
+			rw.saveType(rdbTypeSetListPack)
+			rw.saveString([]byte("key"))
+			rw.saveString(redisListPack("1", "1"))
+		}},
+		{"unknown quicklist container", func(rw *rdbWriter) {
+			rw.saveType(rdbTypeListQuickList2)
+			rw.saveString([]byte("key"))
+			rw.saveLen(1)
+			rw.saveLen(3)
+			rw.saveString(redisListPack("a"))
+		}},
+		{"unknown module opcode", func(rw *rdbWriter) {
+			rw.saveType(rdbOpcodeModuleAux)
+			rw.saveLen(1)
+			rw.saveLen(rdbModuleOpcodeUInt)
+			rw.saveLen(2)
+			rw.saveLen(9)
+		}},
+		{"module value", func(rw *rdbWriter) {
+			rw.saveType(rdbTypeModule2)
+			rw.saveString([]byte("key"))
+			rw.saveLen(1)
+			rw.saveLen(rdbModuleOpcodeEOF)
+		}},
+		{"stream", func(rw *rdbWriter) {
+			rw.saveType(rdbTypeStreamListPack3)
+			rw.saveString([]byte("key"))
+			rw.saveLen(0)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := redisRdb(10, tt.saveKeys)
+			if err := CreateServer().rdbLoad(bytes.NewReader(data), int64(len(data))); !errors.Is(err, RdbCorruptedErr) {
+				t.Fatalf("rdbLoad() error = %v, want %v", err, RdbCorruptedErr)
+			}
+		})
+	}
+}
+
+// bgsaveInProgress 在事件循环中读取 rdbBgsaveInProgress
+func bgsaveInProgress(s *Server) bool {
+	inProgress := make(chan bool)
+	s.submit(func() {
+		inProgress <- s.rdbBgsaveInProgress
+	})
+	return <-inProgress
+}
+
+func TestBgsave(t *testing.T) {
+	s := CreateServer()
+	s.config.dir = t.TempDir()
+	s.config.listMaxZiplistSize = 1
+	c := newTestClient(s)
+	go s.eventLoop()
+
+	for i := 0; i < 100; i++ {
+		c.execInLoop("RPUSH", "big", strconv.Itoa(i))
+		if i < 10 {
+			c.execInLoop("RPUSH", "list", strconv.Itoa(i))
+			c.execInLoop("HSET", "hash", "f"+strconv.Itoa(i), "v")
+		}
+	}
+
+	// 阻塞唯一的 worker，保证修改 key 时 BGSAVE 还没有完成
+	release := make(chan struct{})
+	_ = s.rdbPool.Submit(func() { <-release })
+	for s.rdbPool.Stats().Running == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if got := c.execInLoop("BGSAVE"); got != "+Background saving started\r\n" {
+		t.Fatalf("BGSAVE = %q", got)
+	}
+
+	lastSave := s.lastSave
+	for _, tt := range []cmdCase{
+		{args: []string{"BGSAVE"}, want: "-ERR Background save already in progress\r\n"},
+		{args: []string{"SAVE"}, want: "-ERR Background save already in progress\r\n"},
+		{args: []string{"RPUSH", "list", "new"}, want: ":11\r\n"},
+		{args: []string{"HGET", "hash", "f0"}, want: "$1\r\nv\r\n"},
+		{args: []string{"HDEL", "hash", "f1"}, want: ":1\r\n"},
+		{args: []string{"UNLINK", "big"}, want: ":1\r\n"},
+		{args: []string{"RPUSH", "other", "a"}, want: ":1\r\n"},
+	} {
+		if got := c.execInLoop(tt.args...); got != tt.want {
+			t.Fatalf("%s = %q, want %q", strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+	if s.lazyfree.freedObjects() != 0 {
+		t.Fatalf("value being saved is freed in background")
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for bgsaveInProgress(s) {
+		if time.Now().After(deadline) {
+			t.Fatalf("BGSAVE is not done")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := c.execInLoop("LASTSAVE"); got != ":"+strconv.FormatInt(s.lastSave, 10)+"\r\n" || s.lastSave < lastSave {
+		t.Fatalf("LASTSAVE = %q", got)
+	}
+
+	// 文件中是执行 BGSAVE 时的数据
+	loaded := loadServer(t, s.config.dir)
+	lc := newTestClient(loaded)
+	runCmdCases(t, lc, []cmdCase{
+		{args: []string{"LLEN", "list"}, want: ":10\r\n"},
+		{args: []string{"HLEN", "hash"}, want: ":10\r\n"},
+		{args: []string{"LLEN", "big"}, want: ":100\r\n"},
+		{args: []string{"EXISTS", "other"}, want: ":0\r\n"},
+	})
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LLEN", "list"}, want: ":11\r\n"},
+		{args: []string{"HLEN", "hash"}, want: ":9\r\n"},
+	})
+	for _, key := range []string{"list", "hash"} {
+		if obj := s.dbs[0].lookupKeyRead([]byte(key)); obj.shared {
+			t.Fatalf("%s is still shared after BGSAVE", key)
+		}
+	}
+	_ = s.Close()
+}
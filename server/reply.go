@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+)
+
+// 以 RESP2 格式向 Client.reply 写入回复
+
+const (
+	wrongTypeErr     = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	syntaxErr        = "ERR syntax error"
+	notIntegerErr    = "ERR value is not an integer or out of range"
+	outOfRangeErr    = "ERR index out of range"
+	noSuchKeyErr     = "ERR no such key"
+	notPositiveErr   = "ERR value is out of range, must be positive"
+	oomErr           = "OOM command not allowed when used memory > 'maxmemory'."
+	wrongArgCountFmt = "ERR wrong number of arguments for '%s' command"
+)
+
+func (c *Client) addReplyStatus(status string) {
+	c.reply.WriteString("+")
+	c.reply.WriteString(status)
+	c.reply.WriteString("\r\n")
+}
+
+func (c *Client) addReplyOK() {
+	c.addReplyStatus("OK")
+}
+
+func (c *Client) addReplyError(msg string) {
+	c.reply.WriteString("-")
+	c.reply.WriteString(msg)
+	c.reply.WriteString("\r\n")
+}
+
+func (c *Client) addReplyErrorFormat(format string, args ...interface{}) {
+	c.addReplyError(fmt.Sprintf(format, args...))
+}
+
+func (c *Client) addReplyInt(num int64) {
+	c.reply.WriteString(":")
+	c.reply.WriteString(strconv.FormatInt(num, 10))
+	c.reply.WriteString("\r\n")
+}
+
+func (c *Client) addReplyBulk(b []byte) {
+	c.reply.WriteString("$")
+	c.reply.WriteString(strconv.Itoa(len(b)))
+	c.reply.WriteString("\r\n")
+	c.reply.Write(b)
+	c.reply.WriteString("\r\n")
+}
+
+func (c *Client) addReplyBulkString(s string) {
+	c.addReplyBulk([]byte(s))
+}
+
+// addReplyValue replies val from ziplist or quicklist as a bulk string, val is int64 or *rs.RedisString.
+func (c *Client) addReplyValue(val interface{}) {
+	switch val := val.(type) {
+	case int64:
+		c.addReplyBulkString(strconv.FormatInt(val, 10))
+	case *rs.RedisString:
+		c.addReplyBulk(val.Content)
+	default:
+		c.addReplyNull()
+	}
+}
+
+func (c *Client) addReplyNull() {
+	c.reply.WriteString("$-1\r\n")
+}
+
+func (c *Client) addReplyNullArray() {
+	c.reply.WriteString("*-1\r\n")
+}
+
+func (c *Client) addReplyArrayLen(length int) {
+	c.reply.WriteString("*")
+	c.reply.WriteString(strconv.Itoa(length))
+	c.reply.WriteString("\r\n")
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RESP 协议的解析，请求可以是 multibulk 形式，例如 "*2\r\n$4\r\nLLEN\r\n$1\r\nk\r\n"，
+// 也可以是以空格分隔的 inline 形式，例如 "LLEN k\r\n"。
+
+const (
+	readBufferSize  = 64 * 1024 // inline 请求以及每一行的最大长度
+	maxMultiBulkLen = 1024 * 1024
+	maxBulkLen      = 512 * 1024 * 1024
+)
+
+var (
+	ProtocolErr = errors.New("Protocol error")
+)
+
+// readCommand reads a command from r, nil is returned for empty requests.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		return splitInline(line), nil
+	}
+
+	count, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || count > maxMultiBulkLen {
+		return nil, fmt.Errorf("%w: invalid multibulk length", ProtocolErr)
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+
+	argv := make([][]byte, count)
+	for i := range argv {
+		if argv[i], err = readBulk(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return argv, nil
+}
+
+func readBulk(r *bufio.Reader) ([]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '$' {
+		return nil, fmt.Errorf("%w: expected '$', got '%s'", ProtocolErr, line)
+	}
+	length, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || length < 0 || length > maxBulkLen {
+		return nil, fmt.Errorf("%w: invalid bulk length", ProtocolErr)
+	}
+
+	buf := make([]byte, length+2)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if buf[length] != '\r' || buf[length+1] != '\n' {
+		return nil, fmt.Errorf("%w: bulk is not terminated by CRLF", ProtocolErr)
+	}
+
+	return buf[:length], nil
+}
+
+// readLine reads a line without the trailing "\r\n" or "\n", the returned slice is only valid until
+// the next read.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("%w: too big request", ProtocolErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+func splitInline(line []byte) [][]byte {
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	argv := make([][]byte, len(fields))
+	for i, field := range fields {
+		argv[i] = append([]byte{}, field...)
+	}
+	return argv
+}
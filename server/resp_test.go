@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_readCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{name: "multibulk", input: "*2\r\n$4\r\nLLEN\r\n$1\r\nk\r\n", want: []string{"LLEN", "k"}},
+		{name: "binary safe", input: "*1\r\n$4\r\na\r\nb\r\n", want: []string{"a\r\nb"}},
+		{name: "empty bulk", input: "*1\r\n$0\r\n\r\n", want: []string{""}},
+		{name: "inline", input: "LLEN  k\r\n", want: []string{"LLEN", "k"}},
+		{name: "inline without CR", input: "PING\n", want: []string{"PING"}},
+		{name: "empty line", input: "\r\n", want: nil},
+		{name: "empty multibulk", input: "*0\r\n", want: nil},
+		{name: "invalid multibulk length", input: "*a\r\n", wantErr: ProtocolErr},
+		{name: "too many bulks", input: "*1048577\r\n", wantErr: ProtocolErr},
+		{name: "invalid bulk length", input: "*1\r\n$-1\r\n", wantErr: ProtocolErr},
+		{name: "missing $", input: "*1\r\n:1\r\n", wantErr: ProtocolErr},
+		{name: "bulk without CRLF", input: "*1\r\n$1\r\nab\r\n", wantErr: ProtocolErr},
+		{name: "too big inline", input: strings.Repeat("a", readBufferSize+1), wantErr: ProtocolErr},
+		{name: "truncated", input: "*2\r\n$4\r\nLLEN\r\n", wantErr: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReaderSize(strings.NewReader(tt.input), readBufferSize)
+			got, err := readCommand(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("readCommand() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readCommand() error = %v", err)
+			}
+
+			var gotStrs []string
+			for _, arg := range got {
+				gotStrs = append(gotStrs, string(arg))
+			}
+			if !reflect.DeepEqual(gotStrs, tt.want) {
+				t.Fatalf("readCommand() = %q, want %q", gotStrs, tt.want)
+			}
+		})
+	}
+}
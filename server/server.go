@@ -1,5 +1,137 @@
 package server
 
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// 与 redis 相同，所有命令都在同一个 goroutine(事件循环)中执行，因此数据结构不需要加锁。
+// 每个连接由单独的 goroutine 负责读取请求、发送回复，读到完整的命令后提交给事件循环执行。
+
+type Server struct {
+	config *config
+	dbs    []*redisDb
+
+	// events 中的函数按顺序在事件循环中执行
+	events chan func()
+	// expireDb 是下次 activeExpireCycle 开始处理的 db
+	expireDb int
+	// evictionPool 是按照 idle 升序排列的待淘汰的 key，evictDb 是下次随机淘汰的 db，见 evict.go
+	evictionPool []evictionPoolEntry
+	evictDb      int
+	// lazyfree 在后台释放被删除的较大的 value，见 lazyfree.go
+	lazyfree *lazyfree
+	// rdbPool 在后台执行 BGSAVE，rdbBgsaveInProgress 表示 BGSAVE 正在执行，lastSave 是最近一次成功保存
+	// RDB 文件的 unix 时间(秒)，见 rdb.go
+	rdbPool             *utils.GoPool
+	rdbBgsaveInProgress bool
+	lastSave            int64
+
+	mu       sync.Mutex
+	listener net.Listener
+	clients  map[*Client]struct{}
+	nextID   uint64
+}
+
+// CreateServer creates a server with default config.
+func CreateServer() *Server {
+	s := &Server{
+		config:   defaultConfig(),
+		events:   make(chan func(), 1024),
+		clients:  make(map[*Client]struct{}),
+		lazyfree: createLazyfree(),
+		rdbPool:  utils.NewGoPool(1, 1),
+		lastSave: time.Now().Unix(),
+	}
+	s.initDbs()
+
+	return s
+}
+
+func (s *Server) initDbs() {
+	s.dbs = make([]*redisDb, s.config.databases)
+	for i := range s.dbs {
+		s.dbs[i] = createRedisDb(i, s.config, s.lazyfree)
+	}
+}
+
+// ListenAndServe listens on addr and serves clients until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	go s.eventLoop()
+	s.submit(s.serverCron)
+
+	log.Printf("Ready to accept connections on %s\n", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveClient(conn)
+	}
+}
+
+// Close stops accepting new connections and closes all clients, it waits until values being freed
+// in background are freed and the background save is done.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.clients {
+		_ = c.conn.Close()
+	}
+	s.lazyfree.close()
+	s.rdbPool.Close()
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *Server) eventLoop() {
+	for fn := range s.events {
+		fn()
+	}
+}
+
+// serverCron runs background tasks in the event loop, it's called hz times per second.
+func (s *Server) serverCron() {
+	s.activeExpireCycle()
+	s.lazyfree.flush()
+
+	time.AfterFunc(time.Second/time.Duration(s.config.hz), func() {
+		s.submit(s.serverCron)
+	})
+}
+
+// submit runs fn in the event loop.
+func (s *Server) submit(fn func()) {
+	s.events <- fn
+}
+
+// StartServer starts a server listening on the default port.
 func StartServer() {
-	return
-}
\ No newline at end of file
+	s := CreateServer()
+	start := time.Now()
+	if err := s.rdbLoadFile(s.config.rdbFilename()); err != nil {
+		log.Fatalf("Fatal error loading the DB: %v. Exiting.\n", err)
+	}
+	log.Printf("DB loaded from disk: %.3f seconds\n", time.Since(start).Seconds())
+
+	if err := s.ListenAndServe(fmt.Sprintf(":%d", s.config.port)); err != nil {
+		log.Fatalf("Server exits: %v\n", err)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// newTestClient 创建一个没有连接的客户端，命令通过 exec 直接执行
+func newTestClient(s *Server) *Client {
+	if s == nil {
+		s = CreateServer()
+	}
+
+	return &Client{
+		server: s,
+		db:     s.dbs[0],
+		done:   make(chan struct{}, 1),
+	}
+}
+
+// exec 在当前 goroutine 中执行命令，返回 RESP 格式的回复
+func (c *Client) exec(args ...string) string {
+	c.argv = make([][]byte, len(args))
+	for i, arg := range args {
+		c.argv[i] = []byte(arg)
+	}
+
+	c.server.processCommand(c)
+	reply := c.reply.String()
+	c.reply.Reset()
+
+	return reply
+}
+
+type cmdCase struct {
+	args []string
+	want string
+}
+
+// runCmdCases 按顺序执行命令并检查回复
+func runCmdCases(t *testing.T, c *Client, cases []cmdCase) {
+	t.Helper()
+
+	for _, tt := range cases {
+		if got := c.exec(tt.args...); got != tt.want {
+			t.Fatalf("%s = %q, want %q", strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+}
+
+func TestProcessCommand(t *testing.T) {
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"PING"}, want: "+PONG\r\n"},
+		{args: []string{"ping", "hello"}, want: "$5\r\nhello\r\n"},
+		{args: []string{"PING", "a", "b"}, want: "-ERR wrong number of arguments for 'ping' command\r\n"},
+		{args: []string{"ECHO", "hi"}, want: "$2\r\nhi\r\n"},
+		{args: []string{"FOO", "a", "b"}, want: "-ERR unknown command 'FOO', with args beginning with: 'a' 'b' \r\n"},
+		{args: []string{"LLEN"}, want: "-ERR wrong number of arguments for 'llen' command\r\n"},
+		{args: []string{"RPUSH", "k", "a"}, want: ":1\r\n"},
+		{args: []string{"SELECT", "1"}, want: "+OK\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+		{args: []string{"SELECT", "0"}, want: "+OK\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":1\r\n"},
+		{args: []string{"SELECT", "16"}, want: "-ERR DB index is out of range\r\n"},
+		{args: []string{"SELECT", "a"}, want: "-ERR invalid DB index\r\n"},
+		{args: []string{"QUIT"}, want: "+OK\r\n"},
+	})
+
+	if !c.closeAfterReply {
+		t.Fatalf("client should be closed after QUIT")
+	}
+}
+
+func TestConfigCommand(t *testing.T) {
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"CONFIG", "GET", "list-max-ziplist-size"}, want: "*2\r\n$21\r\nlist-max-ziplist-size\r\n$2\r\n-2\r\n"},
+		{args: []string{"CONFIG", "SET", "list-max-listpack-size", "3"}, want: "+OK\r\n"},
+		{args: []string{"CONFIG", "GET", "LIST-MAX-ZIPLIST-SIZE", "unknown"}, want: "*2\r\n$21\r\nlist-max-ziplist-size\r\n$1\r\n3\r\n"},
+		{args: []string{"CONFIG", "SET", "list-max-ziplist-size", "-6"}, want: "-ERR CONFIG SET failed (possibly related to argument 'list-max-ziplist-size') - argument must be between -5 and 32767 inclusive\r\n"},
+		{args: []string{"CONFIG", "SET", "list-max-ziplist-size", "a"}, want: "-ERR CONFIG SET failed (possibly related to argument 'list-max-ziplist-size') - argument couldn't be parsed into an integer\r\n"},
+		{args: []string{"CONFIG", "SET", "unknown", "1"}, want: "-ERR Unknown option or number of arguments for CONFIG SET - 'unknown'\r\n"},
+		{args: []string{"CONFIG", "SET", "list-max-ziplist-size"}, want: "-ERR wrong number of arguments for 'config|set' command\r\n"},
+		{args: []string{"CONFIG", "FOO"}, want: "-ERR unknown subcommand 'FOO'. Try CONFIG HELP.\r\n"},
+	})
+
+	if fill := c.server.config.listMaxZiplistSize; fill != 3 {
+		t.Fatalf("list-max-ziplist-size = %d, want 3", fill)
+	}
+}
+
+// TestServeClient 通过 net.Pipe 完整地测试请求的读取、执行以及回复的发送
+func TestServeClient(t *testing.T) {
+	s := CreateServer()
+	go s.eventLoop()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go s.serveClient(serverConn)
+
+	r := bufio.NewReader(clientConn)
+	send := func(req string, replyLines int) string {
+		t.Helper()
+
+		go func() {
+			_, _ = clientConn.Write([]byte(req))
+		}()
+		var reply strings.Builder
+		for i := 0; i < replyLines; i++ {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read reply of %q error = %v", req, err)
+			}
+			reply.WriteString(line)
+		}
+		return reply.String()
+	}
+
+	tests := []struct {
+		name       string
+		req        string
+		replyLines int
+		want       string
+	}{
+		{name: "inline", req: "PING\r\n", replyLines: 1, want: "+PONG\r\n"},
+		{name: "multibulk", req: "*3\r\n$5\r\nRPUSH\r\n$1\r\nk\r\n$3\r\na b\r\n", replyLines: 1, want: ":1\r\n"},
+		{name: "pipeline", req: "RPUSH k c\r\nLRANGE k 0 -1\r\n", replyLines: 6, want: ":2\r\n*2\r\n$3\r\na b\r\n$1\r\nc\r\n"},
+		{name: "protocol error", req: "*1\r\n+PING\r\n", replyLines: 1, want: "-Protocol error: expected '$', got '+PING'\r\n"},
+	}
+	for _, tt := range tests {
+		if got := send(tt.req, tt.replyLines); got != tt.want {
+			t.Fatalf("%s: reply = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// 协议错误后连接被关闭
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatalf("connection should be closed after protocol error")
+	}
+}
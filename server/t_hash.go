@@ -0,0 +1,548 @@
+package server
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/WANGgbin/tiny_redis/data_type/dict"
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/data_type/ziplist"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// hash 类型元素较少时使用 ziplist 实现，field 和 value 依次相邻存储；元素个数超过 hash-max-ziplist-entries
+// 或者 field、value 的长度超过 hash-max-ziplist-value 时转换为 dict，之后不再转换回 ziplist。
+// dict 的 key 为 field，value 为 string 类型的 value。
+
+// lookupHashOrReply gets the hash of key, nil is returned when key doesn't exist or is not a hash.
+// reply is called when key doesn't exist.
+func (c *Client) lookupHashOrReply(key []byte, write bool, reply func()) *Object {
+	var obj *Object
+	if write {
+		obj = c.db.lookupKeyWrite(key)
+	} else {
+		obj = c.db.lookupKeyRead(key)
+	}
+
+	if obj == nil {
+		reply()
+		return nil
+	}
+	if c.checkType(obj, ObjHash) {
+		return nil
+	}
+
+	return obj
+}
+
+// hashTypeLookupWriteOrCreate gets the hash of key, an empty hash is created when key doesn't exist.
+// Nil is returned when key is not a hash.
+func (c *Client) hashTypeLookupWriteOrCreate(key []byte) *Object {
+	obj := c.db.lookupKeyWrite(key)
+	if obj == nil {
+		obj = createHashObject()
+		c.db.dbAdd(key, obj)
+		return obj
+	}
+
+	if c.checkType(obj, ObjHash) {
+		return nil
+	}
+	return obj
+}
+
+func hashTypeLength(obj *Object) int {
+	if obj.Encoding == EncodingZipList {
+		return obj.Ptr.(*ziplist.ZipList).ZipListLen() / 2
+	}
+	return obj.Ptr.(*dict.Dict).Len()
+}
+
+// zipListFindField gets cursor of field in the ziplist of a hash, nil is returned when field doesn't exist.
+func zipListFindField(zl *ziplist.ZipList, field []byte) *ziplist.Cursor {
+	c, _ := zl.ZipListFind(nil, &rs.RedisString{Content: field}, 1)
+	return c
+}
+
+// hashTypeGetValue gets value of field, false is returned when field doesn't exist.
+func hashTypeGetValue(obj *Object, field []byte) ([]byte, bool) {
+	if obj.Encoding == EncodingZipList {
+		zl := obj.Ptr.(*ziplist.ZipList)
+		fc := zipListFindField(zl, field)
+		if fc == nil {
+			return nil, false
+		}
+		vc, _ := zl.ZipListNext(fc)
+		val, _ := zl.ZipListGet(vc)
+		return zipListValueBytes(val), true
+	}
+
+	val, ok := obj.Ptr.(*dict.Dict).Get(string(field))
+	if !ok {
+		return nil, false
+	}
+	return []byte(val.(string)), true
+}
+
+func hashTypeExists(obj *Object, field []byte) bool {
+	if obj.Encoding == EncodingZipList {
+		return zipListFindField(obj.Ptr.(*ziplist.ZipList), field) != nil
+	}
+
+	_, ok := obj.Ptr.(*dict.Dict).Get(string(field))
+	return ok
+}
+
+// hashTypeSet sets field to value, true is returned when field already exists. The hash is converted
+// to dict when it's too large for ziplist.
+func (c *Client) hashTypeSet(obj *Object, field, value []byte) bool {
+	cfg := c.server.config
+	if obj.Encoding == EncodingZipList && (len(field) > cfg.hashMaxZiplistValue || len(value) > cfg.hashMaxZiplistValue) {
+		hashTypeConvert(obj)
+	}
+
+	if obj.Encoding == EncodingZipList {
+		zl := obj.Ptr.(*ziplist.ZipList)
+		if fc := zipListFindField(zl, field); fc != nil {
+			vc, _ := zl.ZipListNext(fc)
+			_, _ = zl.ZipListReplace(vc, zipListValue(value))
+			return true
+		}
+
+		_ = zl.ZipListPush(zipListValue(field))
+		_ = zl.ZipListPush(zipListValue(value))
+		if hashTypeLength(obj) > cfg.hashMaxZiplistEntries {
+			hashTypeConvert(obj)
+		}
+		return false
+	}
+
+	return !obj.Ptr.(*dict.Dict).Set(string(field), string(value))
+}
+
+// hashTypeDelete deletes field, false is returned when field doesn't exist.
+func hashTypeDelete(obj *Object, field []byte) bool {
+	if obj.Encoding == EncodingZipList {
+		zl := obj.Ptr.(*ziplist.ZipList)
+		fc := zipListFindField(zl, field)
+		if fc == nil {
+			return false
+		}
+		// 依次删除 field 和 value
+		vc, _ := zl.ZipListDeleteAt(fc)
+		_, _ = zl.ZipListDeleteAt(vc)
+		return true
+	}
+
+	_, ok := obj.Ptr.(*dict.Dict).Delete(string(field))
+	return ok
+}
+
+// hashTypeForEach calls fn for every field and value until fn returns false, the hash must not be
+// modified in fn.
+func hashTypeForEach(obj *Object, fn func(field, value []byte) bool) {
+	if obj.Encoding == EncodingZipList {
+		zl := obj.Ptr.(*ziplist.ZipList)
+		for fc := zl.ZipListIndex(0); fc != nil; {
+			vc, _ := zl.ZipListNext(fc)
+			field, _ := zl.ZipListGet(fc)
+			value, _ := zl.ZipListGet(vc)
+			if !fn(zipListValueBytes(field), zipListValueBytes(value)) {
+				return
+			}
+			fc, _ = zl.ZipListNext(vc)
+		}
+		return
+	}
+
+	obj.Ptr.(*dict.Dict).ForEach(func(key string, val interface{}) bool {
+		return fn([]byte(key), []byte(val.(string)))
+	})
+}
+
+// hashTypeRandomElement returns a random field and its value, the hash must not be empty.
+func hashTypeRandomElement(obj *Object) ([]byte, []byte) {
+	if obj.Encoding == EncodingZipList {
+		zl := obj.Ptr.(*ziplist.ZipList)
+		fc := zl.ZipListIndex(2 * rand.Intn(zl.ZipListLen()/2))
+		vc, _ := zl.ZipListNext(fc)
+		field, _ := zl.ZipListGet(fc)
+		value, _ := zl.ZipListGet(vc)
+		return zipListValueBytes(field), zipListValueBytes(value)
+	}
+
+	key, val, _ := obj.Ptr.(*dict.Dict).RandomEntry()
+	return []byte(key), []byte(val.(string))
+}
+
+// hashTypeConvert converts a hash encoded as ziplist to dict.
+func hashTypeConvert(obj *Object) {
+	if obj.Encoding != EncodingZipList {
+		return
+	}
+
+	d := dict.CreateDict()
+	hashTypeForEach(obj, func(field, value []byte) bool {
+		d.Add(string(field), string(value))
+		return true
+	})
+	obj.Encoding = EncodingHashTable
+	obj.Ptr = d
+}
+
+// hsetCommand HSET key field value [field value ...]
+func hsetCommand(c *Client) {
+	if len(c.argv)%2 == 1 {
+		c.addReplyErrorFormat(wrongArgCountFmt, "hset")
+		return
+	}
+
+	obj := c.hashTypeLookupWriteOrCreate(c.argv[1])
+	if obj == nil {
+		return
+	}
+
+	created := 0
+	for i := 2; i < len(c.argv); i += 2 {
+		if !c.hashTypeSet(obj, c.argv[i], c.argv[i+1]) {
+			created++
+		}
+	}
+	c.addReplyInt(int64(created))
+}
+
+// hsetnxCommand HSETNX key field value
+func hsetnxCommand(c *Client) {
+	obj := c.hashTypeLookupWriteOrCreate(c.argv[1])
+	if obj == nil {
+		return
+	}
+
+	if hashTypeExists(obj, c.argv[2]) {
+		c.addReplyInt(0)
+		return
+	}
+	c.hashTypeSet(obj, c.argv[2], c.argv[3])
+	c.addReplyInt(1)
+}
+
+// hgetCommand HGET key field
+func hgetCommand(c *Client) {
+	obj := c.lookupHashOrReply(c.argv[1], false, c.addReplyNull)
+	if obj == nil {
+		return
+	}
+
+	if value, ok := hashTypeGetValue(obj, c.argv[2]); ok {
+		c.addReplyBulk(value)
+	} else {
+		c.addReplyNull()
+	}
+}
+
+// hmgetCommand HMGET key field [field ...]
+func hmgetCommand(c *Client) {
+	obj := c.db.lookupKeyRead(c.argv[1])
+	if obj != nil && c.checkType(obj, ObjHash) {
+		return
+	}
+
+	fields := c.argv[2:]
+	c.addReplyArrayLen(len(fields))
+	for _, field := range fields {
+		if obj == nil {
+			c.addReplyNull()
+		} else if value, ok := hashTypeGetValue(obj, field); ok {
+			c.addReplyBulk(value)
+		} else {
+			c.addReplyNull()
+		}
+	}
+}
+
+// hdelCommand HDEL key field [field ...]
+func hdelCommand(c *Client) {
+	key := c.argv[1]
+	obj := c.lookupHashOrReply(key, true, func() {
+		c.addReplyInt(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	deleted := 0
+	for _, field := range c.argv[2:] {
+		if hashTypeDelete(obj, field) {
+			deleted++
+			if hashTypeLength(obj) == 0 {
+				c.db.dbDelete(key)
+				break
+			}
+		}
+	}
+	c.addReplyInt(int64(deleted))
+}
+
+// hlenCommand HLEN key
+func hlenCommand(c *Client) {
+	obj := c.lookupHashOrReply(c.argv[1], false, func() {
+		c.addReplyInt(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	c.addReplyInt(int64(hashTypeLength(obj)))
+}
+
+// hstrlenCommand HSTRLEN key field
+func hstrlenCommand(c *Client) {
+	obj := c.lookupHashOrReply(c.argv[1], false, func() {
+		c.addReplyInt(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	value, _ := hashTypeGetValue(obj, c.argv[2])
+	c.addReplyInt(int64(len(value)))
+}
+
+// hexistsCommand HEXISTS key field
+func hexistsCommand(c *Client) {
+	obj := c.lookupHashOrReply(c.argv[1], false, func() {
+		c.addReplyInt(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	if hashTypeExists(obj, c.argv[2]) {
+		c.addReplyInt(1)
+	} else {
+		c.addReplyInt(0)
+	}
+}
+
+// hgetallCommand HGETALL key
+func hgetallCommand(c *Client) {
+	genericHgetallCommand(c, true, true)
+}
+
+// hkeysCommand HKEYS key
+func hkeysCommand(c *Client) {
+	genericHgetallCommand(c, true, false)
+}
+
+// hvalsCommand HVALS key
+func hvalsCommand(c *Client) {
+	genericHgetallCommand(c, false, true)
+}
+
+func genericHgetallCommand(c *Client, withFields, withValues bool) {
+	obj := c.lookupHashOrReply(c.argv[1], false, func() {
+		c.addReplyArrayLen(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	length := hashTypeLength(obj)
+	if withFields && withValues {
+		length *= 2
+	}
+	c.addReplyArrayLen(length)
+	hashTypeForEach(obj, func(field, value []byte) bool {
+		if withFields {
+			c.addReplyBulk(field)
+		}
+		if withValues {
+			c.addReplyBulk(value)
+		}
+		return true
+	})
+}
+
+// hincrbyCommand HINCRBY key field increment
+func hincrbyCommand(c *Client) {
+	incr, ok := c.getInt64OrReply(c.argv[3])
+	if !ok {
+		return
+	}
+
+	obj := c.hashTypeLookupWriteOrCreate(c.argv[1])
+	if obj == nil {
+		return
+	}
+
+	var value int64
+	if old, ok := hashTypeGetValue(obj, c.argv[2]); ok {
+		if value, ok = utils.String2Int64(old); !ok {
+			c.addReplyError("ERR hash value is not an integer")
+			return
+		}
+	}
+
+	if (incr < 0 && value < 0 && incr < math.MinInt64-value) || (incr > 0 && value > 0 && incr > math.MaxInt64-value) {
+		c.addReplyError("ERR increment or decrement would overflow")
+		return
+	}
+
+	value += incr
+	c.hashTypeSet(obj, c.argv[2], strconv.AppendInt(nil, value, 10))
+	c.addReplyInt(value)
+}
+
+// hincrbyfloatCommand HINCRBYFLOAT key field increment
+func hincrbyfloatCommand(c *Client) {
+	incr, ok := utils.String2Float64(c.argv[3])
+	if !ok {
+		c.addReplyError("ERR value is not a valid float")
+		return
+	}
+
+	obj := c.hashTypeLookupWriteOrCreate(c.argv[1])
+	if obj == nil {
+		return
+	}
+
+	var value float64
+	if old, ok := hashTypeGetValue(obj, c.argv[2]); ok {
+		if value, ok = utils.String2Float64(old); !ok {
+			c.addReplyError("ERR hash value is not a float")
+			return
+		}
+	}
+
+	value += incr
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		c.addReplyError("ERR increment would produce NaN or Infinity")
+		return
+	}
+
+	// 与 redis 相同，结果不使用指数形式
+	result := []byte(strconv.FormatFloat(value, 'f', -1, 64))
+	c.hashTypeSet(obj, c.argv[2], result)
+	c.addReplyBulk(result)
+}
+
+// hrandfieldCommand HRANDFIELD key [count [WITHVALUES]]
+func hrandfieldCommand(c *Client) {
+	if len(c.argv) >= 3 {
+		hrandfieldWithCountCommand(c)
+		return
+	}
+
+	obj := c.lookupHashOrReply(c.argv[1], false, c.addReplyNull)
+	if obj == nil {
+		return
+	}
+
+	field, _ := hashTypeRandomElement(obj)
+	c.addReplyBulk(field)
+}
+
+func hrandfieldWithCountCommand(c *Client) {
+	count, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+	withValues := false
+	if len(c.argv) == 4 && strings.EqualFold(string(c.argv[3]), "withvalues") {
+		withValues = true
+	} else if len(c.argv) > 3 {
+		c.addReplyError(syntaxErr)
+		return
+	}
+
+	// count 为负数时允许重复的元素
+	unique := true
+	if count < 0 {
+		if count == math.MinInt64 || (withValues && -count > math.MaxInt64/2) {
+			c.addReplyError("ERR value is out of range")
+			return
+		}
+		count, unique = -count, false
+	}
+
+	obj := c.lookupHashOrReply(c.argv[1], false, func() {
+		c.addReplyArrayLen(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	addReplyPair := func(field, value []byte) {
+		c.addReplyBulk(field)
+		if withValues {
+			c.addReplyBulk(value)
+		}
+	}
+	multiplier := 1
+	if withValues {
+		multiplier = 2
+	}
+
+	if !unique {
+		c.addReplyArrayLen(int(count) * multiplier)
+		for i := int64(0); i < count; i++ {
+			addReplyPair(hashTypeRandomElement(obj))
+		}
+		return
+	}
+
+	size := int64(hashTypeLength(obj))
+	if count >= size {
+		c.addReplyArrayLen(int(size) * multiplier)
+		hashTypeForEach(obj, func(field, value []byte) bool {
+			addReplyPair(field, value)
+			return true
+		})
+		return
+	}
+
+	c.addReplyArrayLen(int(count) * multiplier)
+	if obj.Encoding == EncodingHashTable && count*3 <= size {
+		// count 远小于元素个数时，随机选取直到得到 count 个不同的元素
+		picked := make(map[string]struct{}, count)
+		for int64(len(picked)) < count {
+			field, value := hashTypeRandomElement(obj)
+			if _, ok := picked[string(field)]; ok {
+				continue
+			}
+			picked[string(field)] = struct{}{}
+			addReplyPair(field, value)
+		}
+		return
+	}
+
+	// 否则取出所有元素，随机打乱前 count 个
+	fields, values := make([][]byte, 0, size), make([][]byte, 0, size)
+	hashTypeForEach(obj, func(field, value []byte) bool {
+		fields = append(fields, field)
+		values = append(values, value)
+		return true
+	})
+	for i := 0; i < int(count); i++ {
+		j := i + rand.Intn(len(fields)-i)
+		fields[i], fields[j] = fields[j], fields[i]
+		values[i], values[j] = values[j], values[i]
+		addReplyPair(fields[i], values[i])
+	}
+}
+
+// hscanCommand HSCAN key cursor [MATCH pattern] [COUNT count]
+func hscanCommand(c *Client) {
+	cursor, ok := c.parseScanCursorOrReply(c.argv[2])
+	if !ok {
+		return
+	}
+
+	obj := c.lookupHashOrReply(c.argv[1], false, c.addReplyEmptyScan)
+	if obj == nil {
+		return
+	}
+	scanGenericCommand(c, obj, cursor)
+}
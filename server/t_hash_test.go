@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bufio"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseReply 解析由 bulk string 和数组组成的回复，数组中的元素按顺序展开
+func parseReply(t *testing.T, reply string) []string {
+	t.Helper()
+
+	var elems []string
+	r := bufio.NewReader(strings.NewReader(reply))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return elems
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		switch line[0] {
+		case '*':
+		case '$':
+			length, _ := strconv.Atoi(line[1:])
+			if length < 0 {
+				continue
+			}
+			buf := make([]byte, length+2)
+			if _, err = r.Read(buf); err != nil {
+				t.Fatalf("invalid reply %q", reply)
+			}
+			elems = append(elems, string(buf[:length]))
+		default:
+			t.Fatalf("unexpected reply %q", reply)
+		}
+	}
+}
+
+func sortedReply(t *testing.T, reply string) []string {
+	t.Helper()
+
+	elems := parseReply(t, reply)
+	sort.Strings(elems)
+	return elems
+}
+
+func TestHashCommands(t *testing.T) {
+	for _, maxEntries := range []string{"128", "1"} {
+		t.Run("max entries "+maxEntries, func(t *testing.T) {
+			c := newTestClient(nil)
+			c.exec("CONFIG", "SET", "hash-max-ziplist-entries", maxEntries)
+
+			runCmdCases(t, c, []cmdCase{
+				{args: []string{"HSET", "h", "a", "1", "b", "x"}, want: ":2\r\n"},
+				{args: []string{"HSET", "h", "a", "2", "c", "007"}, want: ":1\r\n"},
+				{args: []string{"HSET", "h", "a"}, want: "-ERR wrong number of arguments for 'hset' command\r\n"},
+				{args: []string{"HGET", "h", "a"}, want: "$1\r\n2\r\n"},
+				{args: []string{"HGET", "h", "c"}, want: "$3\r\n007\r\n"},
+				{args: []string{"HGET", "h", "d"}, want: "$-1\r\n"},
+				{args: []string{"HGET", "none", "a"}, want: "$-1\r\n"},
+				{args: []string{"HMGET", "h", "a", "d", "b"}, want: "*3\r\n$1\r\n2\r\n$-1\r\n$1\r\nx\r\n"},
+				{args: []string{"HMGET", "none", "a"}, want: "*1\r\n$-1\r\n"},
+				{args: []string{"HLEN", "h"}, want: ":3\r\n"},
+				{args: []string{"HLEN", "none"}, want: ":0\r\n"},
+				{args: []string{"HEXISTS", "h", "b"}, want: ":1\r\n"},
+				{args: []string{"HEXISTS", "h", "d"}, want: ":0\r\n"},
+				{args: []string{"HSTRLEN", "h", "c"}, want: ":3\r\n"},
+				{args: []string{"HSTRLEN", "h", "d"}, want: ":0\r\n"},
+				{args: []string{"HSETNX", "h", "a", "3"}, want: ":0\r\n"},
+				{args: []string{"HSETNX", "h", "d", "4"}, want: ":1\r\n"},
+				{args: []string{"HDEL", "h", "d", "d", "e"}, want: ":1\r\n"},
+				{args: []string{"HDEL", "none", "a"}, want: ":0\r\n"},
+			})
+
+			for _, tt := range []struct {
+				cmd  string
+				want []string
+			}{
+				{cmd: "HGETALL", want: []string{"007", "2", "a", "b", "c", "x"}},
+				{cmd: "HKEYS", want: []string{"a", "b", "c"}},
+				{cmd: "HVALS", want: []string{"007", "2", "x"}},
+			} {
+				if got := sortedReply(t, c.exec(tt.cmd, "h")); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+					t.Fatalf("%s = %v, want %v", tt.cmd, got, tt.want)
+				}
+			}
+
+			// 删除所有 field 后 key 被删除
+			runCmdCases(t, c, []cmdCase{
+				{args: []string{"HDEL", "h", "a", "b", "c"}, want: ":3\r\n"},
+				{args: []string{"HGETALL", "h"}, want: "*0\r\n"},
+			})
+			if c.db.lookupKeyRead([]byte("h")) != nil {
+				t.Fatalf("empty hash should be deleted")
+			}
+		})
+	}
+}
+
+func TestHashConversion(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("CONFIG", "SET", "hash-max-ziplist-entries", "4", "hash-max-ziplist-value", "8")
+
+	encoding := func(key string) uint8 {
+		return c.db.lookupKeyRead([]byte(key)).Encoding
+	}
+
+	for i := 0; i < 4; i++ {
+		c.exec("HSET", "h", "f"+strconv.Itoa(i), strconv.Itoa(i))
+	}
+	if encoding("h") != EncodingZipList {
+		t.Fatalf("hash with 4 fields should be encoded as ziplist")
+	}
+	c.exec("HSET", "h", "f4", "4")
+	if encoding("h") != EncodingHashTable {
+		t.Fatalf("hash with 5 fields should be converted to hashtable")
+	}
+	for i := 0; i < 5; i++ {
+		if got, want := c.exec("HGET", "h", "f"+strconv.Itoa(i)), "$1\r\n"+strconv.Itoa(i)+"\r\n"; got != want {
+			t.Fatalf("HGET f%d = %q, want %q", i, got, want)
+		}
+	}
+
+	c.exec("HSET", "long", "f", "short")
+	c.exec("HSET", "long", "f", "123456789")
+	if encoding("long") != EncodingHashTable {
+		t.Fatalf("hash with long value should be converted to hashtable")
+	}
+	c.exec("HSET", "field", "123456789", "v")
+	if encoding("field") != EncodingHashTable {
+		t.Fatalf("hash with long field should be converted to hashtable")
+	}
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"HGET", "long", "f"}, want: "$9\r\n123456789\r\n"},
+		{args: []string{"HGET", "field", "123456789"}, want: "$1\r\nv\r\n"},
+	})
+}
+
+func TestHincrby(t *testing.T) {
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"HINCRBY", "h", "n", "5"}, want: ":5\r\n"},
+		{args: []string{"HINCRBY", "h", "n", "-7"}, want: ":-2\r\n"},
+		{args: []string{"HINCRBY", "h", "n", "a"}, want: "-ERR value is not an integer or out of range\r\n"},
+		{args: []string{"HSET", "h", "s", "abc", "max", "9223372036854775807"}, want: ":2\r\n"},
+		{args: []string{"HINCRBY", "h", "s", "1"}, want: "-ERR hash value is not an integer\r\n"},
+		{args: []string{"HINCRBY", "h", "max", "1"}, want: "-ERR increment or decrement would overflow\r\n"},
+		{args: []string{"HINCRBY", "h", "max", "-1"}, want: ":9223372036854775806\r\n"},
+
+		{args: []string{"HINCRBYFLOAT", "h", "f", "10.5"}, want: "$4\r\n10.5\r\n"},
+		{args: []string{"HINCRBYFLOAT", "h", "f", "0.25"}, want: "$5\r\n10.75\r\n"},
+		{args: []string{"HINCRBYFLOAT", "h", "n", "2"}, want: "$1\r\n0\r\n"},
+		{args: []string{"HINCRBYFLOAT", "h", "f", "5e3"}, want: "$7\r\n5010.75\r\n"},
+		{args: []string{"HINCRBYFLOAT", "h", "f", "abc"}, want: "-ERR value is not a valid float\r\n"},
+		{args: []string{"HINCRBYFLOAT", "h", "s", "1"}, want: "-ERR hash value is not a float\r\n"},
+		{args: []string{"HINCRBYFLOAT", "h", "f", "inf"}, want: "-ERR increment would produce NaN or Infinity\r\n"},
+		{args: []string{"HGET", "h", "f"}, want: "$7\r\n5010.75\r\n"},
+	})
+}
+
+func TestHrandfield(t *testing.T) {
+	for _, maxEntries := range []string{"128", "1"} {
+		t.Run("max entries "+maxEntries, func(t *testing.T) {
+			c := newTestClient(nil)
+			c.exec("CONFIG", "SET", "hash-max-ziplist-entries", maxEntries)
+			fields := map[string]string{}
+			for i := 0; i < 20; i++ {
+				field, value := "f"+strconv.Itoa(i), "v"+strconv.Itoa(i)
+				c.exec("HSET", "h", field, value)
+				fields[field] = value
+			}
+
+			runCmdCases(t, c, []cmdCase{
+				{args: []string{"HRANDFIELD", "none"}, want: "$-1\r\n"},
+				{args: []string{"HRANDFIELD", "none", "3"}, want: "*0\r\n"},
+				{args: []string{"HRANDFIELD", "h", "0"}, want: "*0\r\n"},
+				{args: []string{"HRANDFIELD", "h", "a"}, want: "-ERR value is not an integer or out of range\r\n"},
+				{args: []string{"HRANDFIELD", "h", "1", "foo"}, want: "-ERR syntax error\r\n"},
+				{args: []string{"HRANDFIELD", "h", "-9223372036854775808"}, want: "-ERR value is out of range\r\n"},
+			})
+
+			if field := parseReply(t, c.exec("HRANDFIELD", "h")); len(field) != 1 || fields[field[0]] == "" {
+				t.Fatalf("HRANDFIELD = %v", field)
+			}
+
+			for _, tt := range []struct {
+				count      string
+				withValues bool
+				want       int
+				unique     bool
+			}{
+				{count: "5", want: 5, unique: true},
+				{count: "15", withValues: true, want: 15, unique: true},
+				{count: "30", want: 20, unique: true},
+				{count: "-30", withValues: true, want: 30},
+			} {
+				args := []string{"HRANDFIELD", "h", tt.count}
+				if tt.withValues {
+					args = append(args, "WITHVALUES")
+				}
+				elems := parseReply(t, c.exec(args...))
+
+				step := 1
+				if tt.withValues {
+					step = 2
+				}
+				if len(elems) != tt.want*step {
+					t.Fatalf("%v returned %d elements, want %d", args, len(elems), tt.want*step)
+				}
+				seen := map[string]bool{}
+				for i := 0; i < len(elems); i += step {
+					if _, ok := fields[elems[i]]; !ok {
+						t.Fatalf("%v returned unknown field %s", args, elems[i])
+					}
+					if tt.withValues && elems[i+1] != fields[elems[i]] {
+						t.Fatalf("%v returned %s: %s, want %s", args, elems[i], elems[i+1], fields[elems[i]])
+					}
+					if tt.unique && seen[elems[i]] {
+						t.Fatalf("%v returned duplicate field %s", args, elems[i])
+					}
+					seen[elems[i]] = true
+				}
+			}
+		})
+	}
+}
+
+func TestHscan(t *testing.T) {
+	for _, maxEntries := range []string{"128", "1"} {
+		t.Run("max entries "+maxEntries, func(t *testing.T) {
+			c := newTestClient(nil)
+			c.exec("CONFIG", "SET", "hash-max-ziplist-entries", maxEntries)
+			for i := 0; i < 100; i++ {
+				c.exec("HSET", "h", "f"+strconv.Itoa(i), "v"+strconv.Itoa(i))
+			}
+
+			// 遍历直到 cursor 为 0，返回所有 field 和 value
+			scanAll := func(opts ...string) map[string]string {
+				got := map[string]string{}
+				cursor := "0"
+				for {
+					elems := parseReply(t, c.exec(append([]string{"HSCAN", "h", cursor}, opts...)...))
+					cursor = elems[0]
+					for i := 1; i < len(elems); i += 2 {
+						got[elems[i]] = elems[i+1]
+					}
+					if cursor == "0" {
+						return got
+					}
+				}
+			}
+
+			all := scanAll("COUNT", "7")
+			if len(all) != 100 {
+				t.Fatalf("HSCAN returned %d fields, want 100", len(all))
+			}
+			for field, value := range all {
+				if "v"+field[1:] != value {
+					t.Fatalf("HSCAN returned %s: %s", field, value)
+				}
+			}
+
+			matched := scanAll("MATCH", "f1*")
+			if len(matched) != 11 {
+				t.Fatalf("HSCAN MATCH f1* returned %v, want 11 fields", matched)
+			}
+			if len(scanAll("MATCH", "*")) != 100 {
+				t.Fatalf("HSCAN MATCH * should return all fields")
+			}
+		})
+	}
+
+	c := newTestClient(nil)
+	c.exec("RPUSH", "l", "a")
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"HSCAN", "none", "0"}, want: "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{args: []string{"HSCAN", "none", "a"}, want: "-ERR invalid cursor\r\n"},
+		{args: []string{"HSCAN", "none", "-1"}, want: "-ERR invalid cursor\r\n"},
+		{args: []string{"HSCAN", "l", "0"}, want: "-" + wrongTypeErr + "\r\n"},
+		{args: []string{"HSET", "h", "a", "1"}, want: ":1\r\n"},
+		{args: []string{"HSCAN", "h", "0", "COUNT", "0"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"HSCAN", "h", "0", "COUNT"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"HSCAN", "h", "0", "FOO", "1"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"HSCAN", "h", "0"}, want: "*2\r\n$1\r\n0\r\n" + arrayReply("a", "1")},
+	})
+}
+
+func TestHashWrongType(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "l", "a")
+
+	for _, args := range [][]string{
+		{"HSET", "l", "a", "1"},
+		{"HSETNX", "l", "a", "1"},
+		{"HGET", "l", "a"},
+		{"HMGET", "l", "a"},
+		{"HDEL", "l", "a"},
+		{"HLEN", "l"},
+		{"HSTRLEN", "l", "a"},
+		{"HEXISTS", "l", "a"},
+		{"HGETALL", "l"},
+		{"HINCRBY", "l", "a", "1"},
+		{"HINCRBYFLOAT", "l", "a", "1"},
+		{"HRANDFIELD", "l"},
+		{"HRANDFIELD", "l", "2"},
+	} {
+		if got := c.exec(args...); got != "-"+wrongTypeErr+"\r\n" {
+			t.Fatalf("%v = %q, want WRONGTYPE error", args, got)
+		}
+	}
+}
@@ -0,0 +1,646 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/WANGgbin/tiny_redis/data_type/quicklist"
+	rs "github.com/WANGgbin/tiny_redis/data_type/redis_string"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// list 类型使用 quicklist 实现，列表为空时删除对应的 key。
+
+func listTypePush(ql *quicklist.QuickList, val interface{}, head bool) {
+	// val 只可能是整数或者长度受限的字符串，push 不会失败
+	if head {
+		_ = ql.PushHead(val)
+	} else {
+		_ = ql.PushTail(val)
+	}
+}
+
+func listTypePop(ql *quicklist.QuickList, head bool) interface{} {
+	var val interface{}
+	if head {
+		val, _ = ql.PopHead()
+	} else {
+		val, _ = ql.PopTail()
+	}
+	return val
+}
+
+// lookupListOrReply gets the list of key, nil is returned when key doesn't exist or is not a list.
+// reply is called when key doesn't exist.
+func (c *Client) lookupListOrReply(key []byte, write bool, reply func()) *quicklist.QuickList {
+	var obj *Object
+	if write {
+		obj = c.db.lookupKeyWrite(key)
+	} else {
+		obj = c.db.lookupKeyRead(key)
+	}
+
+	if obj == nil {
+		reply()
+		return nil
+	}
+	if c.checkType(obj, ObjList) {
+		return nil
+	}
+
+	return obj.Ptr.(*quicklist.QuickList)
+}
+
+// deleteIfEmpty deletes key when the list is empty.
+func (c *Client) deleteIfEmpty(key []byte, ql *quicklist.QuickList) {
+	if ql.Len() == 0 {
+		c.db.dbDelete(key)
+	}
+}
+
+// lpushCommand LPUSH key element [element ...]
+func lpushCommand(c *Client) {
+	pushGenericCommand(c, true)
+}
+
+// rpushCommand RPUSH key element [element ...]
+func rpushCommand(c *Client) {
+	pushGenericCommand(c, false)
+}
+
+func pushGenericCommand(c *Client, head bool) {
+	key := c.argv[1]
+	obj := c.db.lookupKeyWrite(key)
+	if obj != nil && c.checkType(obj, ObjList) {
+		return
+	}
+
+	if obj == nil {
+		obj = createQuickListObject(c.server.config.listMaxZiplistSize, c.server.config.listCompressDepth)
+		c.db.dbAdd(key, obj)
+	}
+
+	ql := obj.Ptr.(*quicklist.QuickList)
+	for _, arg := range c.argv[2:] {
+		listTypePush(ql, zipListValue(arg), head)
+	}
+
+	c.addReplyInt(int64(ql.Len()))
+}
+
+// lpopCommand LPOP key [count]
+func lpopCommand(c *Client) {
+	popGenericCommand(c, true)
+}
+
+// rpopCommand RPOP key [count]
+func rpopCommand(c *Client) {
+	popGenericCommand(c, false)
+}
+
+func popGenericCommand(c *Client, head bool) {
+	if len(c.argv) > 3 {
+		c.addReplyErrorFormat(wrongArgCountFmt, strings.ToLower(string(c.argv[0])))
+		return
+	}
+
+	hasCount := len(c.argv) == 3
+	count := int64(1)
+	if hasCount {
+		var ok bool
+		if count, ok = c.getPositiveInt64OrReply(c.argv[2]); !ok {
+			return
+		}
+	}
+
+	key := c.argv[1]
+	ql := c.lookupListOrReply(key, true, func() {
+		if hasCount {
+			c.addReplyNullArray()
+		} else {
+			c.addReplyNull()
+		}
+	})
+	if ql == nil {
+		return
+	}
+
+	if !hasCount {
+		c.addReplyValue(listTypePop(ql, head))
+	} else {
+		if count > int64(ql.Len()) {
+			count = int64(ql.Len())
+		}
+		c.addReplyArrayLen(int(count))
+		for i := int64(0); i < count; i++ {
+			c.addReplyValue(listTypePop(ql, head))
+		}
+	}
+
+	c.deleteIfEmpty(key, ql)
+}
+
+// llenCommand LLEN key
+func llenCommand(c *Client) {
+	ql := c.lookupListOrReply(c.argv[1], false, func() {
+		c.addReplyInt(0)
+	})
+	if ql == nil {
+		return
+	}
+
+	c.addReplyInt(int64(ql.Len()))
+}
+
+// lrangeCommand LRANGE key start stop
+func lrangeCommand(c *Client) {
+	start, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+	end, ok := c.getInt64OrReply(c.argv[3])
+	if !ok {
+		return
+	}
+
+	ql := c.lookupListOrReply(c.argv[1], false, func() {
+		c.addReplyArrayLen(0)
+	})
+	if ql == nil {
+		return
+	}
+
+	start, end, ok = normalizeRange(start, end, int64(ql.Len()))
+	if !ok {
+		c.addReplyArrayLen(0)
+		return
+	}
+
+	c.addReplyArrayLen(int(end - start + 1))
+	it := ql.Iterator(int(start), true)
+	for i := start; i <= end && it.Next(); i++ {
+		c.addReplyValue(it.Value())
+	}
+}
+
+// normalizeRange converts [start, end] with negative indexes to a valid range of a list with specific
+// length, false is returned when the range is empty.
+func normalizeRange(start, end, length int64) (int64, int64, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	if start > end || start >= length {
+		return 0, 0, false
+	}
+	if end >= length {
+		end = length - 1
+	}
+
+	return start, end, true
+}
+
+// lindexCommand LINDEX key index
+func lindexCommand(c *Client) {
+	index, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+
+	ql := c.lookupListOrReply(c.argv[1], false, c.addReplyNull)
+	if ql == nil {
+		return
+	}
+
+	val, ok := ql.Index(int(index))
+	if !ok {
+		c.addReplyNull()
+		return
+	}
+	c.addReplyValue(val)
+}
+
+// lsetCommand LSET key index element
+func lsetCommand(c *Client) {
+	index, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+
+	ql := c.lookupListOrReply(c.argv[1], true, func() {
+		c.addReplyError(noSuchKeyErr)
+	})
+	if ql == nil {
+		return
+	}
+
+	if err := ql.Replace(int(index), zipListValue(c.argv[3])); err != nil {
+		c.addReplyError(outOfRangeErr)
+		return
+	}
+	c.addReplyOK()
+}
+
+// linsertCommand LINSERT key BEFORE|AFTER pivot element
+func linsertCommand(c *Client) {
+	var after bool
+	switch strings.ToLower(string(c.argv[2])) {
+	case "before":
+		after = false
+	case "after":
+		after = true
+	default:
+		c.addReplyError(syntaxErr)
+		return
+	}
+
+	ql := c.lookupListOrReply(c.argv[1], true, func() {
+		c.addReplyInt(0)
+	})
+	if ql == nil {
+		return
+	}
+
+	pivot := &rs.RedisString{Content: c.argv[3]}
+	for it := ql.Iterator(0, true); it.Next(); {
+		if it.Equal(pivot) {
+			_ = it.Insert(zipListValue(c.argv[4]), after)
+			c.addReplyInt(int64(ql.Len()))
+			return
+		}
+	}
+
+	c.addReplyInt(-1)
+}
+
+// lremCommand LREM key count element
+func lremCommand(c *Client) {
+	count, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+
+	key := c.argv[1]
+	ql := c.lookupListOrReply(key, true, func() {
+		c.addReplyInt(0)
+	})
+	if ql == nil {
+		return
+	}
+
+	// count 为负数时从尾部开始删除
+	it := ql.Iterator(0, true)
+	if count < 0 {
+		it = ql.Iterator(-1, false)
+		count = -count
+	}
+
+	elem := &rs.RedisString{Content: c.argv[3]}
+	removed := int64(0)
+	for it.Next() {
+		if !it.Equal(elem) {
+			continue
+		}
+
+		_ = it.Delete()
+		removed++
+		if removed == count {
+			break
+		}
+	}
+
+	c.deleteIfEmpty(key, ql)
+	c.addReplyInt(removed)
+}
+
+// ltrimCommand LTRIM key start stop
+func ltrimCommand(c *Client) {
+	start, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+	end, ok := c.getInt64OrReply(c.argv[3])
+	if !ok {
+		return
+	}
+
+	key := c.argv[1]
+	ql := c.lookupListOrReply(key, true, c.addReplyOK)
+	if ql == nil {
+		return
+	}
+
+	length := int64(ql.Len())
+	start, end, ok = normalizeRange(start, end, length)
+	if !ok {
+		// 范围为空时删除所有元素
+		start, end = length, length-1
+	}
+
+	ql.DeleteRange(0, int(start))
+	ql.DeleteRange(int(end-start+1), int(length-end-1))
+
+	c.deleteIfEmpty(key, ql)
+	c.addReplyOK()
+}
+
+// lposCommand LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func lposCommand(c *Client) {
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 3; i < len(c.argv); i += 2 {
+		if i+1 >= len(c.argv) {
+			c.addReplyError(syntaxErr)
+			return
+		}
+
+		num, ok := c.getInt64OrReply(c.argv[i+1])
+		if !ok {
+			return
+		}
+		switch strings.ToLower(string(c.argv[i])) {
+		case "rank":
+			if num == 0 {
+				c.addReplyError("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+				return
+			}
+			if num == utils.INT64_MIN {
+				c.addReplyError("ERR value is out of range")
+				return
+			}
+			rank = num
+		case "count":
+			if num < 0 {
+				c.addReplyError("ERR COUNT can't be negative")
+				return
+			}
+			count = num
+		case "maxlen":
+			if num < 0 {
+				c.addReplyError("ERR MAXLEN can't be negative")
+				return
+			}
+			maxLen = num
+		default:
+			c.addReplyError(syntaxErr)
+			return
+		}
+	}
+
+	ql := c.lookupListOrReply(c.argv[1], false, func() {
+		if count >= 0 {
+			c.addReplyArrayLen(0)
+		} else {
+			c.addReplyNull()
+		}
+	})
+	if ql == nil {
+		return
+	}
+
+	// rank 为负数时从尾部开始查找
+	forward, index, step := true, int64(0), int64(1)
+	it := ql.Iterator(0, true)
+	if rank < 0 {
+		rank = -rank
+		forward, index, step = false, int64(ql.Len()-1), -1
+		it = ql.Iterator(-1, forward)
+	}
+
+	elem := &rs.RedisString{Content: c.argv[2]}
+	var positions []int64
+	matches := int64(0)
+	for visited := int64(0); (maxLen == 0 || visited < maxLen) && it.Next(); visited++ {
+		if it.Equal(elem) {
+			matches++
+			if matches >= rank {
+				positions = append(positions, index)
+				// count 为 0 时返回所有匹配的位置
+				if count < 0 || (count > 0 && int64(len(positions)) == count) {
+					break
+				}
+			}
+		}
+		index += step
+	}
+
+	if count >= 0 {
+		c.addReplyArrayLen(len(positions))
+		for _, pos := range positions {
+			c.addReplyInt(pos)
+		}
+	} else if len(positions) > 0 {
+		c.addReplyInt(positions[0])
+	} else {
+		c.addReplyNull()
+	}
+}
+
+// lmoveCommand LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func lmoveCommand(c *Client) {
+	fromHead, ok := c.parseWhere(c.argv[3])
+	if !ok {
+		return
+	}
+	toHead, ok := c.parseWhere(c.argv[4])
+	if !ok {
+		return
+	}
+
+	lmoveGeneric(c, c.argv[1], c.argv[2], fromHead, toHead)
+}
+
+// parseWhere parses LEFT or RIGHT, true is returned for LEFT.
+func (c *Client) parseWhere(arg []byte) (bool, bool) {
+	switch strings.ToLower(string(arg)) {
+	case "left":
+		return true, true
+	case "right":
+		return false, true
+	default:
+		c.addReplyError(syntaxErr)
+		return false, false
+	}
+}
+
+func lmoveGeneric(c *Client, source, destination []byte, fromHead, toHead bool) {
+	src := c.lookupListOrReply(source, true, c.addReplyNull)
+	if src == nil {
+		return
+	}
+
+	// 先检查 destination 的类型，避免元素被弹出后无法写入
+	dstObj := c.db.lookupKeyWrite(destination)
+	if dstObj != nil && c.checkType(dstObj, ObjList) {
+		return
+	}
+	if dstObj == nil {
+		dstObj = createQuickListObject(c.server.config.listMaxZiplistSize, c.server.config.listCompressDepth)
+		c.db.dbAdd(destination, dstObj)
+	}
+
+	val := listTypePop(src, fromHead)
+	listTypePush(dstObj.Ptr.(*quicklist.QuickList), val, toHead)
+	c.addReplyValue(val)
+
+	c.deleteIfEmpty(source, src)
+}
+
+// blpopCommand BLPOP key [key ...] timeout
+func blpopCommand(c *Client) {
+	blockingPopGenericCommand(c, true)
+}
+
+// brpopCommand BRPOP key [key ...] timeout
+func brpopCommand(c *Client) {
+	blockingPopGenericCommand(c, false)
+}
+
+func blockingPopGenericCommand(c *Client, head bool) {
+	timeout, ok := c.getTimeoutOrReply(c.argv[len(c.argv)-1])
+	if !ok {
+		return
+	}
+
+	keys := c.argv[1 : len(c.argv)-1]
+	for _, key := range keys {
+		obj := c.db.lookupKeyWrite(key)
+		if obj == nil {
+			continue
+		}
+		if c.checkType(obj, ObjList) {
+			return
+		}
+
+		ql := obj.Ptr.(*quicklist.QuickList)
+		c.addReplyArrayLen(2)
+		c.addReplyBulk(key)
+		c.addReplyValue(listTypePop(ql, head))
+		c.deleteIfEmpty(key, ql)
+		return
+	}
+
+	// 事务中的阻塞命令在 key 都为空时直接返回
+	if c.flags&clientDenyBlocking != 0 {
+		c.addReplyNullArray()
+		return
+	}
+	c.blockForKeys(keys, timeout)
+}
+
+// blmoveCommand BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func blmoveCommand(c *Client) {
+	fromHead, ok := c.parseWhere(c.argv[3])
+	if !ok {
+		return
+	}
+	toHead, ok := c.parseWhere(c.argv[4])
+	if !ok {
+		return
+	}
+	timeout, ok := c.getTimeoutOrReply(c.argv[5])
+	if !ok {
+		return
+	}
+
+	source := c.argv[1]
+	if c.db.lookupKeyWrite(source) == nil {
+		if c.flags&clientDenyBlocking != 0 {
+			c.addReplyNull()
+			return
+		}
+		c.blockForKeys([][]byte{source}, timeout)
+		return
+	}
+
+	lmoveGeneric(c, source, c.argv[2], fromHead, toHead)
+}
+
+// lmpopCommand LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func lmpopCommand(c *Client) {
+	mpopGenericCommand(c, 1, false, 0)
+}
+
+// blmpopCommand BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func blmpopCommand(c *Client) {
+	timeout, ok := c.getTimeoutOrReply(c.argv[1])
+	if !ok {
+		return
+	}
+
+	mpopGenericCommand(c, 2, true, timeout)
+}
+
+// mpopGenericCommand pops elements from the first non-empty list, arguments start from
+// c.argv[numKeysIdx] are "numkeys key [key ...] LEFT|RIGHT [COUNT count]".
+func mpopGenericCommand(c *Client, numKeysIdx int, blocking bool, timeout time.Duration) {
+	numKeys, ok := utils.String2Int64(c.argv[numKeysIdx])
+	if !ok || numKeys <= 0 {
+		c.addReplyError("ERR numkeys should be greater than 0")
+		return
+	}
+	// numkeys 之后至少还有 LEFT|RIGHT
+	if numKeys > int64(len(c.argv)-numKeysIdx-2) {
+		c.addReplyError(syntaxErr)
+		return
+	}
+
+	keys := c.argv[numKeysIdx+1 : numKeysIdx+1+int(numKeys)]
+	whereIdx := numKeysIdx + 1 + int(numKeys)
+	head, ok := c.parseWhere(c.argv[whereIdx])
+	if !ok {
+		return
+	}
+
+	count := int64(-1)
+	for i := whereIdx + 1; i < len(c.argv); i++ {
+		if strings.EqualFold(string(c.argv[i]), "count") && i+1 < len(c.argv) && count == -1 {
+			count, ok = utils.String2Int64(c.argv[i+1])
+			if !ok || count <= 0 {
+				c.addReplyError("ERR count should be greater than 0")
+				return
+			}
+			i++
+		} else {
+			c.addReplyError(syntaxErr)
+			return
+		}
+	}
+	if count == -1 {
+		count = 1
+	}
+
+	for _, key := range keys {
+		obj := c.db.lookupKeyWrite(key)
+		if obj == nil {
+			continue
+		}
+		if c.checkType(obj, ObjList) {
+			return
+		}
+
+		ql := obj.Ptr.(*quicklist.QuickList)
+		if count > int64(ql.Len()) {
+			count = int64(ql.Len())
+		}
+		c.addReplyArrayLen(2)
+		c.addReplyBulk(key)
+		c.addReplyArrayLen(int(count))
+		for i := int64(0); i < count; i++ {
+			c.addReplyValue(listTypePop(ql, head))
+		}
+		c.deleteIfEmpty(key, ql)
+		return
+	}
+
+	if !blocking || c.flags&clientDenyBlocking != 0 {
+		c.addReplyNullArray()
+		return
+	}
+	c.blockForKeys(keys, timeout)
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/WANGgbin/tiny_redis/data_type/quicklist"
+)
+
+// arrayReply 构造由 bulk string 组成的数组回复
+func arrayReply(elems ...string) string {
+	reply := "*" + strconv.Itoa(len(elems)) + "\r\n"
+	for _, elem := range elems {
+		reply += "$" + strconv.Itoa(len(elem)) + "\r\n" + elem + "\r\n"
+	}
+	return reply
+}
+
+func TestListPushPop(t *testing.T) {
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LPUSH", "k", "a", "b"}, want: ":2\r\n"},
+		{args: []string{"RPUSH", "k", "1", "-2", "abc"}, want: ":5\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("b", "a", "1", "-2", "abc")},
+		{args: []string{"LLEN", "k"}, want: ":5\r\n"},
+		{args: []string{"LPOP", "k"}, want: "$1\r\nb\r\n"},
+		{args: []string{"RPOP", "k"}, want: "$3\r\nabc\r\n"},
+		{args: []string{"RPOP", "k", "2"}, want: arrayReply("-2", "1")},
+		{args: []string{"LPOP", "k", "0"}, want: "*0\r\n"},
+		{args: []string{"LPOP", "k", "5"}, want: arrayReply("a")},
+		// 列表为空后 key 被删除
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+		{args: []string{"LPOP", "k"}, want: "$-1\r\n"},
+		{args: []string{"LPOP", "k", "1"}, want: "*-1\r\n"},
+		{args: []string{"LPOP", "k", "-1"}, want: "-ERR value is out of range, must be positive\r\n"},
+		{args: []string{"LPOP", "k", "a"}, want: "-ERR value is not an integer or out of range\r\n"},
+		{args: []string{"LPOP", "k", "1", "2"}, want: "-ERR wrong number of arguments for 'lpop' command\r\n"},
+	})
+
+	if c.db.lookupKeyRead([]byte("k")) != nil {
+		t.Fatalf("empty list should be deleted")
+	}
+}
+
+func TestListWrongType(t *testing.T) {
+	c := newTestClient(nil)
+	c.db.dbAdd([]byte("s"), &Object{Type: ObjString})
+
+	for _, args := range [][]string{
+		{"LPUSH", "s", "a"},
+		{"RPOP", "s"},
+		{"LLEN", "s"},
+		{"LRANGE", "s", "0", "-1"},
+		{"LINDEX", "s", "0"},
+		{"LSET", "s", "0", "a"},
+		{"LINSERT", "s", "BEFORE", "a", "b"},
+		{"LREM", "s", "0", "a"},
+		{"LTRIM", "s", "0", "1"},
+		{"LPOS", "s", "a"},
+		{"LMOVE", "s", "d", "LEFT", "LEFT"},
+		{"LMPOP", "1", "s", "LEFT"},
+		{"BLPOP", "s", "0"},
+		{"BLMOVE", "s", "d", "LEFT", "LEFT", "0"},
+		{"BLMPOP", "0", "1", "s", "LEFT"},
+	} {
+		if got := c.exec(args...); got != "-"+wrongTypeErr+"\r\n" {
+			t.Errorf("%v = %q, want WRONGTYPE error", args, got)
+		}
+	}
+
+	// destination 类型错误时，source 中的元素不能被弹出
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"RPUSH", "l", "a"}, want: ":1\r\n"},
+		{args: []string{"LMOVE", "l", "s", "LEFT", "LEFT"}, want: "-" + wrongTypeErr + "\r\n"},
+		{args: []string{"LLEN", "l"}, want: ":1\r\n"},
+	})
+}
+
+func TestLrangeAndLindex(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a", "b", "c", "d", "e")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LRANGE", "k", "1", "2"}, want: arrayReply("b", "c")},
+		{args: []string{"LRANGE", "k", "-2", "-1"}, want: arrayReply("d", "e")},
+		{args: []string{"LRANGE", "k", "-100", "100"}, want: arrayReply("a", "b", "c", "d", "e")},
+		{args: []string{"LRANGE", "k", "3", "1"}, want: "*0\r\n"},
+		{args: []string{"LRANGE", "k", "5", "10"}, want: "*0\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-6"}, want: "*0\r\n"},
+		{args: []string{"LRANGE", "missing", "0", "-1"}, want: "*0\r\n"},
+		{args: []string{"LRANGE", "k", "a", "1"}, want: "-" + notIntegerErr + "\r\n"},
+		{args: []string{"LINDEX", "k", "0"}, want: "$1\r\na\r\n"},
+		{args: []string{"LINDEX", "k", "-1"}, want: "$1\r\ne\r\n"},
+		{args: []string{"LINDEX", "k", "5"}, want: "$-1\r\n"},
+		{args: []string{"LINDEX", "k", "-6"}, want: "$-1\r\n"},
+		{args: []string{"LINDEX", "missing", "0"}, want: "$-1\r\n"},
+	})
+}
+
+func TestLset(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a", "b", "c")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LSET", "k", "1", "100"}, want: "+OK\r\n"},
+		{args: []string{"LSET", "k", "-1", "z"}, want: "+OK\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("a", "100", "z")},
+		{args: []string{"LSET", "k", "3", "x"}, want: "-ERR index out of range\r\n"},
+		{args: []string{"LSET", "missing", "0", "x"}, want: "-ERR no such key\r\n"},
+	})
+}
+
+func TestLinsert(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a", "1", "c")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LINSERT", "k", "BEFORE", "a", "x"}, want: ":4\r\n"},
+		{args: []string{"LINSERT", "k", "after", "1", "y"}, want: ":5\r\n"},
+		{args: []string{"LINSERT", "k", "AFTER", "c", "z"}, want: ":6\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("x", "a", "1", "y", "c", "z")},
+		{args: []string{"LINSERT", "k", "BEFORE", "missing", "x"}, want: ":-1\r\n"},
+		{args: []string{"LINSERT", "missing", "BEFORE", "a", "x"}, want: ":0\r\n"},
+		{args: []string{"LINSERT", "k", "MIDDLE", "a", "x"}, want: "-ERR syntax error\r\n"},
+	})
+}
+
+func TestLrem(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a", "b", "a", "c", "a", "b")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LREM", "k", "-1", "a"}, want: ":1\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("a", "b", "a", "c", "b")},
+		{args: []string{"LREM", "k", "1", "b"}, want: ":1\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("a", "a", "c", "b")},
+		{args: []string{"LREM", "k", "0", "a"}, want: ":2\r\n"},
+		{args: []string{"LREM", "k", "0", "x"}, want: ":0\r\n"},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply("c", "b")},
+		{args: []string{"LREM", "k", "0", "c"}, want: ":1\r\n"},
+		{args: []string{"LREM", "k", "5", "b"}, want: ":1\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":0\r\n"},
+		{args: []string{"LREM", "missing", "0", "a"}, want: ":0\r\n"},
+	})
+}
+
+func TestLtrim(t *testing.T) {
+	tests := []struct {
+		start string
+		end   string
+		want  string
+	}{
+		{start: "1", end: "3", want: arrayReply("b", "c", "d")},
+		{start: "-2", end: "-1", want: arrayReply("d", "e")},
+		{start: "0", end: "100", want: arrayReply("a", "b", "c", "d", "e")},
+		{start: "3", end: "1", want: "*0\r\n"},
+		{start: "5", end: "10", want: "*0\r\n"},
+	}
+	for _, tt := range tests {
+		c := newTestClient(nil)
+		c.exec("RPUSH", "k", "a", "b", "c", "d", "e")
+		runCmdCases(t, c, []cmdCase{
+			{args: []string{"LTRIM", "k", tt.start, tt.end}, want: "+OK\r\n"},
+			{args: []string{"LRANGE", "k", "0", "-1"}, want: tt.want},
+		})
+	}
+
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LTRIM", "missing", "0", "1"}, want: "+OK\r\n"},
+	})
+}
+
+func TestLpos(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "k", "a", "b", "c", "1", "2", "3", "c", "c")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LPOS", "k", "c"}, want: ":2\r\n"},
+		{args: []string{"LPOS", "k", "1"}, want: ":3\r\n"},
+		{args: []string{"LPOS", "k", "x"}, want: "$-1\r\n"},
+		{args: []string{"LPOS", "k", "c", "RANK", "2"}, want: ":6\r\n"},
+		{args: []string{"LPOS", "k", "c", "RANK", "-1"}, want: ":7\r\n"},
+		{args: []string{"LPOS", "k", "c", "COUNT", "2"}, want: "*2\r\n:2\r\n:6\r\n"},
+		{args: []string{"LPOS", "k", "c", "COUNT", "0"}, want: "*3\r\n:2\r\n:6\r\n:7\r\n"},
+		{args: []string{"LPOS", "k", "c", "RANK", "-2", "COUNT", "0"}, want: "*2\r\n:6\r\n:2\r\n"},
+		{args: []string{"LPOS", "k", "c", "COUNT", "0", "MAXLEN", "7"}, want: "*2\r\n:2\r\n:6\r\n"},
+		{args: []string{"LPOS", "k", "x", "COUNT", "1"}, want: "*0\r\n"},
+		{args: []string{"LPOS", "missing", "x"}, want: "$-1\r\n"},
+		{args: []string{"LPOS", "missing", "x", "COUNT", "1"}, want: "*0\r\n"},
+		{args: []string{"LPOS", "k", "c", "RANK", "0"}, want: "-ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list\r\n"},
+		{args: []string{"LPOS", "k", "c", "COUNT", "-1"}, want: "-ERR COUNT can't be negative\r\n"},
+		{args: []string{"LPOS", "k", "c", "MAXLEN", "-1"}, want: "-ERR MAXLEN can't be negative\r\n"},
+		{args: []string{"LPOS", "k", "c", "RANK"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"LPOS", "k", "c", "FOO", "1"}, want: "-ERR syntax error\r\n"},
+	})
+}
+
+func TestLmove(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "src", "a", "b", "c")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LMOVE", "src", "dst", "LEFT", "RIGHT"}, want: "$1\r\na\r\n"},
+		{args: []string{"LMOVE", "src", "dst", "RIGHT", "LEFT"}, want: "$1\r\nc\r\n"},
+		{args: []string{"LRANGE", "dst", "0", "-1"}, want: arrayReply("c", "a")},
+		// 同一个 key 时相当于旋转
+		{args: []string{"LMOVE", "dst", "dst", "LEFT", "RIGHT"}, want: "$1\r\nc\r\n"},
+		{args: []string{"LRANGE", "dst", "0", "-1"}, want: arrayReply("a", "c")},
+		{args: []string{"LMOVE", "src", "dst", "LEFT", "LEFT"}, want: "$1\r\nb\r\n"},
+		{args: []string{"LLEN", "src"}, want: ":0\r\n"},
+		{args: []string{"LMOVE", "src", "dst", "LEFT", "LEFT"}, want: "$-1\r\n"},
+		{args: []string{"LMOVE", "dst", "x", "UP", "LEFT"}, want: "-ERR syntax error\r\n"},
+	})
+
+	if c.db.lookupKeyRead([]byte("src")) != nil {
+		t.Fatalf("empty source should be deleted")
+	}
+}
+
+// TestListLargeValues 元素较多时 quicklist 由多个节点组成
+func TestLmpop(t *testing.T) {
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"RPUSH", "k2", "a", "b", "c"}, want: ":3\r\n"},
+		{args: []string{"LMPOP", "2", "k1", "k2", "LEFT"}, want: "*2\r\n$2\r\nk2\r\n" + arrayReply("a")},
+		{args: []string{"LMPOP", "2", "k1", "k2", "RIGHT", "COUNT", "5"}, want: "*2\r\n$2\r\nk2\r\n" + arrayReply("c", "b")},
+		{args: []string{"LMPOP", "2", "k1", "k2", "LEFT"}, want: "*-1\r\n"},
+		{args: []string{"LMPOP", "0", "k1", "LEFT"}, want: "-ERR numkeys should be greater than 0\r\n"},
+		{args: []string{"LMPOP", "2", "k1", "LEFT"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"LMPOP", "1", "k1", "UP"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"LMPOP", "1", "k1", "LEFT", "COUNT", "0"}, want: "-ERR count should be greater than 0\r\n"},
+		{args: []string{"LMPOP", "1", "k1", "LEFT", "COUNT", "1", "COUNT", "1"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"LMPOP", "1", "k1", "LEFT", "COUNT"}, want: "-ERR syntax error\r\n"},
+	})
+}
+
+func TestListLargeValues(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("CONFIG", "SET", "list-max-ziplist-size", "4")
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		c.exec("RPUSH", "k", strconv.Itoa(i))
+		want = append(want, strconv.Itoa(i))
+	}
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply(want...)},
+		{args: []string{"LINSERT", "k", "AFTER", "25", "x"}, want: ":51\r\n"},
+		{args: []string{"LINDEX", "k", "26"}, want: "$1\r\nx\r\n"},
+		{args: []string{"LTRIM", "k", "10", "-11"}, want: "+OK\r\n"},
+		{args: []string{"LLEN", "k"}, want: ":31\r\n"},
+		{args: []string{"LPOS", "k", "x"}, want: ":16\r\n"},
+	})
+}
+
+func TestListCompressed(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("CONFIG", "SET", "list-max-ziplist-size", "4", "list-compress-depth", "1")
+
+	var want []string
+	for i := 0; i < 100; i++ {
+		elem := strings.Repeat("feed entry ", 10) + strconv.Itoa(i)
+		c.exec("RPUSH", "k", elem)
+		want = append(want, elem)
+	}
+
+	ql := c.db.lookupKeyRead([]byte("k")).Ptr.(*quicklist.QuickList)
+	if ql.CompressDepth() != 1 {
+		t.Fatalf("CompressDepth() = %d, want 1", ql.CompressDepth())
+	}
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"CONFIG", "GET", "list-compress-depth"}, want: arrayReply("list-compress-depth", "1")},
+		{args: []string{"LRANGE", "k", "0", "-1"}, want: arrayReply(want...)},
+		{args: []string{"LINDEX", "k", "50"}, want: "$" + strconv.Itoa(len(want[50])) + "\r\n" + want[50] + "\r\n"},
+		{args: []string{"LSET", "k", "50", "x"}, want: "+OK\r\n"},
+		{args: []string{"LREM", "k", "0", want[60]}, want: ":1\r\n"},
+		{args: []string{"LRANGE", "k", "49", "51"}, want: arrayReply(want[49], "x", want[51])},
+		{args: []string{"LPOS", "k", want[61]}, want: ":60\r\n"},
+		{args: []string{"CONFIG", "SET", "list-compress-depth", "-1"}, want: "-ERR CONFIG SET failed (possibly related to argument 'list-compress-depth') - argument must be between 0 and 65535 inclusive\r\n"},
+	})
+}
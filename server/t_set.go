@@ -0,0 +1,766 @@
+package server
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/WANGgbin/tiny_redis/data_type/dict"
+	"github.com/WANGgbin/tiny_redis/data_type/intset"
+	"github.com/WANGgbin/tiny_redis/utils"
+)
+
+// set 类型的元素都是整数时使用 intset 实现；加入非整数元素或者元素个数超过 set-max-intset-entries
+// 时转换为 dict，之后不再转换回 intset。dict 的 key 为元素，value 为 nil。
+
+// lookupSetOrReply gets the set of key, nil is returned when key doesn't exist or is not a set.
+// reply is called when key doesn't exist.
+func (c *Client) lookupSetOrReply(key []byte, write bool, reply func()) *Object {
+	var obj *Object
+	if write {
+		obj = c.db.lookupKeyWrite(key)
+	} else {
+		obj = c.db.lookupKeyRead(key)
+	}
+
+	if obj == nil {
+		reply()
+		return nil
+	}
+	if c.checkType(obj, ObjSet) {
+		return nil
+	}
+
+	return obj
+}
+
+// setTypeCreate creates an empty set suitable for storing member.
+func setTypeCreate(member []byte) *Object {
+	if _, ok := utils.String2Int64(member); ok {
+		return createIntSetObject()
+	}
+	return createDictObject(ObjSet)
+}
+
+func setTypeSize(obj *Object) int {
+	if obj.Encoding == EncodingIntSet {
+		return obj.Ptr.(*intset.IntSet).Len()
+	}
+	return obj.Ptr.(*dict.Dict).Len()
+}
+
+// setTypeAdd adds member to the set, false is returned when member already exists. The set is
+// converted to dict when member is not an integer or the set is too large for intset.
+func (c *Client) setTypeAdd(obj *Object, member []byte) bool {
+	if obj.Encoding == EncodingIntSet {
+		if v, ok := utils.String2Int64(member); ok {
+			is := obj.Ptr.(*intset.IntSet)
+			if !is.Add(v) {
+				return false
+			}
+			if is.Len() > c.server.config.setMaxIntsetEntries {
+				setTypeConvert(obj)
+			}
+			return true
+		}
+		setTypeConvert(obj)
+	}
+
+	return obj.Ptr.(*dict.Dict).Add(string(member), nil)
+}
+
+// setTypeRemove removes member from the set, false is returned when member doesn't exist.
+func setTypeRemove(obj *Object, member []byte) bool {
+	if obj.Encoding == EncodingIntSet {
+		v, ok := utils.String2Int64(member)
+		return ok && obj.Ptr.(*intset.IntSet).Remove(v)
+	}
+
+	_, ok := obj.Ptr.(*dict.Dict).Delete(string(member))
+	return ok
+}
+
+func setTypeIsMember(obj *Object, member []byte) bool {
+	if obj.Encoding == EncodingIntSet {
+		v, ok := utils.String2Int64(member)
+		return ok && obj.Ptr.(*intset.IntSet).Find(v)
+	}
+
+	_, ok := obj.Ptr.(*dict.Dict).Get(string(member))
+	return ok
+}
+
+// setTypeForEach calls fn for every member until fn returns false, the set must not be modified in fn.
+func setTypeForEach(obj *Object, fn func(member []byte) bool) {
+	if obj.Encoding == EncodingIntSet {
+		is := obj.Ptr.(*intset.IntSet)
+		for i := 0; i < is.Len(); i++ {
+			v, _ := is.Get(i)
+			if !fn(strconv.AppendInt(nil, v, 10)) {
+				return
+			}
+		}
+		return
+	}
+
+	obj.Ptr.(*dict.Dict).ForEach(func(key string, _ interface{}) bool {
+		return fn([]byte(key))
+	})
+}
+
+// setTypeRandomElement returns a random member, the set must not be empty.
+func setTypeRandomElement(obj *Object) []byte {
+	if obj.Encoding == EncodingIntSet {
+		return strconv.AppendInt(nil, obj.Ptr.(*intset.IntSet).Random(), 10)
+	}
+
+	key, _, _ := obj.Ptr.(*dict.Dict).RandomEntry()
+	return []byte(key)
+}
+
+// setTypeConvert converts a set encoded as intset to dict.
+func setTypeConvert(obj *Object) {
+	if obj.Encoding != EncodingIntSet {
+		return
+	}
+
+	d := dict.CreateDict()
+	setTypeForEach(obj, func(member []byte) bool {
+		d.Add(string(member), nil)
+		return true
+	})
+	obj.Encoding = EncodingHashTable
+	obj.Ptr = d
+}
+
+// saddCommand SADD key member [member ...]
+func saddCommand(c *Client) {
+	key := c.argv[1]
+	obj := c.db.lookupKeyWrite(key)
+	if obj == nil {
+		obj = setTypeCreate(c.argv[2])
+		c.db.dbAdd(key, obj)
+	} else if c.checkType(obj, ObjSet) {
+		return
+	}
+
+	added := 0
+	for _, member := range c.argv[2:] {
+		if c.setTypeAdd(obj, member) {
+			added++
+		}
+	}
+	c.addReplyInt(int64(added))
+}
+
+// sremCommand SREM key member [member ...]
+func sremCommand(c *Client) {
+	key := c.argv[1]
+	obj := c.lookupSetOrReply(key, true, func() {
+		c.addReplyInt(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	removed := 0
+	for _, member := range c.argv[2:] {
+		if setTypeRemove(obj, member) {
+			removed++
+			if setTypeSize(obj) == 0 {
+				c.db.dbDelete(key)
+				break
+			}
+		}
+	}
+	c.addReplyInt(int64(removed))
+}
+
+// sismemberCommand SISMEMBER key member
+func sismemberCommand(c *Client) {
+	obj := c.lookupSetOrReply(c.argv[1], false, func() {
+		c.addReplyInt(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	if setTypeIsMember(obj, c.argv[2]) {
+		c.addReplyInt(1)
+	} else {
+		c.addReplyInt(0)
+	}
+}
+
+// smismemberCommand SMISMEMBER key member [member ...]
+func smismemberCommand(c *Client) {
+	obj := c.db.lookupKeyRead(c.argv[1])
+	if obj != nil && c.checkType(obj, ObjSet) {
+		return
+	}
+
+	members := c.argv[2:]
+	c.addReplyArrayLen(len(members))
+	for _, member := range members {
+		if obj != nil && setTypeIsMember(obj, member) {
+			c.addReplyInt(1)
+		} else {
+			c.addReplyInt(0)
+		}
+	}
+}
+
+// smembersCommand SMEMBERS key
+func smembersCommand(c *Client) {
+	obj := c.lookupSetOrReply(c.argv[1], false, func() {
+		c.addReplyArrayLen(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	c.addReplyArrayLen(setTypeSize(obj))
+	setTypeForEach(obj, func(member []byte) bool {
+		c.addReplyBulk(member)
+		return true
+	})
+}
+
+// scardCommand SCARD key
+func scardCommand(c *Client) {
+	obj := c.lookupSetOrReply(c.argv[1], false, func() {
+		c.addReplyInt(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	c.addReplyInt(int64(setTypeSize(obj)))
+}
+
+// spopCommand SPOP key [count]
+func spopCommand(c *Client) {
+	if len(c.argv) == 3 {
+		spopWithCountCommand(c)
+		return
+	}
+	if len(c.argv) > 3 {
+		c.addReplyError(syntaxErr)
+		return
+	}
+
+	key := c.argv[1]
+	obj := c.lookupSetOrReply(key, true, c.addReplyNull)
+	if obj == nil {
+		return
+	}
+
+	member := setTypeRandomElement(obj)
+	setTypeRemove(obj, member)
+	if setTypeSize(obj) == 0 {
+		c.db.dbDelete(key)
+	}
+	c.addReplyBulk(member)
+}
+
+func spopWithCountCommand(c *Client) {
+	count, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+	if count < 0 {
+		c.addReplyError(notPositiveErr)
+		return
+	}
+
+	key := c.argv[1]
+	obj := c.lookupSetOrReply(key, true, func() {
+		c.addReplyArrayLen(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	size := int64(setTypeSize(obj))
+	if count >= size {
+		// 返回所有元素并删除 key
+		smembersCommand(c)
+		c.db.dbDelete(key)
+		return
+	}
+
+	c.addReplyArrayLen(int(count))
+	for i := int64(0); i < count; i++ {
+		member := setTypeRandomElement(obj)
+		setTypeRemove(obj, member)
+		c.addReplyBulk(member)
+	}
+}
+
+// srandmemberCommand SRANDMEMBER key [count]
+func srandmemberCommand(c *Client) {
+	if len(c.argv) == 3 {
+		srandmemberWithCountCommand(c)
+		return
+	}
+	if len(c.argv) > 3 {
+		c.addReplyError(syntaxErr)
+		return
+	}
+
+	obj := c.lookupSetOrReply(c.argv[1], false, c.addReplyNull)
+	if obj == nil {
+		return
+	}
+
+	c.addReplyBulk(setTypeRandomElement(obj))
+}
+
+func srandmemberWithCountCommand(c *Client) {
+	count, ok := c.getInt64OrReply(c.argv[2])
+	if !ok {
+		return
+	}
+
+	// count 为负数时允许重复的元素
+	unique := true
+	if count < 0 {
+		if count == math.MinInt64 {
+			c.addReplyError("ERR value is out of range")
+			return
+		}
+		count, unique = -count, false
+	}
+
+	obj := c.lookupSetOrReply(c.argv[1], false, func() {
+		c.addReplyArrayLen(0)
+	})
+	if obj == nil {
+		return
+	}
+
+	if !unique {
+		c.addReplyArrayLen(int(count))
+		for i := int64(0); i < count; i++ {
+			c.addReplyBulk(setTypeRandomElement(obj))
+		}
+		return
+	}
+
+	size := int64(setTypeSize(obj))
+	if count >= size {
+		smembersCommand(c)
+		return
+	}
+
+	c.addReplyArrayLen(int(count))
+	if obj.Encoding == EncodingHashTable && count*3 <= size {
+		// count 远小于元素个数时，随机选取直到得到 count 个不同的元素
+		picked := make(map[string]struct{}, count)
+		for int64(len(picked)) < count {
+			member := setTypeRandomElement(obj)
+			if _, ok := picked[string(member)]; ok {
+				continue
+			}
+			picked[string(member)] = struct{}{}
+			c.addReplyBulk(member)
+		}
+		return
+	}
+
+	// 否则取出所有元素，随机打乱前 count 个
+	members := make([][]byte, 0, size)
+	setTypeForEach(obj, func(member []byte) bool {
+		members = append(members, member)
+		return true
+	})
+	for i := 0; i < int(count); i++ {
+		j := i + rand.Intn(len(members)-i)
+		members[i], members[j] = members[j], members[i]
+		c.addReplyBulk(members[i])
+	}
+}
+
+// smoveCommand SMOVE source destination member
+func smoveCommand(c *Client) {
+	srcKey, dstKey, member := c.argv[1], c.argv[2], c.argv[3]
+	src := c.lookupSetOrReply(srcKey, true, func() {
+		c.addReplyInt(0)
+	})
+	if src == nil {
+		return
+	}
+	dst := c.db.lookupKeyWrite(dstKey)
+	if dst != nil && c.checkType(dst, ObjSet) {
+		return
+	}
+
+	// source 和 destination 相同时不做修改
+	if src == dst {
+		if setTypeIsMember(src, member) {
+			c.addReplyInt(1)
+		} else {
+			c.addReplyInt(0)
+		}
+		return
+	}
+
+	if !setTypeRemove(src, member) {
+		c.addReplyInt(0)
+		return
+	}
+	if setTypeSize(src) == 0 {
+		c.db.dbDelete(srcKey)
+	}
+
+	if dst == nil {
+		dst = setTypeCreate(member)
+		c.db.dbAdd(dstKey, dst)
+	}
+	c.setTypeAdd(dst, member)
+	c.addReplyInt(1)
+}
+
+// sscanCommand SSCAN key cursor [MATCH pattern] [COUNT count]
+func sscanCommand(c *Client) {
+	cursor, ok := c.parseScanCursorOrReply(c.argv[2])
+	if !ok {
+		return
+	}
+
+	obj := c.lookupSetOrReply(c.argv[1], false, c.addReplyEmptyScan)
+	if obj == nil {
+		return
+	}
+	scanGenericCommand(c, obj, cursor)
+}
+
+// setMember 是集合运算中的元素，intset 中的元素只在需要时才转换为字符串
+type setMember struct {
+	str   []byte
+	num   int64
+	isInt bool
+}
+
+func (m *setMember) bytes() []byte {
+	if m.str == nil {
+		m.str = strconv.AppendInt(nil, m.num, 10)
+	}
+	return m.str
+}
+
+// setTypeForEachMember is like setTypeForEach, but members of intset are not converted to string.
+func setTypeForEachMember(obj *Object, fn func(m *setMember) bool) {
+	if obj.Encoding == EncodingIntSet {
+		is := obj.Ptr.(*intset.IntSet)
+		for i := 0; i < is.Len(); i++ {
+			v, _ := is.Get(i)
+			if !fn(&setMember{num: v, isInt: true}) {
+				return
+			}
+		}
+		return
+	}
+
+	obj.Ptr.(*dict.Dict).ForEach(func(key string, _ interface{}) bool {
+		return fn(&setMember{str: []byte(key)})
+	})
+}
+
+func setTypeHasMember(obj *Object, m *setMember) bool {
+	if obj.Encoding == EncodingIntSet && m.isInt {
+		return obj.Ptr.(*intset.IntSet).Find(m.num)
+	}
+	return setTypeIsMember(obj, m.bytes())
+}
+
+func (c *Client) setTypeAddMember(obj *Object, m *setMember) bool {
+	if obj.Encoding == EncodingIntSet && m.isInt {
+		is := obj.Ptr.(*intset.IntSet)
+		if !is.Add(m.num) {
+			return false
+		}
+		if is.Len() > c.server.config.setMaxIntsetEntries {
+			setTypeConvert(obj)
+		}
+		return true
+	}
+	return c.setTypeAdd(obj, m.bytes())
+}
+
+func setTypeRemoveMember(obj *Object, m *setMember) bool {
+	if obj.Encoding == EncodingIntSet && m.isInt {
+		return obj.Ptr.(*intset.IntSet).Remove(m.num)
+	}
+	return setTypeRemove(obj, m.bytes())
+}
+
+// lookupSets gets the sets of keys, nil is set for keys that don't exist. False is returned when
+// any of keys is not a set.
+func (c *Client) lookupSets(keys [][]byte, write bool) ([]*Object, bool) {
+	sets := make([]*Object, len(keys))
+	for i, key := range keys {
+		var obj *Object
+		if write {
+			obj = c.db.lookupKeyWrite(key)
+		} else {
+			obj = c.db.lookupKeyRead(key)
+		}
+		if obj == nil {
+			continue
+		}
+		if c.checkType(obj, ObjSet) {
+			return nil, false
+		}
+		sets[i] = obj
+	}
+	return sets, true
+}
+
+// storeSetResult stores the result of set operations to dstKey, dstKey is deleted when the result
+// is empty.
+func (c *Client) storeSetResult(dstKey []byte, dst *Object) {
+	size := setTypeSize(dst)
+	if size > 0 {
+		c.db.setKey(dstKey, dst)
+	} else {
+		c.db.dbGenericDelete(dstKey, c.db.config.lazyfreeLazyServerDel)
+	}
+	c.addReplyInt(int64(size))
+}
+
+// sinterGenericCommand computes the intersection of keys. The result is stored to dstKey when
+// dstKey is not nil, only the cardinality is replied when cardOnly is true, limit is the max
+// cardinality to compute and 0 means no limit.
+func sinterGenericCommand(c *Client, keys [][]byte, dstKey []byte, cardOnly bool, limit int64) {
+	sets, ok := c.lookupSets(keys, dstKey != nil)
+	if !ok {
+		return
+	}
+
+	for _, set := range sets {
+		if set != nil {
+			continue
+		}
+		// 有 key 不存在时交集为空
+		switch {
+		case dstKey != nil:
+			c.db.dbGenericDelete(dstKey, c.db.config.lazyfreeLazyServerDel)
+			c.addReplyInt(0)
+		case cardOnly:
+			c.addReplyInt(0)
+		default:
+			c.addReplyArrayLen(0)
+		}
+		return
+	}
+
+	// 从最小的集合开始遍历，并优先检查较小的集合，尽早排除不在交集中的元素
+	sort.SliceStable(sets, func(i, j int) bool {
+		return setTypeSize(sets[i]) < setTypeSize(sets[j])
+	})
+
+	var dst *Object
+	if dstKey != nil {
+		dst = createIntSetObject()
+	}
+	var members [][]byte
+	cardinality := int64(0)
+	setTypeForEachMember(sets[0], func(m *setMember) bool {
+		for _, set := range sets[1:] {
+			// 同一个 key 可能出现多次
+			if set != sets[0] && !setTypeHasMember(set, m) {
+				return true
+			}
+		}
+
+		cardinality++
+		switch {
+		case dst != nil:
+			c.setTypeAddMember(dst, m)
+		case !cardOnly:
+			members = append(members, m.bytes())
+		}
+		return limit == 0 || cardinality < limit
+	})
+
+	switch {
+	case dst != nil:
+		c.storeSetResult(dstKey, dst)
+	case cardOnly:
+		c.addReplyInt(cardinality)
+	default:
+		c.addReplyArrayLen(len(members))
+		for _, member := range members {
+			c.addReplyBulk(member)
+		}
+	}
+}
+
+// sinterCommand SINTER key [key ...]
+func sinterCommand(c *Client) {
+	sinterGenericCommand(c, c.argv[1:], nil, false, 0)
+}
+
+// sinterstoreCommand SINTERSTORE destination key [key ...]
+func sinterstoreCommand(c *Client) {
+	sinterGenericCommand(c, c.argv[2:], c.argv[1], false, 0)
+}
+
+// sintercardCommand SINTERCARD numkeys key [key ...] [LIMIT limit]
+func sintercardCommand(c *Client) {
+	numKeys, ok := utils.String2Int64(c.argv[1])
+	if !ok || numKeys <= 0 {
+		c.addReplyError("ERR numkeys should be greater than 0")
+		return
+	}
+	if numKeys > int64(len(c.argv)-2) {
+		c.addReplyError("ERR Number of keys can't be greater than number of args")
+		return
+	}
+
+	limit := int64(0)
+	for i := 2 + int(numKeys); i < len(c.argv); i++ {
+		if strings.EqualFold(string(c.argv[i]), "limit") && i+1 < len(c.argv) {
+			limit, ok = utils.String2Int64(c.argv[i+1])
+			if !ok || limit < 0 {
+				c.addReplyError("ERR LIMIT can't be negative")
+				return
+			}
+			i++
+		} else {
+			c.addReplyError(syntaxErr)
+			return
+		}
+	}
+
+	sinterGenericCommand(c, c.argv[2:2+numKeys], nil, true, limit)
+}
+
+const (
+	setOpUnion = iota
+	setOpDiff
+)
+
+// sunionDiffGenericCommand computes the union or difference of keys, the result is stored to dstKey
+// when dstKey is not nil.
+func sunionDiffGenericCommand(c *Client, keys [][]byte, dstKey []byte, op int) {
+	sets, ok := c.lookupSets(keys, dstKey != nil)
+	if !ok {
+		return
+	}
+
+	// 与 redis 相同，差集有两种算法:
+	// 1. 遍历第一个集合，检查每个元素是否在其他集合中，复杂度为 O(N*M)，N 为第一个集合的大小，M 为集合个数
+	// 2. 将第一个集合的元素全部加入结果，再删除其他集合中的元素，复杂度为 O(N)，N 为所有集合大小之和
+	// 算法 1 找到元素后就不再检查后面的集合，因此工作量按一半估算
+	diffAlgo := 1
+	if op == setOpDiff && sets[0] != nil {
+		algoOneWork, algoTwoWork := 0, 0
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			algoOneWork += setTypeSize(sets[0])
+			algoTwoWork += setTypeSize(set)
+		}
+		algoOneWork /= 2
+		if algoOneWork > algoTwoWork {
+			diffAlgo = 2
+		}
+
+		if diffAlgo == 1 && len(sets) > 2 {
+			// 先检查较大的集合，更可能尽早找到元素
+			others := sets[1:]
+			sort.SliceStable(others, func(i, j int) bool {
+				return setSize(others[i]) > setSize(others[j])
+			})
+		}
+	}
+
+	dst := createIntSetObject()
+	switch {
+	case op == setOpUnion:
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			setTypeForEachMember(set, func(m *setMember) bool {
+				c.setTypeAddMember(dst, m)
+				return true
+			})
+		}
+	case sets[0] == nil:
+		// 第一个集合不存在时差集为空
+	case diffAlgo == 1:
+		setTypeForEachMember(sets[0], func(m *setMember) bool {
+			for _, set := range sets[1:] {
+				if set == nil {
+					continue
+				}
+				if set == sets[0] || setTypeHasMember(set, m) {
+					return true
+				}
+			}
+			c.setTypeAddMember(dst, m)
+			return true
+		})
+	default:
+		setTypeForEachMember(sets[0], func(m *setMember) bool {
+			c.setTypeAddMember(dst, m)
+			return true
+		})
+		for _, set := range sets[1:] {
+			if set == nil {
+				continue
+			}
+			setTypeForEachMember(set, func(m *setMember) bool {
+				setTypeRemoveMember(dst, m)
+				return setTypeSize(dst) > 0
+			})
+			if setTypeSize(dst) == 0 {
+				break
+			}
+		}
+	}
+
+	if dstKey != nil {
+		c.storeSetResult(dstKey, dst)
+		return
+	}
+	c.addReplyArrayLen(setTypeSize(dst))
+	setTypeForEach(dst, func(member []byte) bool {
+		c.addReplyBulk(member)
+		return true
+	})
+}
+
+// setSize is like setTypeSize, but 0 is returned when obj is nil.
+func setSize(obj *Object) int {
+	if obj == nil {
+		return 0
+	}
+	return setTypeSize(obj)
+}
+
+// sunionCommand SUNION key [key ...]
+func sunionCommand(c *Client) {
+	sunionDiffGenericCommand(c, c.argv[1:], nil, setOpUnion)
+}
+
+// sunionstoreCommand SUNIONSTORE destination key [key ...]
+func sunionstoreCommand(c *Client) {
+	sunionDiffGenericCommand(c, c.argv[2:], c.argv[1], setOpUnion)
+}
+
+// sdiffCommand SDIFF key [key ...]
+func sdiffCommand(c *Client) {
+	sunionDiffGenericCommand(c, c.argv[1:], nil, setOpDiff)
+}
+
+// sdiffstoreCommand SDIFFSTORE destination key [key ...]
+func sdiffstoreCommand(c *Client) {
+	sunionDiffGenericCommand(c, c.argv[2:], c.argv[1], setOpDiff)
+}
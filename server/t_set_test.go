@@ -0,0 +1,372 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSetCommands(t *testing.T) {
+	for _, members := range [][]string{{"3", "1", "2"}, {"3", "1", "b"}} {
+		t.Run(strings.Join(members, " "), func(t *testing.T) {
+			c := newTestClient(nil)
+			args := append([]string{"SADD", "s"}, members...)
+			runCmdCases(t, c, []cmdCase{
+				{args: args, want: ":3\r\n"},
+				{args: []string{"SADD", "s", members[0], "-5"}, want: ":1\r\n"},
+				{args: []string{"SCARD", "s"}, want: ":4\r\n"},
+				{args: []string{"SCARD", "none"}, want: ":0\r\n"},
+				{args: []string{"SISMEMBER", "s", members[2]}, want: ":1\r\n"},
+				{args: []string{"SISMEMBER", "s", "x"}, want: ":0\r\n"},
+				{args: []string{"SISMEMBER", "none", "1"}, want: ":0\r\n"},
+				{args: []string{"SMISMEMBER", "s", "1", "x", "-5"}, want: "*3\r\n:1\r\n:0\r\n:1\r\n"},
+				{args: []string{"SMISMEMBER", "none", "1"}, want: "*1\r\n:0\r\n"},
+				{args: []string{"SMEMBERS", "none"}, want: "*0\r\n"},
+				{args: []string{"SREM", "s", "1", "x", "1"}, want: ":1\r\n"},
+				{args: []string{"SREM", "none", "1"}, want: ":0\r\n"},
+			})
+
+			want := []string{"-5", "3", members[2]}
+			sort.Strings(want)
+			if got := sortedReply(t, c.exec("SMEMBERS", "s")); strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Fatalf("SMEMBERS = %v, want %v", got, want)
+			}
+
+			// 删除所有元素后 key 被删除
+			runCmdCases(t, c, []cmdCase{
+				{args: append([]string{"SREM", "s"}, want...), want: ":3\r\n"},
+			})
+			if c.db.lookupKeyRead([]byte("s")) != nil {
+				t.Fatalf("empty set should be deleted")
+			}
+		})
+	}
+}
+
+func TestSetConversion(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("CONFIG", "SET", "set-max-intset-entries", "4")
+
+	encoding := func(key string) uint8 {
+		return c.db.lookupKeyRead([]byte(key)).Encoding
+	}
+
+	c.exec("SADD", "s", "1", "2", "3", "4", "4")
+	if encoding("s") != EncodingIntSet {
+		t.Fatalf("set with 4 integers should be encoded as intset")
+	}
+	c.exec("SADD", "s", "5")
+	if encoding("s") != EncodingHashTable {
+		t.Fatalf("set with 5 integers should be converted to hashtable")
+	}
+
+	c.exec("SADD", "str", "1")
+	if encoding("str") != EncodingIntSet {
+		t.Fatalf("set with integers should be encoded as intset")
+	}
+	// 非规范形式的整数不能存储在 intset 中
+	c.exec("SADD", "str", "01")
+	if encoding("str") != EncodingHashTable {
+		t.Fatalf("set with non integer should be converted to hashtable")
+	}
+	c.exec("SADD", "new", "a")
+	if encoding("new") != EncodingHashTable {
+		t.Fatalf("set created with non integer should be encoded as hashtable")
+	}
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"SISMEMBER", "s", "5"}, want: ":1\r\n"},
+		{args: []string{"SISMEMBER", "str", "01"}, want: ":1\r\n"},
+		{args: []string{"SISMEMBER", "str", "1"}, want: ":1\r\n"},
+		{args: []string{"SCARD", "s"}, want: ":5\r\n"},
+		{args: []string{"CONFIG", "GET", "set-max-intset-entries"}, want: arrayReply("set-max-intset-entries", "4")},
+	})
+}
+
+func TestSpop(t *testing.T) {
+	for _, member := range []string{"0", "a"} {
+		t.Run("encoding of "+member, func(t *testing.T) {
+			c := newTestClient(nil)
+			members := map[string]bool{member: true}
+			c.exec("SADD", "s", member)
+			for i := 1; i < 10; i++ {
+				c.exec("SADD", "s", strconv.Itoa(i))
+				members[strconv.Itoa(i)] = true
+			}
+
+			runCmdCases(t, c, []cmdCase{
+				{args: []string{"SPOP", "none"}, want: "$-1\r\n"},
+				{args: []string{"SPOP", "none", "2"}, want: "*0\r\n"},
+				{args: []string{"SPOP", "s", "-1"}, want: "-ERR value is out of range, must be positive\r\n"},
+				{args: []string{"SPOP", "s", "a"}, want: "-ERR value is not an integer or out of range\r\n"},
+				{args: []string{"SPOP", "s", "1", "2"}, want: "-ERR syntax error\r\n"},
+				{args: []string{"SPOP", "s", "0"}, want: "*0\r\n"},
+			})
+
+			popped := parseReply(t, c.exec("SPOP", "s"))
+			popped = append(popped, parseReply(t, c.exec("SPOP", "s", "4"))...)
+			if len(popped) != 5 {
+				t.Fatalf("popped %v, want 5 members", popped)
+			}
+			popped = append(popped, parseReply(t, c.exec("SPOP", "s", "10"))...)
+			if len(popped) != 10 {
+				t.Fatalf("popped %v, want 10 members", popped)
+			}
+			for _, m := range popped {
+				if !members[m] {
+					t.Fatalf("popped %s which is not a member or popped twice", m)
+				}
+				members[m] = false
+			}
+			if c.db.lookupKeyRead([]byte("s")) != nil {
+				t.Fatalf("empty set should be deleted")
+			}
+		})
+	}
+}
+
+func TestSrandmember(t *testing.T) {
+	for _, member := range []string{"0", "a"} {
+		t.Run("encoding of "+member, func(t *testing.T) {
+			c := newTestClient(nil)
+			members := map[string]bool{member: true}
+			c.exec("SADD", "s", member)
+			for i := 1; i < 20; i++ {
+				c.exec("SADD", "s", strconv.Itoa(i))
+				members[strconv.Itoa(i)] = true
+			}
+
+			runCmdCases(t, c, []cmdCase{
+				{args: []string{"SRANDMEMBER", "none"}, want: "$-1\r\n"},
+				{args: []string{"SRANDMEMBER", "none", "3"}, want: "*0\r\n"},
+				{args: []string{"SRANDMEMBER", "s", "0"}, want: "*0\r\n"},
+				{args: []string{"SRANDMEMBER", "s", "-9223372036854775808"}, want: "-ERR value is out of range\r\n"},
+				{args: []string{"SCARD", "s"}, want: ":20\r\n"},
+			})
+
+			if m := parseReply(t, c.exec("SRANDMEMBER", "s")); len(m) != 1 || !members[m[0]] {
+				t.Fatalf("SRANDMEMBER = %v", m)
+			}
+
+			for _, tt := range []struct {
+				count  string
+				want   int
+				unique bool
+			}{
+				{count: "3", want: 3, unique: true},
+				{count: "15", want: 15, unique: true},
+				{count: "30", want: 20, unique: true},
+				{count: "-30", want: 30},
+			} {
+				got := parseReply(t, c.exec("SRANDMEMBER", "s", tt.count))
+				if len(got) != tt.want {
+					t.Fatalf("SRANDMEMBER %s returned %d members, want %d", tt.count, len(got), tt.want)
+				}
+				seen := map[string]bool{}
+				for _, m := range got {
+					if !members[m] {
+						t.Fatalf("SRANDMEMBER %s returned unknown member %s", tt.count, m)
+					}
+					if tt.unique && seen[m] {
+						t.Fatalf("SRANDMEMBER %s returned duplicate member %s", tt.count, m)
+					}
+					seen[m] = true
+				}
+			}
+		})
+	}
+}
+
+func TestSmove(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("SADD", "src", "1", "2")
+	c.exec("RPUSH", "l", "a")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"SMOVE", "none", "dst", "1"}, want: ":0\r\n"},
+		{args: []string{"SMOVE", "src", "dst", "3"}, want: ":0\r\n"},
+		{args: []string{"SMOVE", "src", "l", "1"}, want: "-" + wrongTypeErr + "\r\n"},
+		{args: []string{"SMOVE", "l", "src", "a"}, want: "-" + wrongTypeErr + "\r\n"},
+		{args: []string{"SMOVE", "src", "src", "1"}, want: ":1\r\n"},
+		{args: []string{"SMOVE", "src", "src", "3"}, want: ":0\r\n"},
+		{args: []string{"SMOVE", "src", "dst", "1"}, want: ":1\r\n"},
+		{args: []string{"SMEMBERS", "src"}, want: arrayReply("2")},
+		{args: []string{"SMEMBERS", "dst"}, want: arrayReply("1")},
+		{args: []string{"SADD", "str", "a"}, want: ":1\r\n"},
+		{args: []string{"SMOVE", "src", "str", "2"}, want: ":1\r\n"},
+		{args: []string{"SCARD", "src"}, want: ":0\r\n"},
+		{args: []string{"SISMEMBER", "str", "2"}, want: ":1\r\n"},
+		// 移动的元素已经在 destination 中
+		{args: []string{"SADD", "src", "2"}, want: ":1\r\n"},
+		{args: []string{"SMOVE", "src", "str", "2"}, want: ":1\r\n"},
+		{args: []string{"SCARD", "str"}, want: ":2\r\n"},
+	})
+	if c.db.lookupKeyRead([]byte("src")) != nil {
+		t.Fatalf("empty set should be deleted")
+	}
+}
+
+func TestSscan(t *testing.T) {
+	for _, member := range []string{"0", "a"} {
+		t.Run("encoding of "+member, func(t *testing.T) {
+			c := newTestClient(nil)
+			c.exec("SADD", "s", member)
+			for i := 1; i < 100; i++ {
+				c.exec("SADD", "s", strconv.Itoa(i))
+			}
+
+			// 遍历直到 cursor 为 0，返回所有元素
+			scanAll := func(opts ...string) map[string]bool {
+				got := map[string]bool{}
+				cursor := "0"
+				for {
+					elems := parseReply(t, c.exec(append([]string{"SSCAN", "s", cursor}, opts...)...))
+					cursor = elems[0]
+					for _, m := range elems[1:] {
+						got[m] = true
+					}
+					if cursor == "0" {
+						return got
+					}
+				}
+			}
+
+			if all := scanAll("COUNT", "7"); len(all) != 100 {
+				t.Fatalf("SSCAN returned %d members, want 100", len(all))
+			}
+			if matched := scanAll("MATCH", "1*"); len(matched) != 11 {
+				t.Fatalf("SSCAN MATCH 1* returned %v, want 11 members", matched)
+			}
+		})
+	}
+
+	c := newTestClient(nil)
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"SSCAN", "none", "0"}, want: "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{args: []string{"SADD", "s", "1"}, want: ":1\r\n"},
+		{args: []string{"SSCAN", "s", "0", "COUNT", "0"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"SSCAN", "s", "0", "MATCH", "2"}, want: "*2\r\n$1\r\n0\r\n*0\r\n"},
+		{args: []string{"SSCAN", "s", "0"}, want: "*2\r\n$1\r\n0\r\n" + arrayReply("1")},
+	})
+}
+
+func TestSetWrongType(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("RPUSH", "l", "a")
+
+	for _, args := range [][]string{
+		{"SADD", "l", "a"},
+		{"SREM", "l", "a"},
+		{"SISMEMBER", "l", "a"},
+		{"SMISMEMBER", "l", "a"},
+		{"SMEMBERS", "l"},
+		{"SCARD", "l"},
+		{"SPOP", "l"},
+		{"SPOP", "l", "1"},
+		{"SRANDMEMBER", "l"},
+		{"SRANDMEMBER", "l", "1"},
+		{"SSCAN", "l", "0"},
+	} {
+		if got := c.exec(args...); got != "-"+wrongTypeErr+"\r\n" {
+			t.Fatalf("%v = %q, want WRONGTYPE error", args, got)
+		}
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("CONFIG", "SET", "set-max-intset-entries", "5")
+	c.exec("SADD", "ints", "1", "2", "3", "4")
+	c.exec("SADD", "mixed", "3", "4", "5", "a")
+	c.exec("SADD", "big", "1", "2", "3", "4", "5", "6", "7", "8")
+	c.exec("SADD", "strs", "a", "b", "c")
+	c.exec("SADD", "one", "1")
+	c.exec("SADD", "x", "x")
+	c.exec("RPUSH", "l", "a")
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{args: []string{"SINTER", "ints", "mixed"}, want: []string{"3", "4"}},
+		{args: []string{"SINTER", "big", "ints", "mixed"}, want: []string{"3", "4"}},
+		{args: []string{"SINTER", "ints", "ints"}, want: []string{"1", "2", "3", "4"}},
+		{args: []string{"SINTER", "mixed", "strs"}, want: []string{"a"}},
+		{args: []string{"SINTER", "ints", "none"}, want: nil},
+		{args: []string{"SINTER", "ints", "strs"}, want: nil},
+		{args: []string{"SUNION", "ints", "mixed", "none"}, want: []string{"1", "2", "3", "4", "5", "a"}},
+		{args: []string{"SUNION", "none"}, want: nil},
+		{args: []string{"SDIFF", "big", "ints", "mixed"}, want: []string{"6", "7", "8"}},
+		{args: []string{"SDIFF", "ints", "none", "big"}, want: nil},
+		{args: []string{"SDIFF", "mixed", "ints"}, want: []string{"5", "a"}},
+		{args: []string{"SDIFF", "ints", "ints"}, want: nil},
+		{args: []string{"SDIFF", "none", "ints"}, want: nil},
+		// 第一个集合远大于其他集合，使用第二种算法
+		{args: []string{"SDIFF", "big", "one", "x"}, want: []string{"2", "3", "4", "5", "6", "7", "8"}},
+		{args: []string{"SDIFF", "big", "x", "big"}, want: nil},
+	}
+	for _, tt := range tests {
+		got := sortedReply(t, c.exec(tt.args...))
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Fatalf("%v = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	for _, cmd := range []string{"SINTER", "SUNION", "SDIFF", "SINTERSTORE", "SUNIONSTORE", "SDIFFSTORE"} {
+		if got := c.exec(cmd, "ints", "l"); got != "-"+wrongTypeErr+"\r\n" {
+			t.Fatalf("%s with list = %q, want WRONGTYPE error", cmd, got)
+		}
+	}
+}
+
+func TestSetAlgebraStore(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("SADD", "a", "1", "2", "3")
+	c.exec("SADD", "b", "3", "4", "x")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"SINTERSTORE", "dst", "a", "b"}, want: ":1\r\n"},
+		{args: []string{"SMEMBERS", "dst"}, want: arrayReply("3")},
+		{args: []string{"SDIFFSTORE", "dst", "a", "b"}, want: ":2\r\n"},
+		{args: []string{"SCARD", "dst"}, want: ":2\r\n"},
+		{args: []string{"SUNIONSTORE", "dst", "a", "b"}, want: ":5\r\n"},
+		{args: []string{"SCARD", "dst"}, want: ":5\r\n"},
+		// 结果为空时删除 destination
+		{args: []string{"SINTERSTORE", "dst", "a", "none"}, want: ":0\r\n"},
+		{args: []string{"SCARD", "dst"}, want: ":0\r\n"},
+		{args: []string{"RPUSH", "l", "a"}, want: ":1\r\n"},
+		{args: []string{"SDIFFSTORE", "l", "a", "a"}, want: ":0\r\n"},
+		{args: []string{"LLEN", "l"}, want: ":0\r\n"},
+		// destination 可以是 source 之一，并覆盖其他类型的值
+		{args: []string{"RPUSH", "l", "a"}, want: ":1\r\n"},
+		{args: []string{"SUNIONSTORE", "l", "a"}, want: ":3\r\n"},
+		{args: []string{"SUNIONSTORE", "a", "a", "b"}, want: ":5\r\n"},
+		{args: []string{"SCARD", "a"}, want: ":5\r\n"},
+		{args: []string{"SCARD", "l"}, want: ":3\r\n"},
+	})
+	if encoding := c.db.lookupKeyRead([]byte("l")).Encoding; encoding != EncodingIntSet {
+		t.Fatalf("union of integers should be encoded as intset, got %d", encoding)
+	}
+}
+
+func TestSintercard(t *testing.T) {
+	c := newTestClient(nil)
+	c.exec("SADD", "a", "1", "2", "3", "4", "x")
+	c.exec("SADD", "b", "2", "3", "4", "5", "x")
+
+	runCmdCases(t, c, []cmdCase{
+		{args: []string{"SINTERCARD", "2", "a", "b"}, want: ":4\r\n"},
+		{args: []string{"SINTERCARD", "1", "a", "b"}, want: "-ERR syntax error\r\n"},
+		{args: []string{"SINTERCARD", "2", "a", "b", "LIMIT", "2"}, want: ":2\r\n"},
+		{args: []string{"SINTERCARD", "2", "a", "b", "LIMIT", "0"}, want: ":4\r\n"},
+		{args: []string{"SINTERCARD", "2", "a", "b", "LIMIT", "10"}, want: ":4\r\n"},
+		{args: []string{"SINTERCARD", "2", "a", "none"}, want: ":0\r\n"},
+		{args: []string{"SINTERCARD", "1", "a"}, want: ":5\r\n"},
+		{args: []string{"SINTERCARD", "0", "a"}, want: "-ERR numkeys should be greater than 0\r\n"},
+		{args: []string{"SINTERCARD", "a", "a"}, want: "-ERR numkeys should be greater than 0\r\n"},
+		{args: []string{"SINTERCARD", "3", "a", "b"}, want: "-ERR Number of keys can't be greater than number of args\r\n"},
+		{args: []string{"SINTERCARD", "2", "a", "b", "LIMIT", "-1"}, want: "-ERR LIMIT can't be negative\r\n"},
+		{args: []string{"SINTERCARD", "2", "a", "b", "LIMIT"}, want: "-ERR syntax error\r\n"},
+	})
+}
@@ -0,0 +1,20 @@
+package crc64
+
+import "hash/crc64"
+
+// crc64 是 redis 使用的 CRC-64/Jones 校验算法：多项式为 0xad93d23594c935a9(反射形式为 0x95ac9329ac4bc9b5)，
+// 输入输出反射，初始值为 0，结果不取反。标准库的 crc64 在计算前后对 crc 取反，这里抵消掉这两次取反。
+
+const jonesPoly = 0x95ac9329ac4bc9b5
+
+var table = crc64.MakeTable(jonesPoly)
+
+// Update returns the result of adding p to crc.
+func Update(crc uint64, p []byte) uint64 {
+	return ^crc64.Update(^crc, table, p)
+}
+
+// Checksum returns the checksum of p.
+func Checksum(p []byte) uint64 {
+	return Update(0, p)
+}
@@ -0,0 +1,34 @@
+package crc64
+
+import "testing"
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint64
+	}{
+		// CRC-64/Jones 的标准校验值，与 redis crc64.c 的测试相同
+		{"123456789", 0xe9c6d914c4b8d9ca},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := Checksum([]byte(tt.input)); got != tt.want {
+			t.Fatalf("Checksum(%q) = %#x, want %#x", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	data := []byte("This is a test of the emergency broadcast system.")
+	crc := uint64(0)
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		crc = Update(crc, data[i:end])
+	}
+	if want := Checksum(data); crc != want {
+		t.Fatalf("Update in pieces = %#x, want %#x", crc, want)
+	}
+}
@@ -1,2 +1,150 @@
 package utils
 
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// GoPool 是由固定数量的 worker 组成的协程池，提交的任务放入有界的队列，由 worker 按提交的顺序取出执行。
+// 队列满时 Submit 阻塞等待，TrySubmit 直接返回 ErrQueueFull，由调用方决定是否降级处理，避免任务无限堆积。
+// 任务 panic 时由 worker recover，不会影响其他任务。Close 之后不再接收新的任务，已经提交的任务全部执行完才返回。
+
+var (
+	ErrPoolClosed = errors.New("gopool: pool is closed")
+	ErrQueueFull  = errors.New("gopool: queue is full")
+)
+
+type GoPool struct {
+	tasks chan func()
+	wg    sync.WaitGroup
+
+	// mu 保证 Close 关闭 tasks 之后没有 Submit 向其中发送任务
+	mu     sync.RWMutex
+	closed bool
+
+	// panicHandler 在任务 panic 时被调用，参数为 recover 的返回值
+	panicHandler func(r interface{})
+
+	queued    int64
+	running   int64
+	completed int64
+	panicked  int64
+}
+
+// GoPoolStats 是协程池的统计信息，Completed 包括 panic 的任务
+type GoPoolStats struct {
+	Queued    int64
+	Running   int64
+	Completed int64
+	Panicked  int64
+}
+
+// NewGoPool creates a pool with workers goroutines and a queue holding at most queueSize tasks.
+func NewGoPool(workers, queueSize int) *GoPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &GoPool{
+		tasks: make(chan func(), queueSize),
+		panicHandler: func(r interface{}) {
+			log.Printf("gopool: task panics: %v\n%s", r, debug.Stack())
+		},
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// SetPanicHandler sets the function called with the recovered value when a task panics, it must be
+// called before any task is submitted.
+func (p *GoPool) SetPanicHandler(handler func(r interface{})) {
+	p.panicHandler = handler
+}
+
+func (p *GoPool) worker() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		atomic.AddInt64(&p.queued, -1)
+		p.run(task)
+	}
+}
+
+func (p *GoPool) run(task func()) {
+	atomic.AddInt64(&p.running, 1)
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddInt64(&p.panicked, 1)
+			p.panicHandler(r)
+		}
+		atomic.AddInt64(&p.running, -1)
+		atomic.AddInt64(&p.completed, 1)
+	}()
+
+	task()
+}
+
+// Submit adds task to the queue, it blocks when the queue is full. ErrPoolClosed is returned after
+// Close is called.
+func (p *GoPool) Submit(task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+	// 先增加计数，避免 worker 取出任务时 queued 变为负数
+	atomic.AddInt64(&p.queued, 1)
+	p.tasks <- task
+	return nil
+}
+
+// TrySubmit is like Submit, but ErrQueueFull is returned instead of blocking when the queue is full.
+func (p *GoPool) TrySubmit(task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+	atomic.AddInt64(&p.queued, 1)
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		atomic.AddInt64(&p.queued, -1)
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting tasks and waits until all submitted tasks are done, it's safe to call Close
+// more than once.
+func (p *GoPool) Close() {
+	// Submit 阻塞时持有读锁，worker 会继续执行任务使其返回，因此这里不会一直等待
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Stats returns the statistics of p.
+func (p *GoPool) Stats() GoPoolStats {
+	return GoPoolStats{
+		Queued:    atomic.LoadInt64(&p.queued),
+		Running:   atomic.LoadInt64(&p.running),
+		Completed: atomic.LoadInt64(&p.completed),
+		Panicked:  atomic.LoadInt64(&p.panicked),
+	}
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGoPool(t *testing.T) {
+	p := NewGoPool(4, 8)
+	var done int64
+	for i := 0; i < 100; i++ {
+		p.Submit(func() {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&done, 1)
+		})
+	}
+	p.Close()
+
+	if done != 100 {
+		t.Fatalf("%d tasks are done after Close, want 100", done)
+	}
+}
+
+func TestGoPool_Order(t *testing.T) {
+	// 只有一个 worker 时按提交的顺序执行
+	p := NewGoPool(1, 16)
+	var got []int
+	for i := 0; i < 10; i++ {
+		i := i
+		p.Submit(func() {
+			got = append(got, i)
+		})
+	}
+	p.Close()
+
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("tasks are executed in order %v", got)
+		}
+	}
+	if len(got) != 10 {
+		t.Fatalf("%d tasks are executed, want 10", len(got))
+	}
+}
+
+func TestGoPool_SubmitBlocks(t *testing.T) {
+	p := NewGoPool(1, 1)
+	release := make(chan struct{})
+	p.Submit(func() { <-release })
+	// 等待 worker 取走第一个任务，之后队列中还能放一个任务
+	time.Sleep(10 * time.Millisecond)
+	p.Submit(func() {})
+
+	submitted := make(chan struct{})
+	go func() {
+		p.Submit(func() {})
+		close(submitted)
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatalf("Submit should block when the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-submitted
+	p.Close()
+}
+
+func TestGoPool_TrySubmit(t *testing.T) {
+	p := NewGoPool(1, 1)
+	release := make(chan struct{})
+	if err := p.TrySubmit(func() { <-release }); err != nil {
+		t.Fatalf("TrySubmit() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := p.TrySubmit(func() {}); err != nil {
+		t.Fatalf("TrySubmit() error = %v", err)
+	}
+	if err := p.TrySubmit(func() {}); err != ErrQueueFull {
+		t.Fatalf("TrySubmit() error = %v, want ErrQueueFull", err)
+	}
+
+	want := GoPoolStats{Queued: 1, Running: 1}
+	if stats := p.Stats(); stats != want {
+		t.Fatalf("Stats() = %+v, want %+v", stats, want)
+	}
+
+	close(release)
+	p.Close()
+	want = GoPoolStats{Completed: 2}
+	if stats := p.Stats(); stats != want {
+		t.Fatalf("Stats() = %+v after Close, want %+v", stats, want)
+	}
+}
+
+func TestGoPool_Panic(t *testing.T) {
+	p := NewGoPool(1, 4)
+	var recovered []interface{}
+	p.SetPanicHandler(func(r interface{}) {
+		recovered = append(recovered, r)
+	})
+
+	var done int64
+	_ = p.Submit(func() { panic("boom") })
+	_ = p.Submit(func() { atomic.AddInt64(&done, 1) })
+	_ = p.Submit(func() { panic("boom again") })
+	_ = p.Submit(func() { atomic.AddInt64(&done, 1) })
+	p.Close()
+
+	// panic 之后 worker 继续执行其他任务
+	if done != 2 || len(recovered) != 2 || recovered[0] != "boom" {
+		t.Fatalf("done = %d, recovered = %v", done, recovered)
+	}
+	want := GoPoolStats{Completed: 4, Panicked: 2}
+	if stats := p.Stats(); stats != want {
+		t.Fatalf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestGoPool_Close(t *testing.T) {
+	p := NewGoPool(2, 100)
+	var done int64
+	for i := 0; i < 100; i++ {
+		_ = p.Submit(func() {
+			time.Sleep(100 * time.Microsecond)
+			atomic.AddInt64(&done, 1)
+		})
+	}
+	// Close 等待队列中的任务全部执行完
+	p.Close()
+	if done != 100 {
+		t.Fatalf("%d tasks are done after Close, want 100", done)
+	}
+
+	if err := p.Submit(func() {}); err != ErrPoolClosed {
+		t.Fatalf("Submit() error = %v after Close, want ErrPoolClosed", err)
+	}
+	if err := p.TrySubmit(func() {}); err != ErrPoolClosed {
+		t.Fatalf("TrySubmit() error = %v after Close, want ErrPoolClosed", err)
+	}
+	p.Close()
+}
+
+func TestGoPool_ConcurrentSubmitAndClose(t *testing.T) {
+	p := NewGoPool(4, 2)
+	var submitted, done int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				err := p.Submit(func() { atomic.AddInt64(&done, 1) })
+				if err == ErrPoolClosed {
+					return
+				}
+				atomic.AddInt64(&submitted, 1)
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond)
+	p.Close()
+	wg.Wait()
+
+	// 提交成功的任务都被执行
+	if done != submitted {
+		t.Fatalf("%d tasks are done, but %d tasks are submitted", done, submitted)
+	}
+	if stats := p.Stats(); stats.Queued != 0 || stats.Running != 0 || stats.Completed != submitted {
+		t.Fatalf("Stats() = %+v, %d tasks are submitted", stats, submitted)
+	}
+}
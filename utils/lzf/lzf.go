@@ -0,0 +1,230 @@
+package lzf
+
+import "errors"
+
+// lzf 是 liblzf 压缩算法的实现，压缩后的数据与 liblzf(以及 redis)兼容。
+// 压缩后的数据由两种指令组成：
+// 000LLLLL <L+1 个字节>           : 字面量，长度为 1~32
+// LLLooooo oooooooo               : 回溯引用，复制距离当前位置 o+1 处的 L+2 个字节
+// 111ooooo LLLLLLLL oooooooo      : 同上，长度为 L+9
+
+const (
+	hashLog  = 16
+	hashSize = 1 << hashLog
+
+	maxLit = 1 << 5
+	maxOff = 1 << 13
+	maxRef = (1 << 8) + (1 << 3)
+)
+
+var (
+	OutputTooSmallErr = errors.New("output buffer is too small")
+	CorruptedDataErr  = errors.New("compressed data is corrupted")
+)
+
+func first(in []byte, ip int) uint32 {
+	return uint32(in[ip])<<8 | uint32(in[ip+1])
+}
+
+func next(hval uint32, in []byte, ip int) uint32 {
+	return hval<<8 | uint32(in[ip+2])
+}
+
+func index(hval uint32) uint32 {
+	return ((hval >> (3*8 - hashLog)) - hval*5) & (hashSize - 1)
+}
+
+// Compress compresses in into out, the number of bytes written is returned. 0 is returned when in is
+// empty or the compressed data doesn't fit in out, which means the data is not compressible when
+// len(out) is less than len(in).
+func Compress(in []byte, out []byte) int {
+	if len(in) == 0 {
+		return 0
+	}
+
+	// htab 中记录的是位置 + 1，0 表示没有记录
+	htab := make([]int, hashSize)
+	inEnd, outEnd := len(in), len(out)
+	ip, op := 0, 0
+	lit := 0
+	// 为第一个字面量的长度预留一个字节
+	op++
+
+	// stopRun 写入当前字面量的长度，没有字面量时撤销预留的字节
+	stopRun := func() {
+		if lit == 0 {
+			op--
+			return
+		}
+		out[op-lit-1] = byte(lit - 1)
+	}
+
+	var hval uint32
+	if inEnd > 2 {
+		hval = first(in, ip)
+	}
+	for ip < inEnd-2 {
+		hval = next(hval, in, ip)
+		slot := index(hval)
+		ref := htab[slot] - 1
+		htab[slot] = ip + 1
+
+		off := ip - ref - 1
+		if ref > 0 && off < maxOff &&
+			in[ref] == in[ip] && in[ref+1] == in[ip+1] && in[ref+2] == in[ip+2] {
+			length := 2
+			maxLen := inEnd - ip - length
+			if maxLen > maxRef {
+				maxLen = maxRef
+			}
+
+			// 回溯引用最多 3 个字节，之后还要预留一个字节
+			if op-boolToInt(lit == 0)+3+1 >= outEnd {
+				return 0
+			}
+
+			stopRun()
+
+			for {
+				length++
+				if length >= maxLen || in[ref+length] != in[ip+length] {
+					break
+				}
+			}
+
+			// 此时 length 为匹配的字节数 - 1
+			length -= 2
+			ip++
+
+			if length < 7 {
+				out[op] = byte(off>>8) + byte(length<<5)
+				op++
+			} else {
+				out[op] = byte(off>>8) + 7<<5
+				out[op+1] = byte(length - 7)
+				op += 2
+			}
+			out[op] = byte(off)
+			op++
+
+			lit = 0
+			op++
+
+			ip += length + 1
+			if ip >= inEnd-2 {
+				break
+			}
+
+			// 记录匹配结尾处的两个位置，提高后续匹配的概率
+			ip -= 2
+			hval = first(in, ip)
+			hval = next(hval, in, ip)
+			htab[index(hval)] = ip + 1
+			ip++
+			hval = next(hval, in, ip)
+			htab[index(hval)] = ip + 1
+			ip++
+		} else {
+			if op >= outEnd {
+				return 0
+			}
+
+			out[op] = in[ip]
+			lit++
+			op++
+			ip++
+
+			if lit == maxLit {
+				out[op-lit-1] = byte(lit - 1)
+				lit = 0
+				op++
+			}
+		}
+	}
+
+	// 最后最多还有 3 个字节
+	if op+3 > outEnd {
+		return 0
+	}
+
+	for ip < inEnd {
+		out[op] = in[ip]
+		lit++
+		op++
+		ip++
+
+		if lit == maxLit {
+			out[op-lit-1] = byte(lit - 1)
+			lit = 0
+			op++
+		}
+	}
+
+	stopRun()
+	return op
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Decompress decompresses in into out, the number of bytes written is returned.
+// OutputTooSmallErr is returned when out is not large enough.
+func Decompress(in []byte, out []byte) (int, error) {
+	ip, op := 0, 0
+	for ip < len(in) {
+		ctrl := int(in[ip])
+		ip++
+
+		if ctrl < 1<<5 {
+			// 字面量
+			length := ctrl + 1
+			if op+length > len(out) {
+				return 0, OutputTooSmallErr
+			}
+			if ip+length > len(in) {
+				return 0, CorruptedDataErr
+			}
+
+			copy(out[op:], in[ip:ip+length])
+			op += length
+			ip += length
+			continue
+		}
+
+		// 回溯引用
+		length := ctrl >> 5
+		if length == 7 {
+			if ip >= len(in) {
+				return 0, CorruptedDataErr
+			}
+			length += int(in[ip])
+			ip++
+		}
+		if ip >= len(in) {
+			return 0, CorruptedDataErr
+		}
+		ref := op - (ctrl&0x1f)<<8 - 1 - int(in[ip])
+		ip++
+		length += 2
+
+		if op+length > len(out) {
+			return 0, OutputTooSmallErr
+		}
+		if ref < 0 {
+			return 0, CorruptedDataErr
+		}
+
+		// 引用的区间可能与输出的区间重叠，只能逐字节复制
+		for i := 0; i < length; i++ {
+			out[op] = out[ref]
+			op++
+			ref++
+		}
+	}
+
+	return op, nil
+}
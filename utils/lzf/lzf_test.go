@@ -0,0 +1,115 @@
+package lzf
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		outLen  int
+		want    []byte
+		wantErr error
+	}{
+		{name: "literal", in: []byte{0x02, 'a', 'b', 'c'}, outLen: 3, want: []byte("abc")},
+		{name: "back reference", in: []byte{0x02, 'a', 'b', 'c', 0x20, 0x02}, outLen: 6, want: []byte("abcabc")},
+		// 引用的区间与输出重叠
+		{name: "overlapped reference", in: []byte{0x00, 'a', 0xe0, 0x01, 0x00}, outLen: 11, want: []byte("aaaaaaaaaaa")},
+		{name: "output too small", in: []byte{0x02, 'a', 'b', 'c'}, outLen: 2, wantErr: OutputTooSmallErr},
+		{name: "truncated literal", in: []byte{0x02, 'a', 'b'}, outLen: 3, wantErr: CorruptedDataErr},
+		{name: "truncated reference", in: []byte{0x00, 'a', 0x20}, outLen: 4, wantErr: CorruptedDataErr},
+		{name: "reference out of range", in: []byte{0x00, 'a', 0x20, 0x01}, outLen: 4, wantErr: CorruptedDataErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := make([]byte, tt.outLen)
+			n, err := Decompress(tt.in, out)
+			if err != tt.wantErr {
+				t.Fatalf("Decompress() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(out[:n], tt.want) {
+				t.Fatalf("Decompress() = %q, want %q", out[:n], tt.want)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.Read(random)
+
+	tests := []struct {
+		name string
+		in   []byte
+		// compressible 表示压缩后的数据比原始数据小
+		compressible bool
+	}{
+		{name: "one byte", in: []byte("a")},
+		{name: "short", in: []byte("abcd")},
+		{name: "repeated byte", in: bytes.Repeat([]byte("a"), 1000), compressible: true},
+		{name: "repeated pattern", in: bytes.Repeat([]byte("hello world "), 1000), compressible: true},
+		{name: "long literal", in: random[:100]},
+		{name: "random", in: random},
+		{name: "mixed", in: append(append([]byte{}, random[:500]...), bytes.Repeat(random[:300], 20)...), compressible: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 输出足够大时一定能压缩成功
+			out := make([]byte, len(tt.in)*2+16)
+			n := Compress(tt.in, out)
+			if n == 0 {
+				t.Fatalf("Compress() failed")
+			}
+			if tt.compressible && n >= len(tt.in)/2 {
+				t.Fatalf("Compress() = %d bytes, input is %d bytes", n, len(tt.in))
+			}
+
+			got := make([]byte, len(tt.in))
+			m, err := Decompress(out[:n], got)
+			if err != nil || !bytes.Equal(got[:m], tt.in) {
+				t.Fatalf("Decompress() = %q, %v, want %q", got[:m], err, tt.in)
+			}
+
+			// 输出不够大时返回 0
+			if n > 1 && Compress(tt.in, make([]byte, n-1)) != 0 {
+				t.Fatalf("Compress() should fail when output is too small")
+			}
+		})
+	}
+}
+
+func TestCompressRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		// 由少量字符组成的数据，包含各种长度的匹配
+		in := make([]byte, r.Intn(20000))
+		alphabet := r.Intn(16) + 1
+		for j := range in {
+			in[j] = byte('a' + r.Intn(alphabet))
+		}
+
+		out := make([]byte, r.Intn(len(in)+64)+1)
+		n := Compress(in, out)
+		if n == 0 {
+			continue
+		}
+
+		got := make([]byte, len(in))
+		m, err := Decompress(out[:n], got)
+		if err != nil || !bytes.Equal(got[:m], in) {
+			t.Fatalf("round trip of %d bytes failed, err = %v", len(in), err)
+		}
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	in := bytes.Repeat([]byte("activity feed entry 1234567890 "), 256)
+	out := make([]byte, len(in))
+	b.SetBytes(int64(len(in)))
+	for i := 0; i < b.N; i++ {
+		Compress(in, out)
+	}
+}
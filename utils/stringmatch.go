@@ -0,0 +1,125 @@
+package utils
+
+// StringMatch reports whether str matches the glob-style pattern, it's the same as redis stringmatchlen:
+//   - * matches any sequence of bytes
+//   - ? matches any single byte
+//   - [abc], [^abc] and [a-z] match a byte in or not in the set
+//   - \x matches x literally
+func StringMatch(pattern, str []byte, nocase bool) bool {
+	skipLongerMatches := false
+	return stringMatch(pattern, str, nocase, &skipLongerMatches, 0)
+}
+
+// skipLongerMatches 为 true 表示 * 之后的部分在 str 的任何位置都无法匹配，
+// 这时更早的 * 匹配更长的子串也没有意义，避免指数级的回溯
+func stringMatch(pattern, str []byte, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	// 避免恶意的 pattern 导致递归过深
+	if nesting > 1000 {
+		return false
+	}
+
+	p, s := 0, 0
+	for p < len(pattern) && s < len(str) {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for ; s < len(str); s++ {
+				if stringMatch(pattern[p+1:], str[s:], nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			s++
+		case '[':
+			p++
+			not := p < len(pattern) && pattern[p] == '^'
+			if not {
+				p++
+			}
+
+			match := false
+			for {
+				if p == len(pattern) {
+					// 没有 ']' 时，回退一个字节，下面的 p++ 之后 pattern 恰好结束
+					p--
+					break
+				}
+				if pattern[p] == '\\' && len(pattern)-p >= 2 {
+					p++
+					if pattern[p] == str[s] {
+						match = true
+					}
+				} else if pattern[p] == ']' {
+					break
+				} else if len(pattern)-p >= 3 && pattern[p+1] == '-' {
+					start, end, c := pattern[p], pattern[p+2], str[s]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = toLower(start), toLower(end), toLower(c)
+					}
+					p += 2
+					if c >= start && c <= end {
+						match = true
+					}
+				} else if equalByte(pattern[p], str[s], nocase) {
+					match = true
+				}
+				p++
+			}
+
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s++
+		case '\\':
+			if len(pattern)-p >= 2 {
+				p++
+			}
+			fallthrough
+		default:
+			if !equalByte(pattern[p], str[s], nocase) {
+				return false
+			}
+			s++
+		}
+
+		p++
+		if s == len(str) {
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			break
+		}
+	}
+
+	return p == len(pattern) && s == len(str)
+}
+
+func toLower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+func equalByte(a, b byte, nocase bool) bool {
+	if nocase {
+		return toLower(a) == toLower(b)
+	}
+	return a == b
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		nocase  bool
+		want    bool
+	}{
+		// 与 redis 相同，空字符串不匹配任何非空的 pattern，调用者需要单独处理 "*"
+		{pattern: "*", str: "", want: false},
+		{pattern: "*", str: "abc", want: true},
+		{pattern: "", str: "", want: true},
+		{pattern: "", str: "a", want: false},
+		{pattern: "a*", str: "abc", want: true},
+		{pattern: "a*", str: "ba", want: false},
+		{pattern: "*c", str: "abc", want: true},
+		{pattern: "a**c", str: "ac", want: true},
+		{pattern: "a*b*c", str: "axxbyyc", want: true},
+		{pattern: "a*b*c", str: "axxbyy", want: false},
+		{pattern: "a?c", str: "abc", want: true},
+		{pattern: "a?c", str: "ac", want: false},
+		{pattern: "h[ae]llo", str: "hallo", want: true},
+		{pattern: "h[ae]llo", str: "hillo", want: false},
+		{pattern: "h[^e]llo", str: "hallo", want: true},
+		{pattern: "h[^e]llo", str: "hello", want: false},
+		{pattern: "h[a-c]llo", str: "hbllo", want: true},
+		{pattern: "h[c-a]llo", str: "hbllo", want: true},
+		{pattern: "h[a-c]llo", str: "hdllo", want: false},
+		{pattern: "h[A-C]llo", str: "hbllo", nocase: true, want: true},
+		{pattern: `h[\]]llo`, str: "h]llo", want: true},
+		{pattern: `a\*c`, str: "a*c", want: true},
+		{pattern: `a\*c`, str: "abc", want: false},
+		{pattern: `a\`, str: `a\`, want: true},
+		{pattern: "ABC", str: "abc", want: false},
+		{pattern: "ABC", str: "abc", nocase: true, want: true},
+		// 没有结束的 ']'
+		{pattern: "a[bc", str: "ab", want: true},
+		{pattern: "a[", str: "ab", want: false},
+		{pattern: "a[^", str: "ab", want: true},
+		{pattern: "abc*", str: "abc", want: true},
+		{pattern: "user:*:name", str: "user:1000:name", want: true},
+		{pattern: "user:*:name", str: "user:1000:age", want: false},
+	}
+	for _, tt := range tests {
+		if got := StringMatch([]byte(tt.pattern), []byte(tt.str), tt.nocase); got != tt.want {
+			t.Errorf("StringMatch(%q, %q, %v) = %v, want %v", tt.pattern, tt.str, tt.nocase, got, tt.want)
+		}
+	}
+}
+
+// TestStringMatch_Pathological 大量的 * 不会导致指数级的回溯
+func TestStringMatch_Pathological(t *testing.T) {
+	pattern := strings.Repeat("a*", 50) + "b"
+	str := strings.Repeat("a", 100)
+	if StringMatch([]byte(pattern), []byte(str), false) {
+		t.Fatalf("StringMatch() = true, want false")
+	}
+}
@@ -1,5 +1,10 @@
 package utils
 
+import (
+	"math"
+	"strconv"
+)
+
 const (
 	UINT8_MAX  = ^(uint8(0))
 	UINT16_MAX = ^(uint16(0))
@@ -17,3 +22,50 @@ const (
 	INT64_MAX = int64(^(uint64(1) << 63))
 	INT64_MIN = ^INT64_MAX
 )
+
+// String2Int64 converts b to int64 strictly like redis string2ll: only the canonical decimal form is
+// accepted, so "+1", "01", " 1" and "-0" are not integers.
+func String2Int64(b []byte) (int64, bool) {
+	if len(b) == 0 || len(b) > 20 {
+		return 0, false
+	}
+
+	num, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	// 只有转换回字符串后与原字符串相同才认为是整数
+	if strconv.FormatInt(num, 10) != string(b) {
+		return 0, false
+	}
+
+	return num, true
+}
+
+// Float2String formats f in the shortest form which can be parsed back to the same float64, infinity
+// is formatted as "inf" and "-inf" like redis.
+func Float2String(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// String2Float64 converts b to float64, NaN is not accepted.
+func String2Float64(b []byte) (float64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+
+	return f, true
+}
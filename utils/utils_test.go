@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestConst(t *testing.T) {
 	t.Logf("INT8_MAX: %d, INT8_MIN: %d", INT8_MAX, INT8_MIN)
@@ -9,3 +12,89 @@ func TestConst(t *testing.T) {
 	t.Logf("INT32_MAX: %d, INT32_MIN: %d", INT32_MAX, INT32_MIN)
 	t.Logf("INT64_MAX: %d, INT64_MIN: %d", INT64_MAX, INT64_MIN)
 }
+
+func TestString2Int64(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   int64
+		wantOk bool
+	}{
+		{input: "0", want: 0, wantOk: true},
+		{input: "12", want: 12, wantOk: true},
+		{input: "-12", want: -12, wantOk: true},
+		{input: "9223372036854775807", want: INT64_MAX, wantOk: true},
+		{input: "-9223372036854775808", want: INT64_MIN, wantOk: true},
+		{input: "9223372036854775808", wantOk: false},
+		{input: "", wantOk: false},
+		{input: "-0", wantOk: false},
+		{input: "+1", wantOk: false},
+		{input: "01", wantOk: false},
+		{input: " 1", wantOk: false},
+		{input: "1 ", wantOk: false},
+		{input: "1.0", wantOk: false},
+		{input: "a", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := String2Int64([]byte(tt.input))
+			if ok != tt.wantOk || (ok && got != tt.want) {
+				t.Errorf("String2Int64(%q) = %d, %v, want %d, %v", tt.input, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestFloat2String(t *testing.T) {
+	tests := []struct {
+		input float64
+		want  string
+	}{
+		{input: 0, want: "0"},
+		{input: math.Copysign(0, -1), want: "-0"},
+		{input: 1.5, want: "1.5"},
+		{input: 0.1, want: "0.1"},
+		{input: -3.25, want: "-3.25"},
+		{input: 1e21, want: "1e+21"},
+		{input: math.MaxFloat64, want: "1.7976931348623157e+308"},
+		{input: math.Inf(1), want: "inf"},
+		{input: math.Inf(-1), want: "-inf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := Float2String(tt.input)
+			if got != tt.want {
+				t.Fatalf("Float2String(%v) = %q, want %q", tt.input, got, tt.want)
+			}
+
+			f, ok := String2Float64([]byte(got))
+			if !ok || math.Float64bits(f) != math.Float64bits(tt.input) {
+				t.Errorf("String2Float64(%q) = %v, %v, want %v", got, f, ok, tt.input)
+			}
+		})
+	}
+}
+
+func TestString2Float64(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   float64
+		wantOk bool
+	}{
+		{input: "1", want: 1, wantOk: true},
+		{input: "-1.5", want: -1.5, wantOk: true},
+		{input: "1e3", want: 1000, wantOk: true},
+		{input: "+inf", want: math.Inf(1), wantOk: true},
+		{input: "", wantOk: false},
+		{input: "nan", wantOk: false},
+		{input: " 1", wantOk: false},
+		{input: "1a", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := String2Float64([]byte(tt.input))
+			if ok != tt.wantOk || (ok && got != tt.want) {
+				t.Errorf("String2Float64(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}